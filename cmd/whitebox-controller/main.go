@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -38,18 +39,47 @@ func main() {
 		return
 	}
 
+	if flag.Arg(0) == "validate" {
+		p := *configPath
+		if flag.NArg() > 1 {
+			p = flag.Arg(1)
+		}
+
+		os.Exit(validate(p))
+	}
+
+	if flag.Arg(0) == "schema" {
+		os.Exit(printSchema())
+	}
+
 	c, err := config.LoadFile(*configPath)
 	if err != nil {
 		log.Error(err, "could not load configuration file")
 		os.Exit(1)
 	}
 
+	manager.Version = VERSION
+	manager.Commit = COMMIT
+
 	kc, err := kconfig.GetConfig()
 	if err != nil {
 		log.Error(err, "could not load kubernetes configuration")
 		os.Exit(1)
 	}
 
+	if c.RunMode == config.RunModeOnce {
+		failed, err := manager.RunOnce(c, kc)
+		if err != nil {
+			log.Error(err, "could not reconcile resources")
+			os.Exit(1)
+		}
+		if failed > 0 {
+			log.Info("Some resources failed to reconcile", "failed", failed)
+			os.Exit(1)
+		}
+		return
+	}
+
 	mgr, err := manager.New(c, kc)
 	if err != nil {
 		log.Error(err, "could not create controller manager")
@@ -62,3 +92,32 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// validate validates the configuration file and prints all errors.
+// It returns the exit code of the command.
+func validate(p string) int {
+	errs := config.ValidateConfigFile(p)
+	if len(errs) == 0 {
+		fmt.Printf("%s: ok\n", p)
+		return 0
+	}
+
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", p, err)
+	}
+
+	return 1
+}
+
+// printSchema prints the JSON Schema of the configuration file.
+// It returns the exit code of the command.
+func printSchema() int {
+	buf, err := json.MarshalIndent((&config.Config{}).JSONSchema(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate schema: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(string(buf))
+	return 0
+}
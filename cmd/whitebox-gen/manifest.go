@@ -64,7 +64,7 @@ func manifest(args []string) error {
 		return err
 	}
 
-	for _, res := range c.Resources {
+	for _, res := range c.EnabledResources() {
 		if res.Validator != nil {
 			o.ValidationWebhook = true
 		}
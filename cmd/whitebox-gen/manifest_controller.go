@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"strings"
 	"text/template"
+
+	"github.com/summerwind/whitebox-controller/config"
 )
 
 func genController(o *Option) (string, error) {
 	funcMap := template.FuncMap{
-		"toLower": strings.ToLower,
+		"toLower":       strings.ToLower,
+		"includeEvents": includeEvents,
 	}
 
 	tmpl, err := template.New("").Funcs(funcMap).Parse(controllerTemplate)
@@ -25,6 +28,18 @@ func genController(o *Option) (string, error) {
 	return strings.Trim(buf.String(), "\n"), nil
 }
 
+// includeEvents returns whether any resource passes the recent events
+// to the reconciler.
+func includeEvents(c *config.Config) bool {
+	for _, r := range c.Resources {
+		if r.Reconciler != nil && r.Reconciler.IncludeEvents > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 var controllerTemplate = `
 apiVersion: v1
 kind: ServiceAccount
@@ -50,6 +65,20 @@ rules:
   - update
   - patch
   - delete
+{{ range .AdditionalResources -}}
+- apiGroups:
+  - {{ .Group }}
+  resources:
+  - {{ .Kind | toLower }}
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+  - update
+  - patch
+  - delete
+{{ end -}}
 {{ range .Dependents -}}
 - apiGroups:
   - {{ .Group }}
@@ -74,6 +103,18 @@ rules:
   - list
   - watch
 {{ end -}}
+{{ if .Reconciler -}}
+{{ range .Reconciler.DynamicObjectKinds -}}
+- apiGroups:
+  - {{ .Group }}
+  resources:
+  - {{ .Kind | toLower }}
+  verbs:
+  - get
+  - create
+  - update
+{{ end -}}
+{{ end -}}
 {{ end -}}
 - apiGroups:
   - ""
@@ -82,6 +123,10 @@ rules:
   verbs:
   - create
   - patch
+{{- if includeEvents .Config }}
+  - list
+  - watch
+{{- end }}
 ---
 apiVersion: rbac.authorization.k8s.io/v1
 kind: ClusterRoleBinding
@@ -50,5 +50,5 @@ spec:
     kind: {{ .Kind }}
     plural: {{ .Kind | toLower }}
     singular: {{ .Kind | toLower }}
-  scope: Namespaced
+  scope: {{ if .ClusterScoped }}Cluster{{ else }}Namespaced{{ end }}
 `
@@ -36,7 +36,7 @@ metadata:
     certmanager.k8s.io/inject-ca-from: {{ .Namespace }}/{{ .Name }}
 webhooks:
 {{ range .Config.Resources -}}
-{{ if .Mutator -}}
+{{ if and .IsEnabled .Mutator -}}
 - name: {{ .Kind | toLower }}.{{ .Group }}
   rules:
   - apiGroups:
@@ -36,7 +36,7 @@ metadata:
     certmanager.k8s.io/inject-ca-from: {{ .Namespace }}/{{ .Name }}
 webhooks:
 {{ range .Config.Resources -}}
-{{ if .Validator -}}
+{{ if and .IsEnabled .Validator -}}
 - name: {{ .Kind | toLower }}.{{ .Group }}
   rules:
   - apiGroups:
@@ -45,6 +45,9 @@ webhooks:
     - {{ .Version }}
     resources:
     - {{ .Kind | toLower }}
+    {{- if .ValidateScale }}
+    - {{ .Kind | toLower }}/scale
+    {{- end }}
     operations:
     - CREATE
     - UPDATE
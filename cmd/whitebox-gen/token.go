@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"flag"
@@ -56,10 +58,18 @@ func token(args []string) error {
 		return fmt.Errorf("failed to parse signing key: %v", err)
 	}
 
+	// The token ID is used by the replay protection of the injector.
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	if err != nil {
+		return fmt.Errorf("failed to generate token ID: %v", err)
+	}
+
 	token := jwt.NewWithClaims(method, jwt.MapClaims{
 		"name":      *name,
 		"namespace": *namespace,
 		"iat":       time.Now().Unix(),
+		"jti":       hex.EncodeToString(id),
 	})
 
 	t, err := token.SignedString(key)
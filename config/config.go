@@ -1,327 +1,2057 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
 	"time"
 
 	"github.com/ghodss/yaml"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
 
 	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/cel"
+	"github.com/summerwind/whitebox-controller/reconciler/jsonschema"
+)
+
+const (
+	// EncodingJSON encodes handler input and output as JSON.
+	EncodingJSON = "json"
+	// EncodingYAML encodes handler input and output as YAML.
+	EncodingYAML = "yaml"
+	// EncodingKRM encodes handler input and output as the ResourceList
+	// of KRM functions. Used only by reconciler and finalizer.
+	EncodingKRM = "krm"
+
+	// OutputModeStdout reads the output of exec handler from stdout.
+	OutputModeStdout = "stdout"
+	// OutputModeFile reads the output of exec handler from a file.
+	OutputModeFile = "file"
+
+	// CompressionNone sends the body of HTTP handler as is.
+	CompressionNone = "none"
+	// CompressionGzip compresses the body of HTTP handler with gzip.
+	CompressionGzip = "gzip"
+
+	// InputModeStdin passes the input of exec handler via stdin.
+	InputModeStdin = "stdin"
+	// InputModeArg passes the input of exec handler as a base64
+	// encoded argument.
+	InputModeArg = "arg"
+	// InputModeEnv passes the input of exec handler as a base64
+	// encoded environment variable.
+	InputModeEnv = "env"
+
+	// ProfileEnvVar is the name of environment variable to select
+	// the profile of configuration.
+	ProfileEnvVar = "WHITEBOX_PROFILE"
+
+	// RunModeController runs the controllers until the process is
+	// stopped.
+	RunModeController = "controller"
+	// RunModeOnce reconciles all existing objects one time and exits.
+	RunModeOnce = "once"
+
+	// ExporterPrometheus serves metrics for Prometheus to scrape.
+	ExporterPrometheus = "prometheus"
+	// ExporterOTLP pushes metrics to an OTLP collector over HTTP.
+	ExporterOTLP = "otlp"
+	// ExporterStatsd pushes metrics to a statsd server over UDP.
+	ExporterStatsd = "statsd"
+
+	// DefaultMetricsPrefix is the prefix of the names of the metrics of
+	// the controller if the prefix is not configured.
+	DefaultMetricsPrefix = "whitebox"
+
+	// HookValidate is the validation webhook of resources.
+	HookValidate = "validate"
+	// HookMutate is the mutation webhook of resources.
+	HookMutate = "mutate"
+	// HookInject is the injection webhook of resources.
+	HookInject = "inject"
+
+	// PredicateObjectVar is the variable of the predicate that has the
+	// object.
+	PredicateObjectVar = "object"
 )
 
 type Config struct {
 	Name      string            `json:"name,omitempty"`
+	RunMode   string            `json:"runMode,omitempty"`
 	Resources []*ResourceConfig `json:"resources"`
 	Webhook   *ServerConfig     `json:"webhook,omitempty"`
+	Webhooks  []*ServerConfig   `json:"webhooks,omitempty"`
+	Metrics   *MetricsConfig    `json:"metrics,omitempty"`
+	Health    *HealthConfig     `json:"health,omitempty"`
+
+	ClientQPS   float32 `json:"clientQPS,omitempty"`
+	ClientBurst int     `json:"clientBurst,omitempty"`
+	UserAgent   string  `json:"userAgent,omitempty"`
+
+	// MaxInFlightHandlers is the maximum number of the reconciler and
+	// finalizer calls in flight across all resources. Zero means no
+	// limit.
+	MaxInFlightHandlers int `json:"maxInFlightHandlers,omitempty"`
+
+	// HTTPRateLimit limits the rate of the requests of all HTTP
+	// handlers.
+	HTTPRateLimit *HandlerRateLimit `json:"httpRateLimit,omitempty"`
+
+	// MapperRefreshInterval is the interval to rediscover the resources
+	// of the API server, so that the kinds of the CRDs installed after
+	// the controller started can be used.
+	MapperRefreshInterval string `json:"mapperRefreshInterval,omitempty"`
+
+	// StartupDelay is the time to wait after the controller started
+	// before reconciling, such as for a sidecar of the handlers to be
+	// ready.
+	StartupDelay string `json:"startupDelay,omitempty"`
+
+	// Middlewares are the names of the middlewares that wrap the calls
+	// of the reconciler, finalizer, shadow and notify handlers of all
+	// resources. The first one is the outermost.
+	Middlewares []string `json:"middlewares,omitempty"`
+
+	// Handlers are the named handlers that can be referenced by
+	// handlerRef of the handlers of resources and webhooks.
+	Handlers map[string]*HandlerConfig `json:"handlers,omitempty"`
+
+	// PluginDir is the directory of the Go plugins that provide the
+	// handlers referenced by plugin of the handlers.
+	PluginDir string `json:"pluginDir,omitempty"`
+
+	// Defaults is the configuration applied to all resources. Each
+	// resource is merged into it on loading.
+	Defaults *ResourceConfig `json:"defaults,omitempty"`
+
+	// Profiles are the variants of configuration. The selected profile
+	// is merged into the base configuration on loading.
+	Profiles map[string]*Config `json:"profiles,omitempty"`
 }
 
 func LoadFile(p string) (*Config, error) {
+	return LoadFileWithProfile(p, os.Getenv(ProfileEnvVar))
+}
+
+// LoadFileWithProfile loads the configuration file and merges the
+// specified profile into the base configuration. If the profile is
+// empty, the base configuration is returned as is.
+func LoadFileWithProfile(p, profile string) (*Config, error) {
 	buf, err := ioutil.ReadFile(p)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %v", p, err)
 	}
 
+	raw := map[string]interface{}{}
+	err = yaml.Unmarshal(buf, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %s: %v", p, err)
+	}
+
+	if profile != "" {
+		err = applyProfile(raw, profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply profile to file %s: %v", p, err)
+		}
+	}
+
+	err = applyDefaults(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to file %s: %v", p, err)
+	}
+
+	_, err = expandFileRefs(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand file %s: %v", p, err)
+	}
+
+	buf, err = json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %s: %v", p, err)
+	}
+
 	c := &Config{}
-	err = yaml.Unmarshal(buf, c)
+	err = json.Unmarshal(buf, c)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse file %s: %v", p, err)
 	}
 
+	err = c.resolveHandlerRefs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve handler in file %s: %v", p, err)
+	}
+
 	return c, nil
 }
 
-func (c *Config) Validate() error {
-	if len(c.Resources) == 0 {
-		return errors.New("at least one resource must be specified")
-	}
+// applyProfile merges the specified profile into the base section of
+// the configuration.
+func applyProfile(base map[string]interface{}, profile string) error {
+	profiles, _ := base["profiles"].(map[string]interface{})
+	delete(base, "profiles")
 
-	for i, r := range c.Resources {
-		err := r.Validate()
-		if err != nil {
-			return fmt.Errorf("resources[%d]: %v", i, err)
-		}
+	p, ok := profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found", profile)
 	}
 
-	if c.Webhook != nil {
-		err := c.Webhook.Validate()
-		if err != nil {
-			return fmt.Errorf("webhook: %v", err)
+	if p != nil {
+		pm, ok := p.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("profile %q must be an object", profile)
 		}
+		mergeMap(base, pm)
 	}
 
 	return nil
 }
 
-type ResourceConfig struct {
-	schema.GroupVersionKind
+// defaultHandlerSections are the handler sections of a resource that
+// the defaults apply to only if the resource declares them.
+var defaultHandlerSections = []string{"reconciler", "finalizer", "validator", "mutator", "injector"}
+
+// handlerKeys are the keys that specify the handler of a handler
+// configuration. They are replaced as a unit when merging defaults.
+var handlerKeys = []string{"exec", "http", "plugin", "handlerRef"}
+
+// applyDefaults merges each resource into a copy of the defaults of
+// the configuration, so that the fields of the resource take precedence.
+func applyDefaults(base map[string]interface{}) error {
+	d, ok := base["defaults"]
+	delete(base, "defaults")
+	if !ok || d == nil {
+		return nil
+	}
 
-	Dependents []DependentConfig `json:"dependents,omitempty"`
-	References []ReferenceConfig `json:"references,omitempty"`
+	defaults, ok := d.(map[string]interface{})
+	if !ok {
+		return errors.New("defaults must be an object")
+	}
 
-	Reconciler   *ReconcilerConfig `json:"reconciler,omitempty"`
-	Finalizer    *HandlerConfig    `json:"finalizer,omitempty"`
-	ResyncPeriod string            `json:"resyncPeriod,omitempty"`
+	resources, _ := base["resources"].([]interface{})
+	for i, r := range resources {
+		rm, ok := r.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("resources[%d] must be an object", i)
+		}
 
-	Validator *HandlerConfig  `json:"validator,omitempty"`
-	Mutator   *HandlerConfig  `json:"mutator,omitempty"`
-	Injector  *InjectorConfig `json:"injector,omitempty"`
-}
+		merged := copyValue(defaults).(map[string]interface{})
+		for _, key := range defaultHandlerSections {
+			_, ok := rm[key]
+			if !ok {
+				delete(merged, key)
+			}
+		}
 
-func (c *ResourceConfig) Validate() error {
-	if c.GroupVersionKind.Empty() {
-		return errors.New("resource is empty")
+		mergeDefaults(merged, rm)
+		resources[i] = merged
 	}
 
-	for i, dep := range c.Dependents {
-		if dep.Empty() {
-			return fmt.Errorf("dependents[%d] is empty", i)
+	return nil
+}
+
+// copyValue returns a deep copy of the objects and lists of v.
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for key := range val {
+			m[key] = copyValue(val[key])
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(val))
+		for i := range val {
+			l[i] = copyValue(val[i])
 		}
+		return l
 	}
 
-	for i, ref := range c.References {
-		err := ref.Validate()
-		if err != nil {
-			return fmt.Errorf("references[%d]: %v", i, err)
+	return v
+}
+
+// mergeMap merges src into dst recursively. Objects are merged and
+// any other values including lists are replaced by the value of src.
+func mergeMap(dst, src map[string]interface{}) {
+	for key, val := range src {
+		sm, ok := val.(map[string]interface{})
+		if ok {
+			dm, ok := dst[key].(map[string]interface{})
+			if ok {
+				mergeMap(dm, sm)
+				continue
+			}
 		}
+
+		dst[key] = val
 	}
+}
 
-	if c.Reconciler != nil {
-		err := c.Reconciler.Validate()
-		if err != nil {
-			return fmt.Errorf("reconciler: %v", err)
+// mergeDefaults merges src into dst like mergeMap, but the handler of
+// dst is replaced as a unit if src specifies any handler, so that the
+// handler of the defaults can be overridden by another kind of handler.
+func mergeDefaults(dst, src map[string]interface{}) {
+	for _, key := range handlerKeys {
+		_, ok := src[key]
+		if !ok {
+			continue
 		}
-	}
 
-	if c.Finalizer != nil {
-		err := c.Finalizer.Validate()
-		if err != nil {
-			return fmt.Errorf("finalizer: %v", err)
+		for _, k := range handlerKeys {
+			delete(dst, k)
 		}
+		break
 	}
 
-	if c.ResyncPeriod != "" {
-		_, err := time.ParseDuration(c.ResyncPeriod)
-		if err != nil {
-			return fmt.Errorf("invalid resync period: %v", err)
+	for key, val := range src {
+		sm, ok := val.(map[string]interface{})
+		if ok {
+			dm, ok := dst[key].(map[string]interface{})
+			if ok {
+				mergeDefaults(dm, sm)
+				continue
+			}
 		}
+
+		dst[key] = val
 	}
+}
 
-	if c.Validator != nil {
-		err := c.Validator.Validate()
-		if err != nil {
-			return fmt.Errorf("validator: %v", err)
+// fileRefPattern matches a reference to the content of a file.
+var fileRefPattern = regexp.MustCompile(`\$\{file:([^}]+)\}`)
+
+// expandFileRefs replaces the file references in all string values of
+// v with the content of the file. A trailing newline of the content is
+// removed. Maps and slices are updated in place.
+func expandFileRefs(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return expandFileRef(val)
+	case map[string]interface{}:
+		for key := range val {
+			expanded, err := expandFileRefs(val[key])
+			if err != nil {
+				return nil, err
+			}
+			val[key] = expanded
+		}
+	case []interface{}:
+		for i := range val {
+			expanded, err := expandFileRefs(val[i])
+			if err != nil {
+				return nil, err
+			}
+			val[i] = expanded
 		}
 	}
 
-	if c.Mutator != nil {
-		err := c.Mutator.Validate()
+	return v, nil
+}
+
+// expandFileRef replaces the file references in s with the content
+// of the file.
+func expandFileRef(s string) (string, error) {
+	var err error
+
+	expanded := fileRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
 		if err != nil {
-			return fmt.Errorf("mutator: %v", err)
+			return ref
 		}
-	}
 
-	if c.Injector != nil {
-		err := c.Injector.Validate()
-		if err != nil {
-			return fmt.Errorf("injector: %v", err)
+		p := fileRefPattern.FindStringSubmatch(ref)[1]
+		buf, readErr := ioutil.ReadFile(p)
+		if readErr != nil {
+			err = fmt.Errorf("failed to read file reference %s: %v", p, readErr)
+			return ref
 		}
-	}
 
-	return nil
+		return strings.TrimSuffix(string(buf), "\n")
+	})
+
+	return expanded, err
 }
 
-type DependentConfig struct {
-	schema.GroupVersionKind
-	Orphan bool `json:"orphan"`
+// ValidateConfigFile loads the configuration file of specified path
+// and returns all validation errors found in it.
+func ValidateConfigFile(p string) []error {
+	c, err := LoadFile(p)
+	if err != nil {
+		return []error{err}
+	}
+
+	return c.validateAll()
 }
 
-func (c *DependentConfig) Validate() error {
-	if c.GroupVersionKind.Empty() {
-		return errors.New("resource is empty")
+func (c *Config) Validate() error {
+	errs := c.validateAll()
+	if len(errs) > 0 {
+		return errs[0]
 	}
 
 	return nil
 }
 
-type ReferenceConfig struct {
-	schema.GroupVersionKind
-	NameFieldPath string `json:"nameFieldPath"`
-}
+// validateAll validates the configuration and returns errors of
+// all resources instead of only the first one.
+func (c *Config) validateAll() []error {
+	errs := []error{}
 
-func (c *ReferenceConfig) Validate() error {
-	if c.GroupVersionKind.Empty() {
-		return errors.New("resource is empty")
+	if len(c.Resources) == 0 {
+		errs = append(errs, errors.New("at least one resource must be specified"))
+	} else if len(c.EnabledResources()) == 0 {
+		errs = append(errs, errors.New("at least one resource must be enabled"))
 	}
 
-	if c.NameFieldPath == "" {
-		return errors.New("nameFieldPath must be specified")
+	for i, r := range c.Resources {
+		err := r.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resources[%d]: %v", i, err))
+		}
 	}
 
-	return nil
-}
-
-type ReconcilerConfig struct {
-	HandlerConfig
-	RequeueAfter string `json:"requeueAfter"`
-	Observe      bool   `json:"observe"`
-}
+	switch c.RunMode {
+	case "", RunModeController, RunModeOnce:
+	default:
+		errs = append(errs, fmt.Errorf("invalid runMode: %s", c.RunMode))
+	}
 
-func (c *ReconcilerConfig) Validate() error {
-	if c.RequeueAfter != "" {
-		_, err := time.ParseDuration(c.RequeueAfter)
+	if c.Webhook != nil {
+		err := c.Webhook.Validate()
 		if err != nil {
-			return fmt.Errorf("invalid requeueAfter: %v", err)
+			errs = append(errs, fmt.Errorf("webhook: %v", err))
 		}
 	}
 
-	return c.HandlerConfig.Validate()
-}
-
-type InjectorConfig struct {
-	HandlerConfig
-	VerifyKeyFile string `json:"verifyKeyFile"`
-}
+	ports := map[int]struct{}{}
+	for i, w := range c.WebhookServers() {
+		if w != c.Webhook {
+			err := w.Validate()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("webhooks[%d]: %v", i, err))
+			}
+		}
 
-func (c *InjectorConfig) Validate() error {
-	if c.VerifyKeyFile == "" {
-		return errors.New("verification key file must be specified")
+		_, ok := ports[w.Port]
+		if ok {
+			errs = append(errs, fmt.Errorf("duplicate webhook port: %d", w.Port))
+		}
+		ports[w.Port] = struct{}{}
 	}
 
-	return c.HandlerConfig.Validate()
-}
-
-type HandlerConfig struct {
-	Exec *ExecHandlerConfig `json:"exec"`
-	HTTP *HTTPHandlerConfig `json:"http"`
+	errs = append(errs, c.validateHooks()...)
+	errs = append(errs, c.validateWebhookResources()...)
 
-	StateHandler            handler.StateHandler            `json:"-"`
-	AdmissionRequestHandler handler.AdmissionRequestHandler `json:"-"`
-	InjectionRequestHandler handler.InjectionRequestHandler `json:"-"`
-}
+	if c.Metrics != nil {
+		err := c.Metrics.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("metrics: %v", err))
+		}
+	}
 
-func (c *HandlerConfig) Validate() error {
-	specified := 0
+	if c.Health != nil {
+		err := c.Health.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("health: %v", err))
+		}
+	}
 
-	if c.Exec != nil {
-		specified++
+	if c.ClientQPS < 0 {
+		errs = append(errs, errors.New("clientQPS must be greater than or equal to 0"))
 	}
-	if c.HTTP != nil {
-		specified++
+
+	if c.ClientBurst < 0 {
+		errs = append(errs, errors.New("clientBurst must be greater than or equal to 0"))
 	}
-	if c.StateHandler != nil || c.AdmissionRequestHandler != nil || c.InjectionRequestHandler != nil {
-		specified++
+
+	if c.UserAgent != "" && strings.TrimSpace(c.UserAgent) == "" {
+		errs = append(errs, errors.New("userAgent must not be blank"))
 	}
 
-	if specified == 0 {
-		return errors.New("handler must be specified")
+	if c.MaxInFlightHandlers < 0 {
+		errs = append(errs, errors.New("maxInFlightHandlers must be greater than or equal to 0"))
 	}
-	if specified > 1 {
-		return errors.New("exactly one handler must be specified")
+
+	if c.HTTPRateLimit != nil {
+		err := c.HTTPRateLimit.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid httpRateLimit: %v", err))
+		}
 	}
 
-	if c.Exec != nil {
-		err := c.Exec.Validate()
+	if c.MapperRefreshInterval != "" {
+		interval, err := time.ParseDuration(c.MapperRefreshInterval)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("invalid mapperRefreshInterval: %v", err))
+		} else if interval <= 0 {
+			errs = append(errs, errors.New("mapperRefreshInterval must be greater than 0"))
 		}
 	}
 
-	if c.HTTP != nil {
-		err := c.HTTP.Validate()
+	if c.StartupDelay != "" {
+		delay, err := time.ParseDuration(c.StartupDelay)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("invalid startupDelay: %v", err))
+		} else if delay < 0 {
+			errs = append(errs, errors.New("startupDelay must be greater than or equal to 0"))
 		}
 	}
 
-	return nil
-}
+	seen := map[string]struct{}{}
+	for i, name := range c.Middlewares {
+		_, ok := handler.LookupMiddleware(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid middlewares[%d]: unknown middleware: %s", i, name))
+			continue
+		}
 
-type ExecHandlerConfig struct {
-	Command    string            `json:"command"`
-	Args       []string          `json:"args"`
-	WorkingDir string            `json:"workingDir"`
-	Env        map[string]string `json:"env"`
-	Timeout    string            `json:"timeout"`
-	Debug      bool              `json:"debug"`
+		_, ok = seen[name]
+		if ok {
+			errs = append(errs, fmt.Errorf("invalid middlewares[%d]: duplicate middleware: %s", i, name))
+		}
+		seen[name] = struct{}{}
+	}
+
+	errs = append(errs, c.validateHandlers()...)
+	errs = append(errs, c.validatePlugins()...)
+	errs = append(errs, c.validatePrune()...)
+
+	return errs
 }
 
-func (c ExecHandlerConfig) Validate() error {
-	if c.Command == "" {
-		return errors.New("command must be specified")
+// validatePrune validates that the dependents pruned on startup are
+// not tracked by other resources, since the tracking labels do not
+// identify the kind of the owner.
+func (c *Config) validatePrune() []error {
+	errs := []error{}
+
+	tracked := map[schema.GroupVersionKind]int{}
+	for _, r := range c.EnabledResources() {
+		for _, dep := range r.Dependents {
+			if dep.TrackingLabels {
+				tracked[dep.GroupVersionKind]++
+			}
+		}
 	}
 
-	if c.Timeout != "" {
-		_, err := time.ParseDuration(c.Timeout)
-		if err != nil {
-			return fmt.Errorf("invalid timeout: %v", err)
+	for i, r := range c.Resources {
+		if r.PruneOnStartup == nil || !r.IsEnabled() {
+			continue
 		}
-	}
 
-	return nil
-}
+		for _, dep := range r.Dependents {
+			if dep.TrackingLabels && tracked[dep.GroupVersionKind] > 1 {
+				errs = append(errs, fmt.Errorf("resources[%d]: pruneOnStartup must not be specified for %s tracked by other resources", i, dep.GroupVersionKind.Kind))
+			}
+		}
+	}
 
-type HTTPHandlerConfig struct {
-	URL     string     `json:"url"`
-	TLS     *TLSConfig `json:"tls,omitempty"`
-	Timeout string     `json:"timeout"`
-	Debug   bool       `json:"debug"`
+	return errs
 }
 
-func (c HTTPHandlerConfig) Validate() error {
-	if c.URL == "" {
-		return errors.New("url must be specified")
+// WebhookServers returns the configurations of all webhook servers.
+func (c *Config) WebhookServers() []*ServerConfig {
+	servers := []*ServerConfig{}
+	if c.Webhook != nil {
+		servers = append(servers, c.Webhook)
 	}
 
-	if c.Timeout != "" {
-		_, err := time.ParseDuration(c.Timeout)
-		if err != nil {
-			return fmt.Errorf("invalid timeout: %v", err)
+	for _, w := range c.Webhooks {
+		if w != nil {
+			servers = append(servers, w)
 		}
 	}
 
-	return nil
+	return servers
 }
 
-type FuncHandlerConfig struct {
-	Handler handler.Handler `json:"-"`
-}
+// validateHooks validates that the webhooks of all enabled resources
+// are served by at least one of the webhook servers.
+func (c *Config) validateHooks() []error {
+	errs := []error{}
 
-func (c *FuncHandlerConfig) Validate() error {
-	if c.Handler == nil {
-		return errors.New("handler must be specified")
+	servers := c.WebhookServers()
+	if len(servers) == 0 {
+		return errs
 	}
 
-	return nil
-}
+	served := func(hook string) bool {
+		for _, w := range servers {
+			if w.Serves(hook) {
+				return true
+			}
+		}
+		return false
+	}
 
-type ServerConfig struct {
-	Host string     `json:"host"`
-	Port int        `json:"port"`
-	TLS  *TLSConfig `json:"tls"`
+	for _, r := range c.EnabledResources() {
+		hooks := map[string]bool{
+			HookValidate: r.Validator != nil,
+			HookMutate:   r.Mutator != nil,
+			HookInject:   r.Injector != nil,
+		}
+		for _, hook := range []string{HookValidate, HookMutate, HookInject} {
+			if hooks[hook] && !served(hook) {
+				errs = append(errs, fmt.Errorf("no webhook server serves %s hook of %s", hook, r.Kind))
+			}
+		}
+	}
+
+	return errs
 }
 
-func (c *ServerConfig) Validate() error {
-	if c.Port == 0 {
-		return errors.New("port must be specified")
+// validateWebhookResources validates that each webhook of the enabled
+// resources is configured for at most one resource of the same kind,
+// since the webhooks of a kind are served on the same path.
+func (c *Config) validateWebhookResources() []error {
+	errs := []error{}
+
+	seen := map[string]map[string]struct{}{
+		HookValidate: {},
+		HookMutate:   {},
+		HookInject:   {},
 	}
 
-	if c.TLS != nil {
-		err := c.TLS.Validate()
-		if err != nil {
-			return fmt.Errorf("tls: %v", err)
+	for _, r := range c.EnabledResources() {
+		hooks := map[string]bool{
+			HookValidate: r.Validator != nil,
+			HookMutate:   r.Mutator != nil,
+			HookInject:   r.Injector != nil,
+		}
+
+		// The kind is case-insensitive in the path of the webhook.
+		gvk := r.GroupVersionKind
+		key := fmt.Sprintf("%s/%s/%s", gvk.Group, gvk.Version, strings.ToLower(gvk.Kind))
+
+		for _, hook := range []string{HookValidate, HookMutate, HookInject} {
+			if !hooks[hook] {
+				continue
+			}
+
+			_, ok := seen[hook][key]
+			if ok {
+				errs = append(errs, fmt.Errorf("duplicate %s hook of resource: %s", hook, gvk))
+				continue
+			}
+			seen[hook][key] = struct{}{}
 		}
 	}
 
-	return nil
+	return errs
 }
 
-type TLSConfig struct {
+// EnabledResources returns a list of enabled resources.
+func (c *Config) EnabledResources() []*ResourceConfig {
+	resources := []*ResourceConfig{}
+	for _, r := range c.Resources {
+		if r.IsEnabled() {
+			resources = append(resources, r)
+		}
+	}
+
+	return resources
+}
+
+type ResourceConfig struct {
+	schema.GroupVersionKind
+
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// ClusterScoped specifies that the resource is not namespaced.
+	// Dependents of the resource can be in any namespace or
+	// cluster-scoped.
+	ClusterScoped bool `json:"clusterScoped,omitempty"`
+
+	// AdditionalResources are the kinds of resources reconciled with
+	// the same configuration as the resource.
+	AdditionalResources []schema.GroupVersionKind `json:"additionalResources,omitempty"`
+
+	Dependents []DependentConfig `json:"dependents,omitempty"`
+	References []ReferenceConfig `json:"references,omitempty"`
+	Watches    []WatchConfig     `json:"watches,omitempty"`
+
+	// ReferenceDebounce is the duration to coalesce the changes of the
+	// watched resources into one reconcile of the resource.
+	ReferenceDebounce string `json:"referenceDebounce,omitempty"`
+
+	// PruneOnStartup deletes the dependents tracked by labels whose
+	// owner no longer exists when the controller starts.
+	PruneOnStartup *PruneConfig `json:"pruneOnStartup,omitempty"`
+
+	Reconciler   *ReconcilerConfig `json:"reconciler,omitempty"`
+	Finalizer    *HandlerConfig    `json:"finalizer,omitempty"`
+	ResyncPeriod string            `json:"resyncPeriod,omitempty"`
+
+	// ResyncMaxAge limits the resync to the resources created or
+	// changed within the duration. The time of change is read from
+	// ResyncAgeAnnotation if specified, otherwise the creation timestamp
+	// is used.
+	ResyncMaxAge        string `json:"resyncMaxAge,omitempty"`
+	ResyncAgeAnnotation string `json:"resyncAgeAnnotation,omitempty"`
+
+	GenerationChangedPredicate bool `json:"generationChangedPredicate,omitempty"`
+	DependentReadiness         bool `json:"dependentReadiness,omitempty"`
+
+	// KeyFieldPath is the field path of the object whose value
+	// identifies the object in logs instead of namespace and name.
+	KeyFieldPath string `json:"keyFieldPath,omitempty"`
+
+	// Predicate is the CEL expression over the object. The handler is
+	// not run for the object if the expression evaluates to false.
+	Predicate string `json:"predicate,omitempty"`
+
+	Validator *HandlerConfig  `json:"validator,omitempty"`
+	Mutator   *HandlerConfig  `json:"mutator,omitempty"`
+	Injector  *InjectorConfig `json:"injector,omitempty"`
+
+	ValidateScale bool `json:"validateScale,omitempty"`
+}
+
+// validatePruneOnStartup validates that the tracked dependents can be
+// pruned by the reconciler of the resource.
+func (c *ResourceConfig) validatePruneOnStartup() error {
+	if c.Reconciler == nil || c.Reconciler.Observe {
+		return errors.New("pruneOnStartup requires reconciler")
+	}
+
+	// The owner of the dependent is looked up only in the kind of
+	// the resource.
+	if len(c.AdditionalResources) > 0 {
+		return errors.New("pruneOnStartup must not be specified with additionalResources")
+	}
+
+	for _, dep := range c.Dependents {
+		if dep.TrackingLabels {
+			return nil
+		}
+	}
+
+	return errors.New("pruneOnStartup requires dependents with trackingLabels")
+}
+
+// PruneConfig represents the configuration of pruning the tracked
+// dependents on startup.
+type PruneConfig struct {
+	// DryRun only logs the dependents to prune. Use DryRuns to read
+	// this value.
+	DryRun *bool `json:"dryRun,omitempty"`
+}
+
+// DryRuns returns whether the dependents are only logged instead of
+// being deleted. It is true unless explicitly disabled.
+func (c *PruneConfig) DryRuns() bool {
+	return c.DryRun == nil || *c.DryRun
+}
+
+// ReconciledResources returns the configurations of the resource and
+// each of the additional resources. The configuration of an additional
+// resource is a copy of the resource without the webhooks.
+func (c *ResourceConfig) ReconciledResources() []*ResourceConfig {
+	resources := []*ResourceConfig{c}
+	for _, gvk := range c.AdditionalResources {
+		rc := *c
+		rc.GroupVersionKind = gvk
+		rc.AdditionalResources = nil
+		rc.Validator = nil
+		rc.Mutator = nil
+		rc.Injector = nil
+		rc.ValidateScale = false
+		resources = append(resources, &rc)
+	}
+
+	return resources
+}
+
+// IsEnabled returns whether the resource is enabled. Resources are
+// enabled unless explicitly disabled.
+func (c *ResourceConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+func (c *ResourceConfig) Validate() error {
+	if c.GroupVersionKind.Empty() {
+		return errors.New("resource is empty")
+	}
+
+	if len(c.AdditionalResources) > 0 && c.Reconciler == nil {
+		return errors.New("additionalResources requires reconciler")
+	}
+
+	kinds := map[schema.GroupVersionKind]struct{}{c.GroupVersionKind: struct{}{}}
+	for i, gvk := range c.AdditionalResources {
+		if gvk.Version == "" || gvk.Kind == "" {
+			return fmt.Errorf("additionalResources[%d]: version and kind must be specified", i)
+		}
+
+		_, ok := kinds[gvk]
+		if ok {
+			return fmt.Errorf("additionalResources[%d]: duplicate resource: %s", i, gvk)
+		}
+		kinds[gvk] = struct{}{}
+	}
+
+	readiness := false
+	for i, dep := range c.Dependents {
+		if dep.Empty() {
+			return fmt.Errorf("dependents[%d] is empty", i)
+		}
+
+		err := dep.Validate()
+		if err != nil {
+			return fmt.Errorf("dependents[%d]: %v", i, err)
+		}
+
+		if dep.ReadinessPath != "" {
+			readiness = true
+		}
+	}
+
+	if c.DependentReadiness && !readiness {
+		return errors.New("dependentReadiness requires readinessPath of at least one dependent")
+	}
+
+	for i, ref := range c.References {
+		err := ref.Validate()
+		if err != nil {
+			return fmt.Errorf("references[%d]: %v", i, err)
+		}
+
+		if c.ClusterScoped && ref.Namespace == "" {
+			return fmt.Errorf("references[%d]: namespace is required for cluster-scoped resource", i)
+		}
+		if !c.ClusterScoped && ref.Namespace != "" {
+			return fmt.Errorf("references[%d]: namespace is supported only for cluster-scoped resource", i)
+		}
+	}
+
+	for i, w := range c.Watches {
+		err := w.Validate()
+		if err != nil {
+			return fmt.Errorf("watches[%d]: %v", i, err)
+		}
+	}
+
+	if c.ReferenceDebounce != "" {
+		d, err := time.ParseDuration(c.ReferenceDebounce)
+		if err != nil {
+			return fmt.Errorf("invalid referenceDebounce: %v", err)
+		}
+		if d < 0 {
+			return errors.New("referenceDebounce must be greater than or equal to 0")
+		}
+	}
+
+	if c.PruneOnStartup != nil {
+		err := c.validatePruneOnStartup()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Reconciler != nil {
+		err := c.Reconciler.Validate()
+		if err != nil {
+			return fmt.Errorf("reconciler: %v", err)
+		}
+	}
+
+	if c.Finalizer != nil {
+		err := c.Finalizer.Validate()
+		if err != nil {
+			return fmt.Errorf("finalizer: %v", err)
+		}
+
+		// Finalizer runs within a reconcile.
+		if c.Reconciler != nil {
+			err := c.Reconciler.ValidateHandlerTimeout(c.Finalizer)
+			if err != nil {
+				return fmt.Errorf("finalizer: %v", err)
+			}
+		}
+	}
+
+	if c.ResyncPeriod != "" {
+		_, err := time.ParseDuration(c.ResyncPeriod)
+		if err != nil {
+			return fmt.Errorf("invalid resync period: %v", err)
+		}
+	}
+
+	if c.ResyncMaxAge != "" {
+		if c.ResyncPeriod == "" {
+			return errors.New("resyncMaxAge requires resyncPeriod")
+		}
+
+		maxAge, err := time.ParseDuration(c.ResyncMaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid resync max age: %v", err)
+		}
+		if maxAge <= 0 {
+			return errors.New("resync max age must be greater than 0")
+		}
+	}
+
+	if c.ResyncAgeAnnotation != "" && c.ResyncMaxAge == "" {
+		return errors.New("resyncAgeAnnotation requires resyncMaxAge")
+	}
+
+	if c.ClusterScoped && c.Injector != nil {
+		return errors.New("injector is not supported for cluster-scoped resource")
+	}
+
+	if c.KeyFieldPath != "" {
+		_, err := ParseFieldPath(c.KeyFieldPath)
+		if err != nil {
+			return fmt.Errorf("invalid keyFieldPath: %v", err)
+		}
+	}
+
+	if c.Predicate != "" {
+		_, err := cel.Compile(c.Predicate, PredicateObjectVar)
+		if err != nil {
+			return fmt.Errorf("invalid predicate: %v", err)
+		}
+	}
+
+	if c.Validator != nil {
+		err := c.Validator.Validate()
+		if err != nil {
+			return fmt.Errorf("validator: %v", err)
+		}
+		if len(c.Validator.AllowedFields) > 0 {
+			return errors.New("validator: allowedFields is not supported")
+		}
+		if c.Validator.usesKRM() {
+			return errors.New("validator: krm encoding is not supported")
+		}
+		if c.Validator.Typed && !IsTypedKind(c.GroupVersionKind) {
+			return fmt.Errorf("validator: typed is not supported for %s", c.GroupVersionKind)
+		}
+	}
+
+	if c.ValidateScale && c.Validator == nil {
+		return errors.New("validateScale requires validator")
+	}
+
+	if c.Mutator != nil {
+		err := c.Mutator.Validate()
+		if err != nil {
+			return fmt.Errorf("mutator: %v", err)
+		}
+		if len(c.Mutator.ImmutableFields) > 0 {
+			return errors.New("mutator: immutableFields is not supported")
+		}
+		if c.Mutator.usesKRM() {
+			return errors.New("mutator: krm encoding is not supported")
+		}
+		if c.Mutator.Typed && !IsTypedKind(c.GroupVersionKind) {
+			return fmt.Errorf("mutator: typed is not supported for %s", c.GroupVersionKind)
+		}
+	}
+
+	if c.Injector != nil {
+		err := c.Injector.Validate()
+		if err != nil {
+			return fmt.Errorf("injector: %v", err)
+		}
+		if len(c.Injector.AllowedFields) > 0 {
+			return errors.New("injector: allowedFields is not supported")
+		}
+		if len(c.Injector.ImmutableFields) > 0 {
+			return errors.New("injector: immutableFields is not supported")
+		}
+		if c.Injector.usesKRM() {
+			return errors.New("injector: krm encoding is not supported")
+		}
+		if c.Injector.Typed {
+			return errors.New("injector: typed is not supported")
+		}
+		// The injection request has no object to render the URL with.
+		if c.Injector.HTTP != nil && IsURLTemplate(c.Injector.HTTP.URL) {
+			return errors.New("injector: url template is not supported")
+		}
+	}
+
+	return nil
+}
+
+// TypedKinds are the built-in kinds whose objects are typed and
+// defaulted by the validator and mutator with typed option.
+var TypedKinds = []schema.GroupVersionKind{
+	{Version: "v1", Kind: "Pod"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+}
+
+// IsTypedKind returns true if specified kind is one of TypedKinds.
+func IsTypedKind(gvk schema.GroupVersionKind) bool {
+	for _, k := range TypedKinds {
+		if k == gvk {
+			return true
+		}
+	}
+
+	return false
+}
+
+type DependentConfig struct {
+	schema.GroupVersionKind
+	Orphan        bool   `json:"orphan"`
+	ReadinessPath string `json:"readinessPath,omitempty"`
+
+	// ControllerOwnerRef specifies whether the owner reference of the
+	// dependent is a controller reference. Defaults to true.
+	ControllerOwnerRef *bool `json:"controllerOwnerRef,omitempty"`
+
+	// TrackingLabels tracks the dependents by the labels of the owner
+	// instead of the owner reference, so that the dependents can be
+	// in other namespaces than the owner.
+	TrackingLabels bool `json:"trackingLabels,omitempty"`
+}
+
+func (c *DependentConfig) Validate() error {
+	if c.GroupVersionKind.Empty() {
+		return errors.New("resource is empty")
+	}
+
+	if c.Orphan && c.ControllerOwnerRef != nil {
+		return errors.New("controllerOwnerRef must not be specified for orphan dependent")
+	}
+
+	if c.TrackingLabels && c.Orphan {
+		return errors.New("trackingLabels must not be specified for orphan dependent")
+	}
+
+	if c.TrackingLabels && c.ControllerOwnerRef != nil {
+		return errors.New("controllerOwnerRef must not be specified with trackingLabels")
+	}
+
+	if c.ReadinessPath != "" {
+		err := jsonpath.New("readiness").Parse(fmt.Sprintf("{%s}", c.ReadinessPath))
+		if err != nil {
+			return fmt.Errorf("invalid readinessPath: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// IsControllerOwnerRef returns whether the owner reference of the
+// dependent is a controller reference, which prevents other controllers
+// from managing the dependent. It is true unless explicitly disabled.
+func (c *DependentConfig) IsControllerOwnerRef() bool {
+	return c.ControllerOwnerRef == nil || *c.ControllerOwnerRef
+}
+
+type ReferenceConfig struct {
+	schema.GroupVersionKind
+	NameFieldPath string `json:"nameFieldPath"`
+	Required      bool   `json:"required,omitempty"`
+
+	// Namespace is the namespace of the referenced resources. This is
+	// required for the references of cluster-scoped resource.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (c *ReferenceConfig) Validate() error {
+	if c.GroupVersionKind.Empty() {
+		return errors.New("resource is empty")
+	}
+
+	if c.NameFieldPath == "" {
+		return errors.New("nameFieldPath must be specified")
+	}
+
+	return nil
+}
+
+type WatchConfig struct {
+	schema.GroupVersionKind
+	NameFieldPath string `json:"nameFieldPath"`
+}
+
+func (c *WatchConfig) Validate() error {
+	if c.GroupVersionKind.Empty() {
+		return errors.New("resource is empty")
+	}
+
+	if c.NameFieldPath == "" {
+		return errors.New("nameFieldPath must be specified")
+	}
+
+	return nil
+}
+
+type ReconcilerConfig struct {
+	HandlerConfig
+	RequeueAfter string `json:"requeueAfter"`
+	Observe      bool   `json:"observe"`
+	MaxRetries   int    `json:"maxRetries"`
+	Timeout      string `json:"timeout,omitempty"`
+
+	// ConflictRetries is the number of times to retry the update of the
+	// object within a reconcile when it conflicts.
+	ConflictRetries int `json:"conflictRetries,omitempty"`
+
+	// EnforceDeadline stops reconciling the objects whose deadline
+	// annotation has passed.
+	EnforceDeadline bool `json:"enforceDeadline,omitempty"`
+
+	// Started is closed when the reconciler may start reconciling, and
+	// reconciles wait until then. Set by the manager if the startup
+	// delay is configured.
+	Started <-chan struct{} `json:"-"`
+
+	// TimeoutFieldPath is the JSON Path of the object field that
+	// overrides the timeout of the handler for each reconcile.
+	TimeoutFieldPath string `json:"timeoutFieldPath,omitempty"`
+
+	StatusErrorField  string `json:"statusErrorField,omitempty"`
+	ReferenceCacheTTL string `json:"referenceCacheTTL,omitempty"`
+	IncludeEvents     int    `json:"includeEvents,omitempty"`
+
+	IncludeAPIVersions bool `json:"includeAPIVersions,omitempty"`
+
+	// OutputSchema is the JSON Schema that the object returned by the
+	// handler must match.
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+
+	SkipDeletionWithoutFinalizer *bool `json:"skipDeletionWithoutFinalizer,omitempty"`
+
+	// ServerDryRun makes the writes of the reconciler server-side
+	// dry-run requests that are not persisted.
+	ServerDryRun bool `json:"serverDryRun,omitempty"`
+
+	// ErrorEvents emits a warning event to the object on handler errors.
+	ErrorEvents bool `json:"errorEvents,omitempty"`
+
+	// ResultSink receives a record of the outcome of each reconcile.
+	ResultSink *ResultSinkConfig `json:"resultSink,omitempty"`
+
+	// AllowDynamicObjects allows the handler to return additional
+	// objects to apply. Only the kinds in DynamicObjectKinds are allowed.
+	AllowDynamicObjects bool                      `json:"allowDynamicObjects,omitempty"`
+	DynamicObjectKinds  []schema.GroupVersionKind `json:"dynamicObjectKinds,omitempty"`
+
+	// Shadow is the handler that is run in parallel with the handler
+	// to compare its output. The output of it is never applied.
+	Shadow *HandlerConfig `json:"shadow,omitempty"`
+
+	// Notify is the handler that is run when the outcome of the
+	// reconciles of an object changes between success and error.
+	Notify *HandlerConfig `json:"notify,omitempty"`
+}
+
+func (c *ReconcilerConfig) Validate() error {
+	if c.MaxRetries < 0 {
+		return errors.New("maxRetries must be greater than or equal to 0")
+	}
+
+	if c.ConflictRetries < 0 {
+		return errors.New("conflictRetries must be greater than or equal to 0")
+	}
+
+	if c.IncludeEvents < 0 {
+		return errors.New("includeEvents must be greater than or equal to 0")
+	}
+
+	if c.ServerDryRun && c.Observe {
+		return errors.New("serverDryRun must not be specified with observe")
+	}
+
+	if c.AllowDynamicObjects {
+		if c.Observe {
+			return errors.New("allowDynamicObjects must not be specified with observe")
+		}
+		if len(c.DynamicObjectKinds) == 0 {
+			return errors.New("allowDynamicObjects requires dynamicObjectKinds")
+		}
+	} else if len(c.DynamicObjectKinds) > 0 {
+		return errors.New("dynamicObjectKinds requires allowDynamicObjects")
+	}
+
+	kinds := map[schema.GroupVersionKind]struct{}{}
+	for i, gvk := range c.DynamicObjectKinds {
+		if gvk.Version == "" || gvk.Kind == "" {
+			return fmt.Errorf("dynamicObjectKinds[%d]: version and kind must be specified", i)
+		}
+
+		_, ok := kinds[gvk]
+		if ok {
+			return fmt.Errorf("dynamicObjectKinds[%d]: duplicate kind: %s", i, gvk)
+		}
+		kinds[gvk] = struct{}{}
+	}
+
+	if c.StatusErrorField != "" {
+		_, err := ParseFieldPath(c.StatusErrorField)
+		if err != nil {
+			return fmt.Errorf("invalid statusErrorField: %v", err)
+		}
+	}
+
+	if c.RequeueAfter != "" {
+		_, err := time.ParseDuration(c.RequeueAfter)
+		if err != nil {
+			return fmt.Errorf("invalid requeueAfter: %v", err)
+		}
+	}
+
+	if c.Timeout != "" {
+		_, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+	}
+
+	if c.TimeoutFieldPath != "" {
+		err := jsonpath.New("timeout").Parse(fmt.Sprintf("{%s}", c.TimeoutFieldPath))
+		if err != nil {
+			return fmt.Errorf("invalid timeoutFieldPath: %v", err)
+		}
+	}
+
+	if c.ReferenceCacheTTL != "" {
+		ttl, err := time.ParseDuration(c.ReferenceCacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid referenceCacheTTL: %v", err)
+		}
+		if ttl < 0 {
+			return errors.New("referenceCacheTTL must be greater than or equal to 0")
+		}
+	}
+
+	if c.OutputSchema != nil {
+		_, err := jsonschema.Compile(c.OutputSchema)
+		if err != nil {
+			return fmt.Errorf("invalid outputSchema: %v", err)
+		}
+	}
+
+	if c.ResultSink != nil {
+		err := c.ResultSink.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid resultSink: %v", err)
+		}
+	}
+
+	if c.Shadow != nil {
+		if c.Observe {
+			return errors.New("shadow must not be specified with observe")
+		}
+
+		err := c.Shadow.Validate()
+		if err != nil {
+			return fmt.Errorf("shadow: %v", err)
+		}
+		if len(c.Shadow.AllowedFields) > 0 {
+			return errors.New("shadow: allowedFields is not supported")
+		}
+
+		err = c.ValidateHandlerTimeout(c.Shadow)
+		if err != nil {
+			return fmt.Errorf("shadow: %v", err)
+		}
+	}
+
+	if c.Notify != nil {
+		if c.Observe {
+			return errors.New("notify must not be specified with observe")
+		}
+
+		err := c.Notify.Validate()
+		if err != nil {
+			return fmt.Errorf("notify: %v", err)
+		}
+		if len(c.Notify.AllowedFields) > 0 {
+			return errors.New("notify: allowedFields is not supported")
+		}
+		if c.Notify.usesKRM() {
+			return errors.New("notify: krm encoding is not supported")
+		}
+
+		err = c.ValidateHandlerTimeout(c.Notify)
+		if err != nil {
+			return fmt.Errorf("notify: %v", err)
+		}
+	}
+
+	err := c.HandlerConfig.Validate()
+	if err != nil {
+		return err
+	}
+
+	return c.ValidateHandlerTimeout(&c.HandlerConfig)
+}
+
+// ResultSinkConfig represents the destination of the records of
+// reconcile outcomes. Exactly one sink must be specified.
+type ResultSinkConfig struct {
+	HTTP *HTTPSinkConfig `json:"http,omitempty"`
+}
+
+func (c *ResultSinkConfig) Validate() error {
+	if c.HTTP == nil {
+		return errors.New("sink must be specified")
+	}
+
+	return c.HTTP.Validate()
+}
+
+// HTTPSinkConfig represents the HTTP endpoint that receives the
+// records as JSON with POST requests.
+type HTTPSinkConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+}
+
+func (c *HTTPSinkConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("url must be specified")
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url: unsupported scheme: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("invalid url: host must be specified")
+	}
+
+	if c.Timeout != "" {
+		timeout, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+		if timeout <= 0 {
+			return errors.New("timeout must be greater than 0")
+		}
+	}
+
+	return nil
+}
+
+// SkipsDeletionWithoutFinalizer returns whether the reconciler skips
+// the objects being deleted when no finalizer is configured. Such
+// objects are skipped unless explicitly disabled.
+func (c *ReconcilerConfig) SkipsDeletionWithoutFinalizer() bool {
+	return c.SkipDeletionWithoutFinalizer == nil || *c.SkipDeletionWithoutFinalizer
+}
+
+// ValidateHandlerTimeout validates that the timeout of specified handler
+// does not exceed the timeout of reconciler.
+func (c *ReconcilerConfig) ValidateHandlerTimeout(hc *HandlerConfig) error {
+	if c.Timeout == "" {
+		return nil
+	}
+
+	field, ht := hc.timeout()
+	if ht == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %v", err)
+	}
+
+	handlerTimeout, err := time.ParseDuration(ht)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", field, err)
+	}
+
+	if handlerTimeout > timeout {
+		return fmt.Errorf("%s (%s) must be less than or equal to reconciler timeout (%s)", field, ht, c.Timeout)
+	}
+
+	return nil
+}
+
+type InjectorConfig struct {
+	HandlerConfig
+	VerifyKeyFile string `json:"verifyKeyFile"`
+
+	// ReplayWindow enables the replay protection. Tokens issued before
+	// the window or with an ID used within the window are rejected.
+	ReplayWindow string `json:"replayWindow,omitempty"`
+}
+
+func (c *InjectorConfig) Validate() error {
+	if c.VerifyKeyFile == "" {
+		return errors.New("verification key file must be specified")
+	}
+
+	if c.ReplayWindow != "" {
+		window, err := time.ParseDuration(c.ReplayWindow)
+		if err != nil {
+			return fmt.Errorf("invalid replayWindow: %v", err)
+		}
+		if window <= 0 {
+			return errors.New("replayWindow must be greater than 0")
+		}
+	}
+
+	return c.HandlerConfig.Validate()
+}
+
+type HandlerConfig struct {
+	Exec   *ExecHandlerConfig   `json:"exec"`
+	HTTP   *HTTPHandlerConfig   `json:"http"`
+	Plugin *PluginHandlerConfig `json:"plugin,omitempty"`
+
+	// HandlerRef is the name of the handler in Handlers of Config. The
+	// exec or http handler of it is used by this handler.
+	HandlerRef string `json:"handlerRef,omitempty"`
+
+	// AllowedFields are the field paths of the object that the handler
+	// is allowed to modify. Used only by reconciler, finalizer and
+	// mutator.
+	AllowedFields []string `json:"allowedFields,omitempty"`
+
+	// ImmutableFields are the field paths of the object that must not
+	// be changed on update. Used only by validator.
+	ImmutableFields []string `json:"immutableFields,omitempty"`
+
+	// Typed decodes the objects of the admission requests of TypedKinds
+	// into the typed objects and applies the defaults of them before
+	// passing to the handler. The objects of the other kinds are passed
+	// as is. Used only by validator and mutator.
+	Typed bool `json:"typed,omitempty"`
+
+	// RecordDir is the directory to write the input and output of each
+	// call of the handler for debugging. Recording is disabled if empty.
+	RecordDir string `json:"recordDir,omitempty"`
+	// RecordMaxFiles is the maximum number of the recorded files kept in
+	// RecordDir. The oldest files are removed when exceeded.
+	RecordMaxFiles int `json:"recordMaxFiles,omitempty"`
+
+	StateHandler            handler.StateHandler            `json:"-"`
+	AdmissionRequestHandler handler.AdmissionRequestHandler `json:"-"`
+	InjectionRequestHandler handler.InjectionRequestHandler `json:"-"`
+
+	// Limiter limits the calls of the handler in flight. Used only by
+	// reconciler and finalizer.
+	Limiter *handler.Limiter `json:"-"`
+}
+
+// usesKRM returns whether the handler is an exec handler with the krm
+// encoding, which only handles the state of reconciler and finalizer.
+func (c *HandlerConfig) usesKRM() bool {
+	return c.Exec != nil && c.Exec.Encoding == EncodingKRM
+}
+
+func (c *HandlerConfig) Validate() error {
+	specified := 0
+
+	if c.Exec != nil {
+		specified++
+	}
+	if c.HTTP != nil {
+		specified++
+	}
+	// The handler of a plugin is set as an in-process handler by the
+	// manager.
+	if c.Plugin != nil || c.StateHandler != nil || c.AdmissionRequestHandler != nil || c.InjectionRequestHandler != nil {
+		specified++
+	}
+
+	if specified == 0 {
+		if c.HandlerRef != "" {
+			return fmt.Errorf("handlerRef %q is not resolved", c.HandlerRef)
+		}
+		return errors.New("handler must be specified")
+	}
+	if specified > 1 {
+		return errors.New("exactly one handler must be specified")
+	}
+
+	for i, f := range c.AllowedFields {
+		_, err := ParseFieldPath(f)
+		if err != nil {
+			return fmt.Errorf("invalid allowedFields[%d]: %v", i, err)
+		}
+	}
+
+	for i, f := range c.ImmutableFields {
+		_, err := ParseFieldPath(f)
+		if err != nil {
+			return fmt.Errorf("invalid immutableFields[%d]: %v", i, err)
+		}
+	}
+
+	if c.RecordMaxFiles < 0 {
+		return errors.New("recordMaxFiles must be greater than or equal to 0")
+	}
+	if c.RecordMaxFiles > 0 && c.RecordDir == "" {
+		return errors.New("recordMaxFiles requires recordDir")
+	}
+
+	if c.Exec != nil {
+		err := c.Exec.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.HTTP != nil {
+		err := c.HTTP.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Plugin != nil {
+		err := c.Plugin.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// timeout returns the name of timeout field and its value of
+// the specified handler.
+func (c *HandlerConfig) timeout() (string, string) {
+	if c.Exec != nil && c.Exec.Timeout != "" {
+		return "exec.timeout", c.Exec.Timeout
+	}
+
+	if c.HTTP != nil && c.HTTP.Timeout != "" {
+		return "http.timeout", c.HTTP.Timeout
+	}
+
+	return "", ""
+}
+
+type ExecHandlerConfig struct {
+	Command    string            `json:"command"`
+	Args       []string          `json:"args"`
+	Shell      string            `json:"shell,omitempty"`
+	WorkingDir string            `json:"workingDir"`
+	Env        map[string]string `json:"env"`
+	InheritEnv *bool             `json:"inheritEnv,omitempty"`
+	Timeout    string            `json:"timeout"`
+	Encoding   string            `json:"encoding"`
+	InputMode  string            `json:"inputMode,omitempty"`
+	OutputMode string            `json:"outputMode,omitempty"`
+	PreExec    []string          `json:"preExec,omitempty"`
+	PostExec   []string          `json:"postExec,omitempty"`
+	RunAsUser  *int              `json:"runAsUser,omitempty"`
+	RunAsGroup *int              `json:"runAsGroup,omitempty"`
+	Debug      bool              `json:"debug"`
+
+	// LogPassthrough logs the lines written to the file descriptor 3 by
+	// the command as they are written.
+	LogPassthrough bool `json:"logPassthrough,omitempty"`
+
+	RedactFields []string `json:"redactFields,omitempty"`
+
+	// EnvFromFields maps the names of environment variables to the JSON
+	// Path of the fields of the object whose values are passed.
+	EnvFromFields map[string]string `json:"envFromFields,omitempty"`
+
+	// ArgTemplates are the Go templates rendered with the object whose
+	// results are appended to Args. See ParseArgTemplate.
+	ArgTemplates []string `json:"argTemplates,omitempty"`
+
+	// SecurityProfile restricts the privileges of the command. This is
+	// only supported on Linux.
+	SecurityProfile *SecurityProfileConfig `json:"securityProfile,omitempty"`
+}
+
+// SecurityProfileConfig represents the restrictions of the privileges
+// of the handler command.
+type SecurityProfileConfig struct {
+	// NoNewPrivs sets the no_new_privs flag to the command, so that
+	// the command and its children never gain privileges by setuid
+	// binaries or file capabilities.
+	NoNewPrivs bool `json:"noNewPrivs,omitempty"`
+}
+
+func (c ExecHandlerConfig) Validate() error {
+	if c.Command == "" {
+		return errors.New("command must be specified")
+	}
+
+	if c.PreExec != nil && (len(c.PreExec) == 0 || c.PreExec[0] == "") {
+		return errors.New("preExec: command must be specified")
+	}
+
+	if c.PostExec != nil && (len(c.PostExec) == 0 || c.PostExec[0] == "") {
+		return errors.New("postExec: command must be specified")
+	}
+
+	if c.RunAsUser != nil && *c.RunAsUser < 0 {
+		return errors.New("runAsUser must be greater than or equal to 0")
+	}
+
+	if c.RunAsGroup != nil && *c.RunAsGroup < 0 {
+		return errors.New("runAsGroup must be greater than or equal to 0")
+	}
+
+	_, err := ParseRedactFields(c.RedactFields)
+	if err != nil {
+		return err
+	}
+
+	for name, path := range c.EnvFromFields {
+		if name == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("envFromFields: invalid name: %q", name)
+		}
+
+		_, ok := c.Env[name]
+		if ok {
+			return fmt.Errorf("envFromFields: %s is already specified in env", name)
+		}
+
+		err := jsonpath.New("env").Parse(fmt.Sprintf("{%s}", path))
+		if err != nil {
+			return fmt.Errorf("envFromFields: invalid path of %s: %v", name, err)
+		}
+	}
+
+	for i, t := range c.ArgTemplates {
+		_, err := ParseArgTemplate(t)
+		if err != nil {
+			return fmt.Errorf("invalid argTemplates[%d]: %v", i, err)
+		}
+	}
+
+	if strings.ContainsAny(c.Shell, " \t\n") {
+		return errors.New("shell must be a path to the shell interpreter")
+	}
+
+	switch c.Encoding {
+	case "", EncodingJSON, EncodingYAML, EncodingKRM:
+	default:
+		return fmt.Errorf("invalid encoding: %s", c.Encoding)
+	}
+
+	switch c.InputMode {
+	case "", InputModeStdin, InputModeArg, InputModeEnv:
+	default:
+		return fmt.Errorf("invalid input mode: %s", c.InputMode)
+	}
+
+	switch c.OutputMode {
+	case "", OutputModeStdout, OutputModeFile:
+	default:
+		return fmt.Errorf("invalid output mode: %s", c.OutputMode)
+	}
+
+	if c.Timeout != "" {
+		_, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+	}
+
+	return nil
+}
+
+type HTTPHandlerConfig struct {
+	URL     string            `json:"url"`
+	TLS     *TLSConfig        `json:"tls,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout string            `json:"timeout"`
+	Debug   bool              `json:"debug"`
+
+	Compression  string   `json:"compression,omitempty"`
+	UserAgent    string   `json:"userAgent,omitempty"`
+	RedactFields []string `json:"redactFields,omitempty"`
+
+	// SigningKeyFile is the path of the key file to sign the request
+	// body with HMAC-SHA256.
+	SigningKeyFile string `json:"signingKeyFile,omitempty"`
+
+	// SuccessStatusCodes is the list of the status codes of the
+	// response treated as success. Any 2xx code is success if empty.
+	SuccessStatusCodes []int `json:"successStatusCodes,omitempty"`
+
+	// RateLimiter limits the rate of the requests to the handler. It is
+	// shared by all HTTP handlers.
+	RateLimiter *rate.Limiter `json:"-"`
+}
+
+// HandlerRateLimit represents the rate limit of the requests to the
+// handlers with a token bucket.
+type HandlerRateLimit struct {
+	// RequestsPerSecond is the rate of the tokens added to the bucket.
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	// Burst is the size of the bucket. The default is 1.
+	Burst int `json:"burst,omitempty"`
+}
+
+func (c *HandlerRateLimit) Validate() error {
+	if c.RequestsPerSecond <= 0 {
+		return errors.New("requestsPerSecond must be greater than 0")
+	}
+
+	if c.Burst < 0 {
+		return errors.New("burst must be greater than or equal to 0")
+	}
+
+	return nil
+}
+
+func (c HTTPHandlerConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("url must be specified")
+	}
+
+	_, err := ParseURLTemplate(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+
+	switch c.Compression {
+	case "", CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("invalid compression: %s", c.Compression)
+	}
+
+	_, err = ParseRedactFields(c.RedactFields)
+	if err != nil {
+		return err
+	}
+
+	if c.UserAgent != "" && strings.TrimSpace(c.UserAgent) == "" {
+		return errors.New("userAgent must not be blank")
+	}
+
+	if c.TLS != nil {
+		err := c.TLS.Validate()
+		if err != nil {
+			return fmt.Errorf("tls: %v", err)
+		}
+	}
+
+	if c.SigningKeyFile != "" {
+		_, err := os.Stat(c.SigningKeyFile)
+		if err != nil {
+			return fmt.Errorf("invalid signingKeyFile: %v", err)
+		}
+	}
+
+	for _, code := range c.SuccessStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid successStatusCodes: %d", code)
+		}
+	}
+
+	if c.Timeout != "" {
+		_, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+	}
+
+	return nil
+}
+
+type FuncHandlerConfig struct {
+	Handler handler.Handler `json:"-"`
+}
+
+func (c *FuncHandlerConfig) Validate() error {
+	if c.Handler == nil {
+		return errors.New("handler must be specified")
+	}
+
+	return nil
+}
+
+// PluginHandlerConfig represents the handler registered by a Go plugin
+// in the plugin directory.
+type PluginHandlerConfig struct {
+	Name string `json:"name"`
+}
+
+func (c *PluginHandlerConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("plugin: name must be specified")
+	}
+
+	return nil
+}
+
+type ServerConfig struct {
+	Host string     `json:"host"`
+	Port int        `json:"port"`
+	TLS  *TLSConfig `json:"tls"`
+
+	ReadTimeout  string `json:"readTimeout,omitempty"`
+	WriteTimeout string `json:"writeTimeout,omitempty"`
+	IdleTimeout  string `json:"idleTimeout,omitempty"`
+
+	// CertReloadInterval is the interval of reloading the certificate
+	// in addition to the reload on change of the certificate files.
+	CertReloadInterval string `json:"certReloadInterval,omitempty"`
+
+	Default *DefaultWebhookConfig `json:"default,omitempty"`
+
+	// Hooks is the list of webhooks of resources served by the server.
+	// All webhooks are served if it is empty.
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+func (c *ServerConfig) Validate() error {
+	if c.Port == 0 {
+		return errors.New("port must be specified")
+	}
+
+	for _, hook := range c.Hooks {
+		switch hook {
+		case HookValidate, HookMutate, HookInject:
+		default:
+			return fmt.Errorf("invalid hook: %s", hook)
+		}
+	}
+
+	timeouts := map[string]string{
+		"readTimeout":  c.ReadTimeout,
+		"writeTimeout": c.WriteTimeout,
+		"idleTimeout":  c.IdleTimeout,
+	}
+	for name, t := range timeouts {
+		if t == "" {
+			continue
+		}
+
+		_, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+
+	if c.CertReloadInterval != "" {
+		interval, err := time.ParseDuration(c.CertReloadInterval)
+		if err != nil {
+			return fmt.Errorf("invalid certReloadInterval: %v", err)
+		}
+		if interval <= 0 {
+			return errors.New("certReloadInterval must be greater than 0")
+		}
+	}
+
+	if c.TLS != nil {
+		err := c.TLS.Validate()
+		if err != nil {
+			return fmt.Errorf("tls: %v", err)
+		}
+	}
+
+	if c.Default != nil {
+		err := c.Default.Validate()
+		if err != nil {
+			return fmt.Errorf("default: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Serves returns whether the server serves specified webhook of
+// resources.
+func (c *ServerConfig) Serves(hook string) bool {
+	if len(c.Hooks) == 0 {
+		return true
+	}
+
+	for _, h := range c.Hooks {
+		if h == hook {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MetricsConfig represents the configuration of metrics exporter.
+type MetricsConfig struct {
+	Exporter    string `json:"exporter,omitempty"`
+	BindAddress string `json:"bindAddress,omitempty"`
+	Endpoint    string `json:"endpoint,omitempty"`
+	Interval    string `json:"interval,omitempty"`
+
+	// FailOpen disables serving metrics instead of failing to start
+	// when the bind address is already in use.
+	FailOpen bool `json:"failOpen,omitempty"`
+
+	// Compress compresses the response of metrics with gzip if the
+	// client accepts it. Use Compresses to read this value.
+	Compress *bool `json:"compress,omitempty"`
+
+	// Prefix replaces the prefix of the names of the metrics of the
+	// controller. Use MetricsPrefix to read this value.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// metricsPrefixPattern is the pattern of the valid prefix of metric
+// names in Prometheus.
+var metricsPrefixPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+func (c *MetricsConfig) Validate() error {
+	switch c.Exporter {
+	case "", ExporterPrometheus:
+		if c.Endpoint != "" {
+			return errors.New("endpoint is not supported by prometheus exporter")
+		}
+	case ExporterOTLP:
+		if c.Endpoint == "" {
+			return errors.New("endpoint must be specified")
+		}
+		u, err := url.Parse(c.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint: %v", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid endpoint: unsupported scheme: %s", u.Scheme)
+		}
+	case ExporterStatsd:
+		if c.Endpoint == "" {
+			return errors.New("endpoint must be specified")
+		}
+		_, _, err := net.SplitHostPort(c.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint: %v", err)
+		}
+	default:
+		return fmt.Errorf("invalid exporter: %s", c.Exporter)
+	}
+
+	if c.FailOpen && c.Exporter != "" && c.Exporter != ExporterPrometheus {
+		return errors.New("failOpen is supported only by prometheus exporter")
+	}
+
+	if c.Compress != nil && c.Exporter != "" && c.Exporter != ExporterPrometheus {
+		return errors.New("compress is supported only by prometheus exporter")
+	}
+
+	if c.Interval != "" {
+		interval, err := time.ParseDuration(c.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid interval: %v", err)
+		}
+		if interval <= 0 {
+			return errors.New("interval must be greater than 0")
+		}
+	}
+
+	if c.Prefix != "" && (!metricsPrefixPattern.MatchString(c.Prefix) || strings.HasSuffix(c.Prefix, "_")) {
+		return fmt.Errorf("invalid prefix: %q", c.Prefix)
+	}
+
+	return nil
+}
+
+// MetricsPrefix returns the prefix of the names of the metrics of the
+// controller. It is DefaultMetricsPrefix unless configured.
+func (c *MetricsConfig) MetricsPrefix() string {
+	if c.Prefix == "" {
+		return DefaultMetricsPrefix
+	}
+	return c.Prefix
+}
+
+// Compresses returns whether the response of metrics is compressed.
+// It is compressed unless explicitly disabled.
+func (c *MetricsConfig) Compresses() bool {
+	return c.Compress == nil || *c.Compress
+}
+
+// HealthConfig represents the configuration of health probes.
+type HealthConfig struct {
+	BindAddress string `json:"bindAddress"`
+	HandlerPing bool   `json:"handlerPing,omitempty"`
+	PingTimeout string `json:"pingTimeout,omitempty"`
+}
+
+func (c *HealthConfig) Validate() error {
+	if c.BindAddress == "" {
+		return errors.New("bindAddress must be specified")
+	}
+
+	_, _, err := net.SplitHostPort(c.BindAddress)
+	if err != nil {
+		return fmt.Errorf("invalid bindAddress: %v", err)
+	}
+
+	if c.PingTimeout != "" {
+		timeout, err := time.ParseDuration(c.PingTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid pingTimeout: %v", err)
+		}
+		if timeout <= 0 {
+			return errors.New("pingTimeout must be greater than 0")
+		}
+	}
+
+	return nil
+}
+
+// DefaultWebhookConfig represents the handlers for the webhook requests
+// that do not match any resource.
+type DefaultWebhookConfig struct {
+	Validator *HandlerConfig `json:"validator,omitempty"`
+	Mutator   *HandlerConfig `json:"mutator,omitempty"`
+}
+
+func (c *DefaultWebhookConfig) Validate() error {
+	if c.Validator == nil && c.Mutator == nil {
+		return errors.New("validator or mutator must be specified")
+	}
+
+	if c.Validator != nil {
+		err := c.Validator.Validate()
+		if err != nil {
+			return fmt.Errorf("validator: %v", err)
+		}
+		if len(c.Validator.AllowedFields) > 0 {
+			return errors.New("validator: allowedFields is not supported")
+		}
+		if c.Validator.usesKRM() {
+			return errors.New("validator: krm encoding is not supported")
+		}
+	}
+
+	if c.Mutator != nil {
+		err := c.Mutator.Validate()
+		if err != nil {
+			return fmt.Errorf("mutator: %v", err)
+		}
+		if len(c.Mutator.ImmutableFields) > 0 {
+			return errors.New("mutator: immutableFields is not supported")
+		}
+		if c.Mutator.usesKRM() {
+			return errors.New("mutator: krm encoding is not supported")
+		}
+	}
+
+	return nil
+}
+
+type TLSConfig struct {
 	CertFile   string `json:"certFile"`
 	KeyFile    string `json:"keyFile"`
 	CACertFile string `json:"caCertFile"`
+
+	// CACertDir is the directory of the PEM files of CA certificates to
+	// be used in addition to CACertFile. Used only by HTTP handler.
+	CACertDir string `json:"caCertDir,omitempty"`
 }
 
 func (c *TLSConfig) Validate() error {
@@ -333,5 +2063,157 @@ func (c *TLSConfig) Validate() error {
 		return errors.New("certificate key file must be specified")
 	}
 
+	if c.CACertDir != "" {
+		info, err := os.Stat(c.CACertDir)
+		if err != nil {
+			return fmt.Errorf("invalid caCertDir: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid caCertDir: %s is not a directory", c.CACertDir)
+		}
+	}
+
 	return nil
 }
+
+// urlEscapeFunc is the name of the template function that escapes the
+// values rendered in the URL templates.
+const urlEscapeFunc = "_urlEscape"
+
+// ParseURLTemplate parses the URL of HTTP handler as a Go template
+// that is rendered with the object. Each value rendered by the template
+// is escaped as a path segment, so that the values of the object cannot
+// change the path or query of the URL. Rendering fails if the template
+// refers to a missing field.
+func ParseURLTemplate(u string) (*template.Template, error) {
+	funcs := template.FuncMap{urlEscapeFunc: urlEscape}
+
+	t, err := template.New("url").Funcs(funcs).Option("missingkey=error").Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			escapeNode(tt.Tree.Root)
+		}
+	}
+
+	return t, nil
+}
+
+// urlEscape escapes the values as a path segment of URL. The dot
+// segments are also escaped since they change the path.
+func urlEscape(v ...interface{}) string {
+	s := url.PathEscape(fmt.Sprint(v...))
+	if s == "." || s == ".." {
+		s = strings.Replace(s, ".", "%2E", -1)
+	}
+
+	return s
+}
+
+// escapeNode appends the escape function to the pipelines of all
+// actions that render a value in specified node.
+func escapeNode(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeNode(child)
+		}
+	case *parse.ActionNode:
+		// Actions that only declare variables render nothing.
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		cmd := &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(urlEscapeFunc).SetPos(n.Pos)},
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, cmd)
+	case *parse.IfNode:
+		escapeNode(n.List)
+		escapeNode(n.ElseList)
+	case *parse.RangeNode:
+		escapeNode(n.List)
+		escapeNode(n.ElseList)
+	case *parse.WithNode:
+		escapeNode(n.List)
+		escapeNode(n.ElseList)
+	}
+}
+
+// argSeparator separates the arguments rendered by an argument
+// template. NUL never appears in the arguments of a command.
+const argSeparator = "\x00"
+
+// ParseArgTemplate parses the argument template of exec handler as a
+// Go template that is rendered with the object. The rendered string is
+// a single argument, or is empty for no argument. The 'arg' function
+// renders its operands as a separate argument, so that a list field
+// can be expanded into multiple arguments with 'range', such as
+// '{{range .spec.items}}{{arg "--item=" .}}{{end}}'. Rendering fails
+// if the template refers to a missing field.
+func ParseArgTemplate(t string) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"arg": func(v ...interface{}) string {
+			return fmt.Sprint(v...) + argSeparator
+		},
+	}
+
+	return template.New("arg").Funcs(funcs).Option("missingkey=error").Parse(t)
+}
+
+// SplitArgs splits the string rendered by an argument template into
+// the arguments. Text outside 'arg' is a part of the next argument.
+func SplitArgs(rendered string) []string {
+	if rendered == "" {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(rendered, argSeparator), argSeparator)
+}
+
+// IsURLTemplate returns whether the URL contains template actions.
+func IsURLTemplate(u string) bool {
+	return strings.Contains(u, "{{")
+}
+
+// ParseRedactFields parses the field paths to be redacted in the debug
+// output of handlers.
+func ParseRedactFields(fields []string) ([][]string, error) {
+	parsed := [][]string{}
+	for i, f := range fields {
+		p, err := ParseFieldPath(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redactFields[%d]: %v", i, err)
+		}
+		parsed = append(parsed, p)
+	}
+
+	return parsed, nil
+}
+
+// ParseFieldPath parses a simple field path such as '.status.lastError'
+// and returns a list of field names.
+func ParseFieldPath(p string) ([]string, error) {
+	if !strings.HasPrefix(p, ".") {
+		return nil, errors.New("field path must start with '.'")
+	}
+
+	fields := strings.Split(strings.TrimPrefix(p, "."), ".")
+	for _, f := range fields {
+		if f == "" {
+			return nil, errors.New("field name must not be empty")
+		}
+		if strings.ContainsAny(f, "[]{}*@$") {
+			return nil, fmt.Errorf("unsupported field name: %s", f)
+		}
+	}
+
+	return fields, nil
+}
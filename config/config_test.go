@@ -1,6 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -8,6 +13,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+var (
+	enabled  = true
+	disabled = false
+)
+
 func TestConfigValidate(t *testing.T) {
 	var (
 		err error
@@ -38,6 +48,513 @@ func TestConfigValidate(t *testing.T) {
 	c.Webhook.Port = 0
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
+
+	// Multiple webhook servers
+	c = newTestConfig()
+	c.Webhook.Hooks = []string{HookValidate, HookInject}
+	c.Webhooks = []*ServerConfig{newTestServerConfig(8443, HookMutate)}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.WebhookServers()).To(HaveLen(2))
+
+	// Duplicate webhook port
+	c = newTestConfig()
+	c.Webhooks = []*ServerConfig{newTestServerConfig(443)}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid webhook server
+	c = newTestConfig()
+	c.Webhooks = []*ServerConfig{newTestServerConfig(0)}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Hook not served by any webhook server
+	c = newTestConfig()
+	c.Webhook.Hooks = []string{HookValidate, HookInject}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Duplicate webhooks of resource
+	c = newTestConfig()
+	c.Resources = append(c.Resources, newTestConfig().Resources[0])
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(Equal("duplicate validate hook of resource: example.com/v1alpha1, Kind=Test"))
+	Expect(c.validateAll()).To(HaveLen(3))
+
+	// Duplicate webhook of resource with kind in another case
+	c = newTestConfig()
+	c.Resources = append(c.Resources, newTestConfig().Resources[0])
+	c.Resources[1].Kind = "TEST"
+	c.Resources[1].Mutator = nil
+	c.Resources[1].Injector = nil
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Different webhooks of the same resource
+	c = newTestConfig()
+	c.Resources = append(c.Resources, newTestConfig().Resources[0])
+	c.Resources[0].Mutator = nil
+	c.Resources[0].Injector = nil
+	c.Resources[1].Validator = nil
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Webhooks of distinct resources
+	c = newTestConfig()
+	c.Resources = append(c.Resources, newTestConfig().Resources[0])
+	c.Resources[1].Kind = "Other"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Duplicate webhooks of disabled resource
+	c = newTestConfig()
+	c.Resources = append(c.Resources, newTestConfig().Resources[0])
+	c.Resources[1].Enabled = &disabled
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid client QPS
+	c = newTestConfig()
+	c.ClientQPS = -1
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid client burst
+	c = newTestConfig()
+	c.ClientBurst = -1
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Max in-flight handlers
+	c = newTestConfig()
+	c.MaxInFlightHandlers = 1
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// No limit of in-flight handlers
+	c = newTestConfig()
+	c.MaxInFlightHandlers = 0
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid max in-flight handlers
+	c = newTestConfig()
+	c.MaxInFlightHandlers = -1
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// HTTP rate limit
+	c = newTestConfig()
+	c.HTTPRateLimit = &HandlerRateLimit{RequestsPerSecond: 0.5, Burst: 2}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid HTTP rate limit
+	c = newTestConfig()
+	c.HTTPRateLimit = &HandlerRateLimit{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("invalid httpRateLimit"))
+
+	// Invalid HTTP rate limit burst
+	c = newTestConfig()
+	c.HTTPRateLimit = &HandlerRateLimit{RequestsPerSecond: 1, Burst: -1}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Mapper refresh interval
+	c = newTestConfig()
+	c.MapperRefreshInterval = "5m"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid mapper refresh interval
+	c = newTestConfig()
+	c.MapperRefreshInterval = "soon"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Non-positive mapper refresh interval
+	c = newTestConfig()
+	c.MapperRefreshInterval = "0s"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Startup delay
+	c = newTestConfig()
+	c.StartupDelay = "30s"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid startup delay
+	c = newTestConfig()
+	c.StartupDelay = "soon"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Negative startup delay
+	c = newTestConfig()
+	c.StartupDelay = "-1s"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Middlewares
+	c = newTestConfig()
+	c.Middlewares = []string{"logging", "metrics"}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Unknown middleware
+	c = newTestConfig()
+	c.Middlewares = []string{"tracing"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Duplicate middleware
+	c = newTestConfig()
+	c.Middlewares = []string{"logging", "logging"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// User agent
+	c = newTestConfig()
+	c.UserAgent = "test-agent/1.0"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Blank user agent
+	c = newTestConfig()
+	c.UserAgent = " "
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// No enabled resources
+	c = newTestConfig()
+	c.Resources[0].Enabled = &disabled
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Run once
+	c = newTestConfig()
+	c.RunMode = RunModeOnce
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid run mode
+	c = newTestConfig()
+	c.RunMode = "daemon"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Prune on startup
+	dryRun := false
+	c = newTestConfig()
+	c.Resources[0].Dependents[0].TrackingLabels = true
+	c.Resources[0].PruneOnStartup = &PruneConfig{DryRun: &dryRun}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Resources[0].PruneOnStartup.DryRuns()).To(BeFalse())
+
+	// Prune on startup of the dependents tracked by other resources
+	other := newTestConfig().Resources[0]
+	other.Kind = "Other"
+	other.Dependents[0].TrackingLabels = true
+	c.Resources = append(c.Resources, other)
+	err = c.Validate()
+	Expect(err).To(MatchError(ContainSubstring("pruneOnStartup")))
+}
+
+func TestConfigEnabledResources(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := newTestConfig()
+	c.Resources = append(c.Resources, newTestConfig().Resources[0], newTestConfig().Resources[0])
+	c.Resources[1].Enabled = &disabled
+	c.Resources[2].Enabled = &enabled
+	c.Resources[2].Kind = "Other"
+
+	resources := c.EnabledResources()
+	Expect(resources).To(HaveLen(2))
+	Expect(resources[0]).To(BeIdenticalTo(c.Resources[0]))
+	Expect(resources[1]).To(BeIdenticalTo(c.Resources[2]))
+
+	err := c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	f, err := ioutil.TempFile("", "config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  resyncPeriod: invalid
+- group: example.com
+  version: v1alpha1
+  kind: Test2
+  reconciler:
+    requeueAfter: 30s
+webhook:
+  port: 0
+`)
+	Expect(err).NotTo(HaveOccurred())
+	f.Close()
+
+	errs := ValidateConfigFile(f.Name())
+	Expect(errs).To(HaveLen(3))
+	Expect(errs[0].Error()).To(HavePrefix("resources[0]:"))
+	Expect(errs[1].Error()).To(HavePrefix("resources[1]:"))
+	Expect(errs[2].Error()).To(HavePrefix("webhook:"))
+
+	// Missing file
+	errs = ValidateConfigFile("/nonexistent/config.yaml")
+	Expect(errs).To(HaveLen(1))
+}
+
+func TestLoadFileWithProfile(t *testing.T) {
+	RegisterTestingT(t)
+
+	f, err := ioutil.TempFile("", "config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+name: test
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+webhook:
+  host: 127.0.0.1
+  port: 443
+profiles:
+  dev:
+    name: test-dev
+    webhook:
+      port: 8443
+  prod: {}
+`)
+	Expect(err).NotTo(HaveOccurred())
+	f.Close()
+
+	// Base only
+	c, err := LoadFileWithProfile(f.Name(), "")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Name).To(Equal("test"))
+	Expect(c.Webhook.Port).To(Equal(443))
+	Expect(c.Profiles).To(HaveLen(2))
+
+	// Merged profile
+	c, err = LoadFileWithProfile(f.Name(), "dev")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Name).To(Equal("test-dev"))
+	Expect(c.Resources).To(HaveLen(1))
+	Expect(c.Webhook.Host).To(Equal("127.0.0.1"))
+	Expect(c.Webhook.Port).To(Equal(8443))
+	Expect(c.Profiles).To(BeNil())
+
+	// Empty profile
+	c, err = LoadFileWithProfile(f.Name(), "prod")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Name).To(Equal("test"))
+	Expect(c.Webhook.Port).To(Equal(443))
+
+	// Unknown profile
+	_, err = LoadFileWithProfile(f.Name(), "staging")
+	Expect(err).To(HaveOccurred())
+
+	// Selected by the environment variable
+	os.Setenv(ProfileEnvVar, "dev")
+	defer os.Unsetenv(ProfileEnvVar)
+
+	c, err = LoadFile(f.Name())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Name).To(Equal("test-dev"))
+}
+
+func TestLoadFileWithDefaults(t *testing.T) {
+	RegisterTestingT(t)
+
+	f, err := ioutil.TempFile("", "config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+defaults:
+  resyncPeriod: 10m
+  reconciler:
+    timeout: 90s
+    maxRetries: 3
+    exec:
+      command: /bin/controller
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  reconciler: {}
+- group: example.com
+  version: v1alpha1
+  kind: Test2
+  resyncPeriod: 5m
+  reconciler:
+    maxRetries: 0
+    exec:
+      command: /bin/test2
+      args: ["reconcile"]
+profiles:
+  dev:
+    defaults:
+      reconciler:
+        timeout: 30s
+`)
+	Expect(err).NotTo(HaveOccurred())
+	f.Close()
+
+	c, err := LoadFileWithProfile(f.Name(), "")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Defaults).To(BeNil())
+	Expect(c.Resources).To(HaveLen(2))
+
+	// Defaults apply
+	r := c.Resources[0]
+	Expect(r.Kind).To(Equal("Test"))
+	Expect(r.ResyncPeriod).To(Equal("10m"))
+	Expect(r.Reconciler.Timeout).To(Equal("90s"))
+	Expect(r.Reconciler.MaxRetries).To(Equal(3))
+	Expect(r.Reconciler.Exec.Command).To(Equal("/bin/controller"))
+
+	// Per-resource values override
+	r = c.Resources[1]
+	Expect(r.Kind).To(Equal("Test2"))
+	Expect(r.ResyncPeriod).To(Equal("5m"))
+	Expect(r.Reconciler.Timeout).To(Equal("90s"))
+	Expect(r.Reconciler.MaxRetries).To(Equal(0))
+	Expect(r.Reconciler.Exec.Command).To(Equal("/bin/test2"))
+	Expect(r.Reconciler.Exec.Args).To(Equal([]string{"reconcile"}))
+
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Defaults of the profile
+	c, err = LoadFileWithProfile(f.Name(), "dev")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Resources[0].Reconciler.Timeout).To(Equal("30s"))
+	Expect(c.Resources[0].Reconciler.MaxRetries).To(Equal(3))
+
+	// Handler of another kind replaces the handler of the defaults
+	err = ioutil.WriteFile(f.Name(), []byte(`
+defaults:
+  reconciler:
+    timeout: 90s
+    exec:
+      command: /bin/controller
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  reconciler:
+    http:
+      url: http://127.0.0.1:8080/reconcile
+`), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	c, err = LoadFileWithProfile(f.Name(), "")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Resources[0].Reconciler.Exec).To(BeNil())
+	Expect(c.Resources[0].Reconciler.HTTP.URL).To(Equal("http://127.0.0.1:8080/reconcile"))
+	Expect(c.Resources[0].Reconciler.Timeout).To(Equal("90s"))
+
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Reconciler defaults do not add a reconciler to webhook-only resources
+	err = ioutil.WriteFile(f.Name(), []byte(`
+defaults:
+  reconciler:
+    timeout: 90s
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  validator:
+    exec:
+      command: /bin/validator
+`), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	c, err = LoadFileWithProfile(f.Name(), "")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Resources[0].Reconciler).To(BeNil())
+
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid defaults
+	err = ioutil.WriteFile(f.Name(), []byte(`
+defaults: []
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+`), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = LoadFileWithProfile(f.Name(), "")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestLoadFileWithFileRefs(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	configFile := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(configFile, []byte(fmt.Sprintf(`
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  reconciler:
+    http:
+      url: http://127.0.0.1:8080
+      headers:
+        Authorization: "Bearer ${file:%s}"
+  validator:
+    exec:
+      command: /bin/controller
+      env:
+        TOKEN: "${file:%s}"
+`, tokenFile, tokenFile)), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	c, err := LoadFileWithProfile(configFile, "")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Resources[0].Reconciler.HTTP.Headers["Authorization"]).To(Equal("Bearer secret-token"))
+	Expect(c.Resources[0].Validator.Exec.Env["TOKEN"]).To(Equal("secret-token"))
+
+	// Unreadable file
+	err = ioutil.WriteFile(configFile, []byte(`
+name: "${file:/nonexistent/token}"
+`), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = LoadFileWithProfile(configFile, "")
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("/nonexistent/token"))
 }
 
 func TestResourceConfigValidate(t *testing.T) {
@@ -65,12 +582,44 @@ func TestResourceConfigValidate(t *testing.T) {
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
+	// Invalid readiness path of dependents
+	c = newTestConfig().Resources[0]
+	c.Dependents[0].ReadinessPath = ".status["
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Dependent readiness without readiness path
+	c = newTestConfig().Resources[0]
+	c.DependentReadiness = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Dependent readiness with readiness path
+	c = newTestConfig().Resources[0]
+	c.DependentReadiness = true
+	c.Dependents[0].ReadinessPath = ".status.ready"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Scale validation without validator
+	c = newTestConfig().Resources[0]
+	c.ValidateScale = true
+	c.Validator = nil
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
 	// Invalid references
 	c = newTestConfig().Resources[0]
 	c.References[0].GroupVersionKind = schema.GroupVersionKind{}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
+	// Invalid watches
+	c = newTestConfig().Resources[0]
+	c.Watches[0].GroupVersionKind = schema.GroupVersionKind{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
 	// Invalid reconciler
 	c = newTestConfig().Resources[0]
 	c.Reconciler.HandlerConfig.Exec = nil
@@ -83,93 +632,719 @@ func TestResourceConfigValidate(t *testing.T) {
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
-	// Invalid resync period
+	// Finalizer timeout exceeds reconciler timeout
 	c = newTestConfig().Resources[0]
-	c.ResyncPeriod = "invalid"
+	c.Reconciler.Timeout = "60s"
+	c.Finalizer.Exec.Timeout = "90s"
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
-	// Invalid validator
+	// Reference debounce
 	c = newTestConfig().Resources[0]
-	c.Validator.Exec = nil
+	c.ReferenceDebounce = "5s"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid reference debounce
+	c = newTestConfig().Resources[0]
+	c.ReferenceDebounce = "invalid"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Negative reference debounce
+	c = newTestConfig().Resources[0]
+	c.ReferenceDebounce = "-5s"
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
-	// Invalid mutator
-	c = newTestConfig().Resources[0]
-	c.Mutator.Exec = nil
+	// Prune on startup
+	c = newTestConfig().Resources[0]
+	c.Dependents[0].TrackingLabels = true
+	c.PruneOnStartup = &PruneConfig{}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.PruneOnStartup.DryRuns()).To(BeTrue())
+
+	// Prune on startup without tracking labels
+	c = newTestConfig().Resources[0]
+	c.PruneOnStartup = &PruneConfig{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Prune on startup with additional resources
+	c = newTestConfig().Resources[0]
+	c.Dependents[0].TrackingLabels = true
+	c.AdditionalResources = []schema.GroupVersionKind{
+		{Group: "example.com", Version: "v1alpha1", Kind: "Other"},
+	}
+	c.PruneOnStartup = &PruneConfig{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Prune on startup of observer
+	c = newTestConfig().Resources[0]
+	c.Dependents[0].TrackingLabels = true
+	c.Reconciler.Observe = true
+	c.PruneOnStartup = &PruneConfig{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid resync period
+	c = newTestConfig().Resources[0]
+	c.ResyncPeriod = "invalid"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Resync max age
+	c = newTestConfig().Resources[0]
+	c.ResyncMaxAge = "24h"
+	c.ResyncAgeAnnotation = "example.com/updated-at"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid resync max age
+	c = newTestConfig().Resources[0]
+	c.ResyncMaxAge = "invalid"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Resync max age without resync period
+	c = newTestConfig().Resources[0]
+	c.ResyncPeriod = ""
+	c.ResyncMaxAge = "24h"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Resync age annotation without max age
+	c = newTestConfig().Resources[0]
+	c.ResyncAgeAnnotation = "example.com/updated-at"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Cluster-scoped resource
+	c = newTestConfig().Resources[0]
+	c.ClusterScoped = true
+	c.Injector = nil
+	for i := range c.References {
+		c.References[i].Namespace = "default"
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// References without namespace for cluster-scoped resource
+	c = newTestConfig().Resources[0]
+	c.ClusterScoped = true
+	c.Injector = nil
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// References with namespace for namespaced resource
+	c = newTestConfig().Resources[0]
+	c.References[0].Namespace = "default"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Injector for cluster-scoped resource
+	c = newTestConfig().Resources[0]
+	c.ClusterScoped = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid key field path
+	c = newTestConfig().Resources[0]
+	c.KeyFieldPath = "spec.orderId"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Predicate
+	c = newTestConfig().Resources[0]
+	c.Predicate = "object.spec.replicas > 0"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid predicate
+	c = newTestConfig().Resources[0]
+	c.Predicate = "object.spec.replicas >"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("invalid predicate"))
+
+	// Predicate with undeclared variable
+	c = newTestConfig().Resources[0]
+	c.Predicate = "self.spec.replicas > 0"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid validator
+	c = newTestConfig().Resources[0]
+	c.Validator.Exec = nil
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid mutator
+	c = newTestConfig().Resources[0]
+	c.Mutator.Exec = nil
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid injector
+	c = newTestConfig().Resources[0]
+	c.Injector.Exec = nil
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Allowed fields of mutator
+	c = newTestConfig().Resources[0]
+	c.Mutator.AllowedFields = []string{".metadata.labels"}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Allowed fields of validator
+	c = newTestConfig().Resources[0]
+	c.Validator.AllowedFields = []string{".metadata.labels"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Immutable fields of validator
+	c = newTestConfig().Resources[0]
+	c.Validator.ImmutableFields = []string{".spec.storageClass"}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Immutable fields of mutator
+	c = newTestConfig().Resources[0]
+	c.Mutator.ImmutableFields = []string{".spec.storageClass"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Typed validator and mutator
+	for _, gvk := range TypedKinds {
+		c = newTestConfig().Resources[0]
+		c.GroupVersionKind = gvk
+		c.Validator.Typed = true
+		c.Mutator.Typed = true
+		err = c.Validate()
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// Typed validator of the kind that is not typed
+	c = newTestConfig().Resources[0]
+	c.Validator.Typed = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Typed mutator of the kind that is not typed
+	c = newTestConfig().Resources[0]
+	c.GroupVersionKind = schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+	c.Mutator.Typed = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Typed injector
+	c = newTestConfig().Resources[0]
+	c.Injector.Typed = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Templated URL of injector
+	c = newTestConfig().Resources[0]
+	c.Injector.Exec = nil
+	c.Injector.HTTP = &HTTPHandlerConfig{URL: "http://{{.metadata.namespace}}.example.com/inject"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Static URL of injector
+	c.Injector.HTTP.URL = "http://example.com/inject"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// KRM encoding of reconciler and finalizer
+	c = newTestConfig().Resources[0]
+	c.Reconciler.Exec.Encoding = EncodingKRM
+	c.Finalizer.Exec.Encoding = EncodingKRM
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// KRM encoding of validator
+	c = newTestConfig().Resources[0]
+	c.Validator.Exec.Encoding = EncodingKRM
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// KRM encoding of mutator
+	c = newTestConfig().Resources[0]
+	c.Mutator.Exec.Encoding = EncodingKRM
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// KRM encoding of injector
+	c = newTestConfig().Resources[0]
+	c.Injector.Exec.Encoding = EncodingKRM
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Additional resources
+	c = newTestConfig().Resources[0]
+	c.AdditionalResources = []schema.GroupVersionKind{
+		{Group: "example.com", Version: "v1alpha1", Kind: "Other"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Additional resources without reconciler
+	c = newTestConfig().Resources[0]
+	c.AdditionalResources = []schema.GroupVersionKind{
+		{Group: "example.com", Version: "v1alpha1", Kind: "Other"},
+	}
+	c.Reconciler = nil
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Empty additional resource
+	c = newTestConfig().Resources[0]
+	c.AdditionalResources = []schema.GroupVersionKind{
+		{Group: "example.com", Kind: "Other"},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Duplicate additional resource
+	c = newTestConfig().Resources[0]
+	c.AdditionalResources = []schema.GroupVersionKind{c.GroupVersionKind}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("duplicate resource"))
+}
+
+func TestResourceConfigReconciledResources(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := newTestConfig().Resources[0]
+	Expect(c.ReconciledResources()).To(Equal([]*ResourceConfig{c}))
+
+	other := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Other"}
+	c.AdditionalResources = []schema.GroupVersionKind{other}
+
+	resources := c.ReconciledResources()
+	Expect(resources).To(HaveLen(2))
+	Expect(resources[0]).To(BeIdenticalTo(c))
+
+	rc := resources[1]
+	Expect(rc.GroupVersionKind).To(Equal(other))
+	Expect(rc.AdditionalResources).To(BeNil())
+	Expect(rc.Reconciler).To(BeIdenticalTo(c.Reconciler))
+	Expect(rc.Dependents).To(Equal(c.Dependents))
+	Expect(rc.Validator).To(BeNil())
+	Expect(rc.Mutator).To(BeNil())
+	Expect(rc.Injector).To(BeNil())
+
+	// The original is not changed
+	Expect(c.GroupVersionKind.Kind).To(Equal("Test"))
+	Expect(c.Validator).NotTo(BeNil())
+}
+
+func TestDependentConfigValidate(t *testing.T) {
+	var (
+		err error
+		c   DependentConfig
+	)
+
+	RegisterTestingT(t)
+
+	// Valid
+	c = newTestConfig().Resources[0].Dependents[0]
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Empty GVK
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.GroupVersionKind = schema.GroupVersionKind{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Valid readiness path
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.ReadinessPath = `.status.conditions[?(@.type=="Ready")].status`
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid readiness path
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.ReadinessPath = ".status.conditions[?(@.type"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Plain owner reference
+	controller := false
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.ControllerOwnerRef = &controller
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.IsControllerOwnerRef()).To(BeFalse())
+
+	// Owner reference for orphan dependent
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.Orphan = true
+	c.ControllerOwnerRef = &controller
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Tracking labels
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.TrackingLabels = true
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Tracking labels for orphan dependent
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.TrackingLabels = true
+	c.Orphan = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Tracking labels with owner reference
+	c = newTestConfig().Resources[0].Dependents[0]
+	c.TrackingLabels = true
+	c.ControllerOwnerRef = &controller
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+}
+
+func TestReferenceConfigValidate(t *testing.T) {
+	var (
+		err error
+		c   ReferenceConfig
+	)
+
+	RegisterTestingT(t)
+
+	// Valid
+	c = newTestConfig().Resources[0].References[0]
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Empty GVK
+	c = newTestConfig().Resources[0].References[0]
+	c.GroupVersionKind = schema.GroupVersionKind{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Empty name field path
+	c = newTestConfig().Resources[0].References[0]
+	c.NameFieldPath = ""
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+}
+
+func TestWatchConfigValidate(t *testing.T) {
+	var (
+		err error
+		c   WatchConfig
+	)
+
+	RegisterTestingT(t)
+
+	// Valid
+	c = newTestConfig().Resources[0].Watches[0]
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Empty GVK
+	c = newTestConfig().Resources[0].Watches[0]
+	c.GroupVersionKind = schema.GroupVersionKind{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Empty name field path
+	c = newTestConfig().Resources[0].Watches[0]
+	c.NameFieldPath = ""
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+}
+
+func TestReconcilerConfigValidate(t *testing.T) {
+	var (
+		err error
+		c   *ReconcilerConfig
+	)
+
+	RegisterTestingT(t)
+
+	// Valid
+	c = newTestConfig().Resources[0].Reconciler
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid requeue after
+	c = newTestConfig().Resources[0].Reconciler
+	c.RequeueAfter = "invalid"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid max retries
+	c = newTestConfig().Resources[0].Reconciler
+	c.MaxRetries = -1
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Conflict retries
+	c = newTestConfig().Resources[0].Reconciler
+	c.ConflictRetries = 3
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid conflict retries
+	c = newTestConfig().Resources[0].Reconciler
+	c.ConflictRetries = -1
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid timeout
+	c = newTestConfig().Resources[0].Reconciler
+	c.Timeout = "invalid"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Dynamic objects
+	c = newTestConfig().Resources[0].Reconciler
+	c.AllowDynamicObjects = true
+	c.DynamicObjectKinds = []schema.GroupVersionKind{
+		{Group: "", Version: "v1", Kind: "ConfigMap"},
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Dynamic objects without kinds
+	c = newTestConfig().Resources[0].Reconciler
+	c.AllowDynamicObjects = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Dynamic object kinds without allowDynamicObjects
+	c = newTestConfig().Resources[0].Reconciler
+	c.DynamicObjectKinds = []schema.GroupVersionKind{{Version: "v1", Kind: "ConfigMap"}}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid dynamic object kind
+	c = newTestConfig().Resources[0].Reconciler
+	c.AllowDynamicObjects = true
+	c.DynamicObjectKinds = []schema.GroupVersionKind{{Group: "apps", Kind: "Deployment"}}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Duplicate dynamic object kind
+	c = newTestConfig().Resources[0].Reconciler
+	c.AllowDynamicObjects = true
+	c.DynamicObjectKinds = []schema.GroupVersionKind{
+		{Version: "v1", Kind: "ConfigMap"},
+		{Version: "v1", Kind: "ConfigMap"},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Dynamic objects with observe
+	c = newTestConfig().Resources[0].Reconciler
+	c.Observe = true
+	c.AllowDynamicObjects = true
+	c.DynamicObjectKinds = []schema.GroupVersionKind{{Version: "v1", Kind: "ConfigMap"}}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Handler timeout within reconciler timeout
+	c = newTestConfig().Resources[0].Reconciler
+	c.Timeout = "30s"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Handler timeout exceeds reconciler timeout
+	c = newTestConfig().Resources[0].Reconciler
+	c.Timeout = "10s"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("exec.timeout"))
+	Expect(err.Error()).To(ContainSubstring("reconciler timeout"))
+
+	// Timeout field path
+	c = newTestConfig().Resources[0].Reconciler
+	c.TimeoutFieldPath = ".spec.timeout"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid timeout field path
+	c = newTestConfig().Resources[0].Reconciler
+	c.TimeoutFieldPath = ".spec[timeout"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("invalid timeoutFieldPath"))
+
+	// Invalid status error field
+	c = newTestConfig().Resources[0].Reconciler
+	c.StatusErrorField = "status.lastError"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Reference cache TTL
+	c = newTestConfig().Resources[0].Reconciler
+	c.ReferenceCacheTTL = "10s"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid reference cache TTL
+	c = newTestConfig().Resources[0].Reconciler
+	c.ReferenceCacheTTL = "10"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Negative reference cache TTL
+	c = newTestConfig().Resources[0].Reconciler
+	c.ReferenceCacheTTL = "-10s"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Include events
+	c = newTestConfig().Resources[0].Reconciler
+	c.IncludeEvents = 5
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Negative include events
+	c = newTestConfig().Resources[0].Reconciler
+	c.IncludeEvents = -1
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Output schema
+	c = newTestConfig().Resources[0].Reconciler
+	c.OutputSchema = map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"spec"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid output schema
+	c = newTestConfig().Resources[0].Reconciler
+	c.OutputSchema = map[string]interface{}{"type": "map"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("invalid outputSchema"))
+
+	// Server dry-run
+	c = newTestConfig().Resources[0].Reconciler
+	c.ServerDryRun = true
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Server dry-run with observe
+	c = newTestConfig().Resources[0].Reconciler
+	c.ServerDryRun = true
+	c.Observe = true
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Result sink
+	c = newTestConfig().Resources[0].Reconciler
+	c.ResultSink = &ResultSinkConfig{
+		HTTP: &HTTPSinkConfig{URL: "http://127.0.0.1:8080/results", Timeout: "5s"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// No result sink
+	c = newTestConfig().Resources[0].Reconciler
+	c.ResultSink = &ResultSinkConfig{}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("invalid resultSink"))
+
+	// Invalid result sink URL
+	for _, u := range []string{"", "127.0.0.1:8080", "ftp://127.0.0.1/results", "http://"} {
+		c = newTestConfig().Resources[0].Reconciler
+		c.ResultSink = &ResultSinkConfig{HTTP: &HTTPSinkConfig{URL: u}}
+		err = c.Validate()
+		Expect(err).To(HaveOccurred(), u)
+	}
 
-	// Invalid injector
-	c = newTestConfig().Resources[0]
-	c.Injector.Exec = nil
+	// Invalid result sink timeout
+	c = newTestConfig().Resources[0].Reconciler
+	c.ResultSink = &ResultSinkConfig{
+		HTTP: &HTTPSinkConfig{URL: "http://127.0.0.1:8080/results", Timeout: "0s"},
+	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
-}
-
-func TestDependentConfigValidate(t *testing.T) {
-	var (
-		err error
-		c   DependentConfig
-	)
-
-	RegisterTestingT(t)
 
-	// Valid
-	c = newTestConfig().Resources[0].Dependents[0]
+	// Shadow handler
+	c = newTestConfig().Resources[0].Reconciler
+	c.Shadow = &HandlerConfig{
+		Exec: &ExecHandlerConfig{Command: "/bin/controller-v2"},
+	}
 	err = c.Validate()
 	Expect(err).NotTo(HaveOccurred())
 
-	// Empty GVK
-	c = newTestConfig().Resources[0].Dependents[0]
-	c.GroupVersionKind = schema.GroupVersionKind{}
+	// Invalid shadow handler
+	c = newTestConfig().Resources[0].Reconciler
+	c.Shadow = &HandlerConfig{}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
-}
-
-func TestReferenceConfigValidate(t *testing.T) {
-	var (
-		err error
-		c   ReferenceConfig
-	)
-
-	RegisterTestingT(t)
 
-	// Valid
-	c = newTestConfig().Resources[0].References[0]
+	// Shadow handler with allowed fields
+	c = newTestConfig().Resources[0].Reconciler
+	c.Shadow = &HandlerConfig{
+		Exec:          &ExecHandlerConfig{Command: "/bin/controller-v2"},
+		AllowedFields: []string{".status"},
+	}
 	err = c.Validate()
-	Expect(err).NotTo(HaveOccurred())
+	Expect(err).To(HaveOccurred())
 
-	// Empty GVK
-	c = newTestConfig().Resources[0].References[0]
-	c.GroupVersionKind = schema.GroupVersionKind{}
+	// Shadow handler with observe
+	c = newTestConfig().Resources[0].Reconciler
+	c.Observe = true
+	c.Shadow = &HandlerConfig{
+		Exec: &ExecHandlerConfig{Command: "/bin/controller-v2"},
+	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
-	// Empty name field path
-	c = newTestConfig().Resources[0].References[0]
-	c.NameFieldPath = ""
+	// Shadow handler timeout exceeds reconciler timeout
+	c = newTestConfig().Resources[0].Reconciler
+	c.Timeout = "30s"
+	c.Shadow = &HandlerConfig{
+		Exec: &ExecHandlerConfig{Command: "/bin/controller-v2", Timeout: "60s"},
+	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
-}
 
-func TestReconcilerConfigValidate(t *testing.T) {
-	var (
-		err error
-		c   *ReconcilerConfig
-	)
+	// Notify handler
+	c = newTestConfig().Resources[0].Reconciler
+	c.Notify = &HandlerConfig{
+		HTTP: &HTTPHandlerConfig{URL: "http://127.0.0.1:8080/notify"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
 
-	RegisterTestingT(t)
+	// Invalid notify handler
+	c = newTestConfig().Resources[0].Reconciler
+	c.Notify = &HandlerConfig{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
 
-	// Valid
+	// Notify handler with allowed fields
 	c = newTestConfig().Resources[0].Reconciler
+	c.Notify = &HandlerConfig{
+		Exec:          &ExecHandlerConfig{Command: "/bin/notify"},
+		AllowedFields: []string{".status"},
+	}
 	err = c.Validate()
-	Expect(err).NotTo(HaveOccurred())
+	Expect(err).To(HaveOccurred())
 
-	// Invalid requeue after
+	// Notify handler with observe
 	c = newTestConfig().Resources[0].Reconciler
-	c.RequeueAfter = "invalid"
+	c.Observe = true
+	c.Notify = &HandlerConfig{
+		Exec: &ExecHandlerConfig{Command: "/bin/notify"},
+	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
@@ -180,6 +1355,17 @@ func TestReconcilerConfigValidate(t *testing.T) {
 	Expect(err).To(HaveOccurred())
 }
 
+func TestReconcilerConfigSkipsDeletionWithoutFinalizer(t *testing.T) {
+	RegisterTestingT(t)
+
+	skip := false
+	c := &ReconcilerConfig{}
+	Expect(c.SkipsDeletionWithoutFinalizer()).To(BeTrue())
+
+	c.SkipDeletionWithoutFinalizer = &skip
+	Expect(c.SkipsDeletionWithoutFinalizer()).To(BeFalse())
+}
+
 func TestInjectorConfigValidate(t *testing.T) {
 	var (
 		err error
@@ -199,6 +1385,24 @@ func TestInjectorConfigValidate(t *testing.T) {
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
+	// Replay window
+	c = newTestConfig().Resources[0].Injector
+	c.ReplayWindow = "5m"
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid replay window
+	c = newTestConfig().Resources[0].Injector
+	c.ReplayWindow = "5"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Negative replay window
+	c = newTestConfig().Resources[0].Injector
+	c.ReplayWindow = "-5m"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
 	// Invalid handler
 	c = newTestConfig().Resources[0].Injector
 	c.HandlerConfig.Exec = nil
@@ -254,6 +1458,73 @@ func TestHandlerConfig(t *testing.T) {
 	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
+
+	// Allowed fields
+	c = &HandlerConfig{
+		Exec:          &ExecHandlerConfig{Command: "/bin/controller"},
+		AllowedFields: []string{".status", ".metadata.labels"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid allowed fields
+	c = &HandlerConfig{
+		Exec:          &ExecHandlerConfig{Command: "/bin/controller"},
+		AllowedFields: []string{".spec.containers[*]"},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid immutable fields
+	c = &HandlerConfig{
+		Exec:            &ExecHandlerConfig{Command: "/bin/controller"},
+		ImmutableFields: []string{".spec.containers[*]"},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	dir, err := ioutil.TempDir("", "record")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	// Record directory
+	c = &HandlerConfig{
+		Exec:           &ExecHandlerConfig{Command: "/bin/controller"},
+		RecordDir:      dir,
+		RecordMaxFiles: 10,
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Nothing is left in the record directory by validation
+	files, err := ioutil.ReadDir(dir)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(files).To(BeEmpty())
+
+	// Record directory is not accessed by validation
+	c = &HandlerConfig{
+		Exec:      &ExecHandlerConfig{Command: "/bin/controller"},
+		RecordDir: filepath.Join(dir, "missing"),
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Negative record max files
+	c = &HandlerConfig{
+		Exec:           &ExecHandlerConfig{Command: "/bin/controller"},
+		RecordDir:      dir,
+		RecordMaxFiles: -1,
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Record max files without record directory
+	c = &HandlerConfig{
+		Exec:           &ExecHandlerConfig{Command: "/bin/controller"},
+		RecordMaxFiles: 10,
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
 }
 
 func TestExecHandlerConfig(t *testing.T) {
@@ -262,30 +1533,232 @@ func TestExecHandlerConfig(t *testing.T) {
 		c   *ExecHandlerConfig
 	)
 
-	// Valid
+	// Valid
+	c = &ExecHandlerConfig{
+		Command:    "/bin/controller",
+		Args:       []string{"command"},
+		WorkingDir: "",
+		Env:        map[string]string{},
+		Timeout:    "30s",
+		Debug:      true,
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid command
+	c = &ExecHandlerConfig{
+		Command: "",
+		Timeout: "30s",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Env from fields
+	c = &ExecHandlerConfig{
+		Command: "/bin/controller",
+		EnvFromFields: map[string]string{
+			"NAME":     ".metadata.name",
+			"REPLICAS": ".spec.replicas",
+		},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid env from fields path
+	c = &ExecHandlerConfig{
+		Command:       "/bin/controller",
+		EnvFromFields: map[string]string{"NAME": ".metadata.name["},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid env from fields name
+	c = &ExecHandlerConfig{
+		Command:       "/bin/controller",
+		EnvFromFields: map[string]string{"A=B": ".metadata.name"},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Env from fields conflicts with env
+	c = &ExecHandlerConfig{
+		Command:       "/bin/controller",
+		Env:           map[string]string{"NAME": "test"},
+		EnvFromFields: map[string]string{"NAME": ".metadata.name"},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Arg templates
+	c = &ExecHandlerConfig{
+		Command:      "/bin/controller",
+		ArgTemplates: []string{`{{range .spec.items}}{{arg "--item=" .}}{{end}}`},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid arg templates
+	c = &ExecHandlerConfig{
+		Command:      "/bin/controller",
+		ArgTemplates: []string{`{{range .spec.items}}`},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Arg templates with unknown function
+	c = &ExecHandlerConfig{
+		Command:      "/bin/controller",
+		ArgTemplates: []string{`{{args .spec.items}}`},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid timeout
+	c = &ExecHandlerConfig{
+		Command: "/bin/controller",
+		Timeout: "invalid",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Shell
+	c = &ExecHandlerConfig{
+		Command: "cat | tee /dev/stderr",
+		Shell:   "/bin/sh",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid shell
+	c = &ExecHandlerConfig{
+		Command: "cat",
+		Shell:   "/bin/sh -c",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// YAML encoding
+	c = &ExecHandlerConfig{
+		Command:  "/bin/controller",
+		Encoding: "yaml",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// KRM encoding
+	c = &ExecHandlerConfig{
+		Command:  "/bin/controller",
+		Encoding: "krm",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid encoding
+	c = &ExecHandlerConfig{
+		Command:  "/bin/controller",
+		Encoding: "xml",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Pre-exec and post-exec commands
+	c = &ExecHandlerConfig{
+		Command:  "/bin/controller",
+		PreExec:  []string{"/bin/setup", "--verbose"},
+		PostExec: []string{"/bin/teardown"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Empty pre-exec command
+	c = &ExecHandlerConfig{
+		Command: "/bin/controller",
+		PreExec: []string{},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Empty post-exec command
+	c = &ExecHandlerConfig{
+		Command:  "/bin/controller",
+		PostExec: []string{""},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Run as user and group
+	id := 1000
+	c = &ExecHandlerConfig{
+		Command:    "/bin/controller",
+		RunAsUser:  &id,
+		RunAsGroup: &id,
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Negative user and group
+	negative := -1
+	c = &ExecHandlerConfig{
+		Command:   "/bin/controller",
+		RunAsUser: &negative,
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	c = &ExecHandlerConfig{
+		Command:    "/bin/controller",
+		RunAsGroup: &negative,
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Base64 input modes
+	for _, mode := range []string{InputModeArg, InputModeEnv} {
+		c = &ExecHandlerConfig{
+			Command:   "/bin/controller",
+			InputMode: mode,
+		}
+		err = c.Validate()
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// Invalid input mode
+	c = &ExecHandlerConfig{
+		Command:   "/bin/controller",
+		InputMode: "file",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// File output mode
 	c = &ExecHandlerConfig{
 		Command:    "/bin/controller",
-		Args:       []string{"command"},
-		WorkingDir: "",
-		Env:        map[string]string{},
-		Timeout:    "30s",
-		Debug:      true,
+		OutputMode: "file",
 	}
 	err = c.Validate()
 	Expect(err).NotTo(HaveOccurred())
 
-	// Invalid command
+	// Invalid output mode
 	c = &ExecHandlerConfig{
-		Command: "",
-		Timeout: "30s",
+		Command:    "/bin/controller",
+		OutputMode: "socket",
 	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
-	// Invalid timeout
+	// Redact fields
 	c = &ExecHandlerConfig{
-		Command: "/bin/controller",
-		Timeout: "invalid",
+		Command:      "/bin/controller",
+		Debug:        true,
+		RedactFields: []string{".object.spec.password"},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid redact fields
+	c = &ExecHandlerConfig{
+		Command:      "/bin/controller",
+		RedactFields: []string{"object.spec.password"},
 	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
@@ -309,6 +1782,76 @@ func TestHTTPHandlerConfig(t *testing.T) {
 	err = c.Validate()
 	Expect(err).NotTo(HaveOccurred())
 
+	// Gzip compression
+	c = &HTTPHandlerConfig{
+		URL:         "http://127.0.0.1:8080",
+		Compression: "gzip",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Templated URL
+	c = &HTTPHandlerConfig{
+		URL: "http://127.0.0.1:8080/{{.metadata.namespace}}",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid URL template
+	c = &HTTPHandlerConfig{
+		URL: "http://127.0.0.1:8080/{{.metadata.namespace",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Missing signing key file
+	c = &HTTPHandlerConfig{
+		URL:            "http://127.0.0.1:8080",
+		SigningKeyFile: "/nonexistent/signing-key",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Blank user agent
+	c = &HTTPHandlerConfig{
+		URL:       "http://127.0.0.1:8080",
+		UserAgent: " ",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid compression
+	c = &HTTPHandlerConfig{
+		URL:         "http://127.0.0.1:8080",
+		Compression: "br",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Success status codes
+	c = &HTTPHandlerConfig{
+		URL:                "http://127.0.0.1:8080",
+		SuccessStatusCodes: []int{200, 204, 302},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid redact fields
+	c = &HTTPHandlerConfig{
+		URL:          "http://127.0.0.1:8080",
+		RedactFields: []string{".object..password"},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid success status code
+	c = &HTTPHandlerConfig{
+		URL:                "http://127.0.0.1:8080",
+		SuccessStatusCodes: []int{200, 600},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
 	// Invalid URL
 	c = &HTTPHandlerConfig{
 		URL:     "",
@@ -357,6 +1900,37 @@ func TestServerConfig(t *testing.T) {
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
 
+	// Invalid timeout
+	c = &ServerConfig{
+		Host: "127.0.0.1",
+		Port: 443,
+		TLS: &TLSConfig{
+			CertFile: "server.pem",
+			KeyFile:  "server-key.pem",
+		},
+		ReadTimeout: "invalid",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid hook
+	c = newTestServerConfig(443, "convert")
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid cert reload interval
+	c = &ServerConfig{
+		Host: "127.0.0.1",
+		Port: 443,
+		TLS: &TLSConfig{
+			CertFile: "server.pem",
+			KeyFile:  "server-key.pem",
+		},
+		CertReloadInterval: "0s",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
 	// Invalid TLS config
 	c = &ServerConfig{
 		Host: "127.0.0.1",
@@ -367,6 +1941,233 @@ func TestServerConfig(t *testing.T) {
 	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
+
+	// Valid default handler
+	c = &ServerConfig{
+		Port: 443,
+		Default: &DefaultWebhookConfig{
+			Validator: &HandlerConfig{
+				Exec: &ExecHandlerConfig{Command: "/bin/validator"},
+			},
+		},
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// No default handler
+	c = &ServerConfig{
+		Port:    443,
+		Default: &DefaultWebhookConfig{},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid default handler
+	c = &ServerConfig{
+		Port: 443,
+		Default: &DefaultWebhookConfig{
+			Mutator: &HandlerConfig{},
+		},
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+}
+
+func TestMetricsConfig(t *testing.T) {
+	var (
+		err error
+		c   *MetricsConfig
+	)
+
+	RegisterTestingT(t)
+
+	// Valid
+	c = &MetricsConfig{BindAddress: ":8080"}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// OTLP
+	c = &MetricsConfig{
+		Exporter: ExporterOTLP,
+		Endpoint: "http://127.0.0.1:4318/v1/metrics",
+		Interval: "10s",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Statsd
+	c = &MetricsConfig{
+		Exporter: ExporterStatsd,
+		Endpoint: "127.0.0.1:8125",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Invalid exporter
+	c = &MetricsConfig{Exporter: "influxdb"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Endpoint with prometheus
+	c = &MetricsConfig{
+		Exporter: ExporterPrometheus,
+		Endpoint: "127.0.0.1:8125",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Missing OTLP endpoint
+	c = &MetricsConfig{Exporter: ExporterOTLP}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid OTLP endpoint
+	c = &MetricsConfig{
+		Exporter: ExporterOTLP,
+		Endpoint: "127.0.0.1:4318",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Missing statsd endpoint
+	c = &MetricsConfig{Exporter: ExporterStatsd}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid statsd endpoint
+	c = &MetricsConfig{
+		Exporter: ExporterStatsd,
+		Endpoint: "127.0.0.1",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid interval
+	c = &MetricsConfig{
+		Exporter: ExporterStatsd,
+		Endpoint: "127.0.0.1:8125",
+		Interval: "0s",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Fail open
+	c = &MetricsConfig{BindAddress: ":8080", FailOpen: true}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Fail open with push exporter
+	c = &MetricsConfig{
+		Exporter: ExporterStatsd,
+		Endpoint: "127.0.0.1:8125",
+		FailOpen: true,
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Compression is enabled by default
+	c = &MetricsConfig{}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Compresses()).To(BeTrue())
+
+	// Compression is disabled
+	compress := false
+	c = &MetricsConfig{Compress: &compress}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Compresses()).To(BeFalse())
+
+	// Compression with push exporter
+	c = &MetricsConfig{
+		Exporter: ExporterStatsd,
+		Endpoint: "127.0.0.1:8125",
+		Compress: &compress,
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Default prefix
+	c = &MetricsConfig{}
+	Expect(c.MetricsPrefix()).To(Equal(DefaultMetricsPrefix))
+
+	// Custom prefix
+	c = &MetricsConfig{Prefix: "my_app"}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.MetricsPrefix()).To(Equal("my_app"))
+
+	// Invalid prefixes
+	for _, prefix := range []string{"my-app", "1app", "app_", "my app"} {
+		c = &MetricsConfig{Prefix: prefix}
+		err = c.Validate()
+		Expect(err).To(HaveOccurred(), prefix)
+	}
+}
+
+func TestHealthConfig(t *testing.T) {
+	var (
+		err error
+		c   *HealthConfig
+	)
+
+	RegisterTestingT(t)
+
+	// Valid
+	c = &HealthConfig{
+		BindAddress: ":8081",
+		HandlerPing: true,
+		PingTimeout: "1s",
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// No bind address
+	c = &HealthConfig{}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid bind address
+	c = &HealthConfig{BindAddress: "8081"}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// Invalid ping timeout
+	c = &HealthConfig{
+		BindAddress: ":8081",
+		PingTimeout: "-1s",
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+}
+
+func TestSplitArgs(t *testing.T) {
+	RegisterTestingT(t)
+
+	render := func(t string, obj map[string]interface{}) []string {
+		tmpl, err := ParseArgTemplate(t)
+		Expect(err).NotTo(HaveOccurred())
+
+		var b strings.Builder
+		err = tmpl.Execute(&b, obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		return SplitArgs(b.String())
+	}
+
+	obj := map[string]interface{}{
+		"name":  "test",
+		"items": []interface{}{"a", "b c", ""},
+		"empty": []interface{}{},
+	}
+
+	// Single argument
+	Expect(render(`--name={{.name}}`, obj)).To(Equal([]string{"--name=test"}))
+	// Multiple arguments
+	Expect(render(`{{range .items}}{{arg "--item=" .}}{{end}}`, obj)).To(Equal([]string{"--item=a", "--item=b c", "--item="}))
+	Expect(render(`{{range .items}}{{arg .}}{{end}}`, obj)).To(Equal([]string{"a", "b c", ""}))
+	// No argument
+	Expect(render(`{{range .empty}}{{arg .}}{{end}}`, obj)).To(BeEmpty())
 }
 
 func TestTLSConfig(t *testing.T) {
@@ -396,6 +2197,52 @@ func TestTLSConfig(t *testing.T) {
 	}
 	err = c.Validate()
 	Expect(err).To(HaveOccurred())
+
+	// CA certificate directory
+	dir, err := ioutil.TempDir("", "tls")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	c = &TLSConfig{
+		CACertDir: dir,
+	}
+	err = c.Validate()
+	Expect(err).NotTo(HaveOccurred())
+
+	// Missing CA certificate directory
+	c = &TLSConfig{
+		CACertDir: filepath.Join(dir, "missing"),
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+
+	// CA certificate directory that is a file
+	file := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(file, []byte{}, 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	c = &TLSConfig{
+		CACertDir: file,
+	}
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+}
+
+func TestParseFieldPath(t *testing.T) {
+	RegisterTestingT(t)
+
+	fields, err := ParseFieldPath(".status.lastError")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(fields).To(Equal([]string{"status", "lastError"}))
+
+	_, err = ParseFieldPath("status.lastError")
+	Expect(err).To(HaveOccurred())
+
+	_, err = ParseFieldPath(".status..lastError")
+	Expect(err).To(HaveOccurred())
+
+	_, err = ParseFieldPath(".status.errors[0]")
+	Expect(err).To(HaveOccurred())
 }
 
 func newTestConfig() *Config {
@@ -443,6 +2290,16 @@ func newTestConfig() *Config {
 						NameFieldPath: ".spec.y",
 					},
 				},
+				Watches: []WatchConfig{
+					WatchConfig{
+						GroupVersionKind: schema.GroupVersionKind{
+							Group:   "example.org",
+							Version: "v1alpha1",
+							Kind:    "ResourceZ",
+						},
+						NameFieldPath: ".spec.testRef",
+					},
+				},
 				Reconciler: &ReconcilerConfig{
 					HandlerConfig: HandlerConfig{
 						Exec: &ExecHandlerConfig{
@@ -513,3 +2370,15 @@ func newTestConfig() *Config {
 		},
 	}
 }
+
+func newTestServerConfig(port int, hooks ...string) *ServerConfig {
+	return &ServerConfig{
+		Host: "127.0.0.1",
+		Port: port,
+		TLS: &TLSConfig{
+			CertFile: "server.pem",
+			KeyFile:  "server-key.pem",
+		},
+		Hooks: hooks,
+	}
+}
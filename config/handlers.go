@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/summerwind/whitebox-controller/handler/plugin"
+)
+
+// handlerEntry is a handler configuration with its path in the
+// configuration file.
+type handlerEntry struct {
+	path    string
+	handler *HandlerConfig
+}
+
+// handlerEntries returns all handler configurations of the resources
+// and the default webhooks.
+func (c *Config) handlerEntries() []handlerEntry {
+	entries := []handlerEntry{}
+	add := func(path string, h *HandlerConfig) {
+		if h != nil {
+			entries = append(entries, handlerEntry{path: path, handler: h})
+		}
+	}
+
+	for i, r := range c.Resources {
+		if r.Reconciler != nil {
+			add(fmt.Sprintf("resources[%d].reconciler", i), &r.Reconciler.HandlerConfig)
+			add(fmt.Sprintf("resources[%d].reconciler.shadow", i), r.Reconciler.Shadow)
+			add(fmt.Sprintf("resources[%d].reconciler.notify", i), r.Reconciler.Notify)
+		}
+		add(fmt.Sprintf("resources[%d].finalizer", i), r.Finalizer)
+		add(fmt.Sprintf("resources[%d].validator", i), r.Validator)
+		add(fmt.Sprintf("resources[%d].mutator", i), r.Mutator)
+		if r.Injector != nil {
+			add(fmt.Sprintf("resources[%d].injector", i), &r.Injector.HandlerConfig)
+		}
+	}
+
+	if c.Webhook != nil && c.Webhook.Default != nil {
+		add("webhook.default.validator", c.Webhook.Default.Validator)
+		add("webhook.default.mutator", c.Webhook.Default.Mutator)
+	}
+
+	for i, s := range c.Webhooks {
+		if s != nil && s.Default != nil {
+			add(fmt.Sprintf("webhooks[%d].default.validator", i), s.Default.Validator)
+			add(fmt.Sprintf("webhooks[%d].default.mutator", i), s.Default.Mutator)
+		}
+	}
+
+	return entries
+}
+
+// resolveHandlerRefs replaces the references to the named handlers
+// with the exec, http or plugin handler of the named handler.
+func (c *Config) resolveHandlerRefs() error {
+	for _, e := range c.handlerEntries() {
+		h := e.handler
+		if h.HandlerRef == "" {
+			continue
+		}
+
+		if h.Exec != nil || h.HTTP != nil || h.Plugin != nil {
+			return fmt.Errorf("%s: handlerRef must not be specified with exec, http or plugin", e.path)
+		}
+
+		named, ok := c.Handlers[h.HandlerRef]
+		if !ok || named == nil {
+			return fmt.Errorf("%s: handler %q not found", e.path, h.HandlerRef)
+		}
+
+		if named.Exec != nil {
+			exec := *named.Exec
+			h.Exec = &exec
+		}
+		if named.HTTP != nil {
+			http := *named.HTTP
+			h.HTTP = &http
+		}
+		if named.Plugin != nil {
+			plugin := *named.Plugin
+			h.Plugin = &plugin
+		}
+		if h.AllowedFields == nil {
+			h.AllowedFields = named.AllowedFields
+		}
+		if h.ImmutableFields == nil {
+			h.ImmutableFields = named.ImmutableFields
+		}
+		if !h.Typed {
+			h.Typed = named.Typed
+		}
+		if h.RecordDir == "" {
+			h.RecordDir = named.RecordDir
+			h.RecordMaxFiles = named.RecordMaxFiles
+		}
+	}
+
+	return nil
+}
+
+// validateHandlers validates the named handlers.
+func (c *Config) validateHandlers() []error {
+	errs := []error{}
+
+	names := []string{}
+	for name := range c.Handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		h := c.Handlers[name]
+		if h == nil {
+			errs = append(errs, fmt.Errorf("handlers[%s] is empty", name))
+			continue
+		}
+
+		if h.HandlerRef != "" {
+			errs = append(errs, fmt.Errorf("handlers[%s]: handlerRef must not be specified", name))
+			continue
+		}
+
+		err := h.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("handlers[%s]: %v", name, err))
+		}
+	}
+
+	return errs
+}
+
+// PluginHandlers returns the handler configurations of the resources
+// and the default webhooks that use a plugin handler.
+func (c *Config) PluginHandlers() []*HandlerConfig {
+	handlers := []*HandlerConfig{}
+	for _, e := range c.handlerEntries() {
+		if e.handler.Plugin != nil {
+			handlers = append(handlers, e.handler)
+		}
+	}
+
+	return handlers
+}
+
+// HTTPHandlers returns the HTTP handler configurations of the resources
+// and the default webhooks.
+func (c *Config) HTTPHandlers() []*HTTPHandlerConfig {
+	handlers := []*HTTPHandlerConfig{}
+	for _, e := range c.handlerEntries() {
+		if e.handler.HTTP != nil {
+			handlers = append(handlers, e.handler.HTTP)
+		}
+	}
+
+	return handlers
+}
+
+// pluginSupported indicates whether the plugin handlers can be used by
+// this build of the controller.
+var pluginSupported = plugin.Supported
+
+// validatePlugins validates that the plugin directory is specified if
+// any handler uses a plugin handler, and that this build of the
+// controller can load the plugins.
+func (c *Config) validatePlugins() []error {
+	errs := []error{}
+	for _, e := range c.handlerEntries() {
+		if e.handler.Plugin == nil {
+			continue
+		}
+
+		if !pluginSupported {
+			errs = append(errs, fmt.Errorf("%s: plugin is not supported by this build, which requires cgo", e.path))
+			continue
+		}
+
+		if c.PluginDir == "" {
+			errs = append(errs, fmt.Errorf("%s: plugin requires pluginDir", e.path))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestLoadFileWithHandlerRefs(t *testing.T) {
+	RegisterTestingT(t)
+
+	c, err := loadTestConfig(`
+handlers:
+  shared:
+    exec:
+      command: /bin/controller
+      args: ["reconcile"]
+    allowedFields: [".status"]
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  reconciler:
+    handlerRef: shared
+  validator:
+    handlerRef: shared
+    allowedFields: []
+- group: example.com
+  version: v1alpha1
+  kind: Other
+  reconciler:
+    handlerRef: shared
+webhook:
+  port: 443
+  default:
+    mutator:
+      handlerRef: shared
+`)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Validate()).To(Succeed())
+
+	r := c.Resources[0].Reconciler
+	Expect(r.Exec.Command).To(Equal("/bin/controller"))
+	Expect(r.Exec.Args).To(Equal([]string{"reconcile"}))
+	Expect(r.AllowedFields).To(Equal([]string{".status"}))
+
+	// Allowed fields of the referencing handler take precedence
+	Expect(c.Resources[0].Validator.Exec.Command).To(Equal("/bin/controller"))
+	Expect(c.Resources[0].Validator.AllowedFields).To(BeEmpty())
+
+	Expect(c.Webhook.Default.Mutator.Exec.Command).To(Equal("/bin/controller"))
+
+	// Each reference has its own copy of the handler
+	r.Exec.Debug = true
+	Expect(c.Resources[1].Reconciler.Exec.Debug).To(BeFalse())
+	Expect(c.Handlers["shared"].Exec.Debug).To(BeFalse())
+}
+
+func TestLoadFileWithUnknownHandlerRef(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, err := loadTestConfig(`
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  reconciler:
+    handlerRef: unknown
+`)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring(`resources[0].reconciler: handler "unknown" not found`))
+
+	// With exec handler
+	_, err = loadTestConfig(`
+handlers:
+  shared:
+    exec:
+      command: /bin/controller
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  reconciler:
+    handlerRef: shared
+    exec:
+      command: /bin/other
+`)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("handlerRef must not be specified with exec, http or plugin"))
+}
+
+func TestConfigValidateHandlers(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Valid
+	c := newTestConfig()
+	c.Handlers = map[string]*HandlerConfig{
+		"shared": &HandlerConfig{Exec: &ExecHandlerConfig{Command: "/bin/controller"}},
+	}
+	Expect(c.Validate()).To(Succeed())
+
+	// Invalid named handler
+	c = newTestConfig()
+	c.Handlers = map[string]*HandlerConfig{"shared": &HandlerConfig{}}
+	Expect(c.Validate()).NotTo(Succeed())
+
+	// Named handler with reference
+	c = newTestConfig()
+	c.Handlers = map[string]*HandlerConfig{
+		"shared": &HandlerConfig{HandlerRef: "other"},
+	}
+	Expect(c.Validate()).NotTo(Succeed())
+
+	// Unresolved reference
+	h := &HandlerConfig{HandlerRef: "shared"}
+	err := h.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("not resolved"))
+}
+
+func TestLoadFileWithPluginHandlers(t *testing.T) {
+	RegisterTestingT(t)
+
+	c, err := loadTestConfig(`
+pluginDir: /etc/whitebox/plugins
+handlers:
+  shared:
+    plugin:
+      name: reconcile
+resources:
+- group: example.com
+  version: v1alpha1
+  kind: Test
+  reconciler:
+    handlerRef: shared
+  validator:
+    plugin:
+      name: validate
+`)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.Validate()).To(Succeed())
+
+	handlers := c.PluginHandlers()
+	Expect(handlers).To(HaveLen(2))
+	Expect(handlers[0].Plugin.Name).To(Equal("reconcile"))
+	Expect(handlers[1].Plugin.Name).To(Equal("validate"))
+
+	// Plugin handler with the in-process handler set by the manager
+	handlers[0].StateHandler = &testStateHandler{}
+	Expect(c.Validate()).To(Succeed())
+
+	// Without plugin directory
+	c.PluginDir = ""
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("resources[0].reconciler: plugin requires pluginDir"))
+
+	// Build without plugin support
+	defer func(supported bool) { pluginSupported = supported }(pluginSupported)
+	pluginSupported = false
+	c.PluginDir = "/etc/whitebox/plugins"
+	err = c.Validate()
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("resources[0].reconciler: plugin is not supported by this build"))
+}
+
+func TestHandlerConfigValidateWithPlugin(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Valid
+	h := &HandlerConfig{Plugin: &PluginHandlerConfig{Name: "reconcile"}}
+	Expect(h.Validate()).To(Succeed())
+
+	// Missing name
+	h = &HandlerConfig{Plugin: &PluginHandlerConfig{}}
+	Expect(h.Validate()).NotTo(Succeed())
+
+	// With exec handler
+	h = &HandlerConfig{
+		Plugin: &PluginHandlerConfig{Name: "reconcile"},
+		Exec:   &ExecHandlerConfig{Command: "/bin/controller"},
+	}
+	Expect(h.Validate()).NotTo(Succeed())
+}
+
+func loadTestConfig(content string) (*Config, error) {
+	f, err := ioutil.TempFile("", "config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(content)
+	Expect(err).NotTo(HaveOccurred())
+	f.Close()
+
+	return LoadFileWithProfile(f.Name(), "")
+}
+
+type testStateHandler struct{}
+
+func (h *testStateHandler) HandleState(s *state.State) error {
+	return nil
+}
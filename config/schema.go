@@ -0,0 +1,116 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JSONSchema returns the JSON Schema of the configuration file. The
+// schema is generated from the fields and JSON tags of Config so that
+// it is always in sync with the configuration. It does not depend on
+// the values of the configuration.
+func (c *Config) JSONSchema() map[string]interface{} {
+	t := reflect.TypeOf(Config{})
+
+	s := typeSchema(t, t, map[reflect.Type]bool{})
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "Whitebox Controller configuration"
+
+	return s
+}
+
+// typeSchema returns the schema of specified type. A struct type that
+// is already being generated refers to the root schema if it is the
+// root type, otherwise it is treated as an arbitrary object.
+func typeSchema(t, root reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), root, visiting)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), root, visiting),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), root, visiting),
+		}
+	case reflect.Struct:
+		if visiting[t] {
+			if t == root {
+				return map[string]interface{}{"$ref": "#"}
+			}
+			return map[string]interface{}{"type": "object"}
+		}
+
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		props := map[string]interface{}{}
+		addProperties(props, t, root, visiting)
+
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	}
+
+	return map[string]interface{}{}
+}
+
+// addProperties adds the schema of the fields of specified struct type
+// to props. Fields of embedded structs are added as if they were
+// fields of the struct.
+func addProperties(props map[string]interface{}, t, root reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addProperties(props, ft, root, visiting)
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = lowerFirst(f.Name)
+		}
+
+		props[name] = typeSchema(f.Type, root, visiting)
+	}
+}
+
+// lowerFirst returns s with the first letter in lower case. It is used
+// for the fields without JSON tag, such as the group, version and kind
+// which are matched case-insensitively on decoding.
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestJSONSchema(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := (&Config{}).JSONSchema()
+	Expect(s["type"]).To(Equal("object"))
+
+	resources := property(s, "resources")
+	Expect(resources["type"]).To(Equal("array"))
+
+	resource := resources["items"].(map[string]interface{})
+	Expect(property(resource, "kind")).To(Equal(map[string]interface{}{"type": "string"}))
+	Expect(property(resource, "enabled")).To(Equal(map[string]interface{}{"type": "boolean"}))
+
+	// Fields of the embedded handler config
+	reconciler := property(resource, "reconciler")
+	Expect(property(reconciler, "maxRetries")).To(Equal(map[string]interface{}{"type": "integer"}))
+
+	exec := property(reconciler, "exec")
+	Expect(property(exec, "command")).To(Equal(map[string]interface{}{"type": "string"}))
+	Expect(property(exec, "env")["additionalProperties"]).To(Equal(map[string]interface{}{"type": "string"}))
+
+	// Fields not in the configuration file
+	Expect(reconciler["properties"]).NotTo(HaveKey("stateHandler"))
+	Expect(reconciler["properties"]).NotTo(HaveKey("StateHandler"))
+
+	// Recursive profiles
+	profiles := property(s, "profiles")
+	Expect(profiles["additionalProperties"]).To(Equal(map[string]interface{}{"$ref": "#"}))
+}
+
+func property(s map[string]interface{}, name string) map[string]interface{} {
+	props, ok := s["properties"].(map[string]interface{})
+	Expect(ok).To(BeTrue())
+	Expect(props).To(HaveKey(name))
+
+	return props[name].(map[string]interface{})
+}
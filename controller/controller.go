@@ -1,13 +1,20 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/summerwind/whitebox-controller/config"
@@ -15,6 +22,8 @@ import (
 	"github.com/summerwind/whitebox-controller/reconciler"
 )
 
+var log = logf.Log.WithName("controller")
+
 func New(c *config.ResourceConfig, mgr manager.Manager) (*controller.Controller, error) {
 	var (
 		r   *reconciler.Reconciler
@@ -33,10 +42,24 @@ func New(c *config.ResourceConfig, mgr manager.Manager) (*controller.Controller,
 		return nil, fmt.Errorf("could not create controller: %v", err)
 	}
 
+	sink := r.ResultSink()
+	if sink != nil {
+		err = mgr.Add(sink)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add result sink: %v", err)
+		}
+	}
+
+	depth := registerQueueDepth(name)
+
 	obj := &unstructured.Unstructured{}
 	obj.SetGroupVersionKind(c.GroupVersionKind)
 
-	err = ctrl.Watch(&source.Kind{Type: obj}, &handler.EnqueueRequestForObject{})
+	err = ctrl.Watch(&source.Kind{Type: obj}, &triggerHandler{
+		EventHandler: &handler.EnqueueRequestForObject{},
+		setter:       r,
+		depth:        depth,
+	}, predicates(c)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to watch resource: %v", err)
 	}
@@ -50,22 +73,69 @@ func New(c *config.ResourceConfig, mgr manager.Manager) (*controller.Controller,
 		depObj := &unstructured.Unstructured{}
 		depObj.SetGroupVersionKind(dep.GroupVersionKind)
 
-		err = ctrl.Watch(&source.Kind{Type: depObj}, &handler.EnqueueRequestForOwner{
-			IsController: true,
-			OwnerType:    obj,
-		})
+		h := newDependentHandler(obj, dep.IsControllerOwnerRef(), r, depth)
+		if dep.TrackingLabels {
+			h = newTrackedDependentHandler(r, depth)
+		}
+
+		err = ctrl.Watch(&source.Kind{Type: depObj}, h)
 		if err != nil {
 			return nil, fmt.Errorf("failed to watch dependent resource: %v", err)
 		}
 	}
 
+	if c.PruneOnStartup != nil {
+		dryRun := c.PruneOnStartup.DryRuns()
+		err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			_, err := r.Prune(context.Background(), dryRun)
+			if err != nil {
+				log.Error(err, "Failed to prune tracked dependent resources", "controller", name)
+			}
+			return nil
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to add pruner: %v", err)
+		}
+	}
+
+	var debounce time.Duration
+	if c.ReferenceDebounce != "" {
+		debounce, err = time.ParseDuration(c.ReferenceDebounce)
+		if err != nil {
+			return nil, fmt.Errorf("invalid referenceDebounce: %v", err)
+		}
+	}
+
+	for _, w := range c.Watches {
+		watchObj := &unstructured.Unstructured{}
+		watchObj.SetGroupVersionKind(w.GroupVersionKind)
+
+		err = ctrl.Watch(&source.Kind{Type: watchObj}, &triggerHandler{
+			EventHandler: &handler.EnqueueRequestsFromMapFunc{
+				ToRequests: withReferenceInvalidation(r, newWatchMapper(w.NameFieldPath)),
+			},
+			setter:  r,
+			trigger: reconciler.TriggerWatch,
+			depth:   depth,
+			delay:   debounce,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch resource: %v", err)
+		}
+	}
+
 	if c.ResyncPeriod != "" {
 		s, err := syncer.New(c, mgr)
 		if err != nil {
 			return nil, fmt.Errorf("could not create syncer: %v", err)
 		}
 
-		err = ctrl.Watch(&source.Channel{Source: s.C}, &handler.EnqueueRequestForObject{})
+		err = ctrl.Watch(&source.Channel{Source: s.C}, &triggerHandler{
+			EventHandler: &handler.EnqueueRequestForObject{},
+			setter:       r,
+			trigger:      reconciler.TriggerSync,
+			depth:        depth,
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to watch sync channel: %v", err)
 		}
@@ -73,3 +143,137 @@ func New(c *config.ResourceConfig, mgr manager.Manager) (*controller.Controller,
 
 	return &ctrl, nil
 }
+
+// newDependentHandler returns an event handler that enqueues the owner
+// of the dependent resource on any change of it, including deletion,
+// so that a deleted dependent is recreated by the reconciler. If
+// isController is false, the owner is found in any owner reference.
+func newDependentHandler(owner *unstructured.Unstructured, isController bool, setter triggerSetter, depth *queueDepth) handler.EventHandler {
+	return &triggerHandler{
+		EventHandler: &handler.EnqueueRequestForOwner{
+			IsController: isController,
+			OwnerType:    owner,
+		},
+		setter:  setter,
+		trigger: reconciler.TriggerDependent,
+		depth:   depth,
+	}
+}
+
+// newTrackedDependentHandler returns an event handler that enqueues
+// the owner of the dependent resource found in the tracking labels.
+func newTrackedDependentHandler(setter triggerSetter, depth *queueDepth) handler.EventHandler {
+	return &triggerHandler{
+		EventHandler: &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(mapTrackedDependent),
+		},
+		setter:  setter,
+		trigger: reconciler.TriggerDependent,
+		depth:   depth,
+	}
+}
+
+// mapTrackedDependent maps a tracked dependent resource to the request
+// for its owner.
+func mapTrackedDependent(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+
+	name := labels[reconciler.LabelOwnerName]
+	if name == "" {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Namespace: labels[reconciler.LabelOwnerNamespace],
+				Name:      name,
+			},
+		},
+	}
+}
+
+// predicates returns a list of predicates for the resource based on
+// specified ResourceConfig.
+func predicates(c *config.ResourceConfig) []predicate.Predicate {
+	preds := []predicate.Predicate{}
+
+	// Update events that do not change metadata.generation are
+	// ignored. Create and delete events are always passed through.
+	if c.GenerationChangedPredicate {
+		preds = append(preds, predicate.GenerationChangedPredicate{})
+	}
+
+	return preds
+}
+
+// referenceInvalidator invalidates the cached reference resources.
+type referenceInvalidator interface {
+	InvalidateReference(*unstructured.Unstructured)
+}
+
+// withReferenceInvalidation returns a mapper that invalidates the
+// cached reference of the watched resource before mapping it.
+func withReferenceInvalidation(inv referenceInvalidator, m handler.Mapper) handler.ToRequestsFunc {
+	return handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+		res, ok := obj.Object.(*unstructured.Unstructured)
+		if ok {
+			inv.InvalidateReference(res)
+		}
+
+		return m.Map(obj)
+	})
+}
+
+// newWatchMapper returns a mapper that maps a watched resource to the
+// requests for the resources named by the field of specified JSON Path.
+func newWatchMapper(namePath string) handler.ToRequestsFunc {
+	return handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+		reqs := []reconcile.Request{}
+
+		res, ok := obj.Object.(*unstructured.Unstructured)
+		if !ok {
+			return reqs
+		}
+
+		jp := jsonpath.New("watch")
+		jp.AllowMissingKeys(true)
+
+		err := jp.Parse(fmt.Sprintf("{%s}", namePath))
+		if err != nil {
+			log.Error(err, "Invalid name field path", "path", namePath)
+			return reqs
+		}
+
+		results, err := jp.FindResults(res.Object)
+		if err != nil {
+			log.Error(err, "Failed to find names", "namespace", res.GetNamespace(), "name", res.GetName())
+			return reqs
+		}
+
+		names := map[string]struct{}{}
+		for x := range results {
+			for _, v := range results[x] {
+				name := fmt.Sprint(v.Interface())
+				if name == "" {
+					continue
+				}
+
+				_, ok := names[name]
+				if ok {
+					continue
+				}
+				names[name] = struct{}{}
+
+				reqs = append(reqs, reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Namespace: res.GetNamespace(),
+						Name:      name,
+					},
+				})
+			}
+		}
+
+		return reqs
+	})
+}
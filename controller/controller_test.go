@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestPredicates(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := &config.ResourceConfig{}
+	Expect(predicates(c)).To(HaveLen(0))
+
+	c.GenerationChangedPredicate = true
+	preds := predicates(c)
+	Expect(preds).To(HaveLen(1))
+
+	p := preds[0]
+	oldObj := newTestObject(1)
+
+	// Create and delete events are always passed through
+	Expect(p.Create(event.CreateEvent{Meta: oldObj, Object: oldObj})).To(BeTrue())
+	Expect(p.Delete(event.DeleteEvent{Meta: oldObj, Object: oldObj})).To(BeTrue())
+
+	// Status only update
+	newObj := oldObj.DeepCopy()
+	unstructured.SetNestedField(newObj.Object, "Ready", "status", "phase")
+	Expect(p.Update(event.UpdateEvent{
+		MetaOld:   oldObj,
+		ObjectOld: oldObj,
+		MetaNew:   newObj,
+		ObjectNew: newObj,
+	})).To(BeFalse())
+
+	// Spec update
+	newObj = newTestObject(2)
+	unstructured.SetNestedField(newObj.Object, "updated", "spec", "message")
+	Expect(p.Update(event.UpdateEvent{
+		MetaOld:   oldObj,
+		ObjectOld: oldObj,
+		MetaNew:   newObj,
+		ObjectNew: newObj,
+	})).To(BeTrue())
+}
+
+func TestWatchMapper(t *testing.T) {
+	RegisterTestingT(t)
+
+	obj := newTestObject(1)
+	unstructured.SetNestedStringSlice(obj.Object, []string{"owner1", "owner2", "owner1"}, "spec", "ownerRefs")
+
+	m := newWatchMapper(".spec.ownerRefs[*]")
+	reqs := m.Map(handler.MapObject{Meta: obj, Object: obj})
+	Expect(reqs).To(Equal([]reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "owner1"}},
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "owner2"}},
+	}))
+
+	// Missing field
+	m = newWatchMapper(".spec.missing")
+	reqs = m.Map(handler.MapObject{Meta: obj, Object: obj})
+	Expect(reqs).To(HaveLen(0))
+}
+
+func TestWithReferenceInvalidation(t *testing.T) {
+	RegisterTestingT(t)
+
+	obj := newTestObject(1)
+	unstructured.SetNestedField(obj.Object, "owner", "spec", "ownerRef")
+
+	inv := &testReferenceInvalidator{}
+	m := withReferenceInvalidation(inv, newWatchMapper(".spec.ownerRef"))
+	reqs := m.Map(handler.MapObject{Meta: obj, Object: obj})
+	Expect(reqs).To(HaveLen(1))
+	Expect(inv.invalidated).To(Equal([]*unstructured.Unstructured{obj}))
+}
+
+type testReferenceInvalidator struct {
+	invalidated []*unstructured.Unstructured
+}
+
+func (i *testReferenceInvalidator) InvalidateReference(res *unstructured.Unstructured) {
+	i.invalidated = append(i.invalidated, res)
+}
+
+func newTestObject(generation int64) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1alpha1")
+	obj.SetKind("Test")
+	obj.SetNamespace("default")
+	obj.SetName("test")
+	obj.SetGeneration(generation)
+	unstructured.SetNestedField(obj.Object, "hello", "spec", "message")
+
+	return obj
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// queueDepth reports the depth of the workqueue of a controller.
+// The workqueue is not exposed by the controller, so it is captured
+// from the event handlers when the first event is handled.
+type queueDepth struct {
+	mu    sync.Mutex
+	queue workqueue.Interface
+}
+
+func (d *queueDepth) setQueue(q workqueue.Interface) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.queue == nil {
+		d.queue = q
+	}
+}
+
+func (d *queueDepth) value() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.queue == nil {
+		return 0
+	}
+
+	return float64(d.queue.Len())
+}
+
+// newQueueDepthGauge returns a gauge of the workqueue depth of
+// specified controller.
+func newQueueDepthGauge(name string, d *queueDepth) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "whitebox_queue_depth",
+			Help:        "Current depth of the workqueue per controller",
+			ConstLabels: prometheus.Labels{"controller": name},
+		},
+		d.value,
+	)
+}
+
+// registerQueueDepth registers the workqueue depth gauge of specified
+// controller and returns the queueDepth to be passed to the handlers.
+func registerQueueDepth(name string) *queueDepth {
+	d := &queueDepth{}
+
+	err := metrics.Registry.Register(newQueueDepthGauge(name, d))
+	if err != nil {
+		log.Error(err, "Failed to register queue depth metric", "controller", name)
+	}
+
+	return d
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+func TestQueueDepthGauge(t *testing.T) {
+	RegisterTestingT(t)
+
+	d := &queueDepth{}
+	g := newQueueDepthGauge("depth-test", d)
+
+	// No events handled yet
+	Expect(testutil.ToFloat64(g)).To(Equal(0.0))
+
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+
+	h := &triggerHandler{
+		EventHandler: &handler.EnqueueRequestForObject{},
+		setter:       &testTriggerSetter{triggers: map[types.NamespacedName]string{}},
+		depth:        d,
+	}
+
+	obj1 := newTestObject(1)
+	obj2 := newTestObject(1)
+	obj2.SetName("test2")
+
+	h.Create(event.CreateEvent{Meta: obj1, Object: obj1}, q)
+	h.Create(event.CreateEvent{Meta: obj2, Object: obj2}, q)
+	Expect(testutil.ToFloat64(g)).To(Equal(2.0))
+
+	// Simulate a reconcile
+	item, _ := q.Get()
+	Expect(testutil.ToFloat64(g)).To(Equal(1.0))
+	q.Done(item)
+	q.Forget(item)
+	Expect(testutil.ToFloat64(g)).To(Equal(1.0))
+}
@@ -23,6 +23,8 @@ type Syncer struct {
 	C        chan event.GenericEvent
 	config   *config.ResourceConfig
 	interval time.Duration
+	maxAge   time.Duration
+	now      func() time.Time
 }
 
 func New(c *config.ResourceConfig, mgr manager.Manager) (*Syncer, error) {
@@ -31,11 +33,21 @@ func New(c *config.ResourceConfig, mgr manager.Manager) (*Syncer, error) {
 		return nil, fmt.Errorf("invalid resync period: %v", err)
 	}
 
+	var maxAge time.Duration
+	if c.ResyncMaxAge != "" {
+		maxAge, err = time.ParseDuration(c.ResyncMaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resync max age: %v", err)
+		}
+	}
+
 	s := &Syncer{
 		Client:   mgr.GetClient(),
 		C:        make(chan event.GenericEvent),
 		config:   c,
 		interval: interval,
+		maxAge:   maxAge,
+		now:      time.Now,
 	}
 
 	return s, mgr.Add(s)
@@ -71,6 +83,10 @@ func (s *Syncer) Sync() error {
 	}
 
 	for _, instance := range instanceList.Items {
+		if !s.isRecent(&instance) {
+			continue
+		}
+
 		s.C <- event.GenericEvent{
 			Meta: &metav1.ObjectMeta{
 				Name:      instance.GetName(),
@@ -81,3 +97,25 @@ func (s *Syncer) Sync() error {
 
 	return nil
 }
+
+// isRecent returns whether the object was created or changed within
+// the max age. If the age annotation is configured but missing or
+// invalid, the creation timestamp is used.
+func (s *Syncer) isRecent(obj *unstructured.Unstructured) bool {
+	if s.maxAge == 0 {
+		return true
+	}
+
+	t := obj.GetCreationTimestamp().Time
+	if s.config.ResyncAgeAnnotation != "" {
+		v, ok := obj.GetAnnotations()[s.config.ResyncAgeAnnotation]
+		if ok {
+			at, err := time.Parse(time.RFC3339, v)
+			if err == nil {
+				t = at
+			}
+		}
+	}
+
+	return s.now().Sub(t) <= s.maxAge
+}
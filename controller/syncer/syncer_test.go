@@ -0,0 +1,88 @@
+package syncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestSyncWithMaxAge(t *testing.T) {
+	RegisterTestingT(t)
+
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	recent := newObject("recent", now.Add(-time.Hour))
+	old := newObject("old", now.Add(-48*time.Hour))
+
+	annotated := newObject("annotated", now.Add(-48*time.Hour))
+	annotated.SetAnnotations(map[string]string{
+		"example.com/updated-at": now.Add(-time.Hour).Format(time.RFC3339),
+	})
+
+	invalid := newObject("invalid", now.Add(-48*time.Hour))
+	invalid.SetAnnotations(map[string]string{
+		"example.com/updated-at": "yesterday",
+	})
+
+	s := &Syncer{
+		Client: &testListClient{items: []unstructured.Unstructured{*recent, *old, *annotated, *invalid}},
+		C:      make(chan event.GenericEvent, 4),
+		config: &config.ResourceConfig{
+			GroupVersionKind:    schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"},
+			ResyncAgeAnnotation: "example.com/updated-at",
+		},
+		maxAge: 24 * time.Hour,
+		now:    func() time.Time { return now },
+	}
+
+	err := s.Sync()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(syncedNames(s.C)).To(Equal([]string{"recent", "annotated"}))
+
+	// No max age
+	s.maxAge = 0
+	err = s.Sync()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(syncedNames(s.C)).To(Equal([]string{"recent", "old", "annotated", "invalid"}))
+}
+
+// testListClient is a client that returns the items on List.
+type testListClient struct {
+	client.Client
+	items []unstructured.Unstructured
+}
+
+func (c *testListClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	list.(*unstructured.UnstructuredList).Items = c.items
+	return nil
+}
+
+func newObject(name string, created time.Time) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	obj.SetCreationTimestamp(metav1.NewTime(created))
+
+	return obj
+}
+
+func syncedNames(c chan event.GenericEvent) []string {
+	names := []string{}
+	for len(c) > 0 {
+		e := <-c
+		names = append(names, e.Meta.GetName())
+	}
+
+	return names
+}
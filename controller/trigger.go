@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+
+	"github.com/summerwind/whitebox-controller/reconciler"
+)
+
+// triggerSetter records the trigger of reconcile.
+type triggerSetter interface {
+	SetTrigger(types.NamespacedName, string)
+}
+
+// triggerHandler wraps an EventHandler and records the trigger of
+// the requests enqueued by the handler. If trigger is empty, the type
+// of the event is used as the trigger. If depth is specified, the
+// queue is passed to it for reporting the queue depth. If delay is
+// specified, the requests are added after the delay so that the
+// requests for the same object within the delay are coalesced.
+type triggerHandler struct {
+	handler.EventHandler
+	setter  triggerSetter
+	trigger string
+	depth   *queueDepth
+	delay   time.Duration
+}
+
+func (h *triggerHandler) Create(evt event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Create(evt, h.wrap(q, reconciler.TriggerCreate))
+}
+
+func (h *triggerHandler) Update(evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Update(evt, h.wrap(q, reconciler.TriggerUpdate))
+}
+
+func (h *triggerHandler) Delete(evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Delete(evt, h.wrap(q, reconciler.TriggerDelete))
+}
+
+func (h *triggerHandler) Generic(evt event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Generic(evt, h.wrap(q, reconciler.TriggerSync))
+}
+
+// InjectFunc implements inject.Injector interface so that the wrapped
+// handler receives its dependencies.
+func (h *triggerHandler) InjectFunc(f inject.Func) error {
+	return f(h.EventHandler)
+}
+
+func (h *triggerHandler) wrap(q workqueue.RateLimitingInterface, trigger string) workqueue.RateLimitingInterface {
+	if h.trigger != "" {
+		trigger = h.trigger
+	}
+
+	if h.depth != nil {
+		h.depth.setQueue(q)
+	}
+
+	return &triggerQueue{
+		RateLimitingInterface: q,
+		setter:                h.setter,
+		trigger:               trigger,
+		delay:                 h.delay,
+	}
+}
+
+// triggerQueue records the trigger of requests added to the queue.
+type triggerQueue struct {
+	workqueue.RateLimitingInterface
+	setter  triggerSetter
+	trigger string
+	delay   time.Duration
+}
+
+func (q *triggerQueue) Add(item interface{}) {
+	req, ok := item.(reconcile.Request)
+	if ok {
+		q.setter.SetTrigger(req.NamespacedName, q.trigger)
+	}
+
+	// The delaying queue keeps only the earliest time of the same item
+	// waiting to be added.
+	if q.delay > 0 {
+		q.RateLimitingInterface.AddAfter(item, q.delay)
+		return
+	}
+
+	q.RateLimitingInterface.Add(item)
+}
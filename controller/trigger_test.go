@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+
+	"github.com/summerwind/whitebox-controller/reconciler"
+)
+
+func TestTriggerHandler(t *testing.T) {
+	RegisterTestingT(t)
+
+	nn := types.NamespacedName{Namespace: "default", Name: "test"}
+	obj := newTestObject(1)
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+
+	// Create event
+	setter := &testTriggerSetter{triggers: map[types.NamespacedName]string{}}
+	h := &triggerHandler{
+		EventHandler: &handler.EnqueueRequestForObject{},
+		setter:       setter,
+	}
+	h.Create(event.CreateEvent{Meta: obj, Object: obj}, q)
+	Expect(setter.triggers[nn]).To(Equal(reconciler.TriggerCreate))
+	Expect(q.Len()).To(Equal(1))
+
+	// Syncer tick
+	setter = &testTriggerSetter{triggers: map[types.NamespacedName]string{}}
+	h = &triggerHandler{
+		EventHandler: &handler.EnqueueRequestForObject{},
+		setter:       setter,
+		trigger:      reconciler.TriggerSync,
+	}
+	h.Generic(event.GenericEvent{
+		Meta: &metav1.ObjectMeta{Namespace: "default", Name: "test"},
+	}, q)
+	Expect(setter.triggers[nn]).To(Equal(reconciler.TriggerSync))
+}
+
+func TestTriggerHandlerWithDelay(t *testing.T) {
+	RegisterTestingT(t)
+
+	nn := types.NamespacedName{Namespace: "default", Name: "test"}
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+
+	setter := &testTriggerSetter{triggers: map[types.NamespacedName]string{}}
+	h := &triggerHandler{
+		EventHandler: &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+				return []reconcile.Request{{NamespacedName: nn}}
+			}),
+		},
+		setter:  setter,
+		trigger: reconciler.TriggerWatch,
+		delay:   100 * time.Millisecond,
+	}
+
+	// Changes of the watched resources within the delay
+	for i := 0; i < 10; i++ {
+		ref := newTestObject(int64(i + 1))
+		ref.SetName(fmt.Sprintf("ref-%d", i))
+		h.Update(event.UpdateEvent{MetaOld: ref, ObjectOld: ref, MetaNew: ref, ObjectNew: ref}, q)
+	}
+	Expect(setter.triggers[nn]).To(Equal(reconciler.TriggerWatch))
+	Expect(q.Len()).To(Equal(0))
+
+	// The requests are coalesced into one reconcile
+	Eventually(q.Len).Should(Equal(1))
+	Consistently(q.Len, 200*time.Millisecond).Should(Equal(1))
+}
+
+func TestDependentHandler(t *testing.T) {
+	RegisterTestingT(t)
+
+	owner := newTestObject(1)
+	owner.SetUID(types.UID("test-uid"))
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(owner.GroupVersionKind(), meta.RESTScopeNamespace)
+
+	setter := &testTriggerSetter{triggers: map[types.NamespacedName]string{}}
+	h := newDependentHandler(owner, true, setter, nil)
+
+	err := h.(inject.Injector).InjectFunc(func(i interface{}) error {
+		_, err := inject.SchemeInto(scheme.Scheme, i)
+		if err != nil {
+			return err
+		}
+		_, err = inject.MapperInto(mapper, i)
+		return err
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	dep := &unstructured.Unstructured{}
+	dep.SetAPIVersion("v1")
+	dep.SetKind("Pod")
+	dep.SetNamespace("default")
+	dep.SetName("test-pod")
+	dep.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(owner, owner.GroupVersionKind()),
+	})
+
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+
+	// Deletion of the dependent enqueues the owner
+	h.Delete(event.DeleteEvent{Meta: dep, Object: dep}, q)
+	Expect(q.Len()).To(Equal(1))
+
+	item, _ := q.Get()
+	nn := types.NamespacedName{Namespace: "default", Name: "test"}
+	Expect(item).To(Equal(reconcile.Request{NamespacedName: nn}))
+	Expect(setter.triggers[nn]).To(Equal(reconciler.TriggerDependent))
+}
+
+func TestTrackedDependentHandler(t *testing.T) {
+	RegisterTestingT(t)
+
+	setter := &testTriggerSetter{triggers: map[types.NamespacedName]string{}}
+	h := newTrackedDependentHandler(setter, nil)
+
+	dep := &unstructured.Unstructured{}
+	dep.SetAPIVersion("v1")
+	dep.SetKind("ConfigMap")
+	dep.SetNamespace("other")
+	dep.SetName("test-config")
+	dep.SetLabels(map[string]string{
+		reconciler.LabelOwnerUID:       "test-uid",
+		reconciler.LabelOwnerNamespace: "default",
+		reconciler.LabelOwnerName:      "test",
+	})
+
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+
+	// Deletion of the dependent enqueues the owner in other namespace
+	h.Delete(event.DeleteEvent{Meta: dep, Object: dep}, q)
+	Expect(q.Len()).To(Equal(1))
+
+	item, _ := q.Get()
+	nn := types.NamespacedName{Namespace: "default", Name: "test"}
+	Expect(item).To(Equal(reconcile.Request{NamespacedName: nn}))
+	Expect(setter.triggers[nn]).To(Equal(reconciler.TriggerDependent))
+	q.Done(item)
+
+	// Resources without the labels are ignored
+	dep.SetLabels(nil)
+	h.Delete(event.DeleteEvent{Meta: dep, Object: dep}, q)
+	Expect(q.Len()).To(Equal(0))
+}
+
+type testTriggerSetter struct {
+	triggers map[types.NamespacedName]string
+}
+
+func (s *testTriggerSetter) SetTrigger(nn types.NamespacedName, trigger string) {
+	s.triggers[nn] = trigger
+}
@@ -16,11 +16,41 @@ const debugEnvVar = "WHITEBOX_DEBUG"
 var errNoHandler = errors.New("no handler found")
 
 // NewStateHandler returns StateHandler based on specified HandlerConfig.
+// The returned handler never handles the same object concurrently, and
+// waits for the limiter of HandlerConfig if specified. The middlewares
+// set by handler.SetMiddlewares wrap the handler inside the limiter,
+// and panics of them are recovered.
 func NewStateHandler(c *config.HandlerConfig) (handler.StateHandler, error) {
+	h, err := newStateHandler(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.RecordDir != "" {
+		r, err := newRecorder(c)
+		if err != nil {
+			return nil, err
+		}
+		h = &recordStateHandler{StateHandler: h, recorder: r}
+	}
+
+	ms := handler.Middlewares()
+	if len(ms) > 0 {
+		h = &recoverStateHandler{handler.Chain(h, ms...)}
+	}
+
+	if c.Limiter != nil {
+		h = &limitStateHandler{StateHandler: h, limiter: c.Limiter}
+	}
+
+	return newLockStateHandler(h), nil
+}
+
+func newStateHandler(c *config.HandlerConfig) (handler.StateHandler, error) {
 	var debug bool
 
 	if c.StateHandler != nil {
-		return c.StateHandler, nil
+		return &recoverStateHandler{c.StateHandler}, nil
 	}
 
 	if os.Getenv(debugEnvVar) != "" {
@@ -42,10 +72,27 @@ func NewStateHandler(c *config.HandlerConfig) (handler.StateHandler, error) {
 
 // NewAdmissionRequestHandler returns AdmissionRequestHandler based on specified HandlerConfig.
 func NewAdmissionRequestHandler(c *config.HandlerConfig) (handler.AdmissionRequestHandler, error) {
+	h, err := newAdmissionRequestHandler(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.RecordDir != "" {
+		r, err := newRecorder(c)
+		if err != nil {
+			return nil, err
+		}
+		h = &recordAdmissionRequestHandler{AdmissionRequestHandler: h, recorder: r}
+	}
+
+	return h, nil
+}
+
+func newAdmissionRequestHandler(c *config.HandlerConfig) (handler.AdmissionRequestHandler, error) {
 	var debug bool
 
 	if c.AdmissionRequestHandler != nil {
-		return c.AdmissionRequestHandler, nil
+		return &recoverAdmissionRequestHandler{c.AdmissionRequestHandler}, nil
 	}
 
 	if os.Getenv(debugEnvVar) != "" {
@@ -67,10 +114,27 @@ func NewAdmissionRequestHandler(c *config.HandlerConfig) (handler.AdmissionReque
 
 // NewInjectionRequestHandler returns InjectionRequestHandler based on specified HandlerConfig.
 func NewInjectionRequestHandler(c *config.HandlerConfig) (handler.InjectionRequestHandler, error) {
+	h, err := newInjectionRequestHandler(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.RecordDir != "" {
+		r, err := newRecorder(c)
+		if err != nil {
+			return nil, err
+		}
+		h = &recordInjectionRequestHandler{InjectionRequestHandler: h, recorder: r}
+	}
+
+	return h, nil
+}
+
+func newInjectionRequestHandler(c *config.HandlerConfig) (handler.InjectionRequestHandler, error) {
 	var debug bool
 
 	if c.InjectionRequestHandler != nil {
-		return c.InjectionRequestHandler, nil
+		return &recoverInjectionRequestHandler{c.InjectionRequestHandler}, nil
 	}
 
 	if os.Getenv(debugEnvVar) != "" {
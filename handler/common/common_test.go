@@ -0,0 +1,77 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+	"github.com/summerwind/whitebox-controller/webhook/injection"
+)
+
+func TestNewStateHandlerWithPanic(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := NewStateHandler(&config.HandlerConfig{
+		StateHandler: &testPanicHandler{},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(&state.State{})
+	Expect(err).To(HaveOccurred())
+	Expect(errors.Is(err, handler.ErrPanic)).To(BeTrue())
+	Expect(err.Error()).To(ContainSubstring("test panic"))
+
+	// The handler can be called again after the panic
+	err = h.HandleState(&state.State{})
+	Expect(errors.Is(err, handler.ErrPanic)).To(BeTrue())
+}
+
+func TestNewAdmissionRequestHandlerWithPanic(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := NewAdmissionRequestHandler(&config.HandlerConfig{
+		AdmissionRequestHandler: &testPanicHandler{},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = h.HandleAdmissionRequest(admission.Request{})
+	Expect(errors.Is(err, handler.ErrPanic)).To(BeTrue())
+
+	wh, ok := h.(handler.AdmissionWarningHandler)
+	Expect(ok).To(BeTrue())
+
+	_, err = wh.HandleAdmissionRequestWithWarnings(admission.Request{})
+	Expect(errors.Is(err, handler.ErrPanic)).To(BeTrue())
+}
+
+func TestNewInjectionRequestHandlerWithPanic(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := NewInjectionRequestHandler(&config.HandlerConfig{
+		InjectionRequestHandler: &testPanicHandler{},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = h.HandleInjectionRequest(injection.Request{})
+	Expect(errors.Is(err, handler.ErrPanic)).To(BeTrue())
+}
+
+type testPanicHandler struct{}
+
+func (h *testPanicHandler) HandleState(s *state.State) error {
+	panic("test panic")
+}
+
+func (h *testPanicHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	panic("test panic")
+}
+
+func (h *testPanicHandler) HandleInjectionRequest(req injection.Request) (injection.Response, error) {
+	panic("test panic")
+}
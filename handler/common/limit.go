@@ -0,0 +1,32 @@
+package common
+
+import (
+	"context"
+
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+// limitStateHandler waits for the limiter before calling the
+// StateHandler, so that an overloaded handler is not called more than
+// the limit at once. Waiting for the limiter is aborted when the
+// context of the state is done.
+type limitStateHandler struct {
+	handler.StateHandler
+	limiter *handler.Limiter
+}
+
+func (h *limitStateHandler) HandleState(s *state.State) error {
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	release, err := h.limiter.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return h.StateHandler.HandleState(s)
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestNewStateHandlerWithLimiter(t *testing.T) {
+	RegisterTestingT(t)
+
+	started := make(chan string, 3)
+	finish := make(chan struct{})
+
+	h, err := NewStateHandler(&config.HandlerConfig{
+		StateHandler: &testFuncHandler{
+			Func: func(s *state.State) error {
+				started <- s.Object.GetName()
+				<-finish
+				return nil
+			},
+		},
+		Limiter: handler.NewLimiter(2),
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	for i := 0; i < 3; i++ {
+		go h.HandleState(newTestState(fmt.Sprintf("test%d", i)))
+	}
+
+	// Only 2 calls are in flight
+	Eventually(func() int { return len(started) }).Should(Equal(2))
+	Consistently(func() int { return len(started) }, 100*time.Millisecond).Should(Equal(2))
+
+	// Waiting for the limiter is aborted by the context
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newTestState("cancelled")
+	s.Context = ctx
+	done := make(chan error)
+	go func() { done <- h.HandleState(s) }()
+
+	cancel()
+	Eventually(done).Should(Receive(Equal(context.Canceled)))
+	Expect(len(started)).To(Equal(2))
+
+	// The third call starts once one of them finishes
+	finish <- struct{}{}
+	Eventually(func() int { return len(started) }).Should(Equal(3))
+
+	close(finish)
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+// keyLock is a set of mutexes for each key. The mutex of a key is
+// removed when it is neither held nor waited for.
+type keyLock struct {
+	mu    sync.Mutex
+	locks map[string]*keyLockEntry
+}
+
+type keyLockEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newKeyLock() *keyLock {
+	return &keyLock{locks: map[string]*keyLockEntry{}}
+}
+
+// lock locks the mutex of specified key and returns a function to
+// unlock it.
+func (l *keyLock) lock(key string) func() {
+	l.mu.Lock()
+	e, ok := l.locks[key]
+	if !ok {
+		e = &keyLockEntry{}
+		l.locks[key] = e
+	}
+	e.refs++
+	l.mu.Unlock()
+
+	e.mu.Lock()
+
+	return func() {
+		e.mu.Unlock()
+
+		l.mu.Lock()
+		e.refs--
+		if e.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// lockStateHandler serializes the calls of the StateHandler for the
+// same object, so that a handler never processes an object while
+// another call for the object is in progress.
+type lockStateHandler struct {
+	handler.StateHandler
+	locks *keyLock
+}
+
+func newLockStateHandler(h handler.StateHandler) *lockStateHandler {
+	return &lockStateHandler{StateHandler: h, locks: newKeyLock()}
+}
+
+func (h *lockStateHandler) HandleState(s *state.State) error {
+	if s.Object == nil {
+		return h.StateHandler.HandleState(s)
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", state.ResourceKey(s.Object.GroupVersionKind()), s.Object.GetNamespace(), s.Object.GetName())
+	unlock := h.locks.lock(key)
+	defer unlock()
+
+	return h.StateHandler.HandleState(s)
+}
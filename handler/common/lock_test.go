@@ -0,0 +1,109 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestNewStateHandlerWithConcurrentCalls(t *testing.T) {
+	RegisterTestingT(t)
+
+	var active, maxActive int32
+
+	h, err := NewStateHandler(&config.HandlerConfig{
+		StateHandler: &testFuncHandler{
+			Func: func(s *state.State) error {
+				n := atomic.AddInt32(&active, 1)
+				defer atomic.AddInt32(&active, -1)
+
+				for {
+					max := atomic.LoadInt32(&maxActive)
+					if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+						break
+					}
+				}
+
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.HandleState(newTestState("test"))
+		}()
+	}
+	wg.Wait()
+
+	Expect(maxActive).To(Equal(int32(1)))
+	Expect(h.(*lockStateHandler).locks.locks).To(BeEmpty())
+}
+
+func TestNewStateHandlerWithDifferentObjects(t *testing.T) {
+	RegisterTestingT(t)
+
+	started := make(chan struct{})
+
+	h, err := NewStateHandler(&config.HandlerConfig{
+		StateHandler: &testFuncHandler{
+			Func: func(s *state.State) error {
+				if s.Object.GetName() == "test1" {
+					close(started)
+					return nil
+				}
+
+				// Blocks until the other object is handled.
+				select {
+				case <-started:
+					return nil
+				case <-time.After(time.Second):
+					return errors.New("timed out")
+				}
+			},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- h.HandleState(newTestState("test2"))
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	err = h.HandleState(newTestState("test1"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(<-errCh).NotTo(HaveOccurred())
+}
+
+type testFuncHandler struct {
+	Func func(*state.State) error
+}
+
+func (h *testFuncHandler) HandleState(s *state.State) error {
+	return h.Func(s)
+}
+
+func newTestState(name string) *state.State {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1alpha1")
+	obj.SetKind("Test")
+	obj.SetNamespace("default")
+	obj.SetName(name)
+
+	return state.New(obj, nil, nil)
+}
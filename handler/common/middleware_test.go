@@ -0,0 +1,81 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestNewStateHandlerWithMiddlewares(t *testing.T) {
+	RegisterTestingT(t)
+
+	calls := []string{}
+	record := func(name string) handler.Middleware {
+		return func(next handler.StateHandler) handler.StateHandler {
+			return handler.StateHandlerFunc(func(s *state.State) error {
+				calls = append(calls, name+"-before")
+				err := next.HandleState(s)
+				calls = append(calls, name+"-after")
+				return err
+			})
+		}
+	}
+
+	handler.RegisterMiddleware("common-test-a", record("a"))
+	handler.RegisterMiddleware("common-test-b", record("b"))
+	handler.RegisterMiddleware("common-test-panic", func(next handler.StateHandler) handler.StateHandler {
+		return handler.StateHandlerFunc(func(s *state.State) error {
+			panic("middleware panic")
+		})
+	})
+	defer handler.SetMiddlewares()
+
+	err := handler.SetMiddlewares("common-test-a", "common-test-b")
+	Expect(err).NotTo(HaveOccurred())
+
+	c := &config.HandlerConfig{
+		StateHandler: &testFuncHandler{
+			Func: func(s *state.State) error {
+				calls = append(calls, "handler")
+				return nil
+			},
+		},
+		Limiter: handler.NewLimiter(1),
+	}
+
+	h, err := NewStateHandler(c)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState("test"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(calls).To(Equal([]string{"a-before", "b-before", "handler", "b-after", "a-after"}))
+
+	// Case: Panic in a middleware
+	err = handler.SetMiddlewares("common-test-a", "common-test-panic")
+	Expect(err).NotTo(HaveOccurred())
+
+	h, err = NewStateHandler(c)
+	Expect(err).NotTo(HaveOccurred())
+
+	calls = []string{}
+	err = h.HandleState(newTestState("test"))
+	Expect(errors.Is(err, handler.ErrPanic)).To(BeTrue())
+	Expect(calls).To(Equal([]string{"a-before"}))
+
+	// Case: No middleware
+	err = handler.SetMiddlewares()
+	Expect(err).NotTo(HaveOccurred())
+
+	h, err = NewStateHandler(c)
+	Expect(err).NotTo(HaveOccurred())
+
+	calls = []string{}
+	err = h.HandleState(newTestState("test"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(calls).To(Equal([]string{"handler"}))
+}
@@ -0,0 +1,249 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+	"github.com/summerwind/whitebox-controller/webhook/injection"
+)
+
+// defaultRecordMaxFiles is the number of the recorded files kept if
+// the maximum is not specified.
+const defaultRecordMaxFiles = 100
+
+// recordPrefix is the prefix of the name of the recorded files. Only
+// the files with this prefix are removed on rotation.
+const recordPrefix = "record-"
+
+// record represents a call of the handler written to a file.
+type record struct {
+	Time   time.Time       `json:"time"`
+	Input  json.RawMessage `json:"input"`
+	Output json.RawMessage `json:"output,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// recorder writes the input and output of each handler call to a
+// timestamped file in the directory, and removes the oldest files if
+// the number of files exceeds the maximum. The fields to be redacted
+// are redacted before writing.
+type recorder struct {
+	dir      string
+	maxFiles int
+	redact   [][]string
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// newRecorder returns a recorder for specified HandlerConfig. It
+// returns an error if the record directory is not writable.
+func newRecorder(c *config.HandlerConfig) (*recorder, error) {
+	err := checkWritableDir(c.RecordDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recordDir: %v", err)
+	}
+
+	var fields []string
+	if c.Exec != nil {
+		fields = c.Exec.RedactFields
+	}
+	if c.HTTP != nil {
+		fields = c.HTTP.RedactFields
+	}
+
+	redact, err := config.ParseRedactFields(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	maxFiles := c.RecordMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultRecordMaxFiles
+	}
+
+	return &recorder{dir: c.RecordDir, maxFiles: maxFiles, redact: redact}, nil
+}
+
+// checkWritableDir checks that specified directory exists and a file
+// can be created in it.
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	f, err := ioutil.TempFile(dir, ".whitebox-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// encode returns specified input or output of the handler in JSON
+// with the fields redacted.
+func (r *recorder) encode(v interface{}) (json.RawMessage, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(handler.Redact(buf, r.redact)), nil
+}
+
+// input returns specified input of the handler in JSON.
+func (r *recorder) input(v interface{}) json.RawMessage {
+	buf, err := r.encode(v)
+	if err != nil {
+		log.Error(err, "Failed to encode the input of handler for recording", "dir", r.dir)
+		return nil
+	}
+	return buf
+}
+
+// write writes a record of the handler call of specified kind. The
+// output is not written if the handler returned an error. Failures are
+// only logged so that the handler call is not affected.
+func (r *recorder) write(kind string, start time.Time, in json.RawMessage, out interface{}, handlerErr error) {
+	rec := record{Time: start, Input: in}
+	if handlerErr != nil {
+		rec.Error = handlerErr.Error()
+	} else {
+		buf, err := r.encode(out)
+		if err != nil {
+			log.Error(err, "Failed to encode the output of handler for recording", "dir", r.dir)
+		}
+		rec.Output = buf
+	}
+
+	buf, err := json.MarshalIndent(&rec, "", "  ")
+	if err != nil {
+		log.Error(err, "Failed to encode the handler record", "dir", r.dir)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	name := fmt.Sprintf("%s%s-%06d-%s.json", recordPrefix, start.UTC().Format("20060102T150405.000000000Z"), r.seq%1000000, kind)
+
+	err = ioutil.WriteFile(filepath.Join(r.dir, name), buf, 0600)
+	if err != nil {
+		log.Error(err, "Failed to write the handler record", "dir", r.dir)
+		return
+	}
+
+	err = r.rotate()
+	if err != nil {
+		log.Error(err, "Failed to remove old handler records", "dir", r.dir)
+	}
+}
+
+// rotate removes the oldest recorded files so that at most maxFiles
+// files are kept. The file names are ordered by their timestamp.
+func (r *recorder) rotate() error {
+	files, err := filepath.Glob(filepath.Join(r.dir, recordPrefix+"*.json"))
+	if err != nil {
+		return err
+	}
+
+	if len(files) <= r.maxFiles {
+		return nil
+	}
+
+	sort.Strings(files)
+	for _, f := range files[:len(files)-r.maxFiles] {
+		err := os.Remove(f)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordStateHandler records the state before and after calling the
+// StateHandler.
+type recordStateHandler struct {
+	handler.StateHandler
+	recorder *recorder
+}
+
+func (h *recordStateHandler) HandleState(s *state.State) error {
+	start := time.Now()
+	in := h.recorder.input(s)
+
+	err := h.StateHandler.HandleState(s)
+	h.recorder.write("state", start, in, s, err)
+
+	return err
+}
+
+// recordAdmissionRequestHandler records the request and the response
+// of the AdmissionRequestHandler. It also implements
+// AdmissionWarningHandler so that the warnings of the wrapped handler
+// are preserved.
+type recordAdmissionRequestHandler struct {
+	handler.AdmissionRequestHandler
+	recorder *recorder
+}
+
+func (h *recordAdmissionRequestHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	start := time.Now()
+	in := h.recorder.input(req)
+
+	res, err := h.AdmissionRequestHandler.HandleAdmissionRequest(req)
+	h.recorder.write("admission", start, in, res, err)
+
+	return res, err
+}
+
+func (h *recordAdmissionRequestHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (handler.AdmissionResponse, error) {
+	wh, ok := h.AdmissionRequestHandler.(handler.AdmissionWarningHandler)
+	if !ok {
+		res, err := h.HandleAdmissionRequest(req)
+		return handler.AdmissionResponse{Response: res}, err
+	}
+
+	start := time.Now()
+	in := h.recorder.input(req)
+
+	res, err := wh.HandleAdmissionRequestWithWarnings(req)
+	h.recorder.write("admission", start, in, res, err)
+
+	return res, err
+}
+
+// recordInjectionRequestHandler records the request and the response
+// of the InjectionRequestHandler.
+type recordInjectionRequestHandler struct {
+	handler.InjectionRequestHandler
+	recorder *recorder
+}
+
+func (h *recordInjectionRequestHandler) HandleInjectionRequest(req injection.Request) (injection.Response, error) {
+	start := time.Now()
+	in := h.recorder.input(req)
+
+	res, err := h.InjectionRequestHandler.HandleInjectionRequest(req)
+	h.recorder.write("injection", start, in, res, err)
+
+	return res, err
+}
@@ -0,0 +1,197 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestNewStateHandlerWithRecordDir(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "record")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	h, err := NewStateHandler(&config.HandlerConfig{
+		StateHandler: &testFuncHandler{
+			Func: func(s *state.State) error {
+				if s.Object.GetName() == "fail" {
+					return errors.New("test error")
+				}
+				s.Object.SetLabels(map[string]string{"handled": "true"})
+				return nil
+			},
+		},
+		RecordDir: dir,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Case: The input and output are recorded
+	err = h.HandleState(newTestState("test"))
+	Expect(err).NotTo(HaveOccurred())
+
+	records := readRecords(dir)
+	Expect(records).To(HaveLen(1))
+	Expect(string(records[0].Input)).To(ContainSubstring(`"name":"test"`))
+	Expect(string(records[0].Input)).NotTo(ContainSubstring(`"handled"`))
+	Expect(string(records[0].Output)).To(ContainSubstring(`"handled":"true"`))
+	Expect(records[0].Error).To(BeEmpty())
+
+	// Case: The error is recorded instead of the output
+	err = h.HandleState(newTestState("fail"))
+	Expect(err).To(HaveOccurred())
+
+	records = readRecords(dir)
+	Expect(records).To(HaveLen(2))
+	Expect(string(records[1].Input)).To(ContainSubstring(`"name":"fail"`))
+	Expect(records[1].Output).To(BeEmpty())
+	Expect(records[1].Error).To(Equal("test error"))
+}
+
+func TestNewStateHandlerWithRecordMaxFiles(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "record")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	// Files not written by the recorder are never removed
+	other := filepath.Join(dir, "other.json")
+	err = ioutil.WriteFile(other, []byte("{}"), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	h, err := NewStateHandler(&config.HandlerConfig{
+		StateHandler: &testFuncHandler{
+			Func: func(s *state.State) error { return nil },
+		},
+		RecordDir:      dir,
+		RecordMaxFiles: 3,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	for i := 0; i < 5; i++ {
+		err = h.HandleState(newTestState(fmt.Sprintf("test%d", i)))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// Only the newest 3 records are kept
+	records := readRecords(dir)
+	Expect(records).To(HaveLen(3))
+	for i, rec := range records {
+		Expect(string(rec.Input)).To(ContainSubstring(fmt.Sprintf(`"name":"test%d"`, i+2)))
+	}
+
+	_, err = os.Stat(other)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func TestNewStateHandlerWithRecordRedaction(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "record")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	h, err := NewStateHandler(&config.HandlerConfig{
+		StateHandler: &testFuncHandler{
+			Func: func(s *state.State) error {
+				s.Object.SetAnnotations(map[string]string{"token": "output-secret"})
+				return nil
+			},
+		},
+		Exec: &config.ExecHandlerConfig{
+			Command:      "/bin/controller",
+			RedactFields: []string{".object.metadata.annotations"},
+		},
+		RecordDir: dir,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState("test")
+	s.Object.SetAnnotations(map[string]string{"token": "input-secret"})
+
+	err = h.HandleState(s)
+	Expect(err).NotTo(HaveOccurred())
+
+	records := readRecords(dir)
+	Expect(records).To(HaveLen(1))
+	Expect(string(records[0].Input)).To(ContainSubstring(`"name":"test"`))
+	Expect(string(records[0].Input)).NotTo(ContainSubstring("input-secret"))
+	Expect(string(records[0].Output)).NotTo(ContainSubstring("output-secret"))
+	Expect(string(records[0].Output)).To(ContainSubstring(`"annotations":"[REDACTED]"`))
+}
+
+func TestNewStateHandlerWithInvalidRecordDir(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "record")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	err = ioutil.WriteFile(file, []byte{}, 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, recordDir := range []string{filepath.Join(dir, "missing"), file} {
+		_, err = NewStateHandler(&config.HandlerConfig{
+			StateHandler: &testFuncHandler{
+				Func: func(s *state.State) error { return nil },
+			},
+			RecordDir: recordDir,
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid recordDir"))
+	}
+
+	// Nothing is left in the record directory by the check
+	files, err := ioutil.ReadDir(dir)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(files).To(HaveLen(1))
+}
+
+// readRecords returns the records in specified directory, oldest first.
+// The input and output of the records are compacted.
+func readRecords(dir string) []record {
+	files, err := filepath.Glob(filepath.Join(dir, recordPrefix+"*.json"))
+	Expect(err).NotTo(HaveOccurred())
+
+	records := []record{}
+	for _, f := range files {
+		buf, err := ioutil.ReadFile(f)
+		Expect(err).NotTo(HaveOccurred())
+
+		rec := record{}
+		err = json.Unmarshal(buf, &rec)
+		Expect(err).NotTo(HaveOccurred())
+
+		rec.Input = compactJSON(rec.Input)
+		rec.Output = compactJSON(rec.Output)
+
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+func compactJSON(buf json.RawMessage) json.RawMessage {
+	if len(buf) == 0 {
+		return buf
+	}
+
+	out := &bytes.Buffer{}
+	err := json.Compact(out, buf)
+	Expect(err).NotTo(HaveOccurred())
+
+	return out.Bytes()
+}
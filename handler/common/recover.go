@@ -0,0 +1,72 @@
+package common
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+	"github.com/summerwind/whitebox-controller/webhook/injection"
+)
+
+var log = logf.Log.WithName("handler")
+
+// recoverPanic converts a panic of in-process handler or middleware
+// into an error. It must be called with defer.
+func recoverPanic(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	*err = fmt.Errorf("%w: %v", handler.ErrPanic, r)
+	log.Error(*err, "Recovered from handler panic", "stack", string(debug.Stack()))
+}
+
+// recoverStateHandler recovers from panics of the StateHandler.
+type recoverStateHandler struct {
+	handler.StateHandler
+}
+
+func (h *recoverStateHandler) HandleState(s *state.State) (err error) {
+	defer recoverPanic(&err)
+	return h.StateHandler.HandleState(s)
+}
+
+// recoverAdmissionRequestHandler recovers from panics of the
+// AdmissionRequestHandler. It also implements AdmissionWarningHandler
+// so that the warnings of the wrapped handler are preserved.
+type recoverAdmissionRequestHandler struct {
+	handler.AdmissionRequestHandler
+}
+
+func (h *recoverAdmissionRequestHandler) HandleAdmissionRequest(req admission.Request) (res admission.Response, err error) {
+	defer recoverPanic(&err)
+	return h.AdmissionRequestHandler.HandleAdmissionRequest(req)
+}
+
+func (h *recoverAdmissionRequestHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (res handler.AdmissionResponse, err error) {
+	defer recoverPanic(&err)
+
+	wh, ok := h.AdmissionRequestHandler.(handler.AdmissionWarningHandler)
+	if ok {
+		return wh.HandleAdmissionRequestWithWarnings(req)
+	}
+
+	res.Response, err = h.AdmissionRequestHandler.HandleAdmissionRequest(req)
+	return res, err
+}
+
+// recoverInjectionRequestHandler recovers from panics of the
+// InjectionRequestHandler.
+type recoverInjectionRequestHandler struct {
+	handler.InjectionRequestHandler
+}
+
+func (h *recoverInjectionRequestHandler) HandleInjectionRequest(req injection.Request) (res injection.Response, err error) {
+	defer recoverPanic(&err)
+	return h.InjectionRequestHandler.HandleInjectionRequest(req)
+}
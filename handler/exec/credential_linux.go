@@ -0,0 +1,55 @@
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// credentialSupported indicates whether the command can be run as
+// the specified user and group.
+const credentialSupported = true
+
+// setCredential sets the user and group of the command. If only one of
+// them is specified, the other is the one of the current process. The
+// supplementary groups are cleared if the current process is root.
+func setCredential(cmd *exec.Cmd, uid, gid *int) {
+	if uid == nil && gid == nil {
+		return
+	}
+
+	cred := &syscall.Credential{
+		Uid:         uint32(os.Getuid()),
+		Gid:         uint32(os.Getgid()),
+		NoSetGroups: os.Getuid() != 0,
+	}
+
+	if uid != nil {
+		cred.Uid = uint32(*uid)
+	}
+
+	if gid != nil {
+		cred.Gid = uint32(*gid)
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+}
+
+// chownCredential changes the owner of specified file to the user and
+// group of the command, so that the command can write to it. The owner
+// is kept if neither of them is specified.
+func chownCredential(name string, uid, gid *int) error {
+	if uid == nil && gid == nil {
+		return nil
+	}
+
+	owner, group := -1, -1
+	if uid != nil {
+		owner = *uid
+	}
+	if gid != nil {
+		group = *gid
+	}
+
+	return os.Chown(name, owner, group)
+}
@@ -0,0 +1,66 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestHandleStateWithRunAsUser(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Changing the user requires root privilege, so the test runs the
+	// command as the current user unless it is root.
+	uid := os.Getuid()
+	gid := os.Getgid()
+	if uid == 0 {
+		uid = 65534
+		gid = 65534
+	}
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:    fmt.Sprintf(`test "$(id -u)" = "%d" && test "$(id -g)" = "%d" && cat`, uid, gid),
+		Shell:      "/bin/sh",
+		RunAsUser:  &uid,
+		RunAsGroup: &gid,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+}
+
+func TestHandleStateWithRunAsUserAndFileOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	uid := os.Getuid()
+	gid := os.Getgid()
+	if uid == 0 {
+		uid = 65534
+		gid = 65534
+	}
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:    `cat > "$WHITEBOX_RESULT_FILE" && echo done`,
+		Shell:      "/bin/sh",
+		OutputMode: config.OutputModeFile,
+		RunAsUser:  &uid,
+		RunAsGroup: &gid,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+}
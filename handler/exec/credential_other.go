@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package exec
+
+import (
+	"os/exec"
+)
+
+// credentialSupported indicates whether the command can be run as
+// the specified user and group.
+const credentialSupported = false
+
+// setCredential does nothing because it is only supported on Linux.
+func setCredential(cmd *exec.Cmd, uid, gid *int) {}
+
+// chownCredential does nothing because it is only supported on Linux.
+func chownCredential(name string, uid, gid *int) error {
+	return nil
+}
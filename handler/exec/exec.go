@@ -4,33 +4,88 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/ghodss/yaml"
+	"k8s.io/client-go/util/jsonpath"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
 	"github.com/summerwind/whitebox-controller/reconciler/state"
 	"github.com/summerwind/whitebox-controller/webhook/injection"
 )
 
+const (
+	// The name of environment variable to pass the path of result file.
+	resultFileEnvVar = "WHITEBOX_RESULT_FILE"
+	// The token that the command writes to stdout as the last line
+	// after writing the result file.
+	resultDoneToken = "done"
+	// The name of environment variable to pass the base64 encoded input.
+	stateEnvVar = "WHITEBOX_STATE_B64"
+	// The name of environment variable to pass the file descriptor of
+	// the log stream.
+	logFDEnvVar = "WHITEBOX_LOG_FD"
+	// The file descriptor of the log stream. This is the first file
+	// descriptor after stdin, stdout and stderr.
+	logFD = 3
+	// The maximum length of a single line of the log stream.
+	maxLogLineSize = 1024 * 1024
+	// The maximum length of a single argument or environment variable.
+	// This is the MAX_ARG_STRLEN of Linux, which is smaller than ARG_MAX.
+	maxArgSize = 128 * 1024
+)
+
 var log = logf.Log.WithName("handler")
 
 type ExecHandler struct {
 	command    string
 	args       []string
 	env        []string
+	inheritEnv bool
 	workingDir string
 	timeout    time.Duration
+	encoding   string
+	inputMode  string
+	outputMode string
+	preExec    []string
+	postExec   []string
+	runAsUser  *int
+	runAsGroup *int
+	noNewPrivs bool
 	debug      bool
+	redact     [][]string
+
+	logPassthrough bool
+
+	envFromFields map[string]string
+	argTemplates  []*template.Template
 }
 
 func New(c *config.ExecHandlerConfig) (*ExecHandler, error) {
+	command := c.Command
 	args := []string{}
+
+	// With a shell, the command is run as a script and the arguments
+	// are passed as positional parameters of the script.
+	if c.Shell != "" {
+		command = c.Shell
+		args = append(args, "-c", c.Command, c.Shell)
+	}
+
 	if c.Args != nil {
 		args = append(args, c.Args...)
 	}
@@ -51,23 +106,114 @@ func New(c *config.ExecHandlerConfig) (*ExecHandler, error) {
 		}
 	}
 
+	inheritEnv := true
+	if c.InheritEnv != nil {
+		inheritEnv = *c.InheritEnv
+	}
+
+	encoding := config.EncodingJSON
+	if c.Encoding != "" {
+		encoding = c.Encoding
+	}
+
+	inputMode := config.InputModeStdin
+	if c.InputMode != "" {
+		inputMode = c.InputMode
+	}
+
+	outputMode := config.OutputModeStdout
+	if c.OutputMode != "" {
+		outputMode = c.OutputMode
+	}
+
+	redact, err := config.ParseRedactFields(c.RedactFields)
+	if err != nil {
+		return nil, err
+	}
+
+	if (c.RunAsUser != nil || c.RunAsGroup != nil) && !credentialSupported {
+		return nil, errors.New("runAsUser and runAsGroup are not supported on this platform")
+	}
+
+	noNewPrivs := c.SecurityProfile != nil && c.SecurityProfile.NoNewPrivs
+	if noNewPrivs && !noNewPrivsSupported {
+		return nil, errors.New("noNewPrivs is not supported on this platform")
+	}
+
+	argTemplates := []*template.Template{}
+	for i, t := range c.ArgTemplates {
+		tmpl, err := config.ParseArgTemplate(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argTemplates[%d]: %v", i, err)
+		}
+		argTemplates = append(argTemplates, tmpl)
+	}
+
 	return &ExecHandler{
-		command:    c.Command,
+		command:    command,
 		args:       args,
 		env:        env,
+		inheritEnv: inheritEnv,
 		workingDir: c.WorkingDir,
 		timeout:    timeout,
+		encoding:   encoding,
+		inputMode:  inputMode,
+		outputMode: outputMode,
+		preExec:    c.PreExec,
+		postExec:   c.PostExec,
+		runAsUser:  c.RunAsUser,
+		runAsGroup: c.RunAsGroup,
+		noNewPrivs: noNewPrivs,
 		debug:      c.Debug,
+		redact:     redact,
+
+		logPassthrough: c.LogPassthrough,
+		envFromFields:  c.EnvFromFields,
+		argTemplates:   argTemplates,
 	}, nil
 }
 
 func (h *ExecHandler) HandleState(s *state.State) error {
-	in, err := json.Marshal(s)
+	var (
+		in  []byte
+		err error
+	)
+
+	if h.encoding == config.EncodingKRM {
+		in, err = encodeResourceList(s)
+	} else {
+		in, err = h.encode(s)
+	}
+	if err != nil {
+		return err
+	}
+
+	var obj map[string]interface{}
+	if s.Object != nil {
+		obj = s.Object.Object
+	}
+
+	fields, err := h.fieldEnv(obj)
 	if err != nil {
 		return err
 	}
 
-	out, err := h.run(in)
+	args, err := h.templateArgs(obj)
+	if err != nil {
+		return err
+	}
+
+	timeout := h.timeout
+	if s.Timeout > 0 {
+		timeout = s.Timeout
+	}
+
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	out, err := h.run(ctx, in, fields, args, timeout)
 	if err != nil {
 		return err
 	}
@@ -76,7 +222,11 @@ func (h *ExecHandler) HandleState(s *state.State) error {
 		return nil
 	}
 
-	err = json.Unmarshal(out, s)
+	if h.encoding == config.EncodingKRM {
+		return decodeResourceList(out, s)
+	}
+
+	err = h.decode(out, s)
 	if err != nil {
 		return err
 	}
@@ -85,19 +235,51 @@ func (h *ExecHandler) HandleState(s *state.State) error {
 }
 
 func (h *ExecHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
-	res := admission.Response{}
+	res, err := h.HandleAdmissionRequestWithWarnings(req)
+	return res.Response, err
+}
+
+func (h *ExecHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (handler.AdmissionResponse, error) {
+	res := handler.AdmissionResponse{}
+
+	if h.encoding == config.EncodingKRM {
+		return res, errKRMNotSupported
+	}
 
-	in, err := json.Marshal(&req)
+	in, err := h.encode(&req)
 	if err != nil {
 		return res, err
 	}
 
-	out, err := h.run(in)
+	raw := req.Object.Raw
+	if len(raw) == 0 {
+		raw = req.OldObject.Raw
+	}
+
+	var obj map[string]interface{}
+	if len(raw) > 0 {
+		err = json.Unmarshal(raw, &obj)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	fields, err := h.fieldEnv(obj)
 	if err != nil {
 		return res, err
 	}
 
-	err = json.Unmarshal(out, &res)
+	args, err := h.templateArgs(obj)
+	if err != nil {
+		return res, err
+	}
+
+	out, err := h.run(context.Background(), in, fields, args, h.timeout)
+	if err != nil {
+		return res, err
+	}
+
+	err = h.decode(out, &res)
 	if err != nil {
 		return res, err
 	}
@@ -108,17 +290,21 @@ func (h *ExecHandler) HandleAdmissionRequest(req admission.Request) (admission.R
 func (h *ExecHandler) HandleInjectionRequest(req injection.Request) (injection.Response, error) {
 	res := injection.Response{}
 
-	in, err := json.Marshal(&req)
+	if h.encoding == config.EncodingKRM {
+		return res, errKRMNotSupported
+	}
+
+	in, err := h.encode(&req)
 	if err != nil {
 		return res, err
 	}
 
-	out, err := h.run(in)
+	out, err := h.run(context.Background(), in, nil, nil, h.timeout)
 	if err != nil {
 		return res, err
 	}
 
-	err = json.Unmarshal(out, &res)
+	err = h.decode(out, &res)
 	if err != nil {
 		return res, err
 	}
@@ -126,30 +312,301 @@ func (h *ExecHandler) HandleInjectionRequest(req injection.Request) (injection.R
 	return res, nil
 }
 
-func (h *ExecHandler) run(buf []byte) ([]byte, error) {
-	var stdout bytes.Buffer
+// environ returns the environment variables for the command with
+// specified extra variables. Configured and extra variables take
+// precedence over the inherited ones.
+func (h *ExecHandler) environ(extra ...string) []string {
+	if !h.inheritEnv {
+		return append(append([]string{}, h.env...), extra...)
+	}
+
+	keys := map[string]struct{}{}
+	for _, kv := range append(append([]string{}, h.env...), extra...) {
+		keys[strings.SplitN(kv, "=", 2)[0]] = struct{}{}
+	}
+
+	env := []string{}
+	for _, kv := range os.Environ() {
+		_, ok := keys[strings.SplitN(kv, "=", 2)[0]]
+		if ok {
+			continue
+		}
+		env = append(env, kv)
+	}
+
+	env = append(env, h.env...)
+	return append(env, extra...)
+}
+
+// fieldEnv returns the environment variables whose values are the
+// fields of specified object. Variables of missing fields are not set.
+func (h *ExecHandler) fieldEnv(obj map[string]interface{}) ([]string, error) {
+	if len(h.envFromFields) == 0 || obj == nil {
+		return nil, nil
+	}
+
+	names := []string{}
+	for name := range h.envFromFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := []string{}
+	for _, name := range names {
+		jp := jsonpath.New(name)
+		jp.AllowMissingKeys(true)
+
+		err := jp.Parse(fmt.Sprintf("{%s}", h.envFromFields[name]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid field path of %s: %v", name, err)
+		}
+
+		results, err := jp.FindResults(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get field of %s: %v", name, err)
+		}
+
+		if len(results) == 0 || len(results[0]) == 0 {
+			continue
+		}
+
+		var buf bytes.Buffer
+		err = jp.PrintResults(&buf, results[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get field of %s: %v", name, err)
+		}
+
+		env = append(env, fmt.Sprintf("%s=%s", name, buf.String()))
+	}
+
+	return env, nil
+}
+
+// templateArgs returns the arguments rendered by the argument templates
+// with specified object.
+func (h *ExecHandler) templateArgs(obj map[string]interface{}) ([]string, error) {
+	if len(h.argTemplates) == 0 || obj == nil {
+		return nil, nil
+	}
+
+	args := []string{}
+	for _, t := range h.argTemplates {
+		var b strings.Builder
+
+		err := t.Execute(&b, obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render args: %v", err)
+		}
+
+		args = append(args, config.SplitArgs(b.String())...)
+	}
+
+	return args, nil
+}
+
+// input returns the arguments, the environment variables and the data
+// of stdin for the command based on the configured input mode and
+// specified variables of the object fields. The extra arguments are
+// appended to the configured arguments. If the
+// base64 encoded input is too large, it is passed via stdin instead.
+func (h *ExecHandler) input(buf []byte, fields, extraArgs []string) ([]string, []string, []byte) {
+	args := h.args
+	if len(extraArgs) > 0 {
+		args = append(append([]string{}, h.args...), extraArgs...)
+	}
+	env := h.environ(fields...)
 
-	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	if h.inputMode == config.InputModeStdin {
+		return args, env, buf
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(buf)
+	if h.inputMode == config.InputModeEnv {
+		encoded = fmt.Sprintf("%s=%s", stateEnvVar, encoded)
+	}
+
+	if len(encoded) >= maxArgSize {
+		log.Info("Input is too large, falling back to stdin", "mode", h.inputMode, "size", len(encoded))
+		return args, env, buf
+	}
+
+	if h.inputMode == config.InputModeArg {
+		args = append(append([]string{}, args...), encoded)
+	} else {
+		env = append(append([]string{}, env...), encoded)
+	}
+
+	return args, env, nil
+}
+
+// encode serializes v into the configured encoding.
+func (h *ExecHandler) encode(v interface{}) ([]byte, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.encoding == config.EncodingYAML {
+		return yaml.JSONToYAML(buf)
+	}
+
+	return buf, nil
+}
+
+// decode parses buf in the configured encoding and stores the
+// result in the value pointed to by v.
+func (h *ExecHandler) decode(buf []byte, v interface{}) error {
+	if h.encoding == config.EncodingYAML {
+		var err error
+		buf, err = yaml.YAMLToJSON(buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return json.Unmarshal(buf, v)
+}
+
+// run runs the pre-exec command, the command and the post-exec command
+// in order within specified timeout. The commands are killed when ctx
+// is cancelled. The post-exec command is run even if the command fails,
+// and the output of the command is returned only if all of them succeed.
+func (h *ExecHandler) run(ctx context.Context, buf []byte, fields, args []string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, h.command, h.args...)
-	cmd.Stdin = bytes.NewReader(buf)
+	if len(h.preExec) > 0 {
+		err := h.runHook(ctx, h.preExec, fields, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("pre-exec command failed: %w", err)
+		}
+	}
+
+	out, err := h.runCommand(ctx, buf, fields, args, timeout)
+
+	if len(h.postExec) > 0 {
+		postErr := h.runHook(ctx, h.postExec, fields, timeout)
+		if postErr != nil && err == nil {
+			err = fmt.Errorf("post-exec command failed: %w", postErr)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// runHook runs the pre-exec or post-exec command.
+func (h *ExecHandler) runHook(ctx context.Context, argv []string, fields []string, timeout time.Duration) error {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = h.environ(fields...)
+	cmd.Dir = h.workingDir
+	setCredential(cmd, h.runAsUser, h.runAsGroup)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := startCommand(cmd, h.noNewPrivs)
+	if err == nil {
+		err = cmd.Wait()
+	}
+	if h.debug && out.Len() > 0 {
+		log.Info("Received hook output", "command", argv[0], "output", out.String())
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w after %s", handler.ErrTimeout, timeout)
+		}
+		if ctx.Err() == context.Canceled {
+			return fmt.Errorf("command aborted: %w", ctx.Err())
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (h *ExecHandler) runCommand(ctx context.Context, buf []byte, fields, extraArgs []string, timeout time.Duration) ([]byte, error) {
+	var stdout bytes.Buffer
+
+	args, env, stdin := h.input(buf, fields, extraArgs)
+
+	cmd := exec.CommandContext(ctx, h.command, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
 	cmd.Stdout = &stdout
-	cmd.Env = append(os.Environ(), h.env...)
+	cmd.Env = env
 	cmd.Dir = h.workingDir
+	setCredential(cmd, h.runAsUser, h.runAsGroup)
+
+	// In file mode, the command writes the result to the file specified
+	// by the environment variable instead of stdout.
+	var resultFile string
+	if h.outputMode == config.OutputModeFile {
+		f, err := ioutil.TempFile("", "whitebox-result-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create result file: %v", err)
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+
+		// The file is owned by the command so that it can write the
+		// result when it runs as another user.
+		err = chownCredential(f.Name(), h.runAsUser, h.runAsGroup)
+		if err != nil {
+			return nil, fmt.Errorf("failed to change owner of result file: %v", err)
+		}
+
+		resultFile = f.Name()
+		cmd.Env = append(append([]string{}, cmd.Env...), fmt.Sprintf("%s=%s", resultFileEnvVar, resultFile))
+	}
+
+	// With log passthrough, the command writes log lines to the log
+	// stream, which are logged concurrently as they are written.
+	var logReader, logWriter *os.File
+	if h.logPassthrough {
+		var err error
+		logReader, logWriter, err = os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create log stream: %v", err)
+		}
+		defer logReader.Close()
+
+		cmd.ExtraFiles = []*os.File{logWriter}
+		cmd.Env = append(append([]string{}, cmd.Env...), fmt.Sprintf("%s=%d", logFDEnvVar, logFD))
+	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
+		if logWriter != nil {
+			logWriter.Close()
+		}
 		return nil, err
 	}
 
-	err = cmd.Start()
+	err = startCommand(cmd, h.noNewPrivs)
+	if logWriter != nil {
+		// The writer is closed so that the reader gets EOF when the
+		// command exits.
+		logWriter.Close()
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	var logDone chan struct{}
+	if logReader != nil {
+		logDone = make(chan struct{})
+		go func() {
+			h.passthroughLogs(logReader)
+			close(logDone)
+		}()
+	}
+
 	if h.debug {
-		log.Info("Sending state", "state", string(buf))
+		log.Info("Sending input", "command", h.command, "input", handler.Redact(buf, h.redact))
 	}
 
 	scanner := bufio.NewScanner(stderr)
@@ -157,14 +614,107 @@ func (h *ExecHandler) run(buf []byte) ([]byte, error) {
 		log.Info(scanner.Text())
 	}
 
+	if logDone != nil {
+		<-logDone
+	}
+
 	err = cmd.Wait()
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%w after %s", handler.ErrTimeout, timeout)
+		}
+		if ctx.Err() == context.Canceled {
+			return nil, fmt.Errorf("command aborted: %w", ctx.Err())
+		}
 		return nil, err
 	}
 
+	out := stdout.Bytes()
+	if resultFile != "" {
+		if h.debug && stdout.Len() > 0 {
+			log.Info("Received output", "command", h.command, "output", handler.Redact(stdout.Bytes(), h.redact))
+		}
+
+		if !resultDone(stdout.Bytes()) {
+			return nil, fmt.Errorf("command did not write '%s' to stdout after writing result file", resultDoneToken)
+		}
+
+		out, err = ioutil.ReadFile(resultFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read result file: %v", err)
+		}
+	}
+
 	if h.debug {
-		log.Info("Received new state", "state", string(stdout.Bytes()), "code", cmd.ProcessState.ExitCode())
+		log.Info("Received result", "command", h.command, "output", handler.Redact(out, h.redact), "code", cmd.ProcessState.ExitCode())
+	}
+
+	return out, nil
+}
+
+// resultDone returns true if the last line of specified stdout is the
+// completion token of the result file.
+func resultDone(stdout []byte) bool {
+	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]) == resultDoneToken
+}
+
+// passthroughLogs logs each line read from the log stream of the
+// command. A line of JSON object is logged with its 'msg' or 'message'
+// field as the message and other fields as the key-value pairs. The
+// redact fields of the handler are redacted in the lines of JSON.
+func (h *ExecHandler) passthroughLogs(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(h.redact) > 0 && json.Valid(line) {
+			line = []byte(handler.Redact(line, h.redact))
+		}
+
+		msg, kvs := parseLogLine(line)
+		log.Info(msg, append([]interface{}{"command", h.command}, kvs...)...)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		log.Error(err, "Failed to read log stream", "command", h.command)
+	}
+
+	// Keep reading the stream to the end so that the command is not
+	// blocked on writing to it.
+	io.Copy(ioutil.Discard, r)
+}
+
+// parseLogLine returns the message and the key-value pairs of specified
+// log line. The line is the message as is unless it is a JSON object.
+func parseLogLine(line []byte) (string, []interface{}) {
+	fields := map[string]interface{}{}
+	err := json.Unmarshal(line, &fields)
+	if err != nil || fields == nil {
+		return string(line), nil
+	}
+
+	msg := ""
+	for _, key := range []string{"msg", "message"} {
+		m, ok := fields[key].(string)
+		if ok {
+			msg = m
+			delete(fields, key)
+			break
+		}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := []interface{}{}
+	for _, key := range keys {
+		kvs = append(kvs, key, fields[key])
 	}
 
-	return stdout.Bytes(), nil
+	return msg, kvs
 }
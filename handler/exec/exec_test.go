@@ -0,0 +1,587 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestHandleStateWithEncoding(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, encoding := range []string{"", config.EncodingJSON, config.EncodingYAML} {
+		h, err := New(&config.ExecHandlerConfig{
+			Command:  "cat",
+			Encoding: encoding,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		s := newTestState()
+		ns := s.Copy()
+
+		err = h.HandleState(ns)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ns.Object).To(Equal(s.Object))
+	}
+}
+
+func TestHandleStateWithShell(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: `test "$1" = "hello" && cat | cat`,
+		Args:    []string{"hello"},
+		Shell:   "/bin/sh",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// Unexpected argument
+	h, err = New(&config.ExecHandlerConfig{
+		Command: `test "$1" = "hello" && cat | cat`,
+		Args:    []string{"world"},
+		Shell:   "/bin/sh",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(s.Copy())
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandleStateWithOutputFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:    `cat > "$WHITEBOX_RESULT_FILE" && echo done`,
+		Shell:      "/bin/sh",
+		OutputMode: config.OutputModeFile,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// Missing completion token
+	commands := []string{
+		`cat > "$WHITEBOX_RESULT_FILE"`,
+		`cat > "$WHITEBOX_RESULT_FILE" && echo done && echo other`,
+	}
+
+	for _, command := range commands {
+		h, err = New(&config.ExecHandlerConfig{
+			Command:    command,
+			Shell:      "/bin/sh",
+			OutputMode: config.OutputModeFile,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = h.HandleState(newTestState())
+		Expect(err).To(HaveOccurred())
+	}
+}
+
+func TestResultDone(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(resultDone([]byte("done\n"))).To(BeTrue())
+	Expect(resultDone([]byte("progress\ndone\n\n"))).To(BeTrue())
+	Expect(resultDone([]byte(""))).To(BeFalse())
+	Expect(resultDone([]byte("done\nprogress\n"))).To(BeFalse())
+	Expect(resultDone([]byte("undone\n"))).To(BeFalse())
+}
+
+func TestHandleStateWithBase64Input(t *testing.T) {
+	RegisterTestingT(t)
+
+	commands := map[string]string{
+		config.InputModeArg: `test -z "$(cat)" && echo "$1" | base64 -d`,
+		config.InputModeEnv: `test -z "$(cat)" && echo "$WHITEBOX_STATE_B64" | base64 -d`,
+	}
+
+	for mode, command := range commands {
+		h, err := New(&config.ExecHandlerConfig{
+			Command:   command,
+			Shell:     "/bin/sh",
+			InputMode: mode,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		s := newTestState()
+		ns := s.Copy()
+
+		err = h.HandleState(ns)
+		Expect(err).NotTo(HaveOccurred(), mode)
+		Expect(ns.Object).To(Equal(s.Object), mode)
+	}
+}
+
+func TestHandleStateWithLargeBase64Input(t *testing.T) {
+	RegisterTestingT(t)
+
+	// The command fails if the input is passed as an argument.
+	for _, mode := range []string{config.InputModeArg, config.InputModeEnv} {
+		h, err := New(&config.ExecHandlerConfig{
+			Command:   `test -z "$1" && test -z "$WHITEBOX_STATE_B64" && cat`,
+			Shell:     "/bin/sh",
+			InputMode: mode,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		s := newTestState()
+		unstructured.SetNestedField(s.Object.Object, strings.Repeat("a", maxArgSize), "spec", "data")
+		ns := s.Copy()
+
+		err = h.HandleState(ns)
+		Expect(err).NotTo(HaveOccurred(), mode)
+		Expect(ns.Object).To(Equal(s.Object), mode)
+	}
+}
+
+func TestHandleStateWithHooks(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "exec")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "log")
+	env := map[string]string{"LOG_FILE": logFile}
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:  `echo main >> "$LOG_FILE" && cat`,
+		Shell:    "/bin/sh",
+		Env:      env,
+		PreExec:  []string{"/bin/sh", "-c", `echo pre >> "$LOG_FILE"`},
+		PostExec: []string{"/bin/sh", "-c", `echo post >> "$LOG_FILE"`},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	buf, err := ioutil.ReadFile(logFile)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(buf)).To(Equal("pre\nmain\npost\n"))
+
+	// Failing pre-exec command
+	os.Remove(logFile)
+	h, err = New(&config.ExecHandlerConfig{
+		Command:  `echo main >> "$LOG_FILE" && cat`,
+		Shell:    "/bin/sh",
+		Env:      env,
+		PreExec:  []string{"/bin/sh", "-c", `echo pre >> "$LOG_FILE" && exit 1`},
+		PostExec: []string{"/bin/sh", "-c", `echo post >> "$LOG_FILE"`},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(s.Copy())
+	Expect(err).To(HaveOccurred())
+
+	buf, err = ioutil.ReadFile(logFile)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(buf)).To(Equal("pre\n"))
+
+	// Failing post-exec command
+	h, err = New(&config.ExecHandlerConfig{
+		Command:  "cat",
+		PostExec: []string{"false"},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(s.Copy())
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandleStateWithEnvFromFields(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: `test "$NAMESPACE/$NAME/$MESSAGE" = "default/test/hello" && test -z "${MISSING+x}" && cat`,
+		Shell:   "/bin/sh",
+		EnvFromFields: map[string]string{
+			"NAMESPACE": ".metadata.namespace",
+			"NAME":      ".metadata.name",
+			"MESSAGE":   ".spec.message",
+			"MISSING":   ".spec.missing",
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// The values of the fields
+	env, err := h.fieldEnv(s.Object.Object)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(env).To(Equal([]string{"MESSAGE=hello", "NAME=test", "NAMESPACE=default"}))
+
+	// The values of fields override the inherited variables
+	os.Setenv("MESSAGE", "parent")
+	defer os.Unsetenv("MESSAGE")
+
+	environ := h.environ(env...)
+	Expect(environ).To(ContainElement("MESSAGE=hello"))
+	Expect(environ).NotTo(ContainElement("MESSAGE=parent"))
+}
+
+func TestHandleStateWithArgTemplates(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: `test "$*" = "--name=test --item=a --item=b c --message=hello world" && test "$#" = "4" && cat`,
+		Shell:   "/bin/sh",
+		Args:    []string{"--name=test"},
+		ArgTemplates: []string{
+			`{{range .spec.items}}{{arg "--item=" .}}{{end}}`,
+			`--message={{.spec.message}}`,
+			`{{range .spec.empty}}{{arg "--empty=" .}}{{end}}`,
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	unstructured.SetNestedStringSlice(s.Object.Object, []string{"a", "b c"}, "spec", "items")
+	unstructured.SetNestedStringSlice(s.Object.Object, []string{}, "spec", "empty")
+	unstructured.SetNestedField(s.Object.Object, "hello world", "spec", "message")
+
+	// The list field expands into multiple args
+	args, err := h.templateArgs(s.Object.Object)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(args).To(Equal([]string{"--item=a", "--item=b c", "--message=hello world"}))
+
+	ns := s.Copy()
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// Missing field
+	h, err = New(&config.ExecHandlerConfig{
+		Command:      "cat",
+		ArgTemplates: []string{`{{range .spec.missing}}{{arg .}}{{end}}`},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).To(HaveOccurred())
+
+	// Invalid template
+	_, err = New(&config.ExecHandlerConfig{
+		Command:      "cat",
+		ArgTemplates: []string{`{{range .spec.items}}`},
+	})
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandleStateWithTimeout(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: "sleep 1 && cat",
+		Shell:   "/bin/sh",
+		Timeout: "100ms",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Handler timeout
+	err = h.HandleState(newTestState())
+	Expect(errors.Is(err, handler.ErrTimeout)).To(BeTrue())
+
+	// Timeout of the state takes precedence
+	s := newTestState()
+	s.Timeout = 5 * time.Second
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+}
+
+func TestHandleStateWithCancel(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: "exec sleep 5",
+		Shell:   "/bin/sh",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	s := newTestState()
+	s.Context = ctx
+
+	start := time.Now()
+	err = h.HandleState(s)
+	Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	Expect(errors.Is(err, handler.ErrTimeout)).To(BeFalse())
+	Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+}
+
+func TestHandleStateWithLogPassthrough(t *testing.T) {
+	RegisterTestingT(t)
+
+	tl := &testLogger{}
+	defer func(l logr.Logger) { log = l }(log)
+	log = tl
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:        `echo "{\"msg\": \"first\", \"step\": 1}" >&${WHITEBOX_LOG_FD} && sleep 1 && echo second >&3 && cat`,
+		Shell:          "/bin/sh",
+		LogPassthrough: true,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	done := make(chan error)
+	go func() {
+		done <- h.HandleState(ns)
+	}()
+
+	// The line is logged before the command exits
+	Eventually(tl.Messages, 500*time.Millisecond).Should(ContainElement("first"))
+	Expect(tl.Messages()).NotTo(ContainElement("second"))
+	Expect(tl.KeysAndValues("first")).To(Equal([]interface{}{"command", "/bin/sh", "step", float64(1)}))
+
+	Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+	Expect(tl.Messages()).To(ContainElement("second"))
+	Expect(tl.KeysAndValues("second")).To(Equal([]interface{}{"command", "/bin/sh"}))
+	Expect(ns.Object).To(Equal(s.Object))
+}
+
+func TestHandleStateWithLongLogLine(t *testing.T) {
+	RegisterTestingT(t)
+
+	tl := &testLogger{}
+	defer func(l logr.Logger) { log = l }(log)
+	log = tl
+
+	// The command must not be blocked on writing the rest of the stream
+	// after the line that exceeds the maximum length.
+	h, err := New(&config.ExecHandlerConfig{
+		Command:        `head -c 2000000 /dev/zero | tr '\0' a >&3 && echo >&3 && seq 1 100000 >&3 && cat`,
+		Shell:          "/bin/sh",
+		Timeout:        "10s",
+		LogPassthrough: true,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	ns := s.Copy()
+
+	done := make(chan error)
+	go func() {
+		done <- h.HandleState(ns)
+	}()
+
+	Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+	Expect(tl.Messages()).To(ContainElement("Failed to read log stream"))
+	Expect(ns.Object).To(Equal(s.Object))
+}
+
+func TestHandleStateWithLogPassthroughRedaction(t *testing.T) {
+	RegisterTestingT(t)
+
+	tl := &testLogger{}
+	defer func(l logr.Logger) { log = l }(log)
+	log = tl
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:        `echo '{"msg": "login", "password": "secret", "user": "foo"}' >&3 && echo 'password: secret' >&3 && cat`,
+		Shell:          "/bin/sh",
+		LogPassthrough: true,
+		RedactFields:   []string{".password"},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	Expect(h.HandleState(s.Copy())).To(Succeed())
+
+	Expect(tl.KeysAndValues("login")).To(Equal([]interface{}{"command", "/bin/sh", "password", handler.Redacted, "user", "foo"}))
+	// Lines other than JSON are logged as is
+	Expect(tl.Messages()).To(ContainElement("password: secret"))
+}
+
+func TestParseLogLine(t *testing.T) {
+	RegisterTestingT(t)
+
+	msg, kvs := parseLogLine([]byte("plain text"))
+	Expect(msg).To(Equal("plain text"))
+	Expect(kvs).To(BeEmpty())
+
+	msg, kvs = parseLogLine([]byte(`{"message": "hello", "b": "x", "a": true}`))
+	Expect(msg).To(Equal("hello"))
+	Expect(kvs).To(Equal([]interface{}{"a", true, "b", "x"}))
+
+	// Not a JSON object
+	msg, kvs = parseLogLine([]byte(`["hello"]`))
+	Expect(msg).To(Equal(`["hello"]`))
+	Expect(kvs).To(BeEmpty())
+
+	msg, kvs = parseLogLine([]byte("null"))
+	Expect(msg).To(Equal("null"))
+	Expect(kvs).To(BeEmpty())
+}
+
+func TestHandleAdmissionRequestWithWarnings(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: `echo '{"allowed": true, "warnings": ["spec.foo is deprecated"]}'`,
+		Shell:   "/bin/sh",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res, err := h.HandleAdmissionRequestWithWarnings(admission.Request{})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(res.Allowed).To(BeTrue())
+	Expect(res.Warnings).To(Equal([]string{"spec.foo is deprecated"}))
+}
+
+func TestEncode(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:  "cat",
+		Encoding: config.EncodingYAML,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	buf, err := h.encode(newTestState())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(buf)).To(ContainSubstring("kind: Test\n"))
+
+	s := &state.State{}
+	err = h.decode(buf, s)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(s.Object.GetName()).To(Equal("test"))
+}
+
+func TestEnviron(t *testing.T) {
+	RegisterTestingT(t)
+
+	os.Setenv("WHITEBOX_TEST_INHERITED", "parent")
+	os.Setenv("WHITEBOX_TEST_OVERRIDDEN", "parent")
+	defer os.Unsetenv("WHITEBOX_TEST_INHERITED")
+	defer os.Unsetenv("WHITEBOX_TEST_OVERRIDDEN")
+
+	env := map[string]string{
+		"WHITEBOX_TEST_OVERRIDDEN": "child",
+	}
+
+	// Inherit by default
+	h, err := New(&config.ExecHandlerConfig{
+		Command: "cat",
+		Env:     env,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	environ := h.environ()
+	Expect(environ).To(ContainElement("WHITEBOX_TEST_INHERITED=parent"))
+	Expect(environ).To(ContainElement("WHITEBOX_TEST_OVERRIDDEN=child"))
+	Expect(environ).NotTo(ContainElement("WHITEBOX_TEST_OVERRIDDEN=parent"))
+
+	// No inheritance
+	inherit := false
+	h, err = New(&config.ExecHandlerConfig{
+		Command:    "cat",
+		Env:        env,
+		InheritEnv: &inherit,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	environ = h.environ()
+	Expect(environ).To(Equal([]string{"WHITEBOX_TEST_OVERRIDDEN=child"}))
+}
+
+func newTestState() *state.State {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1alpha1")
+	obj.SetKind("Test")
+	obj.SetNamespace("default")
+	obj.SetName("test")
+	unstructured.SetNestedField(obj.Object, "hello", "spec", "message")
+
+	return state.New(obj, nil, nil)
+}
+
+// testLogger records the messages and the key-value pairs of the logs.
+type testLogger struct {
+	logr.Logger
+	mu      sync.Mutex
+	entries []testLogEntry
+}
+
+type testLogEntry struct {
+	msg           string
+	keysAndValues []interface{}
+}
+
+func (l *testLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, testLogEntry{msg: msg, keysAndValues: keysAndValues})
+}
+
+func (l *testLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Info(msg, append([]interface{}{"error", err}, keysAndValues...)...)
+}
+
+func (l *testLogger) Messages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	messages := []string{}
+	for _, e := range l.entries {
+		messages = append(messages, e.msg)
+	}
+	return messages
+}
+
+func (l *testLogger) KeysAndValues(msg string) []interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, e := range l.entries {
+		if e.msg == msg {
+			return e.keysAndValues
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+const (
+	// The API version and kind of the ResourceList of KRM functions.
+	// See: https://github.com/kubernetes-sigs/kustomize/blob/master/cmd/config/docs/api-conventions/functions-spec.md
+	resourceListAPIVersion = "config.kubernetes.io/v1"
+	resourceListKind       = "ResourceList"
+	// The annotation that marks the item not to be applied. References
+	// are passed with this annotation and dropped from the output.
+	annotationLocalConfig = "config.kubernetes.io/local-config"
+	// The severity of the result that fails the function.
+	severityError = "error"
+)
+
+var errKRMNotSupported = errors.New("krm encoding is only supported by reconciler and finalizer")
+
+// resourceList is the input and output of KRM functions.
+type resourceList struct {
+	APIVersion     string                       `json:"apiVersion"`
+	Kind           string                       `json:"kind"`
+	Items          []*unstructured.Unstructured `json:"items"`
+	FunctionConfig *unstructured.Unstructured   `json:"functionConfig,omitempty"`
+	Results        []resourceListResult         `json:"results,omitempty"`
+}
+
+// resourceListResult is a result reported by KRM functions.
+type resourceListResult struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// encodeResourceList returns specified state as a ResourceList in YAML.
+// The items are the object, the dependents and the references in order.
+// The object is also passed as the functionConfig.
+func encodeResourceList(s *state.State) ([]byte, error) {
+	rl := resourceList{
+		APIVersion: resourceListAPIVersion,
+		Kind:       resourceListKind,
+		Items:      []*unstructured.Unstructured{},
+	}
+
+	if s.Object != nil {
+		rl.Items = append(rl.Items, s.Object)
+		rl.FunctionConfig = s.Object
+	}
+
+	for _, key := range sortedKeys(s.Dependents) {
+		rl.Items = append(rl.Items, s.Dependents[key]...)
+	}
+
+	for _, key := range sortedKeys(s.References) {
+		for _, ref := range s.References[key] {
+			ref = ref.DeepCopy()
+			annotations := ref.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[annotationLocalConfig] = "true"
+			ref.SetAnnotations(annotations)
+
+			rl.Items = append(rl.Items, ref)
+		}
+	}
+
+	buf, err := json.Marshal(&rl)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.JSONToYAML(buf)
+}
+
+// decodeResourceList parses buf as a ResourceList in YAML or JSON and
+// stores the items in specified state. The item of the object replaces
+// the object, the items of the dependent kinds replace the dependents
+// and the others are returned as the additional objects. The items
+// marked as local config are dropped. It returns an error if the
+// function reported an error result.
+func decodeResourceList(buf []byte, s *state.State) error {
+	buf, err := yaml.YAMLToJSON(buf)
+	if err != nil {
+		return err
+	}
+
+	rl := resourceList{}
+	err = json.Unmarshal(buf, &rl)
+	if err != nil {
+		return err
+	}
+
+	if rl.Kind != resourceListKind {
+		return fmt.Errorf("invalid kind of the output: %q", rl.Kind)
+	}
+
+	messages := []string{}
+	for _, r := range rl.Results {
+		if r.Severity == severityError {
+			messages = append(messages, r.Message)
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("function failed: %s", strings.Join(messages, "; "))
+	}
+
+	deps := map[string][]*unstructured.Unstructured{}
+	for key := range s.Dependents {
+		deps[key] = []*unstructured.Unstructured{}
+	}
+	objects := []*unstructured.Unstructured{}
+
+	for i, item := range rl.Items {
+		if item == nil {
+			return fmt.Errorf("items[%d]: item is empty", i)
+		}
+
+		if item.GetAnnotations()[annotationLocalConfig] == "true" {
+			continue
+		}
+
+		if s.Object != nil && isSameObject(s.Object, item) {
+			s.Object = item
+			continue
+		}
+
+		key := state.ResourceKey(item.GroupVersionKind())
+		_, ok := deps[key]
+		if ok {
+			deps[key] = append(deps[key], item)
+			continue
+		}
+
+		objects = append(objects, item)
+	}
+
+	s.Dependents = deps
+	if len(objects) > 0 {
+		s.Objects = objects
+	}
+
+	return nil
+}
+
+// isSameObject returns whether specified objects have the same kind,
+// namespace and name.
+func isSameObject(a, b *unstructured.Unstructured) bool {
+	agvk := a.GroupVersionKind()
+	bgvk := b.GroupVersionKind()
+
+	return agvk.Group == bgvk.Group && agvk.Kind == bgvk.Kind &&
+		a.GetNamespace() == b.GetNamespace() && a.GetName() == b.GetName()
+}
+
+func sortedKeys(m map[string][]*unstructured.Unstructured) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
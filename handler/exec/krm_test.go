@@ -0,0 +1,178 @@
+package exec
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestHandleStateWithKRMEncoding(t *testing.T) {
+	RegisterTestingT(t)
+
+	// A sample function that updates the message of all items.
+	h, err := New(&config.ExecHandlerConfig{
+		Command:  `in=$(cat) && echo "$in" | grep -q "^kind: ResourceList" && echo "$in" | sed 's/message: hello$/message: world/'`,
+		Shell:    "/bin/sh",
+		Encoding: config.EncodingKRM,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newKRMTestState()
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+
+	// The object is updated by the function
+	msg, _, _ := unstructured.NestedString(ns.Object.Object, "spec", "message")
+	Expect(msg).To(Equal("world"))
+	Expect(ns.Object.GetName()).To(Equal("test"))
+
+	// The dependents are round-tripped
+	Expect(ns.Dependents).To(HaveLen(1))
+	Expect(ns.Dependents["configmap.v1"]).To(HaveLen(1))
+	Expect(ns.Dependents["configmap.v1"][0].GetName()).To(Equal("test-config"))
+	msg, _, _ = unstructured.NestedString(ns.Dependents["configmap.v1"][0].Object, "data", "message")
+	Expect(msg).To(Equal("world"))
+
+	// The references are dropped from the output
+	Expect(ns.Objects).To(BeEmpty())
+	Expect(ns.References).To(Equal(s.References))
+	Expect(s.References["secret.v1"][0].GetAnnotations()).To(BeEmpty())
+}
+
+func TestHandleStateWithKRMOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: `cat > /dev/null && cat <<EOF
+apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: example.com/v1alpha1
+  kind: Test
+  metadata:
+    name: test
+    namespace: default
+  status:
+    phase: Ready
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: new-config
+    namespace: default
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: test
+    namespace: default
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: test-secret
+    namespace: default
+    annotations:
+      config.kubernetes.io/local-config: "true"
+results:
+- message: all good
+  severity: info
+EOF`,
+		Shell:    "/bin/sh",
+		Encoding: config.EncodingKRM,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	ns := newKRMTestState()
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+
+	phase, _, _ := unstructured.NestedString(ns.Object.Object, "status", "phase")
+	Expect(phase).To(Equal("Ready"))
+
+	// The dependent omitted by the function is removed
+	Expect(ns.Dependents["configmap.v1"]).To(HaveLen(1))
+	Expect(ns.Dependents["configmap.v1"][0].GetName()).To(Equal("new-config"))
+
+	// The items of other kinds are the additional objects
+	Expect(ns.Objects).To(HaveLen(1))
+	Expect(ns.Objects[0].GetKind()).To(Equal("Service"))
+}
+
+func TestHandleStateWithKRMErrorResult(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command: `cat > /dev/null && cat <<EOF
+apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items: []
+results:
+- message: invalid spec
+  severity: error
+EOF`,
+		Shell:    "/bin/sh",
+		Encoding: config.EncodingKRM,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	ns := newKRMTestState()
+	err = h.HandleState(ns)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("invalid spec"))
+
+	// Case: Output that is not a ResourceList
+	h, err = New(&config.ExecHandlerConfig{
+		Command:  `cat > /dev/null && echo "kind: Test"`,
+		Shell:    "/bin/sh",
+		Encoding: config.EncodingKRM,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newKRMTestState())
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandleAdmissionRequestWithKRMEncoding(t *testing.T) {
+	RegisterTestingT(t)
+
+	h, err := New(&config.ExecHandlerConfig{
+		Command:  "cat",
+		Encoding: config.EncodingKRM,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = h.HandleAdmissionRequest(admission.Request{})
+	Expect(err).To(Equal(errKRMNotSupported))
+}
+
+func newKRMTestState() *state.State {
+	s := newTestState()
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("default")
+	cm.SetName("test-config")
+	unstructured.SetNestedField(cm.Object, "hello", "data", "message")
+
+	secret := &unstructured.Unstructured{}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetNamespace("default")
+	secret.SetName("test-secret")
+
+	s.Dependents = map[string][]*unstructured.Unstructured{
+		"configmap.v1": {cm},
+	}
+	s.References = map[string][]*unstructured.Unstructured{
+		"secret.v1": {secret},
+	}
+
+	return s
+}
@@ -0,0 +1,40 @@
+package exec
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// noNewPrivsSupported indicates whether the no_new_privs flag can be
+// set to the command.
+const noNewPrivsSupported = true
+
+// prSetNoNewPrivs is PR_SET_NO_NEW_PRIVS of prctl(2).
+const prSetNoNewPrivs = 38
+
+// startCommand starts the command. If noNewPrivs is true, the command
+// is started from a dedicated thread that has the no_new_privs flag,
+// since the flag is inherited by the command on fork.
+func startCommand(cmd *exec.Cmd, noNewPrivs bool) error {
+	if !noNewPrivs {
+		return cmd.Start()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		// The thread is never unlocked so that it is terminated with
+		// the goroutine instead of being reused with the flag.
+		runtime.LockOSThread()
+
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0)
+		if errno != 0 {
+			errCh <- errno
+			return
+		}
+
+		errCh <- cmd.Start()
+	}()
+
+	return <-errCh
+}
@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestHandleStateWithNoNewPrivs(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := newTestState()
+
+	// The command fails unless the flag is set.
+	c := &config.ExecHandlerConfig{
+		Command: `grep -q "^NoNewPrivs:[[:space:]]*1" /proc/self/status && cat`,
+		Shell:   "/bin/sh",
+		PreExec: []string{"/bin/sh", "-c", `grep -q "^NoNewPrivs:[[:space:]]*1" /proc/self/status`},
+		SecurityProfile: &config.SecurityProfileConfig{
+			NoNewPrivs: true,
+		},
+	}
+
+	h, err := New(c)
+	Expect(err).NotTo(HaveOccurred())
+
+	ns := s.Copy()
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// The flag is not set to the controller process.
+	status, err := ioutil.ReadFile("/proc/self/status")
+	Expect(err).NotTo(HaveOccurred())
+	if strings.Contains(string(status), "NoNewPrivs:\t0") {
+		c.SecurityProfile = nil
+		h, err = New(c)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = h.HandleState(s.Copy())
+		Expect(err).To(HaveOccurred())
+	}
+}
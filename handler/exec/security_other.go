@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package exec
+
+import (
+	"os/exec"
+)
+
+// noNewPrivsSupported indicates whether the no_new_privs flag can be
+// set to the command.
+const noNewPrivsSupported = false
+
+// startCommand starts the command. The no_new_privs flag is ignored
+// because it is only supported on Linux.
+func startCommand(cmd *exec.Cmd, noNewPrivs bool) error {
+	return cmd.Start()
+}
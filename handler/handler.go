@@ -1,12 +1,23 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/summerwind/whitebox-controller/reconciler/state"
 	"github.com/summerwind/whitebox-controller/webhook/injection"
 )
 
+var (
+	// ErrTimeout is returned when a handler does not complete in time.
+	ErrTimeout = errors.New("handler timed out")
+	// ErrPanic is returned when an in-process handler panics.
+	ErrPanic = errors.New("handler panicked")
+)
+
 type Handler interface {
 	Run(buf []byte) ([]byte, error)
 }
@@ -19,6 +30,33 @@ type AdmissionRequestHandler interface {
 	HandleAdmissionRequest(admission.Request) (admission.Response, error)
 }
 
+// AdmissionResponse represents the output of admission request handler.
+// Warnings are returned to the user who made the admission request.
+// JSONPatch is a RFC6902 JSON Patch that is returned to the API server
+// as is by mutation webhook. Inject is translated into the patch of the
+// Pod by mutation webhook.
+type AdmissionResponse struct {
+	admission.Response
+	Warnings  []string        `json:"warnings,omitempty"`
+	JSONPatch json.RawMessage `json:"jsonPatch,omitempty"`
+	Inject    *PodInjection   `json:"inject,omitempty"`
+}
+
+// PodInjection represents the containers, volumes and environment
+// variables to be injected into a Pod. Env is added to all containers
+// of the Pod that do not define the variable.
+type PodInjection struct {
+	Containers []corev1.Container `json:"containers,omitempty"`
+	Volumes    []corev1.Volume    `json:"volumes,omitempty"`
+	Env        []corev1.EnvVar    `json:"env,omitempty"`
+}
+
+// AdmissionWarningHandler is an admission request handler that returns
+// warnings along with the response.
+type AdmissionWarningHandler interface {
+	HandleAdmissionRequestWithWarnings(admission.Request) (AdmissionResponse, error)
+}
+
 type InjectionRequestHandler interface {
 	HandleInjectionRequest(injection.Request) (injection.Response, error)
 }
@@ -2,28 +2,63 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
+	"golang.org/x/time/rate"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
 	"github.com/summerwind/whitebox-controller/reconciler/state"
 	"github.com/summerwind/whitebox-controller/webhook/injection"
 )
 
-var defaultTimeout = 60 * time.Second
+// SignatureHeader is the header of the request that contains the
+// signature of the request body.
+const SignatureHeader = "X-Signature"
+
+// RequeueAfterHeader is the header of the response that requeues the
+// object after the duration, in seconds or in the Go language's
+// duration string. The requeue in the response body takes precedence.
+const RequeueAfterHeader = "X-Requeue-After"
+
+var (
+	log            = logf.Log.WithName("handler")
+	defaultTimeout = 60 * time.Second
+)
 
 type HTTPHandler struct {
-	client *http.Client
-	url    string
-	debug  bool
+	client       *http.Client
+	timeout      time.Duration
+	url          *template.Template
+	headers      map[string]string
+	compression  string
+	userAgent    string
+	debug        bool
+	redact       [][]string
+	signingKey   []byte
+	successCodes map[int]struct{}
+	limiter      *rate.Limiter
 }
 
 func New(c *config.HTTPHandlerConfig) (*HTTPHandler, error) {
@@ -41,6 +76,32 @@ func New(c *config.HTTPHandlerConfig) (*HTTPHandler, error) {
 		timeout = defaultTimeout
 	}
 
+	u, err := config.ParseURLTemplate(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+
+	redact, err := config.ParseRedactFields(c.RedactFields)
+	if err != nil {
+		return nil, err
+	}
+
+	var signingKey []byte
+	if c.SigningKeyFile != "" {
+		signingKey, err = ioutil.ReadFile(c.SigningKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %v", err)
+		}
+	}
+
+	var successCodes map[int]struct{}
+	if len(c.SuccessStatusCodes) > 0 {
+		successCodes = map[int]struct{}{}
+		for _, code := range c.SuccessStatusCodes {
+			successCodes[code] = struct{}{}
+		}
+	}
+
 	tlsConfig := &tls.Config{}
 
 	if c.TLS != nil {
@@ -54,65 +115,188 @@ func New(c *config.HTTPHandlerConfig) (*HTTPHandler, error) {
 			tlsConfig.BuildNameToCertificate()
 		}
 
-		if c.TLS.CACertFile != "" {
-			caCert, err := ioutil.ReadFile(c.TLS.CACertFile)
+		if c.TLS.CACertFile != "" || c.TLS.CACertDir != "" {
+			caCertPool, err := newCertPool(c.TLS)
 			if err != nil {
 				return nil, err
 			}
 
-			caCertPool := x509.NewCertPool()
-			caCertPool.AppendCertsFromPEM(caCert)
-
 			tlsConfig.RootCAs = caCertPool
 		}
 	}
 
 	client := &http.Client{
-		Timeout: timeout,
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 		},
 	}
 
 	return &HTTPHandler{
-		client: client,
-		url:    c.URL,
-		debug:  c.Debug,
+		client:       client,
+		timeout:      timeout,
+		url:          u,
+		headers:      c.Headers,
+		compression:  c.Compression,
+		userAgent:    c.UserAgent,
+		debug:        c.Debug,
+		redact:       redact,
+		signingKey:   signingKey,
+		successCodes: successCodes,
+		limiter:      c.RateLimiter,
 	}, nil
 }
 
+// newCertPool returns a pool of the CA certificates of the CA
+// certificate file and all PEM files in the CA certificate directory.
+// Files in the directory that contain no certificate are ignored.
+func newCertPool(c *config.TLSConfig) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	if c.CACertFile != "" {
+		caCert, err := ioutil.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool.AppendCertsFromPEM(caCert)
+	}
+
+	if c.CACertDir != "" {
+		files, err := ioutil.ReadDir(c.CACertDir)
+		if err != nil {
+			return nil, err
+		}
+
+		loaded := 0
+		for _, f := range files {
+			// Symbolic links are followed as trust bundles of some
+			// distributions are linked from the directory.
+			p := filepath.Join(c.CACertDir, f.Name())
+			info, err := os.Stat(p)
+			if err != nil || info.IsDir() {
+				continue
+			}
+
+			caCert, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			if pool.AppendCertsFromPEM(caCert) {
+				loaded++
+			}
+		}
+
+		if loaded == 0 {
+			return nil, fmt.Errorf("no CA certificate found in %s", c.CACertDir)
+		}
+	}
+
+	return pool, nil
+}
+
 func (h *HTTPHandler) HandleState(s *state.State) error {
 	in, err := json.Marshal(s)
 	if err != nil {
 		return err
 	}
 
-	out, err := h.run(in)
+	var obj map[string]interface{}
+	if s.Object != nil {
+		obj = s.Object.Object
+	}
+
+	u, err := h.renderURL(obj)
 	if err != nil {
 		return err
 	}
 
-	if len(out) == 0 {
-		return nil
+	timeout := h.timeout
+	if s.Timeout > 0 {
+		timeout = s.Timeout
 	}
 
-	err = json.Unmarshal(out, s)
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	out, header, err := h.run(ctx, u, in, timeout)
 	if err != nil {
 		return err
 	}
 
+	if len(out) > 0 {
+		err = json.Unmarshal(out, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !s.Requeue && s.RequeueAfter == 0 {
+		s.RequeueAfter = requeueAfter(header)
+	}
+
 	return nil
 }
 
+// requeueAfter returns the duration of RequeueAfterHeader in specified
+// header of the response. Invalid durations are ignored.
+func requeueAfter(header http.Header) state.Duration {
+	val := strings.TrimSpace(header.Get(RequeueAfterHeader))
+	if val == "" {
+		return 0
+	}
+
+	sec, err := strconv.Atoi(val)
+	if err == nil {
+		if sec < 0 {
+			log.Info("Ignored an invalid requeue header", "value", val)
+			return 0
+		}
+		return state.Duration(sec)
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		log.Info("Ignored an invalid requeue header", "value", val)
+		return 0
+	}
+
+	// Round up to keep a positive duration shorter than one second.
+	return state.Duration(math.Ceil(d.Seconds()))
+}
+
 func (h *HTTPHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
-	res := admission.Response{}
+	res, err := h.HandleAdmissionRequestWithWarnings(req)
+	return res.Response, err
+}
+
+func (h *HTTPHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (handler.AdmissionResponse, error) {
+	res := handler.AdmissionResponse{}
 
 	in, err := json.Marshal(&req)
 	if err != nil {
 		return res, err
 	}
 
-	out, err := h.run(in)
+	raw := req.Object.Raw
+	if len(raw) == 0 {
+		raw = req.OldObject.Raw
+	}
+
+	var obj map[string]interface{}
+	if len(raw) > 0 {
+		err = json.Unmarshal(raw, &obj)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	u, err := h.renderURL(obj)
+	if err != nil {
+		return res, err
+	}
+
+	out, _, err := h.run(context.Background(), u, in, h.timeout)
 	if err != nil {
 		return res, err
 	}
@@ -133,7 +317,12 @@ func (h *HTTPHandler) HandleInjectionRequest(req injection.Request) (injection.R
 		return res, err
 	}
 
-	out, err := h.run(in)
+	u, err := h.renderURL(nil)
+	if err != nil {
+		return res, err
+	}
+
+	out, _, err := h.run(context.Background(), u, in, h.timeout)
 	if err != nil {
 		return res, err
 	}
@@ -146,41 +335,143 @@ func (h *HTTPHandler) HandleInjectionRequest(req injection.Request) (injection.R
 	return res, nil
 }
 
-func (h *HTTPHandler) run(buf []byte) ([]byte, error) {
-	reqBody := bytes.NewBuffer(buf)
+// renderURL renders the URL template with specified object.
+func (h *HTTPHandler) renderURL(obj map[string]interface{}) (string, error) {
+	var b strings.Builder
 
-	req, err := http.NewRequest("POST", h.url, reqBody)
+	err := h.url.Execute(&b, obj)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("failed to render url: %v", err)
+	}
+
+	return b.String(), nil
+}
+
+// run sends buf to u and returns the response body. The request
+// including reading the response body must complete within specified
+// timeout, and is aborted when ctx is cancelled. If the rate limit is
+// configured, it blocks until the request is allowed.
+func (h *HTTPHandler) run(ctx context.Context, u string, buf []byte, timeout time.Duration) ([]byte, http.Header, error) {
+	// Requests wait for the rate limit before the timeout starts.
+	if h.limiter != nil {
+		err := h.limiter.Wait(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if h.debug {
-		log("request", string(buf))
+		log.Info("Sending request", "url", u, "input", handler.Redact(buf, h.redact))
+	}
+
+	reqBody := buf
+	if h.compression == config.CompressionGzip {
+		var err error
+		reqBody, err = compress(buf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compress request: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest("POST", u, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	if h.userAgent != "" {
+		req.Header.Set("User-Agent", h.userAgent)
+	}
+
+	for key, val := range h.headers {
+		req.Header.Set(key, val)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if h.compression == config.CompressionGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	if len(h.signingKey) > 0 {
+		req.Header.Set(SignatureHeader, Sign(h.signingKey, reqBody))
+	}
+
 	res, err := h.client.Do(req)
 	if err != nil {
-		return nil, err
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			return nil, nil, fmt.Errorf("%w: %v", handler.ErrTimeout, err)
+		}
+		return nil, nil, err
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid status: %s", res.Status)
+	if !h.isSuccess(res.StatusCode) {
+		return nil, nil, fmt.Errorf("invalid status: %s", res.Status)
 	}
 
-	resBody, err := ioutil.ReadAll(res.Body)
+	var body io.Reader = res.Body
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress response: %v", err)
+		}
+		defer gr.Close()
+		body = gr
+	}
+
+	resBody, err := ioutil.ReadAll(body)
 	if err != nil {
-		return nil, err
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil, fmt.Errorf("%w: %v", handler.ErrTimeout, err)
+		}
+		return nil, nil, err
 	}
 
 	if h.debug {
-		log("response", string(resBody))
+		log.Info("Received response", "url", u, "output", handler.Redact(resBody, h.redact), "code", res.StatusCode)
+	}
+
+	return resBody, res.Header, nil
+}
+
+// isSuccess returns whether specified status code of the response is
+// treated as success.
+func (h *HTTPHandler) isSuccess(code int) bool {
+	if h.successCodes == nil {
+		return code >= 200 && code < 300
 	}
 
-	return resBody, nil
+	_, ok := h.successCodes[code]
+	return ok
+}
+
+// Sign returns the signature of the request body for SignatureHeader,
+// in the form of 'sha256=<hex encoded HMAC-SHA256 of the body>'.
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
 
-func log(stream, msg string) {
-	fmt.Fprintf(os.Stderr, "[http] %s: %s\n", stream, msg)
+// compress compresses buf with gzip.
+func compress(buf []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	gw := gzip.NewWriter(&b)
+	_, err := gw.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
 }
@@ -0,0 +1,706 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+	"github.com/summerwind/whitebox-controller/webhook/injection"
+)
+
+func TestHandleStateWithGzip(t *testing.T) {
+	RegisterTestingT(t)
+
+	// The server decompresses the request and returns it compressed.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, err := ioutil.ReadAll(gr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		gw.Write(body)
+		gw.Close()
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL:         server.URL,
+		Compression: config.CompressionGzip,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	unstructured.SetNestedField(s.Object.Object, strings.Repeat("a", 1024*1024), "spec", "data")
+	ns := s.Copy()
+
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// Without compression
+	h, err = New(&config.HTTPHandlerConfig{
+		URL: server.URL,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(s.Copy())
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandleStateWithHeaders(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer test"},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func TestHandleStateWithUserAgent(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != "test-agent/1.0" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL:       server.URL,
+		UserAgent: "test-agent/1.0",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func TestHandleStateWithSuccessStatusCodes(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+			io.Copy(w, r.Body)
+		case "/nocontent":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	// Default
+	h, err := New(&config.HTTPHandlerConfig{
+		URL: server.URL + "/created",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	err = h.HandleState(s.Copy())
+	Expect(err).NotTo(HaveOccurred())
+
+	h, err = New(&config.HTTPHandlerConfig{
+		URL: server.URL + "/nocontent",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	ns := s.Copy()
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// Only 201 is success
+	h, err = New(&config.HTTPHandlerConfig{
+		URL:                server.URL + "/created",
+		SuccessStatusCodes: []int{201},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(s.Copy())
+	Expect(err).NotTo(HaveOccurred())
+
+	h, err = New(&config.HTTPHandlerConfig{
+		URL:                server.URL + "/nocontent",
+		SuccessStatusCodes: []int{201},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(s.Copy())
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("204"))
+
+	// Only 200 is success
+	h, err = New(&config.HTTPHandlerConfig{
+		URL:                server.URL + "/created",
+		SuccessStatusCodes: []int{200},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(s.Copy())
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("201"))
+}
+
+func TestHandleStateWithTimeout(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL:     server.URL,
+		Timeout: "100ms",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Handler timeout
+	err = h.HandleState(newTestState())
+	Expect(errors.Is(err, handler.ErrTimeout)).To(BeTrue())
+
+	// Timeout of the state takes precedence
+	s := newTestState()
+	s.Timeout = 5 * time.Second
+
+	err = h.HandleState(s)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func TestHandleStateWithCancel(t *testing.T) {
+	RegisterTestingT(t)
+
+	aborted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server detects the closed connection after reading the body.
+		ioutil.ReadAll(r.Body)
+
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL: server.URL,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	s := newTestState()
+	s.Context = ctx
+
+	start := time.Now()
+	err = h.HandleState(s)
+	Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	Expect(errors.Is(err, handler.ErrTimeout)).To(BeFalse())
+	Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	Eventually(aborted).Should(BeClosed())
+
+	// Cancelled context
+	s = newTestState()
+	s.Context = ctx
+
+	err = h.HandleState(s)
+	Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+}
+
+func TestHandleStateWithRateLimit(t *testing.T) {
+	RegisterTestingT(t)
+
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// The limiter is shared by the handlers
+	limiter := rate.NewLimiter(10, 2)
+	handlers := []*HTTPHandler{}
+	for i := 0; i < 2; i++ {
+		h, err := New(&config.HTTPHandlerConfig{
+			URL:         server.URL,
+			RateLimiter: limiter,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		handlers = append(handlers, h)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(h *HTTPHandler) {
+			defer wg.Done()
+			err := h.HandleState(newTestState())
+			Expect(err).NotTo(HaveOccurred())
+		}(handlers[i%2])
+	}
+	wg.Wait()
+
+	Expect(times).To(HaveLen(5))
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	// The burst is sent immediately and the rest are spaced by the rate
+	Expect(times[1].Sub(times[0])).To(BeNumerically("<", 50*time.Millisecond))
+	for i := 2; i < len(times); i++ {
+		Expect(times[i].Sub(times[i-1])).To(BeNumerically(">=", 80*time.Millisecond))
+	}
+}
+
+func TestHandleStateWithDebug(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	tl := &testLogger{}
+	defer func(l logr.Logger) { log = l }(log)
+	log = tl
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL:          server.URL,
+		Debug:        true,
+		RedactFields: []string{".object.spec.password"},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s := newTestState()
+	unstructured.SetNestedField(s.Object.Object, "secret", "spec", "password")
+
+	err = h.HandleState(s)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(tl.messages).To(Equal([]string{"Sending request", "Received response"}))
+	for _, out := range tl.outputs {
+		Expect(out).NotTo(ContainSubstring("secret"))
+		Expect(out).To(ContainSubstring(handler.Redacted))
+		Expect(out).To(ContainSubstring("hello"))
+	}
+}
+
+func TestHandleStateWithURLTemplate(t *testing.T) {
+	RegisterTestingT(t)
+
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL: server.URL + "/tenants/{{.metadata.namespace}}/reconcile",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(paths).To(Equal([]string{"/tenants/default/reconcile"}))
+
+	// Values that change the path or query are escaped
+	queries := []string{}
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		queries = append(queries, r.URL.RawQuery)
+		io.Copy(w, r.Body)
+	})
+	h, err = New(&config.HTTPHandlerConfig{
+		URL: server.URL + "/tenants/{{.metadata.namespace}}/{{.spec.name}}/reconcile",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, name := range []string{"a/b?c=d#e", ".."} {
+		s := newTestState()
+		s.Object.Object["spec"] = map[string]interface{}{"name": name}
+
+		err = h.HandleState(s)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(paths[1:]).To(Equal([]string{
+		"/tenants/default/a%2Fb%3Fc=d%23e/reconcile",
+		"/tenants/default/%2E%2E/reconcile",
+	}))
+	Expect(queries).To(Equal([]string{"", ""}))
+	paths = paths[:1]
+
+	// Missing field
+	h, err = New(&config.HTTPHandlerConfig{
+		URL: server.URL + "/tenants/{{.spec.tenant}}/reconcile",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).To(HaveOccurred())
+	Expect(paths).To(HaveLen(1))
+
+	// Invalid template
+	_, err = New(&config.HTTPHandlerConfig{
+		URL: server.URL + "/tenants/{{.metadata.namespace",
+	})
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandleInjectionRequest(t *testing.T) {
+	RegisterTestingT(t)
+
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"object":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test"}}}`))
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{URL: server.URL + "/inject"})
+	Expect(err).NotTo(HaveOccurred())
+
+	res, err := h.HandleInjectionRequest(injection.Request{Body: "{}"})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(paths).To(Equal([]string{"/inject"}))
+	Expect(res.Object.GetName()).To(Equal("test"))
+
+	// The templated URL cannot be rendered without the object
+	h, err = New(&config.HTTPHandlerConfig{URL: server.URL + "/{{.metadata.namespace}}/inject"})
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = h.HandleInjectionRequest(injection.Request{Body: "{}"})
+	Expect(err).To(HaveOccurred())
+	Expect(paths).To(HaveLen(1))
+}
+
+func TestHandleStateWithSigningKey(t *testing.T) {
+	RegisterTestingT(t)
+
+	var (
+		body      []byte
+		signature string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(SignatureHeader)
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "signing-key")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("secret")
+	Expect(err).NotTo(HaveOccurred())
+	f.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL:            server.URL,
+		SigningKeyFile: f.Name(),
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).NotTo(HaveOccurred())
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	Expect(signature).To(Equal("sha256=" + hex.EncodeToString(mac.Sum(nil))))
+
+	// No signing key
+	h, err = New(&config.HTTPHandlerConfig{URL: server.URL})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(signature).To(BeEmpty())
+}
+
+func TestHandleStateWithRequeueAfterHeader(t *testing.T) {
+	RegisterTestingT(t)
+
+	var header, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequeueAfterHeader, header)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL: server.URL,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		header   string
+		body     string
+		expected state.Duration
+	}{
+		// Duration string without body
+		{header: "30s", expected: 30},
+		// Seconds
+		{header: "45", expected: 45},
+		// Rounded up to seconds
+		{header: "1500ms", expected: 2},
+		// Requeue after of the body takes precedence
+		{header: "30s", body: `{"requeueAfter": 10}`, expected: 10},
+		// Body without requeue
+		{header: "30s", body: `{}`, expected: 30},
+		// Malformed header is ignored
+		{header: "soon", expected: 0},
+		// Negative duration is ignored
+		{header: "-5s", expected: 0},
+		{header: "-5", expected: 0},
+		// No header
+		{header: "", expected: 0},
+	}
+
+	for _, test := range tests {
+		header = test.header
+		body = test.body
+
+		s := newTestState()
+		err = h.HandleState(s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.RequeueAfter).To(Equal(test.expected), "header: %q, body: %q", test.header, test.body)
+	}
+
+	// Requeue of the body takes precedence
+	header = "30s"
+	body = `{"requeue": true}`
+
+	s := newTestState()
+	err = h.HandleState(s)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(s.Requeue).To(BeTrue())
+	Expect(s.RequeueAfter).To(Equal(state.Duration(0)))
+}
+
+func TestSign(t *testing.T) {
+	RegisterTestingT(t)
+
+	sig := Sign([]byte("key"), []byte("The quick brown fox jumps over the lazy dog"))
+	Expect(sig).To(Equal("sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"))
+}
+
+func newTestState() *state.State {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1alpha1")
+	obj.SetKind("Test")
+	obj.SetNamespace("default")
+	obj.SetName("test")
+	unstructured.SetNestedField(obj.Object, "hello", "spec", "message")
+
+	return state.New(obj, nil, nil)
+}
+
+// testLogger records the messages and the input or output of handler.
+type testLogger struct {
+	logr.Logger
+	messages []string
+	outputs  []string
+}
+
+func (l *testLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.messages = append(l.messages, msg)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "input" || keysAndValues[i] == "output" {
+			l.outputs = append(l.outputs, keysAndValues[i+1].(string))
+		}
+	}
+}
+
+func TestHandleStateWithCACertDir(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-http-test")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	first, _ := newTestCA("first")
+	second, secondKey := newTestCA("second")
+	writeTestCA(filepath.Join(dir, "first.pem"), first)
+	writeTestCA(filepath.Join(dir, "second.pem"), second)
+
+	// Files without certificate are ignored
+	err = ioutil.WriteFile(filepath.Join(dir, "README"), []byte("CA certificates"), 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	// The server certificate is signed by one of the CAs
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{newTestServerCert(second, secondKey)},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	h, err := New(&config.HTTPHandlerConfig{
+		URL: server.URL,
+		TLS: &config.TLSConfig{CACertDir: dir},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).NotTo(HaveOccurred())
+
+	// The server signed by other CA is not trusted
+	other := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	other.TLS = &tls.Config{
+		Certificates: []tls.Certificate{newTestServerCert(newTestCA("other"))},
+	}
+	other.StartTLS()
+	defer other.Close()
+
+	h, err = New(&config.HTTPHandlerConfig{
+		URL: other.URL,
+		TLS: &config.TLSConfig{CACertDir: dir},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = h.HandleState(newTestState())
+	Expect(err).To(HaveOccurred())
+
+	// Both of the file and the directory
+	f, err := ioutil.TempFile("", "whitebox-http-test")
+	Expect(err).NotTo(HaveOccurred())
+	f.Close()
+	defer os.Remove(f.Name())
+
+	extra, _ := newTestCA("extra")
+	writeTestCA(f.Name(), extra)
+
+	pool, err := newCertPool(&config.TLSConfig{
+		CACertFile: f.Name(),
+		CACertDir:  dir,
+	})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(pool.Subjects()).To(HaveLen(3))
+
+	// Directory without certificate
+	empty, err := ioutil.TempDir("", "whitebox-http-test")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(empty)
+
+	_, err = New(&config.HTTPHandlerConfig{
+		URL: server.URL,
+		TLS: &config.TLSConfig{CACertDir: empty},
+	})
+	Expect(err).To(HaveOccurred())
+}
+
+// newTestCA returns a self-signed CA certificate and its key.
+func newTestCA(name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return cert, key
+}
+
+// newTestServerCert returns a certificate of 127.0.0.1 signed by
+// specified CA.
+func newTestServerCert(ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// writeTestCA writes specified CA certificate to the file in PEM.
+func writeTestCA(file string, ca *x509.Certificate) {
+	err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0644)
+	Expect(err).NotTo(HaveOccurred())
+}
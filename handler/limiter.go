@@ -0,0 +1,25 @@
+package handler
+
+import "context"
+
+// Limiter limits the number of handler calls in flight. A Limiter is
+// shared by the handlers of all controllers.
+type Limiter struct {
+	sem chan struct{}
+}
+
+// NewLimiter returns a limiter that allows max calls in flight.
+func NewLimiter(max int) *Limiter {
+	return &Limiter{sem: make(chan struct{}, max)}
+}
+
+// Acquire blocks until a call is allowed or ctx is done, and returns
+// a function to release it.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+// Names of the built-in middlewares.
+const (
+	// MiddlewareLogging logs each call of the handler with its duration
+	// and error.
+	MiddlewareLogging = "logging"
+	// MiddlewareMetrics counts the calls of the handler and observes
+	// their durations.
+	MiddlewareMetrics = "metrics"
+)
+
+// Results of the handler calls in the metrics of the metrics middleware.
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+var log = logf.Log.WithName("handler")
+
+var (
+	handlerCalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "whitebox_handler_calls_total",
+			Help: "Total number of handler calls per controller and result",
+		},
+		[]string{"controller", "result"},
+	)
+
+	handlerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "whitebox_handler_duration_seconds",
+			Help: "Duration of handler calls per controller in seconds",
+		},
+		[]string{"controller"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(handlerCalls, handlerDuration)
+}
+
+// Middleware wraps a StateHandler to add a behavior to all calls of the
+// handler, such as logging, metrics, tracing and authentication.
+type Middleware func(StateHandler) StateHandler
+
+// StateHandlerFunc is an adapter to use a function as StateHandler.
+type StateHandlerFunc func(*state.State) error
+
+// HandleState implements StateHandler interface.
+func (f StateHandlerFunc) HandleState(s *state.State) error {
+	return f(s)
+}
+
+var (
+	middlewaresMu sync.RWMutex
+	middlewares   = map[string]Middleware{
+		MiddlewareLogging: loggingMiddleware,
+		MiddlewareMetrics: metricsMiddleware,
+	}
+	// activeMiddlewares wrap all the state handlers.
+	activeMiddlewares []Middleware
+)
+
+// RegisterMiddleware registers the middleware with specified name, so
+// that it can be referenced in the configuration. It panics if the
+// name is already registered.
+func RegisterMiddleware(name string, m Middleware) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+
+	_, ok := middlewares[name]
+	if ok {
+		panic(fmt.Sprintf("middleware %q is already registered", name))
+	}
+
+	middlewares[name] = m
+}
+
+// LookupMiddleware returns the middleware registered with specified
+// name.
+func LookupMiddleware(name string) (Middleware, bool) {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+
+	m, ok := middlewares[name]
+	return m, ok
+}
+
+// SetMiddlewares sets the middlewares registered with specified names
+// to wrap all the state handlers created afterwards. The first one is
+// the outermost.
+func SetMiddlewares(names ...string) error {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+
+	ms := []Middleware{}
+	for _, name := range names {
+		m, ok := middlewares[name]
+		if !ok {
+			return fmt.Errorf("unknown middleware: %s", name)
+		}
+		ms = append(ms, m)
+	}
+
+	activeMiddlewares = ms
+	return nil
+}
+
+// Middlewares returns the middlewares that wrap the state handlers.
+func Middlewares() []Middleware {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+
+	return activeMiddlewares
+}
+
+// Chain returns specified handler wrapped by the middlewares. The first
+// middleware is the outermost, so that it is called first.
+func Chain(h StateHandler, ms ...Middleware) StateHandler {
+	for i := len(ms) - 1; i >= 0; i-- {
+		h = ms[i](h)
+	}
+
+	return h
+}
+
+// controllerName returns the name of the controller that calls the
+// handler with specified state.
+func controllerName(s *state.State) string {
+	if s.Controller == nil {
+		return ""
+	}
+
+	return s.Controller.Name
+}
+
+func loggingMiddleware(next StateHandler) StateHandler {
+	return StateHandlerFunc(func(s *state.State) error {
+		l := log.WithValues("controller", controllerName(s), "trigger", s.Trigger)
+		if s.Object != nil {
+			l = l.WithValues("namespace", s.Object.GetNamespace(), "name", s.Object.GetName())
+		}
+
+		start := time.Now()
+		err := next.HandleState(s)
+		d := time.Since(start).Seconds()
+
+		if err != nil {
+			l.Info("Handler failed", "duration", d, "error", err.Error())
+		} else {
+			l.Info("Handler completed", "duration", d)
+		}
+
+		return err
+	})
+}
+
+func metricsMiddleware(next StateHandler) StateHandler {
+	return StateHandlerFunc(func(s *state.State) error {
+		name := controllerName(s)
+
+		start := time.Now()
+		err := next.HandleState(s)
+		handlerDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+		result := resultSuccess
+		if err != nil {
+			result = resultError
+		}
+		handlerCalls.WithLabelValues(name, result).Inc()
+
+		return err
+	})
+}
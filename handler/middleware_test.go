@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+// recordMiddleware returns a middleware that appends its name to calls
+// before and after calling the next handler.
+func recordMiddleware(name string, calls *[]string) Middleware {
+	return func(next StateHandler) StateHandler {
+		return StateHandlerFunc(func(s *state.State) error {
+			*calls = append(*calls, name+"-before")
+			err := next.HandleState(s)
+			*calls = append(*calls, name+"-after")
+			return err
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	RegisterTestingT(t)
+
+	calls := []string{}
+	h := StateHandlerFunc(func(s *state.State) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	// Case: The first middleware is the outermost
+	err := Chain(h, recordMiddleware("a", &calls), recordMiddleware("b", &calls)).HandleState(&state.State{})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(calls).To(Equal([]string{"a-before", "b-before", "handler", "b-after", "a-after"}))
+
+	// Case: No middleware
+	calls = []string{}
+	err = Chain(h).HandleState(&state.State{})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(calls).To(Equal([]string{"handler"}))
+}
+
+func TestBuiltinMiddlewares(t *testing.T) {
+	RegisterTestingT(t)
+
+	fail := false
+	h := StateHandlerFunc(func(s *state.State) error {
+		if fail {
+			return errors.New("test error")
+		}
+		return nil
+	})
+
+	logging, ok := LookupMiddleware(MiddlewareLogging)
+	Expect(ok).To(BeTrue())
+	metrics, ok := LookupMiddleware(MiddlewareMetrics)
+	Expect(ok).To(BeTrue())
+
+	s := &state.State{Controller: &state.Controller{Name: "test-middleware"}}
+	wrapped := Chain(h, logging, metrics)
+
+	// Case: Successful call
+	err := wrapped.HandleState(s)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(testutil.ToFloat64(handlerCalls.WithLabelValues("test-middleware", resultSuccess))).To(Equal(float64(1)))
+
+	// Case: Failed call returns the error as is
+	fail = true
+	err = wrapped.HandleState(s)
+	Expect(err).To(MatchError("test error"))
+	Expect(testutil.ToFloat64(handlerCalls.WithLabelValues("test-middleware", resultError))).To(Equal(float64(1)))
+	Expect(testutil.ToFloat64(handlerCalls.WithLabelValues("test-middleware", resultSuccess))).To(Equal(float64(1)))
+}
+
+func TestRegisterMiddleware(t *testing.T) {
+	RegisterTestingT(t)
+
+	calls := []string{}
+	RegisterMiddleware("test-record", recordMiddleware("test", &calls))
+
+	m, ok := LookupMiddleware("test-record")
+	Expect(ok).To(BeTrue())
+	Expect(m).NotTo(BeNil())
+
+	// Case: Unknown middleware
+	_, ok = LookupMiddleware("unknown")
+	Expect(ok).To(BeFalse())
+
+	// Case: Duplicate name
+	Expect(func() { RegisterMiddleware(MiddlewareLogging, m) }).To(Panic())
+}
+
+func TestSetMiddlewares(t *testing.T) {
+	RegisterTestingT(t)
+
+	defer SetMiddlewares()
+
+	err := SetMiddlewares(MiddlewareLogging, MiddlewareMetrics)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(Middlewares()).To(HaveLen(2))
+
+	// Case: Unknown middleware keeps the current middlewares
+	err = SetMiddlewares("unknown")
+	Expect(err).To(HaveOccurred())
+	Expect(Middlewares()).To(HaveLen(2))
+
+	// Case: No middleware
+	err = SetMiddlewares()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(Middlewares()).To(BeEmpty())
+}
@@ -0,0 +1,53 @@
+//go:build linux && cgo
+// +build linux,cgo
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLoad(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-plugin-")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	buildPlugin(filepath.Join(dir, "echo.so"), "./testdata/echo")
+
+	handlers, err := Load(dir)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(handlers).To(HaveKey("echo"))
+
+	// The handler of the plugin handles the state
+	s := newTestState()
+	ns := s.Copy()
+	err = New(handlers["echo"]).HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// The plugin does not export the handlers of the valid type
+	invalidDir, err := ioutil.TempDir("", "whitebox-plugin-")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(invalidDir)
+
+	buildPlugin(filepath.Join(invalidDir, "invalid.so"), "./testdata/invalid")
+
+	_, err = Load(invalidDir)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("must be map[string]handler.Handler"))
+}
+
+// buildPlugin builds the plugin of specified package to the path.
+func buildPlugin(p, pkg string) {
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", p, pkg)
+	out, err := cmd.CombinedOutput()
+	Expect(err).NotTo(HaveOccurred(), string(out))
+}
@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+	"github.com/summerwind/whitebox-controller/webhook/injection"
+)
+
+// HandlersSymbol is the name of the variable that each plugin exports
+// to register its handlers. The variable must be a map of the handler
+// names to the handlers, that is map[string]handler.Handler.
+const HandlersSymbol = "Handlers"
+
+// Load opens the Go plugins (.so files) in specified directory and
+// returns the handlers registered by them. The handler names must be
+// unique across the plugins.
+func Load(dir string) (map[string]handler.Handler, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %v", err)
+	}
+
+	paths := []string{}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".so") {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, f.Name()))
+	}
+	sort.Strings(paths)
+
+	handlers := map[string]handler.Handler{}
+	for _, p := range paths {
+		registered, err := open(p)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, h := range registered {
+			_, ok := handlers[name]
+			if ok {
+				return nil, fmt.Errorf("plugin %s: duplicate handler name: %s", p, name)
+			}
+			handlers[name] = h
+		}
+	}
+
+	return handlers, nil
+}
+
+// open opens the plugin of specified path and returns the handlers
+// registered by it.
+func open(p string) (map[string]handler.Handler, error) {
+	plug, err := plugin.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin %s: %v", p, err)
+	}
+
+	sym, err := plug.Lookup(HandlersSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %v", p, err)
+	}
+
+	registered, ok := sym.(*map[string]handler.Handler)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: %s must be map[string]handler.Handler, got %T", p, HandlersSymbol, sym)
+	}
+
+	for name, h := range *registered {
+		if name == "" {
+			return nil, fmt.Errorf("plugin %s: handler name must be specified", p)
+		}
+		if h == nil {
+			return nil, fmt.Errorf("plugin %s: handler %s is nil", p, name)
+		}
+	}
+
+	return *registered, nil
+}
+
+// PluginHandler runs the handler of a plugin with the JSON encoded
+// input and decodes the JSON output.
+type PluginHandler struct {
+	handler handler.Handler
+}
+
+// New returns a new PluginHandler that runs specified handler.
+func New(h handler.Handler) *PluginHandler {
+	return &PluginHandler{handler: h}
+}
+
+func (h *PluginHandler) HandleState(s *state.State) error {
+	return h.run(s, s)
+}
+
+func (h *PluginHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	res, err := h.HandleAdmissionRequestWithWarnings(req)
+	return res.Response, err
+}
+
+func (h *PluginHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (handler.AdmissionResponse, error) {
+	res := handler.AdmissionResponse{}
+	err := h.run(&req, &res)
+	return res, err
+}
+
+func (h *PluginHandler) HandleInjectionRequest(req injection.Request) (injection.Response, error) {
+	res := injection.Response{}
+	err := h.run(&req, &res)
+	return res, err
+}
+
+// run runs the handler with the input and stores the output to out.
+// The output is ignored if it is empty.
+func (h *PluginHandler) run(in, out interface{}) error {
+	buf, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode input: %v", err)
+	}
+
+	buf, err = h.handler.Run(buf)
+	if err != nil {
+		return err
+	}
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(buf, out)
+	if err != nil {
+		return fmt.Errorf("failed to decode output: %v", err)
+	}
+
+	return nil
+}
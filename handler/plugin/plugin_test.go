@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestHandleState(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := New(&testHandler{
+		out: []byte(`{"object": {"apiVersion": "example.com/v1alpha1", "kind": "Test", "metadata": {"name": "test"}, "status": {"phase": "done"}}}`),
+	})
+
+	s := newTestState()
+	err := h.HandleState(s)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(s.Object.Object["status"]).To(Equal(map[string]interface{}{"phase": "done"}))
+
+	// Empty output
+	h = New(&testHandler{})
+	s = newTestState()
+	ns := s.Copy()
+	err = h.HandleState(ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// Handler error
+	h = New(&testHandler{err: errors.New("test error")})
+	err = h.HandleState(newTestState())
+	Expect(err).To(MatchError("test error"))
+
+	// Invalid output
+	h = New(&testHandler{out: []byte("invalid")})
+	err = h.HandleState(newTestState())
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandleAdmissionRequestWithWarnings(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := New(&testHandler{
+		out: []byte(`{"allowed": true, "warnings": ["spec.foo is deprecated"]}`),
+	})
+
+	res, err := h.HandleAdmissionRequestWithWarnings(admission.Request{})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(res.Allowed).To(BeTrue())
+	Expect(res.Warnings).To(Equal([]string{"spec.foo is deprecated"}))
+}
+
+func TestLoadWithoutPlugins(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-plugin-")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	// Files other than plugins are ignored
+	err = ioutil.WriteFile(filepath.Join(dir, "README"), []byte("test"), 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	handlers, err := Load(dir)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(handlers).To(BeEmpty())
+
+	// Missing directory
+	_, err = Load(filepath.Join(dir, "missing"))
+	Expect(err).To(HaveOccurred())
+
+	// Invalid plugin
+	err = ioutil.WriteFile(filepath.Join(dir, "invalid.so"), []byte("test"), 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = Load(dir)
+	Expect(err).To(HaveOccurred())
+}
+
+type testHandler struct {
+	out []byte
+	err error
+}
+
+func (h *testHandler) Run(buf []byte) ([]byte, error) {
+	return h.out, h.err
+}
+
+func newTestState() *state.State {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1alpha1")
+	obj.SetKind("Test")
+	obj.SetName("test")
+
+	return state.New(obj, nil, nil)
+}
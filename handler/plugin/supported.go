@@ -0,0 +1,8 @@
+//go:build (linux && cgo) || (darwin && cgo) || (freebsd && cgo)
+// +build linux,cgo darwin,cgo freebsd,cgo
+
+package plugin
+
+// Supported indicates whether the Go plugins can be loaded by this
+// build. Loading the plugins requires cgo.
+const Supported = true
@@ -0,0 +1,10 @@
+//go:build !((linux && cgo) || (darwin && cgo) || (freebsd && cgo))
+// +build !linux !cgo
+// +build !darwin !cgo
+// +build !freebsd !cgo
+
+package plugin
+
+// Supported indicates whether the Go plugins can be loaded by this
+// build. Loading the plugins requires cgo.
+const Supported = false
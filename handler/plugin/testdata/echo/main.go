@@ -0,0 +1,19 @@
+// Package main is a sample plugin that registers a handler returning
+// the input as is.
+package main
+
+import (
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+type echoHandler struct{}
+
+func (h *echoHandler) Run(buf []byte) ([]byte, error) {
+	return buf, nil
+}
+
+var Handlers = map[string]handler.Handler{
+	"echo": &echoHandler{},
+}
+
+func main() {}
@@ -0,0 +1,9 @@
+// Package main is a sample plugin that exports the handlers with an
+// invalid type.
+package main
+
+var Handlers = map[string]string{
+	"invalid": "invalid",
+}
+
+func main() {}
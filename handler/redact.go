@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+
+	"github.com/ghodss/yaml"
+)
+
+// Redacted is the value that replaces the redacted fields.
+const Redacted = "[REDACTED]"
+
+// Redact returns the JSON or YAML document with the value of specified
+// fields replaced, for logging the input and output of handlers. Each
+// field is a list of field names from the root of the document. Lists
+// in the middle of a field are redacted in all of their items. If the
+// document cannot be parsed, the whole document is redacted.
+func Redact(buf []byte, fields [][]string) string {
+	if len(fields) == 0 || len(buf) == 0 {
+		return string(buf)
+	}
+
+	j, err := yaml.YAMLToJSON(buf)
+	if err != nil {
+		return Redacted
+	}
+
+	var v interface{}
+	err = json.Unmarshal(j, &v)
+	if err != nil {
+		return Redacted
+	}
+
+	for _, f := range fields {
+		redactField(v, f)
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return Redacted
+	}
+
+	return string(out)
+}
+
+// redactField replaces the value of specified field in v.
+func redactField(v interface{}, field []string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		child, ok := val[field[0]]
+		if !ok {
+			return
+		}
+
+		if len(field) == 1 {
+			val[field[0]] = Redacted
+			return
+		}
+
+		redactField(child, field[1:])
+	case []interface{}:
+		for i := range val {
+			redactField(val[i], field)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRedact(t *testing.T) {
+	RegisterTestingT(t)
+
+	fields := [][]string{
+		{"object", "spec", "password"},
+		{"items", "token"},
+		{"object", "spec", "missing"},
+	}
+
+	buf := []byte(`{"object":{"spec":{"user":"test","password":"secret"}},"items":[{"token":"a"},{"token":"b","name":"c"}]}`)
+
+	v := map[string]interface{}{}
+	err := json.Unmarshal([]byte(Redact(buf, fields)), &v)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(v).To(Equal(map[string]interface{}{
+		"object": map[string]interface{}{
+			"spec": map[string]interface{}{"user": "test", "password": Redacted},
+		},
+		"items": []interface{}{
+			map[string]interface{}{"token": Redacted},
+			map[string]interface{}{"token": Redacted, "name": "c"},
+		},
+	}))
+
+	// YAML
+	v = map[string]interface{}{}
+	err = json.Unmarshal([]byte(Redact([]byte("object:\n  spec:\n    password: secret\n"), fields)), &v)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(v).To(Equal(map[string]interface{}{
+		"object": map[string]interface{}{
+			"spec": map[string]interface{}{"password": Redacted},
+		},
+	}))
+
+	// No fields
+	Expect(Redact(buf, nil)).To(Equal(string(buf)))
+
+	// Invalid document
+	Expect(Redact([]byte(`{"password": `), fields)).To(Equal(Redacted))
+}
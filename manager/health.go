@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+// defaultPingTimeout is the timeout of pinging a handler if it is not
+// configured.
+const defaultPingTimeout = 3 * time.Second
+
+// addHealthChecks adds the liveness and readiness checks to the
+// manager. With handlerPing, the manager is ready only while all HTTP
+// handlers are reachable.
+func addHealthChecks(c *config.Config, mgr manager.Manager) error {
+	if c.Health == nil {
+		return nil
+	}
+
+	err := mgr.AddHealthzCheck("ping", healthz.Ping)
+	if err != nil {
+		return err
+	}
+
+	err = mgr.AddReadyzCheck("ping", healthz.Ping)
+	if err != nil {
+		return err
+	}
+
+	if !c.Health.HandlerPing {
+		return nil
+	}
+
+	timeout := defaultPingTimeout
+	if c.Health.PingTimeout != "" {
+		timeout, err = time.ParseDuration(c.Health.PingTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid ping timeout: %v", err)
+		}
+	}
+
+	for i, u := range handlerURLs(c) {
+		check, err := newHandlerPing(u, timeout)
+		if err != nil {
+			return err
+		}
+
+		err = mgr.AddReadyzCheck(fmt.Sprintf("handler-%d", i), check)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handlerURLs returns the unique URLs of HTTP handlers of the enabled
+// resources and the default webhook handlers. Templated URLs are
+// skipped since their hosts are known only when rendered.
+func handlerURLs(c *config.Config) []string {
+	handlers := []*config.HandlerConfig{}
+	for _, r := range c.EnabledResources() {
+		handlers = append(handlers, r.Finalizer, r.Validator, r.Mutator)
+		if r.Reconciler != nil {
+			handlers = append(handlers, &r.Reconciler.HandlerConfig)
+		}
+		if r.Injector != nil {
+			handlers = append(handlers, &r.Injector.HandlerConfig)
+		}
+	}
+
+	for _, w := range c.WebhookServers() {
+		if w.Default != nil {
+			handlers = append(handlers, w.Default.Validator, w.Default.Mutator)
+		}
+	}
+
+	urls := []string{}
+	seen := map[string]struct{}{}
+	for _, h := range handlers {
+		if h == nil || h.HTTP == nil || config.IsURLTemplate(h.HTTP.URL) {
+			continue
+		}
+
+		_, ok := seen[h.HTTP.URL]
+		if ok {
+			continue
+		}
+		seen[h.HTTP.URL] = struct{}{}
+		urls = append(urls, h.HTTP.URL)
+	}
+
+	return urls
+}
+
+// newHandlerPing returns a checker that connects to the host of the
+// HTTP handler to verify that it is reachable. The handler itself is
+// not invoked.
+func newHandlerPing(rawURL string, timeout time.Duration) (healthz.Checker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid handler URL: %v", err)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return func(_ *http.Request) error {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return fmt.Errorf("handler %s is unreachable: %v", rawURL, err)
+		}
+		conn.Close()
+
+		return nil
+	}, nil
+}
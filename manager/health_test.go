@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestHandlerPing(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Reachable
+	check, err := newHandlerPing(server.URL, time.Second)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(check(nil)).To(Succeed())
+
+	// Unreachable
+	server.Close()
+	Expect(check(nil)).NotTo(Succeed())
+
+	// Invalid URL
+	_, err = newHandlerPing("http://[::1", time.Second)
+	Expect(err).To(HaveOccurred())
+}
+
+func TestHandlerURLs(t *testing.T) {
+	RegisterTestingT(t)
+
+	httpHandler := func(u string) config.HandlerConfig {
+		return config.HandlerConfig{HTTP: &config.HTTPHandlerConfig{URL: u}}
+	}
+
+	validator := httpHandler("http://127.0.0.1:8080/validate")
+	mutator := httpHandler("http://127.0.0.1:8080/validate")
+	policy := httpHandler("http://policy:8080/validate")
+	tenant := httpHandler("http://{{.metadata.namespace}}:8080/finalize")
+
+	c := &config.Config{
+		Resources: []*config.ResourceConfig{
+			{
+				Reconciler: &config.ReconcilerConfig{
+					HandlerConfig: httpHandler("http://127.0.0.1:8080/reconcile"),
+				},
+				Finalizer: &tenant,
+				Validator: &validator,
+				Mutator:   &mutator,
+			},
+		},
+		Webhook: &config.ServerConfig{
+			Default: &config.DefaultWebhookConfig{Validator: &policy},
+		},
+	}
+
+	Expect(handlerURLs(c)).To(ConsistOf(
+		"http://127.0.0.1:8080/reconcile",
+		"http://127.0.0.1:8080/validate",
+		"http://policy:8080/validate",
+	))
+}
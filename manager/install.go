@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+// CheckResourcesInstalled returns an error if the API server does not
+// serve any of the resources that are reconciled or served by webhooks.
+// The error lists the missing resources with a hint to install them.
+func CheckResourcesInstalled(dc discovery.DiscoveryInterface, c *config.Config) error {
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return fmt.Errorf("failed to discover API groups: %v", err)
+	}
+
+	served := map[string]struct{}{}
+	for _, g := range groups.Groups {
+		for _, v := range g.Versions {
+			served[v.GroupVersion] = struct{}{}
+		}
+	}
+
+	missing := []string{}
+	checked := map[schema.GroupVersionKind]struct{}{}
+	kinds := map[string]map[string]struct{}{}
+
+	for _, r := range c.EnabledResources() {
+		gvks := []schema.GroupVersionKind{r.GroupVersionKind}
+		if r.Reconciler != nil {
+			gvks = append(gvks, r.AdditionalResources...)
+		}
+
+		for _, gvk := range gvks {
+			_, ok := checked[gvk]
+			if ok {
+				continue
+			}
+			checked[gvk] = struct{}{}
+
+			gv := gvk.GroupVersion().String()
+			_, ok = served[gv]
+			if !ok {
+				missing = append(missing, resourceName(gvk))
+				continue
+			}
+
+			if kinds[gv] == nil {
+				list, err := dc.ServerResourcesForGroupVersion(gv)
+				if err != nil {
+					return fmt.Errorf("failed to discover resources of %s: %v", gv, err)
+				}
+
+				kinds[gv] = map[string]struct{}{}
+				for _, res := range list.APIResources {
+					// Subresources such as 'status' are ignored.
+					if !strings.Contains(res.Name, "/") {
+						kinds[gv][res.Kind] = struct{}{}
+					}
+				}
+			}
+
+			_, ok = kinds[gv][gvk.Kind]
+			if !ok {
+				missing = append(missing, resourceName(gvk))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("resources are not installed in the cluster: %s. Install the CustomResourceDefinitions of the resources, such as the ones generated by 'whitebox-gen manifest', and restart the controller", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// resourceName returns the name of specified resource with its group
+// and version, such as 'Hello (whitebox.summerwind.dev/v1alpha1)'.
+func resourceName(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf("%s (%s)", gvk.Kind, gvk.GroupVersion().String())
+}
+
+// checkResources checks that the resources of the configuration are
+// installed with the discovery client for specified config.
+func checkResources(c *config.Config, rc *rest.Config) error {
+	dc, err := discovery.NewDiscoveryClientForConfig(rc)
+	if err != nil {
+		return fmt.Errorf("could not create discovery client: %v", err)
+	}
+
+	return CheckResourcesInstalled(dc, c)
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestCheckResourcesInstalled(t *testing.T) {
+	RegisterTestingT(t)
+
+	dc := &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "example.com/v1alpha1",
+					APIResources: []metav1.APIResource{
+						{Name: "tests", Kind: "Test"},
+						{Name: "tests/status", Kind: "Test"},
+						{Name: "others/status", Kind: "Other"},
+					},
+				},
+			},
+		},
+	}
+
+	newConfig := func(gvks ...schema.GroupVersionKind) *config.Config {
+		c := &config.Config{}
+		for _, gvk := range gvks {
+			c.Resources = append(c.Resources, &config.ResourceConfig{GroupVersionKind: gvk})
+		}
+		return c
+	}
+
+	installed := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"}
+
+	// Installed
+	err := CheckResourcesInstalled(dc, newConfig(installed))
+	Expect(err).NotTo(HaveOccurred())
+
+	// Missing group version
+	missing := schema.GroupVersionKind{Group: "example.com", Version: "v1beta1", Kind: "Test"}
+	err = CheckResourcesInstalled(dc, newConfig(installed, missing))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("Test (example.com/v1beta1)"))
+	Expect(err.Error()).To(ContainSubstring("CustomResourceDefinition"))
+
+	// Missing kind, served only as a subresource
+	other := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Other"}
+	err = CheckResourcesInstalled(dc, newConfig(other))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("Other (example.com/v1alpha1)"))
+
+	// Missing additional resource of reconciler
+	c := newConfig(installed)
+	c.Resources[0].Reconciler = &config.ReconcilerConfig{}
+	c.Resources[0].AdditionalResources = []schema.GroupVersionKind{missing}
+	err = CheckResourcesInstalled(dc, c)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("Test (example.com/v1beta1)"))
+
+	// Disabled resources are not checked
+	c = newConfig(installed, missing)
+	disabled := false
+	c.Resources[1].Enabled = &disabled
+	err = CheckResourcesInstalled(dc, c)
+	Expect(err).NotTo(HaveOccurred())
+}
@@ -1,33 +1,125 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/summerwind/whitebox-controller/config"
 	"github.com/summerwind/whitebox-controller/controller"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/handler/plugin"
+	"github.com/summerwind/whitebox-controller/metrics"
+	"github.com/summerwind/whitebox-controller/reconciler"
 	"github.com/summerwind/whitebox-controller/webhook"
 )
 
+// Version and Commit are the version of the controller used in the
+// default user agent. They are set by the main package.
+var (
+	Version = "dev"
+	Commit  = "HEAD"
+)
+
 func New(c *config.Config, kc *rest.Config) (manager.Manager, error) {
 	err := c.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("invalid configuration: %v", err)
 	}
 
-	mgr, err := manager.New(kc, manager.Options{})
+	err = setPluginHandlers(c)
+	if err != nil {
+		return nil, err
+	}
+
+	setUserAgent(c)
+	rc := restConfig(c, kc)
+
+	err = checkResources(c, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr, err := manager.New(rc, options(c))
+	if err != nil {
+		return nil, err
+	}
+
+	err = addHealthChecks(c, mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The mapper is refreshed while the manager is running.
+	mapper, ok := mgr.GetRESTMapper().(*refreshMapper)
+	if ok {
+		err = mgr.Add(mapper)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	exporter, err := metrics.New(c.Metrics, metricsGatherer(c))
+	if err != nil {
+		return nil, err
+	}
+
+	if exporter != nil {
+		err = mgr.Add(exporter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	server := metricsServer(c)
+	if server != nil {
+		err = mgr.Add(server)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resources := c.EnabledResources()
+	setHandlerLimiter(c)
+	setHTTPRateLimiter(c)
+
+	err = handler.SetMiddlewares(c.Middlewares...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addStartupDelay(c, mgr)
 	if err != nil {
 		return nil, err
 	}
 
 	wh := false
-	for _, r := range c.Resources {
+	for _, w := range c.WebhookServers() {
+		if w.Default != nil {
+			wh = true
+		}
+	}
+
+	err = indexEvents(resources, mgr.GetFieldIndexer())
+	if err != nil {
+		return nil, fmt.Errorf("failed to index events: %v", err)
+	}
+
+	for _, r := range resources {
 		if r.Reconciler != nil {
-			_, err := controller.New(r, mgr)
-			if err != nil {
-				return nil, err
+			for _, resource := range r.ReconciledResources() {
+				_, err := controller.New(resource, mgr)
+				if err != nil {
+					return nil, err
+				}
 			}
 		}
 
@@ -37,25 +129,243 @@ func New(c *config.Config, kc *rest.Config) (manager.Manager, error) {
 	}
 
 	if wh {
-		server, err := webhook.NewServer(c.Webhook, mgr)
+		err = addWebhookServers(c, mgr)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		for _, r := range c.Resources {
-			if r.Validator != nil {
-				server.AddValidator(r)
-			}
+	return mgr, nil
+}
 
-			if r.Mutator != nil {
-				server.AddMutator(r)
-			}
+// indexEvents indexes the events by their involved object if any of
+// the reconcilers includes the events in its input.
+func indexEvents(resources []*config.ResourceConfig, fi client.FieldIndexer) error {
+	for _, r := range resources {
+		if r.Reconciler != nil && r.Reconciler.IncludeEvents > 0 {
+			return reconciler.IndexEvents(fi)
+		}
+	}
+
+	return nil
+}
+
+// setHandlerLimiter sets the limiter shared by the reconciler and the
+// finalizer of all resources if the limit is configured. Zero limit
+// means no limit.
+func setHandlerLimiter(c *config.Config) {
+	if c.MaxInFlightHandlers == 0 {
+		return
+	}
+
+	l := handler.NewLimiter(c.MaxInFlightHandlers)
+	for _, r := range c.Resources {
+		if r.Reconciler != nil {
+			r.Reconciler.Limiter = l
+		}
+		if r.Finalizer != nil {
+			r.Finalizer.Limiter = l
+		}
+	}
+}
+
+// setHTTPRateLimiter sets the rate limiter shared by all HTTP handlers
+// if the rate limit is configured.
+func setHTTPRateLimiter(c *config.Config) {
+	if c.HTTPRateLimit == nil {
+		return
+	}
+
+	burst := c.HTTPRateLimit.Burst
+	if burst == 0 {
+		burst = 1
+	}
 
-			if r.Injector != nil {
-				server.AddInjector(r)
+	l := rate.NewLimiter(rate.Limit(c.HTTPRateLimit.RequestsPerSecond), burst)
+	for _, h := range c.HTTPHandlers() {
+		h.RateLimiter = l
+	}
+}
+
+// setPluginHandlers loads the plugins in the plugin directory and sets
+// the registered handlers to the handlers that use them.
+func setPluginHandlers(c *config.Config) error {
+	handlers := c.PluginHandlers()
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	registered, err := plugin.Load(c.PluginDir)
+	if err != nil {
+		return err
+	}
+
+	for _, hc := range handlers {
+		h, ok := registered[hc.Plugin.Name]
+		if !ok {
+			return fmt.Errorf("plugin handler %q not found in %s", hc.Plugin.Name, c.PluginDir)
+		}
+
+		ph := plugin.New(h)
+		hc.StateHandler = ph
+		hc.AdmissionRequestHandler = ph
+		hc.InjectionRequestHandler = ph
+	}
+
+	return nil
+}
+
+// addWebhookServers adds the webhook servers to the manager. Each
+// server serves the webhooks of the resources that it is configured
+// to serve.
+func addWebhookServers(c *config.Config, mgr manager.Manager) error {
+	servers := c.WebhookServers()
+	if len(servers) == 0 {
+		return errors.New("webhook configuration must be specified")
+	}
+
+	for _, w := range servers {
+		server, err := webhook.NewServer(w, mgr)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range c.EnabledResources() {
+			err := server.AddResource(r)
+			if err != nil {
+				return err
 			}
 		}
 	}
 
-	return mgr, nil
+	return nil
+}
+
+// options returns the options of manager for the configuration.
+func options(c *config.Config) manager.Options {
+	opts := manager.Options{}
+
+	if c.Health != nil {
+		opts.HealthProbeBindAddress = c.Health.BindAddress
+	}
+
+	opts.MapperProvider = mapperProvider(c)
+
+	if c.Metrics == nil {
+		return opts
+	}
+
+	switch c.Metrics.Exporter {
+	case "", config.ExporterPrometheus:
+		if servesOwnMetrics(c.Metrics) {
+			// Metrics are served by the server of metrics package.
+			opts.MetricsBindAddress = "0"
+			break
+		}
+		opts.MetricsBindAddress = c.Metrics.BindAddress
+	default:
+		// Metrics are pushed by the exporter instead of being served.
+		opts.MetricsBindAddress = "0"
+	}
+
+	return opts
+}
+
+// mapperProvider returns the provider of the RESTMapper that is
+// refreshed periodically. It returns nil to use the default RESTMapper
+// if the refresh interval is not configured.
+func mapperProvider(c *config.Config) func(*rest.Config) (meta.RESTMapper, error) {
+	if c.MapperRefreshInterval == "" {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(c.MapperRefreshInterval)
+	if err != nil {
+		return nil
+	}
+
+	return func(rc *rest.Config) (meta.RESTMapper, error) {
+		newMapper, err := newDiscoveryMapper(rc)
+		if err != nil {
+			return nil, err
+		}
+		return newRefreshMapper(newMapper, interval)
+	}
+}
+
+// metricsGatherer returns the gatherer of the metrics with the
+// configured prefix.
+func metricsGatherer(c *config.Config) prometheus.Gatherer {
+	if c.Metrics == nil {
+		return ctrlmetrics.Registry
+	}
+
+	return metrics.WithPrefix(ctrlmetrics.Registry, c.Metrics.MetricsPrefix())
+}
+
+// servesOwnMetrics returns whether the metrics are served by the server
+// of metrics package instead of the manager, which always compresses
+// the response, serves the metrics without renaming and fails to start
+// if the bind address is not available.
+func servesOwnMetrics(c *config.MetricsConfig) bool {
+	return !c.Compresses() || c.MetricsPrefix() != config.DefaultMetricsPrefix || c.FailOpen
+}
+
+// metricsServer returns the server of metrics that is used instead of
+// the metrics server of the manager. It returns nil unless the metrics
+// are served without compression, with a custom prefix or with
+// fail-open.
+func metricsServer(c *config.Config) *metrics.Server {
+	if c.Metrics == nil || !servesOwnMetrics(c.Metrics) {
+		return nil
+	}
+
+	if c.Metrics.Exporter != "" && c.Metrics.Exporter != config.ExporterPrometheus {
+		return nil
+	}
+
+	if c.Metrics.BindAddress == "0" {
+		return nil
+	}
+
+	return metrics.NewServer(c.Metrics.BindAddress, metricsGatherer(c), c.Metrics.Compresses(), c.Metrics.FailOpen)
+}
+
+// restConfig returns a copy of specified rest.Config with the client
+// rate limits of the configuration.
+func restConfig(c *config.Config, kc *rest.Config) *rest.Config {
+	rc := rest.CopyConfig(kc)
+
+	if c.ClientQPS > 0 {
+		rc.QPS = c.ClientQPS
+	}
+
+	if c.ClientBurst > 0 {
+		rc.Burst = c.ClientBurst
+	}
+
+	rc.UserAgent = userAgent(c)
+
+	return rc
+}
+
+// userAgent returns the user agent of the configuration. It defaults to
+// 'whitebox-controller/<version> (<commit>)'.
+func userAgent(c *config.Config) string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+
+	return fmt.Sprintf("whitebox-controller/%s (%s)", Version, Commit)
+}
+
+// setUserAgent sets the user agent of the configuration to the HTTP
+// handlers that do not have their own user agent.
+func setUserAgent(c *config.Config) {
+	ua := userAgent(c)
+	for _, h := range c.HTTPHandlers() {
+		if h.UserAgent == "" {
+			h.UserAgent = ua
+		}
+	}
 }
@@ -0,0 +1,348 @@
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestRESTConfig(t *testing.T) {
+	RegisterTestingT(t)
+
+	kc := &rest.Config{
+		Host:  "https://127.0.0.1:6443",
+		QPS:   5,
+		Burst: 10,
+	}
+
+	c := &config.Config{
+		ClientQPS:   50,
+		ClientBurst: 100,
+		UserAgent:   "test-agent/1.0",
+	}
+
+	rc := restConfig(c, kc)
+	Expect(rc.Host).To(Equal(kc.Host))
+	Expect(rc.QPS).To(Equal(float32(50)))
+	Expect(rc.Burst).To(Equal(100))
+	Expect(rc.UserAgent).To(Equal("test-agent/1.0"))
+
+	// Original config is not modified
+	Expect(kc.QPS).To(Equal(float32(5)))
+	Expect(kc.Burst).To(Equal(10))
+
+	// Defaults
+	rc = restConfig(&config.Config{}, kc)
+	Expect(rc.QPS).To(Equal(float32(5)))
+	Expect(rc.Burst).To(Equal(10))
+	Expect(rc.UserAgent).To(Equal(fmt.Sprintf("whitebox-controller/%s (%s)", Version, Commit)))
+}
+
+func TestOptions(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Defaults
+	opts := options(&config.Config{})
+	Expect(opts.MetricsBindAddress).To(BeEmpty())
+	Expect(opts.HealthProbeBindAddress).To(BeEmpty())
+
+	// Health probes
+	opts = options(&config.Config{
+		Health: &config.HealthConfig{BindAddress: ":8081"},
+	})
+	Expect(opts.HealthProbeBindAddress).To(Equal(":8081"))
+
+	// Prometheus
+	opts = options(&config.Config{
+		Metrics: &config.MetricsConfig{
+			Exporter:    config.ExporterPrometheus,
+			BindAddress: ":9090",
+		},
+	})
+	Expect(opts.MetricsBindAddress).To(Equal(":9090"))
+
+	// Push exporter
+	opts = options(&config.Config{
+		Metrics: &config.MetricsConfig{
+			Exporter: config.ExporterStatsd,
+			Endpoint: "127.0.0.1:8125",
+		},
+	})
+	Expect(opts.MetricsBindAddress).To(Equal("0"))
+}
+
+func TestMetricsServer(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Metrics are served by the manager by default
+	c := &config.Config{
+		Metrics: &config.MetricsConfig{BindAddress: ":9090"},
+	}
+	Expect(metricsServer(c)).To(BeNil())
+	Expect(metricsServer(&config.Config{})).To(BeNil())
+
+	// Metrics without compression
+	compress := false
+	c = &config.Config{
+		Metrics: &config.MetricsConfig{BindAddress: ":9090", Compress: &compress},
+	}
+	Expect(options(c).MetricsBindAddress).To(Equal("0"))
+	Expect(metricsServer(c)).NotTo(BeNil())
+
+	// Metrics are not served
+	c.Metrics.BindAddress = "0"
+	Expect(metricsServer(c)).To(BeNil())
+
+	// Metrics with a custom prefix
+	c = &config.Config{
+		Metrics: &config.MetricsConfig{BindAddress: ":9090", Prefix: "myapp"},
+	}
+	Expect(options(c).MetricsBindAddress).To(Equal("0"))
+	Expect(metricsServer(c)).NotTo(BeNil())
+}
+
+func TestOptionsWithMetricsBindConflict(t *testing.T) {
+	RegisterTestingT(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer ln.Close()
+
+	addr := ln.Addr().String()
+
+	// Fail closed
+	c := &config.Config{
+		Metrics: &config.MetricsConfig{BindAddress: addr},
+	}
+	opts := options(c)
+	Expect(opts.MetricsBindAddress).To(Equal(addr))
+	Expect(metricsServer(c)).To(BeNil())
+
+	_, err = ctrlmetrics.NewListener(opts.MetricsBindAddress)
+	Expect(err).To(HaveOccurred())
+
+	// Fail open
+	c = &config.Config{
+		Metrics: &config.MetricsConfig{BindAddress: addr, FailOpen: true},
+	}
+	opts = options(c)
+	Expect(opts.MetricsBindAddress).To(Equal("0"))
+
+	server := metricsServer(c)
+	Expect(server).NotTo(BeNil())
+
+	stop := make(chan struct{})
+	close(stop)
+	Expect(server.Start(stop)).To(Succeed())
+}
+
+func TestSetUserAgent(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := &config.Config{
+		UserAgent: "test-agent/1.0",
+		Resources: []*config.ResourceConfig{
+			{
+				Reconciler: &config.ReconcilerConfig{
+					HandlerConfig: config.HandlerConfig{
+						HTTP: &config.HTTPHandlerConfig{URL: "http://127.0.0.1:8080"},
+					},
+				},
+				Validator: &config.HandlerConfig{
+					HTTP: &config.HTTPHandlerConfig{
+						URL:       "http://127.0.0.1:8080",
+						UserAgent: "validator/1.0",
+					},
+				},
+				Mutator: &config.HandlerConfig{
+					Exec: &config.ExecHandlerConfig{Command: "/bin/controller"},
+				},
+			},
+		},
+		Webhook: &config.ServerConfig{
+			Default: &config.DefaultWebhookConfig{
+				Validator: &config.HandlerConfig{
+					HTTP: &config.HTTPHandlerConfig{URL: "http://127.0.0.1:8080"},
+				},
+			},
+		},
+	}
+
+	setUserAgent(c)
+	Expect(c.Resources[0].Reconciler.HTTP.UserAgent).To(Equal("test-agent/1.0"))
+	Expect(c.Resources[0].Validator.HTTP.UserAgent).To(Equal("validator/1.0"))
+	Expect(c.Webhook.Default.Validator.HTTP.UserAgent).To(Equal("test-agent/1.0"))
+
+	// Default user agent with the version
+	defer func(v, commit string) { Version, Commit = v, commit }(Version, Commit)
+	Version, Commit = "v1.2.3", "abcdef"
+
+	c.UserAgent = ""
+	c.Resources[0].Reconciler.HTTP.UserAgent = ""
+	setUserAgent(c)
+	Expect(c.Resources[0].Reconciler.HTTP.UserAgent).To(Equal("whitebox-controller/v1.2.3 (abcdef)"))
+	Expect(c.Resources[0].Validator.HTTP.UserAgent).To(Equal("validator/1.0"))
+}
+
+func TestSetHandlerLimiter(t *testing.T) {
+	RegisterTestingT(t)
+
+	newResource := func() *config.ResourceConfig {
+		return &config.ResourceConfig{
+			Reconciler: &config.ReconcilerConfig{
+				HandlerConfig: config.HandlerConfig{
+					Exec: &config.ExecHandlerConfig{Command: "/bin/controller"},
+				},
+			},
+			Finalizer: &config.HandlerConfig{
+				Exec: &config.ExecHandlerConfig{Command: "/bin/controller"},
+			},
+		}
+	}
+
+	c := &config.Config{
+		MaxInFlightHandlers: 2,
+		Resources:           []*config.ResourceConfig{newResource(), newResource()},
+	}
+
+	setHandlerLimiter(c)
+	l := c.Resources[0].Reconciler.Limiter
+	Expect(l).NotTo(BeNil())
+	Expect(c.Resources[0].Finalizer.Limiter).To(BeIdenticalTo(l))
+	Expect(c.Resources[1].Reconciler.Limiter).To(BeIdenticalTo(l))
+
+	// No limit
+	c = &config.Config{Resources: []*config.ResourceConfig{newResource()}}
+	setHandlerLimiter(c)
+	Expect(c.Resources[0].Reconciler.Limiter).To(BeNil())
+}
+
+func TestSetHTTPRateLimiter(t *testing.T) {
+	RegisterTestingT(t)
+
+	reconciler := &config.HTTPHandlerConfig{URL: "http://127.0.0.1:8080"}
+	validator := &config.HTTPHandlerConfig{URL: "http://127.0.0.1:8081"}
+	r := &config.ResourceConfig{
+		Reconciler: &config.ReconcilerConfig{
+			HandlerConfig: config.HandlerConfig{HTTP: reconciler},
+		},
+		Finalizer: &config.HandlerConfig{Exec: &config.ExecHandlerConfig{Command: "/bin/controller"}},
+	}
+
+	c := &config.Config{
+		HTTPRateLimit: &config.HandlerRateLimit{RequestsPerSecond: 5},
+		Resources:     []*config.ResourceConfig{r},
+		Webhook: &config.ServerConfig{
+			Default: &config.DefaultWebhookConfig{Validator: &config.HandlerConfig{HTTP: validator}},
+		},
+	}
+
+	// All HTTP handlers share the limiter
+	setHTTPRateLimiter(c)
+	Expect(reconciler.RateLimiter).NotTo(BeNil())
+	Expect(reconciler.RateLimiter.Burst()).To(Equal(1))
+	Expect(validator.RateLimiter).To(BeIdenticalTo(reconciler.RateLimiter))
+
+	// No rate limit
+	reconciler = &config.HTTPHandlerConfig{URL: "http://127.0.0.1:8080"}
+	r.Reconciler.HTTP = reconciler
+	c.HTTPRateLimit = nil
+	setHTTPRateLimiter(c)
+	Expect(reconciler.RateLimiter).To(BeNil())
+}
+
+func TestIndexEvents(t *testing.T) {
+	RegisterTestingT(t)
+
+	resources := []*config.ResourceConfig{
+		{Reconciler: &config.ReconcilerConfig{}},
+		{},
+	}
+
+	// No reconciler includes events
+	fi := &testFieldIndexer{}
+	err := indexEvents(resources, fi)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(fi.fields).To(BeEmpty())
+
+	// Events are indexed once for all reconcilers
+	resources = append(resources,
+		&config.ResourceConfig{Reconciler: &config.ReconcilerConfig{IncludeEvents: 5}},
+		&config.ResourceConfig{Reconciler: &config.ReconcilerConfig{IncludeEvents: 1}},
+	)
+
+	err = indexEvents(resources, fi)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(fi.fields).To(Equal([]string{"involvedObject.uid"}))
+
+	ev := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	unstructured.SetNestedField(ev.Object, "test-uid", "involvedObject", "uid")
+	Expect(fi.extract(ev)).To(Equal([]string{"test-uid"}))
+	Expect(fi.extract(&unstructured.Unstructured{Object: map[string]interface{}{}})).To(BeEmpty())
+}
+
+// testFieldIndexer records the indexed fields.
+type testFieldIndexer struct {
+	fields  []string
+	extract client.IndexerFunc
+}
+
+func (fi *testFieldIndexer) IndexField(obj runtime.Object, field string, extract client.IndexerFunc) error {
+	fi.fields = append(fi.fields, field)
+	fi.extract = extract
+	return nil
+}
+
+func TestSetPluginHandlers(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-plugin-")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	// No plugin handlers
+	c := &config.Config{
+		PluginDir: filepath.Join(dir, "missing"),
+		Resources: []*config.ResourceConfig{
+			{
+				Reconciler: &config.ReconcilerConfig{
+					HandlerConfig: config.HandlerConfig{
+						Exec: &config.ExecHandlerConfig{Command: "/bin/controller"},
+					},
+				},
+			},
+		},
+	}
+	Expect(setPluginHandlers(c)).To(Succeed())
+	Expect(c.Resources[0].Reconciler.StateHandler).To(BeNil())
+
+	// Plugin handler not found
+	c = &config.Config{
+		PluginDir: dir,
+		Resources: []*config.ResourceConfig{
+			{
+				Reconciler: &config.ReconcilerConfig{
+					HandlerConfig: config.HandlerConfig{
+						Plugin: &config.PluginHandlerConfig{Name: "reconcile"},
+					},
+				},
+			},
+		},
+	}
+	err = setPluginHandlers(c)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring(`plugin handler "reconcile" not found`))
+}
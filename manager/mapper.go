@@ -0,0 +1,209 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// minMissRefreshInterval is the minimum interval of the refreshes on
+// the lookups of unknown kinds, so that the lookups do not flood the
+// discovery API of the API server.
+const minMissRefreshInterval = 5 * time.Second
+
+// refreshMapper is a RESTMapper that rediscovers the resources of the
+// API server periodically and when a kind is not found, so that the
+// kinds of the CRDs installed after the controller started can be used
+// without restarting the controller.
+type refreshMapper struct {
+	newMapper   func() (meta.RESTMapper, error)
+	interval    time.Duration
+	minInterval time.Duration
+
+	// refreshMu serializes the refreshes.
+	refreshMu sync.Mutex
+
+	mu          sync.RWMutex
+	mapper      meta.RESTMapper
+	refreshedAt time.Time
+}
+
+// newRefreshMapper returns a new RESTMapper that is refreshed by
+// newMapper every interval.
+func newRefreshMapper(newMapper func() (meta.RESTMapper, error), interval time.Duration) (*refreshMapper, error) {
+	m := &refreshMapper{
+		newMapper:   newMapper,
+		interval:    interval,
+		minInterval: minMissRefreshInterval,
+	}
+
+	err := m.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// newDiscoveryMapper returns a function that returns a RESTMapper of
+// the resources discovered from the API server.
+func newDiscoveryMapper(rc *rest.Config) (func() (meta.RESTMapper, error), error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (meta.RESTMapper, error) {
+		gr, err := restmapper.GetAPIGroupResources(dc)
+		if err != nil {
+			return nil, err
+		}
+		return restmapper.NewDiscoveryRESTMapper(gr), nil
+	}, nil
+}
+
+// Start implements manager.Runnable interface. It refreshes the mapper
+// every interval until stop is closed.
+func (m *refreshMapper) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := m.refresh()
+			if err != nil {
+				log.Error(err, "Failed to refresh the REST mapper")
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+func (m *refreshMapper) refresh() error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	return m.reload()
+}
+
+// reload replaces the mapper with a new one. refreshMu must be held.
+func (m *refreshMapper) reload() error {
+	mapper, err := m.newMapper()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mapper = mapper
+	m.refreshedAt = time.Now()
+
+	return nil
+}
+
+// refreshOnMiss refreshes the mapper if specified error of a lookup
+// means that the kind or the resource is not found. It returns whether
+// the lookup should be retried. The mapper is not refreshed if it has
+// been refreshed within the minimum interval.
+func (m *refreshMapper) refreshOnMiss(err error) bool {
+	if err == nil || !meta.IsNoMatchError(err) {
+		return false
+	}
+
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	m.mu.RLock()
+	recent := time.Since(m.refreshedAt) < m.minInterval
+	m.mu.RUnlock()
+
+	if recent {
+		return false
+	}
+
+	err = m.reload()
+	if err != nil {
+		log.Error(err, "Failed to refresh the REST mapper")
+		return false
+	}
+
+	return true
+}
+
+func (m *refreshMapper) current() meta.RESTMapper {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.mapper
+}
+
+// KindFor implements meta.RESTMapper interface.
+func (m *refreshMapper) KindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	gvk, err := m.current().KindFor(resource)
+	if m.refreshOnMiss(err) {
+		gvk, err = m.current().KindFor(resource)
+	}
+	return gvk, err
+}
+
+// KindsFor implements meta.RESTMapper interface.
+func (m *refreshMapper) KindsFor(resource schema.GroupVersionResource) ([]schema.GroupVersionKind, error) {
+	gvks, err := m.current().KindsFor(resource)
+	if m.refreshOnMiss(err) {
+		gvks, err = m.current().KindsFor(resource)
+	}
+	return gvks, err
+}
+
+// ResourceFor implements meta.RESTMapper interface.
+func (m *refreshMapper) ResourceFor(input schema.GroupVersionResource) (schema.GroupVersionResource, error) {
+	gvr, err := m.current().ResourceFor(input)
+	if m.refreshOnMiss(err) {
+		gvr, err = m.current().ResourceFor(input)
+	}
+	return gvr, err
+}
+
+// ResourcesFor implements meta.RESTMapper interface.
+func (m *refreshMapper) ResourcesFor(input schema.GroupVersionResource) ([]schema.GroupVersionResource, error) {
+	gvrs, err := m.current().ResourcesFor(input)
+	if m.refreshOnMiss(err) {
+		gvrs, err = m.current().ResourcesFor(input)
+	}
+	return gvrs, err
+}
+
+// RESTMapping implements meta.RESTMapper interface.
+func (m *refreshMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	mapping, err := m.current().RESTMapping(gk, versions...)
+	if m.refreshOnMiss(err) {
+		mapping, err = m.current().RESTMapping(gk, versions...)
+	}
+	return mapping, err
+}
+
+// RESTMappings implements meta.RESTMapper interface.
+func (m *refreshMapper) RESTMappings(gk schema.GroupKind, versions ...string) ([]*meta.RESTMapping, error) {
+	mappings, err := m.current().RESTMappings(gk, versions...)
+	if m.refreshOnMiss(err) {
+		mappings, err = m.current().RESTMappings(gk, versions...)
+	}
+	return mappings, err
+}
+
+// ResourceSingularizer implements meta.RESTMapper interface.
+func (m *refreshMapper) ResourceSingularizer(resource string) (string, error) {
+	singular, err := m.current().ResourceSingularizer(resource)
+	if m.refreshOnMiss(err) {
+		singular, err = m.current().ResourceSingularizer(resource)
+	}
+	return singular, err
+}
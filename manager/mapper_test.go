@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+// testDiscovery simulates the discovery of the API server where kinds
+// can be installed after startup.
+type testDiscovery struct {
+	mu    sync.Mutex
+	kinds []schema.GroupVersionKind
+	calls int
+}
+
+func (d *testDiscovery) install(gvk schema.GroupVersionKind) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.kinds = append(d.kinds, gvk)
+}
+
+func (d *testDiscovery) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls
+}
+
+func (d *testDiscovery) newMapper() (meta.RESTMapper, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls++
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range d.kinds {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+
+	return mapper, nil
+}
+
+func TestRefreshMapperOnMiss(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"}
+	d := &testDiscovery{}
+
+	m, err := newRefreshMapper(d.newMapper, time.Hour)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(d.count()).To(Equal(1))
+
+	// Case: Unknown kind within the minimum interval
+	_, err = m.RESTMapping(gvk.GroupKind(), gvk.Version)
+	Expect(meta.IsNoMatchError(err)).To(BeTrue())
+	Expect(d.count()).To(Equal(1))
+
+	// Case: Kind installed after startup
+	d.install(gvk)
+	m.minInterval = 0
+
+	mapping, err := m.RESTMapping(gvk.GroupKind(), gvk.Version)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(mapping.GroupVersionKind).To(Equal(gvk))
+	Expect(d.count()).To(Equal(2))
+
+	// Case: Known kind does not refresh
+	_, err = m.RESTMapping(gvk.GroupKind(), gvk.Version)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(d.count()).To(Equal(2))
+}
+
+func TestRefreshMapperStart(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"}
+	d := &testDiscovery{}
+
+	m, err := newRefreshMapper(d.newMapper, 10*time.Millisecond)
+	Expect(err).NotTo(HaveOccurred())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.Start(stop)
+
+	d.install(gvk)
+
+	// The kind is resolvable by the periodic refresh without a miss
+	Eventually(func() error {
+		_, err := m.current().RESTMapping(gvk.GroupKind(), gvk.Version)
+		return err
+	}).Should(Succeed())
+}
+
+func TestMapperProvider(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Default mapper
+	Expect(mapperProvider(&config.Config{})).To(BeNil())
+	Expect(options(&config.Config{}).MapperProvider).To(BeNil())
+
+	// Refreshed mapper
+	c := &config.Config{MapperRefreshInterval: "1m"}
+	Expect(mapperProvider(c)).NotTo(BeNil())
+	Expect(options(c).MapperProvider).NotTo(BeNil())
+}
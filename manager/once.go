@@ -0,0 +1,175 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler"
+)
+
+var log = logf.Log.WithName("manager")
+
+// RunOnce reconciles all existing objects of the enabled resources
+// exactly one time. It returns the number of objects that failed to
+// reconcile. Requeues requested by the handlers are ignored.
+func RunOnce(c *config.Config, kc *rest.Config) (int, error) {
+	err := c.Validate()
+	if err != nil {
+		return 0, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	err = setPluginHandlers(c)
+	if err != nil {
+		return 0, err
+	}
+
+	setUserAgent(c)
+	setHTTPRateLimiter(c)
+
+	err = handler.SetMiddlewares(c.Middlewares...)
+	if err != nil {
+		return 0, err
+	}
+
+	rc := restConfig(c, kc)
+
+	err = checkResources(c, rc)
+	if err != nil {
+		return 0, err
+	}
+
+	cl, err := client.New(rc, client.Options{})
+	if err != nil {
+		return 0, err
+	}
+
+	cs, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return 0, err
+	}
+
+	broadcaster := record.NewBroadcaster()
+	w := broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: cs.CoreV1().Events("")})
+	defer w.Stop()
+
+	delay, err := startupDelay(c)
+	if err != nil {
+		return 0, err
+	}
+	if delay > 0 {
+		log.Info("Waiting for the startup delay before reconciling", "delay", delay.String())
+		time.Sleep(delay)
+	}
+
+	failed := 0
+	for _, r := range c.EnabledResources() {
+		if r.Reconciler == nil {
+			continue
+		}
+
+		// Retries are meaningless since each object is reconciled
+		// only once, and would hide the failures.
+		rec := *r.Reconciler
+		rec.MaxRetries = 0
+
+		for _, resource := range r.ReconciledResources() {
+			res := *resource
+			res.Reconciler = &rec
+
+			name := fmt.Sprintf("%s-controller", strings.ToLower(res.Kind))
+			recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: name})
+
+			rr, err := reconciler.New(&res, recorder)
+			if err != nil {
+				return failed, fmt.Errorf("could not create reconciler: %v", err)
+			}
+			rr.InjectClient(cl)
+
+			err = rr.InjectConfig(rc)
+			if err != nil {
+				return failed, err
+			}
+
+			n, err := reconcileWithResultSink(cl, rr, res.GroupVersionKind)
+			if err != nil {
+				return failed, err
+			}
+			failed += n
+		}
+	}
+
+	return failed, nil
+}
+
+// reconcileWithResultSink reconciles all objects of specified kind one
+// time with the result sink of the reconciler started, and sends all
+// the result records before returning.
+func reconcileWithResultSink(cl client.Reader, r *reconciler.Reconciler, gvk schema.GroupVersionKind) (int, error) {
+	sink := r.ResultSink()
+	if sink == nil {
+		return reconcileAll(cl, r, gvk)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sink.Start(stop)
+	}()
+
+	n, err := reconcileAll(cl, r, gvk)
+
+	// The queued records are sent on stop.
+	close(stop)
+	<-done
+
+	return n, err
+}
+
+// reconcileAll reconciles all objects of specified kind one time and
+// returns the number of objects that failed to reconcile.
+func reconcileAll(cl client.Reader, r reconcile.Reconciler, gvk schema.GroupVersionKind) (int, error) {
+	listGVK := gvk
+	listGVK.Kind = gvk.Kind + "List"
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(listGVK)
+
+	err := cl.List(context.TODO(), list)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s: %v", gvk.Kind, err)
+	}
+
+	failed := 0
+	for i := range list.Items {
+		nn := types.NamespacedName{
+			Namespace: list.Items[i].GetNamespace(),
+			Name:      list.Items[i].GetName(),
+		}
+
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: nn})
+		if err != nil {
+			log.Error(err, "Failed to reconcile a resource", "kind", gvk.Kind, "namespace", nn.Namespace, "name", nn.Name)
+			failed++
+		}
+	}
+
+	return failed, nil
+}
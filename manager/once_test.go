@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileAll(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"}
+
+	cl := &testListClient{}
+	for _, name := range []string{"a", "b", "c"} {
+		obj := unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		cl.items = append(cl.items, obj)
+	}
+
+	// All succeeded
+	r := &testReconciler{calls: map[types.NamespacedName]int{}}
+	failed, err := reconcileAll(cl, r, gvk)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(failed).To(Equal(0))
+	Expect(cl.kind).To(Equal("TestList"))
+	Expect(r.calls).To(Equal(map[types.NamespacedName]int{
+		{Namespace: "default", Name: "a"}: 1,
+		{Namespace: "default", Name: "b"}: 1,
+		{Namespace: "default", Name: "c"}: 1,
+	}))
+
+	// Failed
+	r = &testReconciler{
+		calls: map[types.NamespacedName]int{},
+		fail:  map[string]bool{"b": true},
+	}
+	failed, err = reconcileAll(cl, r, gvk)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(failed).To(Equal(1))
+	Expect(r.calls).To(HaveLen(3))
+
+	// List error
+	cl.err = errors.New("unexpected error")
+	_, err = reconcileAll(cl, r, gvk)
+	Expect(err).To(HaveOccurred())
+}
+
+// testListClient returns the items on List calls.
+type testListClient struct {
+	client.Reader
+	items []unstructured.Unstructured
+	kind  string
+	err   error
+}
+
+func (c *testListClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	l := list.(*unstructured.UnstructuredList)
+	c.kind = l.GetKind()
+	l.Items = append(l.Items, c.items...)
+
+	return nil
+}
+
+// testReconciler counts the reconciles of each object.
+type testReconciler struct {
+	calls map[types.NamespacedName]int
+	fail  map[string]bool
+}
+
+func (r *testReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	r.calls[req.NamespacedName]++
+	if r.fail[req.Name] {
+		return reconcile.Result{}, errors.New("failed")
+	}
+
+	return reconcile.Result{}, nil
+}
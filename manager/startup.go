@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+// addStartupDelay delays the reconciles of all resources until the
+// startup delay has elapsed since the manager started.
+func addStartupDelay(c *config.Config, mgr manager.Manager) error {
+	delay, err := startupDelay(c)
+	if err != nil || delay == 0 {
+		return err
+	}
+
+	started, gate := newStartupGate(delay)
+	for _, r := range c.Resources {
+		if r.Reconciler != nil {
+			r.Reconciler.Started = started
+		}
+	}
+
+	return mgr.Add(gate)
+}
+
+// startupDelay returns the configured startup delay.
+func startupDelay(c *config.Config) (time.Duration, error) {
+	if c.StartupDelay == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(c.StartupDelay)
+}
+
+// newStartupGate returns a channel that is closed once specified delay
+// has elapsed since the returned runnable started. The channel is also
+// closed when the runnable is stopped, so that the waiting reconciles
+// are not left blocked.
+func newStartupGate(delay time.Duration) (<-chan struct{}, manager.RunnableFunc) {
+	started := make(chan struct{})
+
+	return started, func(stop <-chan struct{}) error {
+		defer close(started)
+
+		log.Info("Waiting for the startup delay before reconciling", "delay", delay.String())
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-stop:
+		}
+
+		return nil
+	}
+}
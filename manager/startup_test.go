@@ -0,0 +1,32 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestStartupGate(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Case: Delay elapsed
+	started, gate := newStartupGate(200 * time.Millisecond)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go gate.Start(stop)
+
+	Consistently(started, 100*time.Millisecond).ShouldNot(BeClosed())
+	Eventually(started).Should(BeClosed())
+
+	// Case: Stopped before the delay elapses
+	started, gate = newStartupGate(time.Hour)
+
+	stop2 := make(chan struct{})
+	go gate.Start(stop2)
+
+	Consistently(started, 100*time.Millisecond).ShouldNot(BeClosed())
+	close(stop2)
+	Eventually(started).Should(BeClosed())
+}
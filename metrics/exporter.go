@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+// defaultInterval is the interval of pushing metrics if it is not
+// configured.
+const defaultInterval = 30 * time.Second
+
+var log = logf.Log.WithName("metrics")
+
+// sample is a value of metric.
+type sample struct {
+	name    string
+	labels  []label
+	value   float64
+	counter bool
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// pusher sends the samples to an external endpoint.
+type pusher interface {
+	push(samples []sample) error
+}
+
+// Exporter pushes metrics to an external endpoint periodically.
+type Exporter struct {
+	gatherer prometheus.Gatherer
+	pusher   pusher
+	interval time.Duration
+}
+
+// New returns a new exporter for specified configuration. It returns
+// nil if metrics are only served for Prometheus to scrape.
+func New(c *config.MetricsConfig, g prometheus.Gatherer) (*Exporter, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	interval := defaultInterval
+	if c.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(c.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %v", err)
+		}
+	}
+
+	var p pusher
+	switch c.Exporter {
+	case "", config.ExporterPrometheus:
+		return nil, nil
+	case config.ExporterOTLP:
+		p = newOTLPPusher(c.Endpoint, interval)
+	case config.ExporterStatsd:
+		p = newStatsdPusher(c.Endpoint)
+	default:
+		return nil, fmt.Errorf("invalid exporter: %s", c.Exporter)
+	}
+
+	if g == nil {
+		return nil, errors.New("gatherer must be specified")
+	}
+
+	return &Exporter{
+		gatherer: g,
+		pusher:   p,
+		interval: interval,
+	}, nil
+}
+
+// Start implements manager.Runnable interface. It pushes metrics until
+// stop is closed, and pushes them once more before returning.
+func (e *Exporter) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := e.export()
+			if err != nil {
+				log.Error(err, "Failed to export metrics")
+			}
+		case <-stop:
+			err := e.export()
+			if err != nil {
+				log.Error(err, "Failed to export metrics")
+			}
+			return nil
+		}
+	}
+}
+
+// export gathers metrics and pushes them.
+func (e *Exporter) export() error {
+	mfs, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %v", err)
+	}
+
+	return e.pusher.push(collect(mfs))
+}
+
+// collect converts the metric families to samples. Summaries and
+// histograms are converted to the samples of their count and sum.
+func collect(mfs []*dto.MetricFamily) []sample {
+	samples := []sample{}
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			labels := []label{}
+			for _, lp := range m.GetLabel() {
+				labels = append(labels, label{name: lp.GetName(), value: lp.GetValue()})
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				samples = append(samples, sample{name: name, labels: labels, value: m.GetCounter().GetValue(), counter: true})
+			case dto.MetricType_GAUGE:
+				samples = append(samples, sample{name: name, labels: labels, value: m.GetGauge().GetValue()})
+			case dto.MetricType_UNTYPED:
+				samples = append(samples, sample{name: name, labels: labels, value: m.GetUntyped().GetValue()})
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				samples = append(samples,
+					sample{name: name + "_count", labels: labels, value: float64(s.GetSampleCount()), counter: true},
+					sample{name: name + "_sum", labels: labels, value: s.GetSampleSum(), counter: true},
+				)
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				samples = append(samples,
+					sample{name: name + "_count", labels: labels, value: float64(h.GetSampleCount()), counter: true},
+					sample{name: name + "_sum", labels: labels, value: h.GetSampleSum(), counter: true},
+				)
+			}
+		}
+	}
+
+	return samples
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestNew(t *testing.T) {
+	RegisterTestingT(t)
+
+	reg := prometheus.NewRegistry()
+
+	// No configuration
+	e, err := New(nil, reg)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(e).To(BeNil())
+
+	// Prometheus
+	e, err = New(&config.MetricsConfig{Exporter: config.ExporterPrometheus}, reg)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(e).To(BeNil())
+
+	// OTLP
+	e, err = New(&config.MetricsConfig{
+		Exporter: config.ExporterOTLP,
+		Endpoint: "http://127.0.0.1:4318/v1/metrics",
+		Interval: "10s",
+	}, reg)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(e.pusher).To(BeAssignableToTypeOf(&otlpPusher{}))
+	Expect(e.interval).To(Equal(10 * time.Second))
+
+	// Statsd
+	e, err = New(&config.MetricsConfig{
+		Exporter: config.ExporterStatsd,
+		Endpoint: "127.0.0.1:8125",
+	}, reg)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(e.pusher).To(BeAssignableToTypeOf(&statsdPusher{}))
+	Expect(e.interval).To(Equal(defaultInterval))
+
+	// Invalid exporter
+	_, err = New(&config.MetricsConfig{Exporter: "influxdb"}, reg)
+	Expect(err).To(HaveOccurred())
+}
+
+func TestExportStatsd(t *testing.T) {
+	RegisterTestingT(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer conn.Close()
+
+	e, err := New(&config.MetricsConfig{
+		Exporter: config.ExporterStatsd,
+		Endpoint: conn.LocalAddr().String(),
+	}, newTestRegistry())
+	Expect(err).NotTo(HaveOccurred())
+
+	err = e.export()
+	Expect(err).NotTo(HaveOccurred())
+
+	buf := make([]byte, maxPacketSize)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	Expect(err).NotTo(HaveOccurred())
+
+	lines := strings.Split(string(buf[:n]), "\n")
+	Expect(lines).To(ConsistOf(
+		"test_reconciles_total:3|g|#controller:test",
+		"test_queue_depth:5|g",
+	))
+}
+
+func TestExportOTLP(t *testing.T) {
+	RegisterTestingT(t)
+
+	var (
+		contentType string
+		body        []byte
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	e, err := New(&config.MetricsConfig{
+		Exporter: config.ExporterOTLP,
+		Endpoint: server.URL,
+	}, newTestRegistry())
+	Expect(err).NotTo(HaveOccurred())
+
+	err = e.export()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(contentType).To(Equal("application/json"))
+
+	req := otlpRequest{}
+	err = json.Unmarshal(body, &req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(req.ResourceMetrics).To(HaveLen(1))
+	Expect(req.ResourceMetrics[0].ScopeMetrics).To(HaveLen(1))
+
+	metrics := map[string]otlpMetric{}
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	counter := metrics["test_reconciles_total"]
+	Expect(counter.Sum).NotTo(BeNil())
+	Expect(counter.Sum.IsMonotonic).To(BeTrue())
+	Expect(counter.Sum.DataPoints).To(HaveLen(1))
+	Expect(counter.Sum.DataPoints[0].AsDouble).To(Equal(3.0))
+	Expect(counter.Sum.DataPoints[0].Attributes).To(Equal([]otlpAttribute{
+		{Key: "controller", Value: otlpAttributeValue{StringValue: "test"}},
+	}))
+
+	gauge := metrics["test_queue_depth"]
+	Expect(gauge.Gauge).NotTo(BeNil())
+	Expect(gauge.Gauge.DataPoints).To(HaveLen(1))
+	Expect(gauge.Gauge.DataPoints[0].AsDouble).To(Equal(5.0))
+
+	// Error response
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	err = e.export()
+	Expect(err).To(HaveOccurred())
+}
+
+func newTestRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_reconciles_total",
+		Help: "Test counter",
+	}, []string{"controller"})
+	counter.WithLabelValues("test").Add(3)
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "test_queue_depth",
+		Help: "Test gauge",
+	})
+	gauge.Set(5)
+
+	reg.MustRegister(counter, gauge)
+
+	return reg
+}
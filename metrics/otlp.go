@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// The name of instrumentation scope of the metrics.
+	otlpScopeName = "whitebox-controller"
+	// The cumulative aggregation temporality of OTLP.
+	otlpCumulative = 2
+)
+
+// otlpPusher pushes metrics to an OTLP collector with the JSON
+// encoding of OTLP/HTTP.
+type otlpPusher struct {
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+}
+
+func newOTLPPusher(endpoint string, timeout time.Duration) *otlpPusher {
+	return &otlpPusher{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: timeout},
+		now:      time.Now,
+	}
+}
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func (p *otlpPusher) push(samples []sample) error {
+	body, err := json.Marshal(p.newRequest(samples))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send metrics to OTLP collector: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("failed to send metrics to OTLP collector: invalid status: %s", res.Status)
+	}
+
+	return nil
+}
+
+// newRequest returns a request of OTLP for specified samples. The
+// samples of same name are grouped into a metric.
+func (p *otlpPusher) newRequest(samples []sample) *otlpRequest {
+	ts := strconv.FormatInt(p.now().UnixNano(), 10)
+
+	metrics := []otlpMetric{}
+	index := map[string]int{}
+
+	for _, s := range samples {
+		dp := otlpDataPoint{TimeUnixNano: ts, AsDouble: s.value}
+		for _, l := range s.labels {
+			dp.Attributes = append(dp.Attributes, otlpAttribute{
+				Key:   l.name,
+				Value: otlpAttributeValue{StringValue: l.value},
+			})
+		}
+
+		i, ok := index[s.name]
+		if !ok {
+			m := otlpMetric{Name: s.name}
+			if s.counter {
+				m.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+			} else {
+				m.Gauge = &otlpGauge{}
+			}
+			metrics = append(metrics, m)
+			i = len(metrics) - 1
+			index[s.name] = i
+		}
+
+		if metrics[i].Sum != nil {
+			metrics[i].Sum.DataPoints = append(metrics[i].Sum.DataPoints, dp)
+		} else {
+			metrics[i].Gauge.DataPoints = append(metrics[i].Gauge.DataPoints, dp)
+		}
+	}
+
+	return &otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{
+			{
+				ScopeMetrics: []otlpScopeMetrics{
+					{Scope: otlpScope{Name: otlpScopeName}, Metrics: metrics},
+				},
+			},
+		},
+	}
+}
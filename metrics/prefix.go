@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+// prefixGatherer replaces the default prefix of the names of the
+// metrics of the controller with the configured prefix. Other metrics,
+// such as the metrics of the Go runtime, are gathered as is.
+type prefixGatherer struct {
+	prometheus.Gatherer
+	prefix string
+}
+
+// WithPrefix returns a gatherer that gathers metrics of specified
+// gatherer with the names prefixed by prefix instead of
+// DefaultMetricsPrefix. It returns g as is if prefix is the default.
+func WithPrefix(g prometheus.Gatherer, prefix string) prometheus.Gatherer {
+	if prefix == "" || prefix == config.DefaultMetricsPrefix {
+		return g
+	}
+
+	return &prefixGatherer{Gatherer: g, prefix: prefix}
+}
+
+// Gather implements prometheus.Gatherer interface.
+func (g *prefixGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+
+	defaultPrefix := config.DefaultMetricsPrefix + "_"
+	for i, mf := range mfs {
+		name := mf.GetName()
+		if !strings.HasPrefix(name, defaultPrefix) {
+			continue
+		}
+
+		mfs[i] = &dto.MetricFamily{
+			Name:   proto.String(g.prefix + "_" + strings.TrimPrefix(name, defaultPrefix)),
+			Help:   mf.Help,
+			Type:   mf.Type,
+			Metric: mf.Metric,
+		}
+	}
+
+	// Metric families are sorted by name as the other gatherers do.
+	sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+
+	return mfs, err
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWithPrefix(t *testing.T) {
+	RegisterTestingT(t)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewCounter(prometheus.CounterOpts{Name: "whitebox_reconcile_errors_total", Help: "Test counter"}),
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "whitebox_queue_depth", Help: "Test gauge"}),
+		prometheus.NewCounter(prometheus.CounterOpts{Name: "workqueue_adds_total", Help: "Test counter"}),
+	)
+
+	names := func(g prometheus.Gatherer) []string {
+		mfs, err := g.Gather()
+		Expect(err).NotTo(HaveOccurred())
+
+		names := []string{}
+		for _, mf := range mfs {
+			names = append(names, mf.GetName())
+		}
+		return names
+	}
+
+	// Case: Default prefix
+	Expect(WithPrefix(reg, "")).To(Equal(reg))
+	Expect(WithPrefix(reg, "whitebox")).To(Equal(reg))
+
+	// Case: Custom prefix
+	Expect(names(WithPrefix(reg, "myapp"))).To(Equal([]string{
+		"myapp_queue_depth",
+		"myapp_reconcile_errors_total",
+		"workqueue_adds_total",
+	}))
+
+	// Case: Prefix that changes the order of the names
+	Expect(names(WithPrefix(reg, "zz"))).To(Equal([]string{
+		"workqueue_adds_total",
+		"zz_queue_depth",
+		"zz_reconcile_errors_total",
+	}))
+
+	// The metrics of the registry are not renamed
+	Expect(names(reg)).To(ContainElement("whitebox_queue_depth"))
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Path is the path to serve metrics.
+const Path = "/metrics"
+
+// Server serves metrics for Prometheus to scrape. It is used instead of
+// the metrics server of the manager when the compression is disabled,
+// since the manager always compresses the response if the client
+// accepts it, or when the metrics are disabled on bind failures.
+type Server struct {
+	addr     string
+	handler  http.Handler
+	failOpen bool
+}
+
+// NewServer returns a new server that serves metrics of specified
+// gatherer on addr. If addr is empty, the default bind address of
+// the manager is used. If failOpen is true, the server does not serve
+// metrics instead of failing when addr cannot be bound.
+func NewServer(addr string, g prometheus.Gatherer, compress, failOpen bool) *Server {
+	if addr == "" {
+		addr = ctrlmetrics.DefaultBindAddress
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, promhttp.HandlerFor(g, promhttp.HandlerOpts{
+		ErrorHandling:      promhttp.HTTPErrorOnError,
+		DisableCompression: !compress,
+	}))
+
+	return &Server{
+		addr:     addr,
+		handler:  mux,
+		failOpen: failOpen,
+	}
+}
+
+// ServeHTTP implements http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Start implements manager.Runnable interface. It serves metrics until
+// stop is closed.
+func (s *Server) Start(stop <-chan struct{}) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		if !s.failOpen {
+			return err
+		}
+
+		log.Info("Metrics are disabled since the bind address is not available", "addr", s.addr, "error", err.Error())
+		<-stop
+		return nil
+	}
+
+	server := &http.Server{Handler: s.handler}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info("Starting metrics server", "addr", s.addr, "path", Path)
+		err := server.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case <-stop:
+		return server.Shutdown(context.Background())
+	case err := <-errCh:
+		return err
+	}
+}
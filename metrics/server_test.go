@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestServer(t *testing.T) {
+	RegisterTestingT(t)
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_total",
+		Help: "Test counter",
+	})
+	reg.MustRegister(counter)
+	counter.Add(3)
+
+	scrape := func(s *Server) *http.Response {
+		req := httptest.NewRequest("GET", Path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	parse := func(r io.Reader) {
+		mfs, err := (&expfmt.TextParser{}).TextToMetricFamilies(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mfs).To(HaveKey("test_total"))
+		Expect(mfs["test_total"].GetMetric()[0].GetCounter().GetValue()).To(Equal(3.0))
+	}
+
+	// Compressed
+	res := scrape(NewServer("", reg, true, false))
+	Expect(res.StatusCode).To(Equal(http.StatusOK))
+	Expect(res.Header.Get("Content-Encoding")).To(Equal("gzip"))
+
+	gr, err := gzip.NewReader(res.Body)
+	Expect(err).NotTo(HaveOccurred())
+	parse(gr)
+
+	// Uncompressed
+	res = scrape(NewServer("", reg, false, false))
+	Expect(res.StatusCode).To(Equal(http.StatusOK))
+	Expect(res.Header.Get("Content-Encoding")).To(BeEmpty())
+	parse(res.Body)
+}
+
+func TestServerStart(t *testing.T) {
+	RegisterTestingT(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := NewServer(addr, prometheus.NewRegistry(), false, false)
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Start(stop)
+	}()
+
+	Eventually(func() error {
+		res, err := http.Get("http://" + addr + Path)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		_, err = ioutil.ReadAll(res.Body)
+		return err
+	}).Should(Succeed())
+
+	close(stop)
+	Eventually(done, time.Second).Should(Receive(BeNil()))
+}
+
+func TestServerStartWithBindConflict(t *testing.T) {
+	RegisterTestingT(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer ln.Close()
+	addr := ln.Addr().String()
+
+	// Fail closed
+	s := NewServer(addr, prometheus.NewRegistry(), false, false)
+	err = s.Start(make(chan struct{}))
+	Expect(err).To(HaveOccurred())
+
+	// Fail open
+	s = NewServer(addr, prometheus.NewRegistry(), false, true)
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Start(stop)
+	}()
+
+	Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+	close(stop)
+	Eventually(done, time.Second).Should(Receive(BeNil()))
+}
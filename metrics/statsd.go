@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxPacketSize is the maximum size of a statsd packet. This fits
+// into the MTU of common networks.
+const maxPacketSize = 1432
+
+// statsdPusher pushes metrics to a statsd server over UDP. All
+// samples are sent as gauges since counters of statsd are deltas.
+// Labels are sent as tags in the DogStatsD format.
+type statsdPusher struct {
+	addr string
+}
+
+func newStatsdPusher(addr string) *statsdPusher {
+	return &statsdPusher{addr: addr}
+}
+
+func (p *statsdPusher) push(samples []sample) error {
+	conn, err := net.Dial("udp", p.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to statsd: %v", err)
+	}
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	for _, s := range samples {
+		line := formatStatsd(s)
+		if buf.Len() > 0 && buf.Len()+len(line)+1 > maxPacketSize {
+			_, err := conn.Write(buf.Bytes())
+			if err != nil {
+				return fmt.Errorf("failed to send metrics to statsd: %v", err)
+			}
+			buf.Reset()
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+
+	if buf.Len() > 0 {
+		_, err := conn.Write(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to send metrics to statsd: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// formatStatsd returns a line of statsd protocol for specified sample.
+func formatStatsd(s sample) string {
+	line := fmt.Sprintf("%s:%s|g", s.name, strconv.FormatFloat(s.value, 'f', -1, 64))
+	if len(s.labels) == 0 {
+		return line
+	}
+
+	tags := []string{}
+	for _, l := range s.labels {
+		tags = append(tags, fmt.Sprintf("%s:%s", l.name, l.value))
+	}
+
+	return fmt.Sprintf("%s|#%s", line, strings.Join(tags, ","))
+}
@@ -0,0 +1,93 @@
+package reconciler
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+// parseAllowedFields parses the field paths that the handler is
+// allowed to modify.
+func parseAllowedFields(fields []string) ([][]string, error) {
+	allowed := [][]string{}
+	for _, f := range fields {
+		p, err := config.ParseFieldPath(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed field: %v", err)
+		}
+		allowed = append(allowed, p)
+	}
+
+	return allowed, nil
+}
+
+// restrictObject reverts the changes of the new object outside the
+// allowed fields to the current object. It returns the field paths of
+// the reverted changes. If no allowed fields are specified, all
+// changes are kept.
+func restrictObject(obj, newObj *unstructured.Unstructured, allowed [][]string) []string {
+	if len(allowed) == 0 || obj == nil || newObj == nil {
+		return nil
+	}
+
+	restricted := obj.DeepCopy()
+	for _, f := range allowed {
+		v, ok, err := unstructured.NestedFieldCopy(newObj.Object, f...)
+		if err != nil {
+			continue
+		}
+
+		if ok {
+			err = unstructured.SetNestedField(restricted.Object, v, f...)
+			if err != nil {
+				continue
+			}
+		} else {
+			unstructured.RemoveNestedField(restricted.Object, f...)
+		}
+	}
+
+	if reflect.DeepEqual(restricted.Object, newObj.Object) {
+		return nil
+	}
+
+	dropped := changedPaths(restricted.Object, newObj.Object, "")
+	newObj.Object = restricted.Object
+
+	return dropped
+}
+
+// changedPaths returns the field paths that differ between a and b.
+func changedPaths(a, b map[string]interface{}, prefix string) []string {
+	keys := map[string]struct{}{}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+
+	paths := []string{}
+	for k := range keys {
+		if reflect.DeepEqual(a[k], b[k]) {
+			continue
+		}
+
+		p := prefix + "." + k
+		am, aok := a[k].(map[string]interface{})
+		bm, bok := b[k].(map[string]interface{})
+		if aok && bok {
+			paths = append(paths, changedPaths(am, bm, p)...)
+			continue
+		}
+
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
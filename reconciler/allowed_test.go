@@ -0,0 +1,59 @@
+package reconciler
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	. "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRestrictObject(t *testing.T) {
+	RegisterTestingT(t)
+
+	allowed, err := parseAllowedFields([]string{".status", ".metadata.labels"})
+	Expect(err).NotTo(HaveOccurred())
+
+	obj := &Unstructured{}
+	obj.SetNamespace("default")
+	obj.SetName("test")
+	SetNestedField(obj.Object, int64(1), "spec", "replicas")
+	SetNestedField(obj.Object, "old", "metadata", "annotations", "note")
+
+	// Changes within allowed fields
+	newObj := obj.DeepCopy()
+	SetNestedField(newObj.Object, "ready", "status", "phase")
+	newObj.SetLabels(map[string]string{"foo": "bar"})
+	expected := newObj.DeepCopy()
+
+	dropped := restrictObject(obj, newObj, allowed)
+	Expect(dropped).To(BeEmpty())
+	Expect(newObj).To(Equal(expected))
+
+	// Changes outside allowed fields
+	SetNestedField(newObj.Object, int64(3), "spec", "replicas")
+	RemoveNestedField(newObj.Object, "metadata", "annotations")
+
+	dropped = restrictObject(obj, newObj, allowed)
+	Expect(dropped).To(Equal([]string{".metadata.annotations", ".spec.replicas"}))
+	Expect(newObj).To(Equal(expected))
+
+	// Removal within allowed fields
+	newObj = obj.DeepCopy()
+	SetNestedField(obj.Object, "ready", "status", "phase")
+	dropped = restrictObject(obj, newObj, allowed)
+	Expect(dropped).To(BeEmpty())
+	_, found, _ := NestedFieldNoCopy(newObj.Object, "status")
+	Expect(found).To(BeFalse())
+
+	// No allowed fields
+	newObj = obj.DeepCopy()
+	SetNestedField(newObj.Object, int64(5), "spec", "replicas")
+	dropped = restrictObject(obj, newObj, nil)
+	Expect(dropped).To(BeEmpty())
+	replicas, _, _ := NestedInt64(newObj.Object, "spec", "replicas")
+	Expect(replicas).To(Equal(int64(5)))
+
+	// Invalid allowed field
+	_, err = parseAllowedFields([]string{"status"})
+	Expect(err).To(HaveOccurred())
+}
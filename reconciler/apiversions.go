@@ -0,0 +1,99 @@
+package reconciler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// apiVersionsTTL is the period for caching the API versions.
+const apiVersionsTTL = 10 * time.Minute
+
+// apiVersionCache is a cache of the preferred API versions of the
+// API server.
+type apiVersionCache struct {
+	client discovery.ServerGroupsInterface
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	versions []string
+	expires  time.Time
+}
+
+func newAPIVersionCache(client discovery.ServerGroupsInterface, ttl time.Duration) *apiVersionCache {
+	return &apiVersionCache{
+		client: client,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// get returns the preferred version of each API group, such as
+// 'apps/v1' or 'v1'. The versions are discovered again when the cache
+// has expired.
+func (c *apiVersionCache) get() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.versions != nil && c.now().Before(c.expires) {
+		return c.versions, nil
+	}
+
+	groups, err := c.client.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover API versions: %v", err)
+	}
+
+	versions := []string{}
+	for _, g := range groups.Groups {
+		if g.PreferredVersion.GroupVersion != "" {
+			versions = append(versions, g.PreferredVersion.GroupVersion)
+		}
+	}
+	sort.Strings(versions)
+
+	c.versions = versions
+	c.expires = c.now().Add(c.ttl)
+
+	return versions, nil
+}
+
+// InjectConfig implements inject.Config interface. The discovery
+// client is created only if includeAPIVersions is enabled.
+func (r *Reconciler) InjectConfig(cfg *rest.Config) error {
+	if r.config.Reconciler == nil || !r.config.Reconciler.IncludeAPIVersions {
+		return nil
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("could not create discovery client: %v", err)
+	}
+	r.apiVersions = newAPIVersionCache(dc, apiVersionsTTL)
+
+	return nil
+}
+
+// getAPIVersions returns the preferred API versions of the API server
+// if includeAPIVersions is enabled.
+func (r *Reconciler) getAPIVersions() ([]string, error) {
+	if r.apiVersions == nil {
+		return nil, nil
+	}
+
+	versions, err := r.apiVersions.get()
+	if err != nil {
+		return nil, err
+	}
+
+	// The handler may modify the versions in the state.
+	copied := make([]string, len(versions))
+	copy(copied, versions)
+
+	return copied, nil
+}
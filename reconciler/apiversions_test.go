@@ -0,0 +1,97 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithAPIVersions(t *testing.T) {
+	RegisterTestingT(t)
+
+	var input []string
+
+	dc := &testDiscoveryClient{
+		groups: []metav1.APIGroup{
+			{
+				Name:             "apps",
+				PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+			},
+			{
+				Name:             "",
+				PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"},
+			},
+		},
+	}
+
+	rc := newResourceConfig()
+	r := newTestReconciler(rc, newClient(), &testHandler{
+		Func: func(s *state.State) error {
+			input = s.APIVersions
+			return nil
+		},
+	}, nil)
+	r.apiVersions = newAPIVersionCache(dc, time.Minute)
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	_, err := r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(input).To(Equal([]string{"apps/v1", "v1"}))
+
+	// Disabled
+	r.apiVersions = nil
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(input).To(BeNil())
+}
+
+func TestAPIVersionCache(t *testing.T) {
+	RegisterTestingT(t)
+
+	dc := &testDiscoveryClient{
+		groups: []metav1.APIGroup{
+			{
+				Name:             "apps",
+				PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+			},
+		},
+	}
+
+	c := newAPIVersionCache(dc, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	versions, err := c.get()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(versions).To(Equal([]string{"apps/v1"}))
+	Expect(dc.calls).To(Equal(1))
+
+	// Within the TTL
+	_, err = c.get()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(dc.calls).To(Equal(1))
+
+	// Expired
+	now = now.Add(time.Minute)
+	_, err = c.get()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(dc.calls).To(Equal(2))
+}
+
+// testDiscoveryClient returns the API groups and counts the calls.
+type testDiscoveryClient struct {
+	groups []metav1.APIGroup
+	calls  int
+}
+
+func (c *testDiscoveryClient) ServerGroups() (*metav1.APIGroupList, error) {
+	c.calls++
+	return &metav1.APIGroupList{Groups: c.groups}, nil
+}
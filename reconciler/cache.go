@@ -0,0 +1,87 @@
+package reconciler
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// referenceCache is a short-lived cache of resolved reference resources.
+type referenceCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[referenceKey]*referenceEntry
+}
+
+type referenceKey struct {
+	gvk schema.GroupVersionKind
+	nn  types.NamespacedName
+}
+
+type referenceEntry struct {
+	object  *unstructured.Unstructured
+	expires time.Time
+}
+
+func newReferenceCache(ttl time.Duration) *referenceCache {
+	return &referenceCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[referenceKey]*referenceEntry{},
+	}
+}
+
+// get returns a copy of the cached resource. If the resource is not
+// cached or has expired, it returns nil.
+func (c *referenceCache) get(gvk schema.GroupVersionKind, nn types.NamespacedName) *unstructured.Unstructured {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := referenceKey{gvk: gvk, nn: nn}
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+
+	return e.object.DeepCopy()
+}
+
+// set stores a copy of specified resource to the cache.
+func (c *referenceCache) set(obj *unstructured.Unstructured) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := referenceKey{
+		gvk: obj.GroupVersionKind(),
+		nn:  types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()},
+	}
+	c.entries[key] = &referenceEntry{
+		object:  obj.DeepCopy(),
+		expires: c.now().Add(c.ttl),
+	}
+}
+
+// invalidate removes the cached resource unless it has the same
+// resource version as specified resource.
+func (c *referenceCache) invalidate(obj *unstructured.Unstructured) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := referenceKey{
+		gvk: obj.GroupVersionKind(),
+		nn:  types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()},
+	}
+	e, ok := c.entries[key]
+	if ok && e.object.GetResourceVersion() != obj.GetResourceVersion() {
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,95 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestGetReferencesWithCache(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	ref := &unstructured.Unstructured{}
+	ref.SetGroupVersionKind(gvk)
+	ref.SetNamespace("default")
+	ref.SetName("test-ref")
+	ref.SetResourceVersion("1")
+
+	c := &testCountingClient{object: ref}
+	r := &Reconciler{
+		Client: c,
+		config: &config.ResourceConfig{
+			References: []config.ReferenceConfig{
+				{GroupVersionKind: gvk, NameFieldPath: ".spec.configMapRef"},
+			},
+		},
+		refCache: newReferenceCache(time.Minute),
+	}
+
+	now := time.Now()
+	r.refCache.now = func() time.Time { return now }
+
+	res := &unstructured.Unstructured{}
+	res.SetNamespace("default")
+	res.SetName("test")
+	unstructured.SetNestedField(res.Object, "test-ref", "spec", "configMapRef")
+
+	key := state.ResourceKey(gvk)
+
+	refs, err := r.getReferences(context.TODO(), res)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(refs[key]).To(HaveLen(1))
+	Expect(c.gets).To(Equal(1))
+
+	// Within the TTL
+	refs, err = r.getReferences(context.TODO(), res)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(refs[key]).To(Equal([]*unstructured.Unstructured{ref}))
+	Expect(c.gets).To(Equal(1))
+
+	// Not changed
+	r.InvalidateReference(ref)
+	_, err = r.getReferences(context.TODO(), res)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.gets).To(Equal(1))
+
+	// Changed
+	updated := ref.DeepCopy()
+	updated.SetResourceVersion("2")
+	r.InvalidateReference(updated)
+	_, err = r.getReferences(context.TODO(), res)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.gets).To(Equal(2))
+
+	// Expired
+	now = now.Add(time.Minute)
+	_, err = r.getReferences(context.TODO(), res)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.gets).To(Equal(3))
+}
+
+// testCountingClient is a client that counts the number of Get calls.
+type testCountingClient struct {
+	client.Client
+	object *unstructured.Unstructured
+	gets   int
+}
+
+func (c *testCountingClient) Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+	c.gets++
+	c.object.DeepCopyInto(obj.(*unstructured.Unstructured))
+	return nil
+}
@@ -0,0 +1,69 @@
+// Package cel evaluates the Common Expression Language expressions over
+// the objects with cel-go.
+package cel
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Program represents a compiled expression.
+type Program struct {
+	program cel.Program
+}
+
+// Compile compiles specified expression. The expression can refer to
+// specified variables only, whose values are of any type.
+func Compile(expr string, vars ...string) (*Program, error) {
+	declared := []*exprpb.Decl{}
+	for _, v := range vars {
+		declared = append(declared, decls.NewVar(v, decls.Dyn))
+	}
+
+	env, err := cel.NewEnv(cel.Declarations(declared...))
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{program: prg}, nil
+}
+
+// Eval evaluates the expression with specified variables.
+func (p *Program) Eval(vars map[string]interface{}) (interface{}, error) {
+	v, _, err := p.program.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Value(), nil
+}
+
+// EvalBool evaluates the expression with specified variables. It
+// returns an error if the result is not a bool.
+func (p *Program) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, _, err := p.program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression must evaluate to bool, got %s", v.Type().TypeName())
+	}
+
+	return bool(b), nil
+}
@@ -0,0 +1,199 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+const testObject = `{
+  "metadata": {"name": "test", "labels": {"app": "web"}},
+  "spec": {
+    "replicas": 3,
+    "ratio": 0.5,
+    "containers": [{"name": "app", "image": "nginx:1.17"}, {"name": "sidecar", "image": "envoy"}]
+  }
+}`
+
+func TestCompile(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, err := Compile("object.spec.replicas > 0", "object")
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = Compile("object.spec.containers.exists(c, c.name == 'app')", "object")
+	Expect(err).NotTo(HaveOccurred())
+
+	// Syntax error
+	_, err = Compile("object.spec.replicas >", "object")
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("Syntax error"))
+
+	// Trailing token
+	_, err = Compile("object.spec.replicas 0", "object")
+	Expect(err).To(HaveOccurred())
+
+	// Undeclared variable
+	_, err = Compile("obj.spec.replicas > 0", "object")
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring(`undeclared reference to 'obj'`))
+
+	// Macro variable out of scope
+	_, err = Compile("object.spec.containers.all(c, true) && c.name == 'app'", "object")
+	Expect(err).To(HaveOccurred())
+
+	// Undeclared function
+	_, err = Compile("len(object.spec.containers) > 0", "object")
+	Expect(err).To(HaveOccurred())
+
+	// Undeclared method
+	_, err = Compile("object.metadata.name.lower() == 'test'", "object")
+	Expect(err).To(HaveOccurred())
+
+	// Invalid has() argument
+	_, err = Compile("has(object)", "object")
+	Expect(err).To(HaveOccurred())
+
+	// Unterminated string
+	_, err = Compile("object.metadata.name == 'test", "object")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestEvalBool(t *testing.T) {
+	RegisterTestingT(t)
+
+	vars := map[string]interface{}{"object": decode(testObject)}
+
+	tests := map[string]bool{
+		"object.spec.replicas > 0":                                          true,
+		"object.spec.replicas == 3":                                         true,
+		"object.spec.replicas % 2 == 1 && object.spec.replicas / 2 == 1":    true,
+		"object.spec.ratio * 2.0 == 1.0":                                    true,
+		"-object.spec.replicas < 0":                                         true,
+		"!(object.spec.replicas >= 3)":                                      false,
+		"object.metadata.name == 'test'":                                    true,
+		"object.metadata.name + \"-x\" == 'test-x'":                         true,
+		"object.metadata.labels['app'] in ['web', 'api']":                   true,
+		"'app' in object.metadata.labels":                                   true,
+		"has(object.spec.paused)":                                           false,
+		"has(object.spec.paused) && object.spec.paused":                     false,
+		"!has(object.spec.paused) || object.spec.paused":                    true,
+		"object.spec.paused && false":                                       false,
+		"size(object.spec.containers) == 2":                                 true,
+		"object.spec.containers.size() == 2":                                true,
+		"object.spec.containers[0].image.startsWith('nginx')":               true,
+		"object.spec.containers[1].image.endsWith('voy')":                   true,
+		"object.metadata.name.contains('es')":                               true,
+		"object.spec.containers[0].image.matches('^nginx:[0-9.]+$')":        true,
+		"object.spec.containers.all(c, c.image != '')":                      true,
+		"object.spec.containers.exists(c, c.name == 'db')":                  false,
+		"object.spec.containers.exists_one(c, c.name.startsWith('s'))":      true,
+		"object.metadata.labels.exists(k, k == 'app')":                      true,
+		"object.spec.replicas > 1 ? object.metadata.name == 'test' : false": true,
+		"int('3') == object.spec.replicas":                                  true,
+		"double(object.spec.replicas) == 3.0":                               true,
+		"string(object.spec.replicas) == '3'":                               true,
+		"[1, 2] + [3] == [1, 2, 3]":                                         true,
+		"{'a': 1}.a == 1":                                                   true,
+		"1e1 == 10.0":                                                       true,
+		"'\\'quoted\\'' == \"'quoted'\"":                                    true,
+	}
+
+	for expr, expected := range tests {
+		p, err := Compile(expr, "object")
+		Expect(err).NotTo(HaveOccurred(), expr)
+
+		result, err := p.EvalBool(vars)
+		Expect(err).NotTo(HaveOccurred(), expr)
+		Expect(result).To(Equal(expected), expr)
+	}
+}
+
+func TestEvalBoolWithError(t *testing.T) {
+	RegisterTestingT(t)
+
+	vars := map[string]interface{}{"object": decode(testObject)}
+
+	tests := map[string]string{
+		"object.spec.paused":                                   "no such key: paused",
+		"object.spec.replicas":                                 "must evaluate to bool",
+		"object.spec.replicas > 'a'":                           "no such overload",
+		"object.spec.replicas + 0.5 > 0.0":                     "no such overload",
+		"object.spec.replicas == 3.0":                          "no such overload",
+		"object.spec.replicas / 0 == 0":                        "divide by zero",
+		"object.spec.containers[2].name == ''":                 "index out of bounds",
+		"object.metadata.name.size() > object.spec.containers": "no such overload",
+	}
+
+	for expr, msg := range tests {
+		p, err := Compile(expr, "object")
+		Expect(err).NotTo(HaveOccurred(), expr)
+
+		_, err = p.EvalBool(vars)
+		Expect(err).To(HaveOccurred(), expr)
+		Expect(err.Error()).To(ContainSubstring(msg), expr)
+	}
+}
+
+func TestEvalBoolConformance(t *testing.T) {
+	RegisterTestingT(t)
+
+	vars := map[string]interface{}{"object": decode(testObject)}
+
+	tests := map[string]bool{
+		"-9223372036854775808 < 0":         true,
+		"9223372036854775807 > 0":          true,
+		"0x10 == 16":                       true,
+		"1u + 2u == 3u":                    true,
+		"uint(object.spec.replicas) == 3u": true,
+		"b'abc' == bytes('abc')":           true,
+		"size(b'abc') == 3":                true,
+		"object.spec.containers.map(c, c.name) == ['app', 'sidecar']":                  true,
+		"object.spec.containers.filter(c, c.name == 'app').size() == 1":                true,
+		"object.spec.containers.map(c, c.name.startsWith('s'), c.name) == ['sidecar']": true,
+		"[1, 2, 3].all(i, i > 0) && [1, 2, 3].exists(i, i == 2)":                       true,
+		"type(object.spec.replicas) == int":                                            true,
+		"timestamp('2020-01-01T00:00:00Z') < timestamp('2020-01-02T00:00:00Z')":        true,
+		"duration('1m') == duration('60s')":                                            true,
+	}
+
+	for expr, expected := range tests {
+		p, err := Compile(expr, "object")
+		Expect(err).NotTo(HaveOccurred(), expr)
+
+		result, err := p.EvalBool(vars)
+		Expect(err).NotTo(HaveOccurred(), expr)
+		Expect(result).To(Equal(expected), expr)
+	}
+
+	// Arithmetic overflow and out of range conversion are errors
+	errors := map[string]string{
+		"9223372036854775807 + 1 > 0":    "overflow",
+		"-9223372036854775808 - 1 < 0":   "overflow",
+		"9223372036854775807 * 2 > 0":    "overflow",
+		"0u - 1u > 0u":                   "overflow",
+		"int(18446744073709551615u) > 0": "range error",
+	}
+
+	for expr, msg := range errors {
+		p, err := Compile(expr, "object")
+		if err != nil {
+			Expect(err.Error()).To(ContainSubstring(msg), expr)
+			continue
+		}
+
+		_, err = p.EvalBool(vars)
+		Expect(err).To(HaveOccurred(), expr)
+		Expect(err.Error()).To(ContainSubstring(msg), expr)
+	}
+}
+
+func decode(s string) map[string]interface{} {
+	v := map[string]interface{}{}
+	err := json.Unmarshal([]byte(s), &v)
+	Expect(err).NotTo(HaveOccurred())
+
+	return v
+}
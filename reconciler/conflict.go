@@ -0,0 +1,105 @@
+package reconciler
+
+import (
+	"context"
+	"reflect"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// updateObject updates specified object of the reconciler, which is
+// the original object changed by the handler. If the update conflicts,
+// the changes of the handler are re-applied onto the latest object and
+// retried up to the configured number of times. The update is not
+// retried if the object has been changed by others except its status,
+// so that their changes are not overwritten.
+func (r *Reconciler) updateObject(ctx context.Context, original, obj *unstructured.Unstructured) error {
+	nn := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+
+	for i := 0; ; i++ {
+		err := r.update(ctx, obj)
+		if err == nil || !apierrors.IsConflict(err) || i >= r.conflictRetries {
+			return err
+		}
+
+		latest := &unstructured.Unstructured{}
+		latest.SetGroupVersionKind(obj.GroupVersionKind())
+
+		getErr := r.Get(ctx, nn, latest)
+		if getErr != nil {
+			log.Error(getErr, "Failed to get a resource", "namespace", nn.Namespace, "name", nn.Name)
+			return err
+		}
+
+		if !equalExceptStatus(original, latest) {
+			return err
+		}
+
+		rebased, rebaseErr := rebaseChanges(original, obj, latest)
+		if rebaseErr != nil {
+			log.Error(rebaseErr, "Failed to re-apply changes", "namespace", nn.Namespace, "name", nn.Name)
+			return err
+		}
+
+		log.Info("Retrying update due to conflict", "namespace", nn.Namespace, "name", nn.Name, "retries", i+1)
+		original = latest
+		obj.Object = rebased.Object
+	}
+}
+
+// equalExceptStatus returns true if specified objects are equal except
+// their status and the fields maintained by the API server on updates.
+func equalExceptStatus(a, b *unstructured.Unstructured) bool {
+	strip := func(obj *unstructured.Unstructured) map[string]interface{} {
+		c := obj.DeepCopy()
+		unstructured.RemoveNestedField(c.Object, "status")
+		unstructured.RemoveNestedField(c.Object, "metadata", "resourceVersion")
+		unstructured.RemoveNestedField(c.Object, "metadata", "managedFields")
+		return c.Object
+	}
+
+	return reflect.DeepEqual(strip(a), strip(b))
+}
+
+// rebaseChanges returns the latest object with the changes from the
+// original object to obj applied.
+func rebaseChanges(original, obj, latest *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	originalJSON, err := original.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	objJSON, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	latestJSON, err := latest.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := jsonpatch.CreateMergePatch(originalJSON, objJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := jsonpatch.MergePatch(latestJSON, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	rebased := &unstructured.Unstructured{}
+	err = rebased.UnmarshalJSON(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The resource version of the latest object is always used.
+	rebased.SetResourceVersion(latest.GetResourceVersion())
+
+	return rebased, nil
+}
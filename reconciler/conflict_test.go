@@ -0,0 +1,143 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithConflictRetries(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{
+			Group:   "example.com",
+			Version: "v1alpha1",
+			Kind:    "Test",
+		},
+		Reconciler: &config.ReconcilerConfig{},
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+	object.SetGeneration(1)
+	object.SetResourceVersion("1")
+
+	latest := object.DeepCopy()
+	latest.SetResourceVersion("2")
+
+	newReconciler := func(c client.Client, retries int) *Reconciler {
+		return &Reconciler{
+			Client: c,
+			config: rc,
+			handler: &testHandler{
+				Func: func(s *state.State) error {
+					return unstructured.SetNestedField(s.Object.Object, "ok", "status", "phase")
+				},
+			},
+			recorder:        record.NewFakeRecorder(32),
+			conflictRetries: retries,
+		}
+	}
+
+	// The update succeeds on retry with the latest resource version
+	c := &testConflictClient{conflicts: 1, latest: latest}
+	r := newReconciler(c, 3)
+
+	_, err := r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.updates).To(Equal(2))
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].GetResourceVersion()).To(Equal("2"))
+	Expect(c.updated[0].Object["status"]).To(Equal(map[string]interface{}{"phase": "ok"}))
+
+	// The conflicts exceed the retries
+	c = &testConflictClient{conflicts: 4, latest: latest}
+	r = newReconciler(c, 3)
+
+	_, err = r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).To(HaveOccurred())
+	Expect(errorReason(err)).To(Equal(ReasonApplyConflict))
+	Expect(c.updates).To(Equal(4))
+	Expect(c.updated).To(BeEmpty())
+
+	// Retry is disabled
+	c = &testConflictClient{conflicts: 1, latest: latest}
+	r = newReconciler(c, 0)
+
+	_, err = r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).To(HaveOccurred())
+	Expect(c.updates).To(Equal(1))
+
+	// The spec has been changed
+	changed := latest.DeepCopy()
+	changed.SetGeneration(2)
+	c = &testConflictClient{conflicts: 1, latest: changed}
+	r = newReconciler(c, 3)
+
+	_, err = r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).To(HaveOccurred())
+	Expect(errorReason(err)).To(Equal(ReasonApplyConflict))
+	Expect(c.updates).To(Equal(1))
+
+	// The metadata has been changed without the generation
+	changed = latest.DeepCopy()
+	changed.SetLabels(map[string]string{"owner": "other"})
+	c = &testConflictClient{conflicts: 1, latest: changed}
+	r = newReconciler(c, 3)
+
+	_, err = r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).To(HaveOccurred())
+	Expect(errorReason(err)).To(Equal(ReasonApplyConflict))
+	Expect(c.updates).To(Equal(1))
+	Expect(c.updated).To(BeEmpty())
+
+	// The status has been changed by others
+	changed = latest.DeepCopy()
+	err = unstructured.SetNestedField(changed.Object, "other", "status", "message")
+	Expect(err).NotTo(HaveOccurred())
+	c = &testConflictClient{conflicts: 1, latest: changed}
+	r = newReconciler(c, 3)
+
+	_, err = r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.updates).To(Equal(2))
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].GetResourceVersion()).To(Equal("2"))
+	Expect(c.updated[0].Object["status"]).To(Equal(map[string]interface{}{"phase": "ok", "message": "other"}))
+}
+
+// testConflictClient returns conflict errors for the specified number
+// of updates before the updates succeed.
+type testConflictClient struct {
+	testTrackingClient
+	conflicts int
+	updates   int
+	latest    *unstructured.Unstructured
+}
+
+func (c *testConflictClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c.latest.DeepCopyInto(obj.(*unstructured.Unstructured))
+	return nil
+}
+
+func (c *testConflictClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.updates++
+	if c.conflicts > 0 {
+		c.conflicts--
+		return apierrors.NewConflict(schema.GroupResource{}, "test", errors.New("conflict"))
+	}
+
+	return c.testTrackingClient.Update(ctx, obj, opts...)
+}
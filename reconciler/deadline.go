@@ -0,0 +1,109 @@
+package reconciler
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// AnnotationDeadline is the annotation of the object that has the
+// time in RFC3339 after which the object is no longer reconciled.
+const AnnotationDeadline = "whitebox.summerwind.dev/deadline"
+
+// objectDeadline returns the deadline of specified object. It returns
+// zero time if the object does not have the deadline annotation.
+func objectDeadline(obj *unstructured.Unstructured) (time.Time, error) {
+	val, ok := obj.GetAnnotations()[AnnotationDeadline]
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	deadline, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline: %v", err)
+	}
+
+	return deadline, nil
+}
+
+// checkDeadline returns a reconcile error if the deadline of specified
+// object has passed. The object without the deadline never exceeds it.
+func (r *Reconciler) checkDeadline(obj *unstructured.Unstructured) error {
+	if !r.enforceDeadline {
+		return nil
+	}
+
+	deadline, err := objectDeadline(obj)
+	if err != nil {
+		r.objectLog(obj).Info("Ignored invalid deadline of the object", "error", err.Error())
+		r.recordWarningEvent(obj, "InvalidDeadline", fmt.Sprintf("Ignored invalid deadline: %v", err))
+		return nil
+	}
+
+	if deadline.IsZero() || time.Now().Before(deadline) {
+		return nil
+	}
+
+	return &reconcileError{
+		reason: ReasonDeadlineExceeded,
+		err:    fmt.Errorf("deadline exceeded at %s", deadline.Format(time.RFC3339)),
+	}
+}
+
+// handleDeadlineExceeded emits a warning event and stops reconciling
+// specified object whose deadline has passed. The event is rate limited
+// since the object is still reconciled on its updates and resyncs.
+func (r *Reconciler) handleDeadlineExceeded(res *unstructured.Unstructured, err error) reconcile.Result {
+	nn := types.NamespacedName{Namespace: res.GetNamespace(), Name: res.GetName()}
+
+	r.notifyTransition(res, err)
+
+	// The failure is recorded without retries so that the transition is
+	// notified only once while the deadline remains exceeded.
+	r.resetFailure(nn)
+	r.mu.Lock()
+	if r.failures == nil {
+		r.failures = map[types.NamespacedName]*failure{}
+	}
+	r.failures[nn] = &failure{resourceVersion: res.GetResourceVersion()}
+	r.mu.Unlock()
+
+	log.Info("Stopped reconciling a resource after its deadline", "namespace", nn.Namespace, "name", nn.Name)
+	r.recordWarningEvent(res, "DeadlineExceeded", err.Error())
+	r.setStatusError(res, err)
+
+	return reconcile.Result{}
+}
+
+// requeueAtDeadline returns specified result of the successful reconcile
+// that is requeued at the deadline of specified object, so that the
+// object is marked failed as soon as the deadline passes. The result
+// that is requeued earlier is returned as is.
+func (r *Reconciler) requeueAtDeadline(obj *unstructured.Unstructured, result reconcile.Result) reconcile.Result {
+	if !r.enforceDeadline || isDeleting(obj) {
+		return result
+	}
+
+	deadline, err := objectDeadline(obj)
+	if err != nil || deadline.IsZero() {
+		return result
+	}
+
+	if result.Requeue && result.RequeueAfter == 0 {
+		return result
+	}
+
+	d := time.Until(deadline)
+	if d <= 0 {
+		return reconcile.Result{Requeue: true}
+	}
+
+	if result.RequeueAfter == 0 || d < result.RequeueAfter {
+		result.RequeueAfter = d
+	}
+
+	return result
+}
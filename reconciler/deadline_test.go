@@ -0,0 +1,187 @@
+package reconciler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithDeadline(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+
+	called := false
+	rec := record.NewFakeRecorder(32)
+	r := &Reconciler{
+		Client: &testTrackingClient{},
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				called = true
+				return nil
+			},
+		},
+		recorder:        rec,
+		enforceDeadline: true,
+	}
+
+	newDeadlineObject := func(deadline string) *unstructured.Unstructured {
+		obj := newObject(rc.GroupVersionKind, "test")
+		obj.SetAnnotations(map[string]string{AnnotationDeadline: deadline})
+		return obj
+	}
+
+	// Before the deadline
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	_, err := r.reconcile(context.TODO(), newDeadlineObject(future), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeTrue())
+
+	// After the deadline
+	called = false
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	_, err = r.reconcile(context.TODO(), newDeadlineObject(past), "update")
+	Expect(err).To(HaveOccurred())
+	Expect(errorReason(err)).To(Equal(ReasonDeadlineExceeded))
+	Expect(called).To(BeFalse())
+
+	// Object without deadline
+	_, err = r.reconcile(context.TODO(), newObject(rc.GroupVersionKind, "test"), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeTrue())
+
+	// Invalid deadline is ignored
+	called = false
+	_, err = r.reconcile(context.TODO(), newDeadlineObject("tomorrow"), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeTrue())
+	Expect(<-rec.Events).To(ContainSubstring("InvalidDeadline"))
+
+	// Repeated invalid deadline does not emit the event again
+	_, err = r.reconcile(context.TODO(), newDeadlineObject("tomorrow"), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(rec.Events).NotTo(Receive())
+
+	// Deleting object is finalized after the deadline
+	called = false
+	r.skipDeletion = false
+	deleting := newDeadlineObject(past)
+	unstructured.SetNestedField(deleting.Object, time.Now().UTC().Format(time.RFC3339), "metadata", "deletionTimestamp")
+	_, err = r.reconcile(context.TODO(), deleting, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeTrue())
+
+	// Deadline is not enforced
+	called = false
+	r.enforceDeadline = false
+	_, err = r.reconcile(context.TODO(), newDeadlineObject(past), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeTrue())
+}
+
+func TestHandleDeadlineExceeded(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+	object.SetAnnotations(map[string]string{
+		AnnotationDeadline: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+	})
+
+	var (
+		mu       sync.Mutex
+		notified []*state.State
+	)
+	notifications := func() []*state.State {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*state.State{}, notified...)
+	}
+
+	rc.Reconciler.StatusErrorField = ".status.error"
+	rc.Reconciler.EnforceDeadline = true
+	rc.Reconciler.Notify = &config.HandlerConfig{
+		StateHandler: &testHandler{
+			Func: func(s *state.State) error {
+				mu.Lock()
+				defer mu.Unlock()
+				notified = append(notified, s)
+				return nil
+			},
+		},
+	}
+
+	c := &testTrackingClient{}
+	rec := record.NewFakeRecorder(32)
+	r := newTestReconciler(rc, c, &testHandler{}, rec)
+
+	err := r.checkDeadline(object)
+	Expect(err).To(HaveOccurred())
+
+	result := r.handleDeadlineExceeded(object, err)
+	Expect(result).To(Equal(reconcile.Result{}))
+	Expect(<-rec.Events).To(ContainSubstring("DeadlineExceeded"))
+
+	// The failure is written to the status
+	Expect(c.updated).To(HaveLen(1))
+	msg, _, _ := unstructured.NestedString(c.updated[0].Object, "status", "error")
+	Expect(msg).To(ContainSubstring("deadline exceeded"))
+
+	// The failure is notified
+	Eventually(notifications).Should(HaveLen(1))
+	Expect(notifications()[0].Transition.To).To(Equal(ResultError))
+
+	// Repeated reconcile does not emit the event nor notify again
+	r.handleDeadlineExceeded(object, err)
+	Expect(rec.Events).NotTo(Receive())
+	Consistently(notifications, 100*time.Millisecond).Should(HaveLen(1))
+}
+
+func TestRequeueAtDeadline(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	r := &Reconciler{config: rc, enforceDeadline: true}
+
+	newDeadlineObject := func(deadline time.Time) *unstructured.Unstructured {
+		obj := newObject(rc.GroupVersionKind, "test")
+		obj.SetAnnotations(map[string]string{AnnotationDeadline: deadline.UTC().Format(time.RFC3339)})
+		return obj
+	}
+
+	// Requeued at the deadline
+	result := r.requeueAtDeadline(newDeadlineObject(time.Now().Add(time.Hour)), reconcile.Result{})
+	Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+
+	// Earlier requeue is kept
+	result = r.requeueAtDeadline(newDeadlineObject(time.Now().Add(time.Hour)), reconcile.Result{RequeueAfter: 30 * time.Second})
+	Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+
+	// Later requeue is replaced
+	result = r.requeueAtDeadline(newDeadlineObject(time.Now().Add(time.Hour)), reconcile.Result{RequeueAfter: 2 * time.Hour})
+	Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+
+	// Passed deadline
+	result = r.requeueAtDeadline(newDeadlineObject(time.Now().Add(-time.Hour)), reconcile.Result{})
+	Expect(result).To(Equal(reconcile.Result{Requeue: true}))
+
+	// Object without deadline
+	result = r.requeueAtDeadline(newObject(rc.GroupVersionKind, "test"), reconcile.Result{})
+	Expect(result).To(Equal(reconcile.Result{}))
+
+	// Deadline is not enforced
+	r.enforceDeadline = false
+	result = r.requeueAtDeadline(newDeadlineObject(time.Now().Add(time.Hour)), reconcile.Result{})
+	Expect(result).To(Equal(reconcile.Result{}))
+}
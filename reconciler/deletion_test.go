@@ -0,0 +1,124 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileDeletionWithoutFinalizer(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"}
+
+	called := 0
+	h := &testHandler{
+		Func: func(s *state.State) error {
+			called++
+			return nil
+		},
+	}
+
+	rc := &config.ResourceConfig{GroupVersionKind: gvk}
+	r := newTestReconciler(rc, &testTrackingClient{}, h, nil)
+	Expect(r.skipDeletion).To(BeTrue())
+
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	object.SetNamespace("default")
+	object.SetName("test")
+	object.SetFinalizers([]string{"example.com/other"})
+	unstructured.SetNestedField(object.Object, time.Now().UTC().Format(time.RFC3339), "metadata", "deletionTimestamp")
+
+	// Skipped
+	_, err := r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(Equal(0))
+
+	// Not skipped
+	r.skipDeletion = false
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(Equal(1))
+}
+
+func TestFinalizeWithDeletionContext(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	var (
+		finalizerErr error
+		input        []byte
+		trigger      string
+	)
+
+	rc.Finalizer = &config.HandlerConfig{
+		StateHandler: &testHandler{
+			Func: func(s *state.State) error {
+				var err error
+				input, err = json.Marshal(s)
+				Expect(err).NotTo(HaveOccurred())
+				trigger = s.Trigger
+				return finalizerErr
+			},
+		},
+	}
+
+	c := &testTrackingClient{}
+	r := newTestReconciler(rc, c, &testHandler{
+		Func: func(s *state.State) error {
+			return errors.New("reconciler must not be called")
+		},
+	}, nil)
+
+	deletedAt := time.Now().UTC().Format(time.RFC3339)
+	object := newObject(rc.GroupVersionKind, "test")
+	object.SetFinalizers([]string{r.getFinalizerName()})
+	unstructured.SetNestedField(object.Object, deletedAt, "metadata", "deletionTimestamp")
+
+	c.objects = []*unstructured.Unstructured{object}
+
+	nn := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	req := reconcile.Request{NamespacedName: nn}
+
+	// Failed finalizer receives the deletion context
+	r.SetTrigger(nn, TriggerUpdate)
+	finalizerErr = errors.New("finalizer failed")
+	_, err := r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Expect(trigger).To(Equal(TriggerDelete))
+
+	in := map[string]interface{}{}
+	err = json.Unmarshal(input, &in)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(in["trigger"]).To(Equal(TriggerDelete))
+	ts, _, _ := unstructured.NestedString(in, "object", "metadata", "deletionTimestamp")
+	Expect(ts).To(Equal(deletedAt))
+
+	// The finalizer is kept on failure
+	Expect(c.updated).To(BeEmpty())
+
+	// The finalizer is removed on success
+	r.SetTrigger(nn, TriggerSync)
+	finalizerErr = nil
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(trigger).To(Equal(TriggerDelete))
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].GetFinalizers()).To(BeEmpty())
+}
@@ -0,0 +1,92 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithDeletedDependent(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"}
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	object.SetNamespace("default")
+	object.SetName("test")
+	object.SetUID(types.UID("test-uid"))
+
+	// The dependent has been deleted, so the list is empty.
+	c := &testDependentClient{object: object}
+
+	h := &testHandler{
+		Func: func(s *state.State) error {
+			key := state.ResourceKey(podGVK)
+			if len(s.Dependents[key]) > 0 {
+				return nil
+			}
+
+			pod := &unstructured.Unstructured{}
+			pod.SetGroupVersionKind(podGVK)
+			pod.SetNamespace("default")
+			pod.SetName("test")
+			s.Dependents[key] = []*unstructured.Unstructured{pod}
+			return nil
+		},
+	}
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: gvk,
+		Dependents: []config.DependentConfig{
+			{GroupVersionKind: podGVK},
+		},
+	}
+	r := newTestReconciler(rc, c, h, nil)
+
+	nn := types.NamespacedName{Namespace: "default", Name: "test"}
+	r.SetTrigger(nn, TriggerDependent)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: nn})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.created).To(HaveLen(1))
+
+	pod := c.created[0]
+	Expect(pod.GetName()).To(Equal("test"))
+	Expect(pod.GetOwnerReferences()).To(HaveLen(1))
+	Expect(pod.GetOwnerReferences()[0].UID).To(Equal(object.GetUID()))
+}
+
+// testDependentClient is a client that has no dependent resources
+// and records the created resources.
+type testDependentClient struct {
+	client.Client
+	object  *unstructured.Unstructured
+	created []*unstructured.Unstructured
+}
+
+func (c *testDependentClient) Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+	c.object.DeepCopyInto(obj.(*unstructured.Unstructured))
+	return nil
+}
+
+func (c *testDependentClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	return nil
+}
+
+func (c *testDependentClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.created = append(c.created, obj.(*unstructured.Unstructured).DeepCopy())
+	return nil
+}
@@ -0,0 +1,38 @@
+package reconciler
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// create creates specified object. The request is a server-side dry-run
+// if it is enabled.
+func (r *Reconciler) create(ctx context.Context, obj runtime.Object) error {
+	if r.serverDryRun {
+		return r.Create(ctx, obj, client.DryRunAll)
+	}
+
+	return r.Create(ctx, obj)
+}
+
+// update updates specified object. The request is a server-side dry-run
+// if it is enabled.
+func (r *Reconciler) update(ctx context.Context, obj runtime.Object) error {
+	if r.serverDryRun {
+		return r.Update(ctx, obj, client.DryRunAll)
+	}
+
+	return r.Update(ctx, obj)
+}
+
+// delete deletes specified object. The request is a server-side dry-run
+// if it is enabled.
+func (r *Reconciler) delete(ctx context.Context, obj runtime.Object) error {
+	if r.serverDryRun {
+		return r.Delete(ctx, obj, client.DryRunAll)
+	}
+
+	return r.Delete(ctx, obj)
+}
@@ -0,0 +1,113 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithServerDryRun(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newTrackingResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+	c := &testDryRunClient{}
+
+	r := &Reconciler{
+		Client: c,
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				cm := &unstructured.Unstructured{}
+				cm.SetGroupVersionKind(configMapGVK)
+				cm.SetNamespace("other")
+				cm.SetName("test")
+
+				key := state.ResourceKey(configMapGVK)
+				s.Dependents[key] = []*unstructured.Unstructured{cm}
+				return nil
+			},
+		},
+		recorder:     record.NewFakeRecorder(32),
+		serverDryRun: true,
+	}
+
+	_, err := r.reconcile(context.TODO(), object, "create")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.created).To(HaveLen(1))
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.dryRun).To(Equal([][]string{
+		{metav1.DryRunAll},
+		{metav1.DryRunAll},
+	}))
+
+	// Deleting the tracked dependents
+	deleting := c.updated[0]
+	unstructured.SetNestedField(deleting.Object, time.Now().Format(time.RFC3339), "metadata", "deletionTimestamp")
+
+	tracked := c.created[0]
+	setTrackingLabels(deleting, tracked)
+
+	c = &testDryRunClient{}
+	c.objects = []*unstructured.Unstructured{tracked}
+	r.Client = c
+
+	_, err = r.reconcile(context.TODO(), deleting, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.deleted).To(HaveLen(1))
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.dryRun).To(Equal([][]string{
+		{metav1.DryRunAll},
+		{metav1.DryRunAll},
+	}))
+
+	// Disabled
+	c = &testDryRunClient{}
+	r.Client = c
+	r.serverDryRun = false
+
+	_, err = r.reconcile(context.TODO(), object, "create")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.created).To(HaveLen(1))
+	Expect(c.dryRun).To(Equal([][]string{nil, nil}))
+}
+
+// testDryRunClient records the dry-run option of the writes.
+type testDryRunClient struct {
+	testTrackingClient
+	dryRun [][]string
+}
+
+func (c *testDryRunClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	co := &client.CreateOptions{}
+	co.ApplyOptions(opts)
+	c.dryRun = append(c.dryRun, co.DryRun)
+
+	return c.testTrackingClient.Create(ctx, obj, opts...)
+}
+
+func (c *testDryRunClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	uo := &client.UpdateOptions{}
+	uo.ApplyOptions(opts)
+	c.dryRun = append(c.dryRun, uo.DryRun)
+
+	return c.testTrackingClient.Update(ctx, obj, opts...)
+}
+
+func (c *testDryRunClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	do := &client.DeleteOptions{}
+	do.ApplyOptions(opts)
+	c.dryRun = append(c.dryRun, do.DryRun)
+
+	return c.testTrackingClient.Delete(ctx, obj, opts...)
+}
@@ -0,0 +1,190 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+// newDynamicKinds returns the set of kinds allowed for the dynamic
+// objects. It returns nil if the dynamic objects are not allowed.
+func newDynamicKinds(c *config.ReconcilerConfig) map[schema.GroupVersionKind]struct{} {
+	if !c.AllowDynamicObjects {
+		return nil
+	}
+
+	kinds := map[schema.GroupVersionKind]struct{}{}
+	for _, gvk := range c.DynamicObjectKinds {
+		kinds[gvk] = struct{}{}
+	}
+
+	return kinds
+}
+
+// validateObjects validates the dynamic objects of specified new state.
+func (r *Reconciler) validateObjects(s, ns *state.State) error {
+	if len(ns.Objects) == 0 {
+		return nil
+	}
+
+	if r.dynamicKinds == nil {
+		return errors.New("objects: dynamic objects are not allowed")
+	}
+
+	for i, obj := range ns.Objects {
+		if obj == nil {
+			return fmt.Errorf("objects[%d]: object is empty", i)
+		}
+
+		gvk := obj.GroupVersionKind()
+		_, ok := r.dynamicKinds[gvk]
+		if !ok {
+			return fmt.Errorf("objects[%d]: group/version/kind is not allowed: %s", i, gvk)
+		}
+
+		if obj.GetName() == "" {
+			return fmt.Errorf("objects[%d]: name must be specified", i)
+		}
+
+		if !r.config.ClusterScoped && obj.GetNamespace() != "" && obj.GetNamespace() != s.Object.GetNamespace() {
+			return fmt.Errorf("objects[%d]: namespace does not match", i)
+		}
+	}
+
+	return nil
+}
+
+// applyObjects creates the dynamic objects of specified state, or
+// updates them if they exist and have been changed. The objects are
+// owned by the object of the state. The existing objects are merged
+// with the fields of the dynamic objects, and are not updated if they
+// are controlled by another owner.
+func (r *Reconciler) applyObjects(ctx context.Context, s *state.State) error {
+	for _, obj := range s.Objects {
+		var ownerRef *metav1.OwnerReference
+		if s.Object != nil {
+			if obj.GetNamespace() == "" && !r.config.ClusterScoped {
+				obj.SetNamespace(s.Object.GetNamespace())
+			}
+			ownerRef = newOwnerReference(s.Object, true)
+			obj.SetOwnerReferences(appendOwnerReference(obj.GetOwnerReferences(), ownerRef))
+		}
+
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(obj.GroupVersionKind())
+
+		err := r.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, current)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				log.Error(err, "Failed to get a resource", "namespace", obj.GetNamespace(), "name", obj.GetName())
+				return err
+			}
+
+			log.Info("Creating resource", "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
+
+			err = r.create(ctx, obj)
+			if err != nil {
+				log.Error(err, "Failed to create a resource", "namespace", obj.GetNamespace(), "name", obj.GetName())
+				return newApplyError(err)
+			}
+			continue
+		}
+
+		if ownerRef != nil {
+			controller := metav1.GetControllerOf(current)
+			if controller != nil && controller.UID != ownerRef.UID {
+				err = fmt.Errorf("%s %s/%s is controlled by another owner: %s %s", obj.GetKind(), obj.GetNamespace(), obj.GetName(), controller.Kind, controller.Name)
+				log.Error(err, "Refused to update a resource", "namespace", obj.GetNamespace(), "name", obj.GetName())
+				r.recorder.Event(s.Object, "Warning", "ObjectConflict", fmt.Sprintf("Refused to update %s", err))
+				return newApplyError(err)
+			}
+		}
+
+		merged, err := mergeObject(current, obj)
+		if err != nil {
+			log.Error(err, "Failed to merge a resource", "namespace", obj.GetNamespace(), "name", obj.GetName())
+			return newApplyError(err)
+		}
+
+		if reflect.DeepEqual(current.Object, merged.Object) {
+			continue
+		}
+
+		log.Info("Updating resource", "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
+
+		err = r.update(ctx, merged)
+		if err != nil {
+			log.Error(err, "Failed to update a resource", "namespace", obj.GetNamespace(), "name", obj.GetName())
+			return newApplyError(err)
+		}
+	}
+
+	return nil
+}
+
+// mergeObject returns the current object merged with the fields of
+// the desired object as a JSON merge patch, so that the fields set by
+// others, such as the API server, are kept. The owner references of
+// the desired object are added to the existing ones, and its status
+// is ignored.
+func mergeObject(current, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	patch := desired.DeepCopy()
+	unstructured.RemoveNestedField(patch.Object, "status")
+	unstructured.RemoveNestedField(patch.Object, "metadata", "ownerReferences")
+	unstructured.RemoveNestedField(patch.Object, "metadata", "resourceVersion")
+
+	currentJSON, err := current.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	patchJSON, err := patch.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := jsonpatch.MergePatch(currentJSON, patchJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &unstructured.Unstructured{}
+	err = merged.UnmarshalJSON(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := current.GetOwnerReferences()
+	desiredRefs := desired.GetOwnerReferences()
+	for i := range desiredRefs {
+		refs = appendOwnerReference(refs, &desiredRefs[i])
+	}
+	if len(refs) > 0 {
+		merged.SetOwnerReferences(refs)
+	}
+
+	return merged, nil
+}
+
+// appendOwnerReference appends specified owner reference to refs
+// unless refs already has a reference to the same owner.
+func appendOwnerReference(refs []metav1.OwnerReference, ref *metav1.OwnerReference) []metav1.OwnerReference {
+	for _, r := range refs {
+		if r.UID == ref.UID {
+			return refs
+		}
+	}
+
+	return append(refs, *ref)
+}
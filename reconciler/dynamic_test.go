@@ -0,0 +1,197 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithDynamicObjects(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newDynamicResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+	ownerRef := newOwnerReference(object, true)
+
+	existing := newDynamicConfigMap("existing", "old")
+	existing.SetResourceVersion("10")
+	existing.SetUID("existing-uid")
+	existing.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "other", UID: "other-uid"}})
+	unstructured.SetNestedField(existing.Object, "kept", "data", "other")
+	unchanged := newDynamicConfigMap("unchanged", "hello")
+	unchanged.SetOwnerReferences([]metav1.OwnerReference{*ownerRef})
+	c := &testTrackingClient{objects: []*unstructured.Unstructured{existing, unchanged}}
+
+	r := &Reconciler{
+		Client: c,
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				created := newDynamicConfigMap("created", "hello")
+				created.SetNamespace("")
+				s.Objects = []*unstructured.Unstructured{
+					created,
+					newDynamicConfigMap("existing", "new"),
+					newDynamicConfigMap("unchanged", "hello"),
+				}
+				return nil
+			},
+		},
+		recorder:     record.NewFakeRecorder(32),
+		dynamicKinds: newDynamicKinds(rc.Reconciler),
+	}
+
+	_, err := r.reconcile(context.TODO(), object, "create")
+	Expect(err).NotTo(HaveOccurred())
+
+	// The new object is created in the namespace of the owner
+	Expect(c.created).To(HaveLen(1))
+	Expect(c.created[0].GetName()).To(Equal("created"))
+	Expect(c.created[0].GetNamespace()).To(Equal("default"))
+	Expect(c.created[0].GetOwnerReferences()).To(Equal([]metav1.OwnerReference{*ownerRef}))
+
+	// Only the changed object is updated
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].GetName()).To(Equal("existing"))
+	Expect(c.updated[0].GetResourceVersion()).To(Equal("10"))
+	Expect(c.updated[0].Object["data"]).To(Equal(map[string]interface{}{"message": "new", "other": "kept"}))
+
+	// The fields set by others are kept and the owner reference is added
+	Expect(string(c.updated[0].GetUID())).To(Equal("existing-uid"))
+	Expect(c.updated[0].GetOwnerReferences()).To(Equal([]metav1.OwnerReference{
+		{APIVersion: "v1", Kind: "Pod", Name: "other", UID: "other-uid"},
+		*ownerRef,
+	}))
+}
+
+func TestReconcileWithDynamicObjectsOfOtherController(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newDynamicResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+	object.SetUID("test-uid")
+
+	existing := newDynamicConfigMap("existing", "old")
+	otherRef := metav1.NewControllerRef(object, rc.GroupVersionKind)
+	otherRef.Name = "other"
+	otherRef.UID = "other-uid"
+	existing.SetOwnerReferences([]metav1.OwnerReference{*otherRef})
+	c := &testTrackingClient{objects: []*unstructured.Unstructured{existing}}
+	recorder := record.NewFakeRecorder(32)
+
+	r := &Reconciler{
+		Client: c,
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				s.Objects = []*unstructured.Unstructured{newDynamicConfigMap("existing", "new")}
+				return nil
+			},
+		},
+		recorder:     recorder,
+		dynamicKinds: newDynamicKinds(rc.Reconciler),
+	}
+
+	_, err := r.reconcile(context.TODO(), object, "create")
+	Expect(err).To(HaveOccurred())
+	Expect(c.updated).To(BeEmpty())
+	Expect(recorder.Events).To(Receive(ContainSubstring("ObjectConflict")))
+}
+
+func TestReconcileWithInvalidDynamicObjects(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newDynamicResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+
+	secret := &unstructured.Unstructured{}
+	secret.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "Secret"})
+	secret.SetName("test")
+
+	otherNamespace := newDynamicConfigMap("test", "hello")
+	otherNamespace.SetNamespace("other")
+
+	invalid := [][]*unstructured.Unstructured{
+		// Disallowed kind
+		{newDynamicConfigMap("test", "hello"), secret},
+		// Missing name
+		{newDynamicConfigMap("", "hello")},
+		// Other namespace
+		{otherNamespace},
+	}
+
+	for _, objects := range invalid {
+		objects := objects
+		c := &testTrackingClient{}
+		r := &Reconciler{
+			Client: c,
+			config: rc,
+			handler: &testHandler{
+				Func: func(s *state.State) error {
+					s.Objects = objects
+					return nil
+				},
+			},
+			recorder:     record.NewFakeRecorder(32),
+			dynamicKinds: newDynamicKinds(rc.Reconciler),
+		}
+
+		_, err := r.reconcile(context.TODO(), object, "create")
+		Expect(err).To(HaveOccurred())
+		Expect(errorReason(err)).To(Equal(ReasonValidation))
+		Expect(c.created).To(BeEmpty())
+	}
+
+	// Dynamic objects are not allowed
+	rc.Reconciler = &config.ReconcilerConfig{}
+	c := &testTrackingClient{}
+	r := &Reconciler{
+		Client: c,
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				s.Objects = []*unstructured.Unstructured{newDynamicConfigMap("test", "hello")}
+				return nil
+			},
+		},
+		recorder:     record.NewFakeRecorder(32),
+		dynamicKinds: newDynamicKinds(rc.Reconciler),
+	}
+
+	_, err := r.reconcile(context.TODO(), object, "create")
+	Expect(err).To(HaveOccurred())
+	Expect(c.created).To(BeEmpty())
+}
+
+func newDynamicResourceConfig() *config.ResourceConfig {
+	return &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{
+			Group:   "example.com",
+			Version: "v1alpha1",
+			Kind:    "Test",
+		},
+		Reconciler: &config.ReconcilerConfig{
+			AllowDynamicObjects: true,
+			DynamicObjectKinds:  []schema.GroupVersionKind{configMapGVK},
+		},
+	}
+}
+
+func newDynamicConfigMap(name, message string) *unstructured.Unstructured {
+	cm := &unstructured.Unstructured{}
+	cm.SetGroupVersionKind(configMapGVK)
+	cm.SetNamespace("default")
+	cm.SetName(name)
+	unstructured.SetNestedField(cm.Object, message, "data", "message")
+
+	return cm
+}
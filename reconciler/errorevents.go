@@ -0,0 +1,100 @@
+package reconciler
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// The minimum interval of the error events of an object.
+	errorEventInterval = time.Minute
+	// The maximum length of the message of an error event.
+	maxErrorEventMessage = 1024
+)
+
+// recordErrorEvent emits a warning event of the handler error to
+// specified object if error events are enabled. The events of an
+// object are limited to one per errorEventInterval.
+func (r *Reconciler) recordErrorEvent(res *unstructured.Unstructured, err error) {
+	if !r.errorEvents {
+		return
+	}
+
+	nn := types.NamespacedName{Namespace: res.GetNamespace(), Name: res.GetName()}
+	now := time.Now()
+
+	r.mu.Lock()
+	last, ok := r.errorEventTimes[nn]
+	if ok && now.Sub(last) < errorEventInterval {
+		r.mu.Unlock()
+		return
+	}
+	if r.errorEventTimes == nil {
+		r.errorEventTimes = map[types.NamespacedName]time.Time{}
+	}
+	r.errorEventTimes[nn] = now
+	r.mu.Unlock()
+
+	msg := truncateMessage(fmt.Sprintf("Handler failed: %v", err), maxErrorEventMessage)
+	r.recorder.Event(res, "Warning", "HandlerError", msg)
+}
+
+// warningEvent identifies a warning event of an object.
+type warningEvent struct {
+	nn      types.NamespacedName
+	reason  string
+	message string
+}
+
+// recordWarningEvent emits a warning event with specified reason and
+// message to specified object. The same event of an object is emitted
+// at most once per errorEventInterval so that a persistent problem of
+// the object does not emit an event on every reconcile.
+func (r *Reconciler) recordWarningEvent(res *unstructured.Unstructured, reason, msg string) {
+	nn := types.NamespacedName{Namespace: res.GetNamespace(), Name: res.GetName()}
+	key := warningEvent{nn: nn, reason: reason, message: msg}
+	now := time.Now()
+
+	r.mu.Lock()
+	for k, last := range r.warningEventTimes {
+		if now.Sub(last) >= errorEventInterval {
+			delete(r.warningEventTimes, k)
+		}
+	}
+	if _, ok := r.warningEventTimes[key]; ok {
+		r.mu.Unlock()
+		return
+	}
+	if r.warningEventTimes == nil {
+		r.warningEventTimes = map[warningEvent]time.Time{}
+	}
+	r.warningEventTimes[key] = now
+	r.mu.Unlock()
+
+	r.recorder.Event(res, "Warning", reason, msg)
+}
+
+// forgetWarningEvents clears the warning events of specified object.
+func (r *Reconciler) forgetWarningEvents(nn types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k := range r.warningEventTimes {
+		if k.nn == nn {
+			delete(r.warningEventTimes, k)
+		}
+	}
+}
+
+// truncateMessage truncates specified message to max characters.
+func truncateMessage(msg string, max int) string {
+	rs := []rune(msg)
+	if len(rs) <= max {
+		return msg
+	}
+
+	return string(rs[:max-3]) + "..."
+}
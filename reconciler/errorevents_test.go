@@ -0,0 +1,124 @@
+package reconciler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithErrorEvents(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	object := newObject(rc.GroupVersionKind, "test")
+	recorder := record.NewFakeRecorder(32)
+
+	handlerErr := errors.New("handler failed")
+	rc.Reconciler.ErrorEvents = true
+	r := newTestReconciler(rc, &testQuotaClient{object: object}, &testHandler{
+		Func: func(s *state.State) error {
+			return handlerErr
+		},
+	}, recorder)
+
+	nn := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	req := reconcile.Request{NamespacedName: nn}
+
+	// The error is recorded as an event
+	_, err := r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Expect(recorder.Events).To(Receive(Equal("Warning HandlerError Handler failed: handler failed")))
+
+	// Repeated failures are rate limited
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Expect(recorder.Events).NotTo(Receive())
+
+	// After the interval
+	r.errorEventTimes[nn] = time.Now().Add(-errorEventInterval)
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Expect(recorder.Events).To(Receive(HavePrefix("Warning HandlerError")))
+
+	// Long message is truncated
+	r.errorEventTimes[nn] = time.Now().Add(-errorEventInterval)
+	handlerErr = errors.New(strings.Repeat("a", 2*maxErrorEventMessage))
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+
+	var ev string
+	Expect(recorder.Events).To(Receive(&ev))
+	Expect(ev).To(HaveSuffix("..."))
+	Expect(len(strings.TrimPrefix(ev, "Warning HandlerError "))).To(Equal(maxErrorEventMessage))
+
+	// Success resets the rate limit
+	handlerErr = nil
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(r.errorEventTimes).To(BeEmpty())
+
+	// Disabled
+	r.errorEvents = false
+	handlerErr = errors.New("handler failed")
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Expect(recorder.Events).NotTo(Receive())
+}
+
+func TestRecordWarningEvent(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+	other := newObject(rc.GroupVersionKind, "other")
+	recorder := record.NewFakeRecorder(32)
+
+	r := &Reconciler{recorder: recorder}
+
+	r.recordWarningEvent(object, "Test", "message")
+	Expect(recorder.Events).To(Receive(Equal("Warning Test message")))
+
+	// The same event is rate limited
+	r.recordWarningEvent(object, "Test", "message")
+	Expect(recorder.Events).NotTo(Receive())
+
+	// Different message, reason or object
+	r.recordWarningEvent(object, "Test", "changed")
+	Expect(recorder.Events).To(Receive(Equal("Warning Test changed")))
+	r.recordWarningEvent(object, "Other", "message")
+	Expect(recorder.Events).To(Receive(Equal("Warning Other message")))
+	r.recordWarningEvent(other, "Test", "message")
+	Expect(recorder.Events).To(Receive(Equal("Warning Test message")))
+
+	// After the interval
+	nn := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	key := warningEvent{nn: nn, reason: "Test", message: "message"}
+	r.warningEventTimes[key] = time.Now().Add(-errorEventInterval)
+	r.recordWarningEvent(object, "Test", "message")
+	Expect(recorder.Events).To(Receive(Equal("Warning Test message")))
+
+	// Forgotten events of the object
+	r.forgetWarningEvents(nn)
+	Expect(r.warningEventTimes).To(HaveLen(1))
+	r.recordWarningEvent(object, "Test", "message")
+	Expect(recorder.Events).To(Receive(Equal("Warning Test message")))
+}
+
+func TestTruncateMessage(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(truncateMessage("hello", 5)).To(Equal("hello"))
+	Expect(truncateMessage("hello world", 8)).To(Equal("hello..."))
+}
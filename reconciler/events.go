@@ -0,0 +1,90 @@
+package reconciler
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// eventObjectUIDField is the field of events indexed by the UID of
+// the involved object.
+const eventObjectUIDField = "involvedObject.uid"
+
+// IndexEvents adds the index of events by the UID of the involved
+// object to the cache, so that the events of a resource can be listed
+// without going through all the events of the namespace.
+func IndexEvents(fi client.FieldIndexer) error {
+	ev := &unstructured.Unstructured{}
+	ev.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "Event"})
+
+	return fi.IndexField(ev, eventObjectUIDField, func(obj runtime.Object) []string {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return nil
+		}
+
+		uid, _, _ := unstructured.NestedString(u.Object, "involvedObject", "uid")
+		if uid == "" {
+			return nil
+		}
+
+		return []string{uid}
+	})
+}
+
+// getRecentEvents returns the latest Kubernetes events that refer to
+// specified resource, newest first. The number of events is limited
+// by includeEvents of reconciler. The events are omitted if they could
+// not be listed.
+func (r *Reconciler) getRecentEvents(ctx context.Context, res *unstructured.Unstructured) []*unstructured.Unstructured {
+	limit := r.config.Reconciler.IncludeEvents
+	if limit == 0 {
+		return nil
+	}
+
+	eventList := &unstructured.UnstructuredList{}
+	eventList.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "EventList"})
+
+	err := r.List(ctx, eventList, client.InNamespace(res.GetNamespace()), client.MatchingFields{eventObjectUIDField: string(res.GetUID())})
+	if err != nil {
+		r.objectLog(res).Error(err, "Failed to get a list of events")
+		return nil
+	}
+
+	events := []*unstructured.Unstructured{}
+	for i := range eventList.Items {
+		events = append(events, &eventList.Items[i])
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).After(eventTime(events[j]))
+	})
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events
+}
+
+// eventTime returns the time when specified event last occurred.
+func eventTime(ev *unstructured.Unstructured) time.Time {
+	for _, field := range []string{"lastTimestamp", "eventTime", "firstTimestamp"} {
+		v, ok, _ := unstructured.NestedString(ev.Object, field)
+		if !ok || v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err == nil {
+			return t
+		}
+	}
+
+	return ev.GetCreationTimestamp().Time
+}
@@ -0,0 +1,106 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestGetRecentEvents(t *testing.T) {
+	RegisterTestingT(t)
+
+	res := &unstructured.Unstructured{}
+	res.SetNamespace("default")
+	res.SetName("test")
+	res.SetUID(types.UID("test-uid"))
+
+	c := &testEventClient{
+		events: []unstructured.Unstructured{
+			newTestEvent("first", "test-uid", "2019-10-01T00:00:00Z"),
+			newTestEvent("other", "other-uid", "2019-10-01T00:00:03Z"),
+			newTestEvent("third", "test-uid", "2019-10-01T00:00:02Z"),
+			newTestEvent("second", "test-uid", "2019-10-01T00:00:01Z"),
+		},
+	}
+
+	rc := newResourceConfig()
+	rc.Reconciler.IncludeEvents = 2
+	r := newTestReconciler(rc, c, &testHandler{}, nil)
+
+	events := r.getRecentEvents(context.TODO(), res)
+	Expect(c.namespace).To(Equal("default"))
+	Expect(c.fields).To(Equal("involvedObject.uid=test-uid"))
+	Expect(events).To(HaveLen(2))
+	Expect(events[0].GetName()).To(Equal("third"))
+	Expect(events[1].GetName()).To(Equal("second"))
+
+	// All events
+	r.config.Reconciler.IncludeEvents = 10
+	events = r.getRecentEvents(context.TODO(), res)
+	Expect(events).To(HaveLen(3))
+
+	// Failure of listing events
+	c.err = errors.New("forbidden")
+	events = r.getRecentEvents(context.TODO(), res)
+	Expect(events).To(BeEmpty())
+	c.err = nil
+
+	// Disabled
+	c.namespace = ""
+	r.config.Reconciler.IncludeEvents = 0
+	events = r.getRecentEvents(context.TODO(), res)
+	Expect(events).To(BeEmpty())
+	Expect(c.namespace).To(BeEmpty())
+}
+
+func newTestEvent(name, uid, timestamp string) unstructured.Unstructured {
+	ev := unstructured.Unstructured{}
+	ev.SetAPIVersion("v1")
+	ev.SetKind("Event")
+	ev.SetNamespace("default")
+	ev.SetName(name)
+	unstructured.SetNestedField(ev.Object, uid, "involvedObject", "uid")
+	unstructured.SetNestedField(ev.Object, timestamp, "lastTimestamp")
+
+	return ev
+}
+
+// testEventClient is a client that returns the events matching the
+// field selector on List calls.
+type testEventClient struct {
+	client.Client
+	events    []unstructured.Unstructured
+	namespace string
+	fields    string
+	err       error
+}
+
+func (c *testEventClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	lo := &client.ListOptions{}
+	lo.ApplyOptions(opts)
+	c.namespace = lo.Namespace
+	c.fields = lo.FieldSelector.String()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	l := list.(*unstructured.UnstructuredList)
+	for i := range c.events {
+		uid, _, _ := unstructured.NestedString(c.events[i].Object, "involvedObject", "uid")
+		if !lo.FieldSelector.Matches(fields.Set{"involvedObject.uid": uid}) {
+			continue
+		}
+		l.Items = append(l.Items, *c.events[i].DeepCopy())
+	}
+
+	return nil
+}
@@ -0,0 +1,416 @@
+// Package jsonschema implements a subset of JSON Schema to validate
+// the objects returned by handlers.
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// annotations are the keywords that do not affect the validation.
+var annotations = map[string]struct{}{
+	"$schema":     struct{}{},
+	"$id":         struct{}{},
+	"title":       struct{}{},
+	"description": struct{}{},
+	"default":     struct{}{},
+	"examples":    struct{}{},
+	"format":      struct{}{},
+}
+
+// types are the valid values of the type keyword.
+var types = map[string]struct{}{
+	"object":  struct{}{},
+	"array":   struct{}{},
+	"string":  struct{}{},
+	"number":  struct{}{},
+	"integer": struct{}{},
+	"boolean": struct{}{},
+	"null":    struct{}{},
+}
+
+// Schema represents a compiled JSON Schema.
+type Schema struct {
+	types                []string
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	noAdditional         bool
+	items                *Schema
+	enum                 []interface{}
+	minimum              *float64
+	maximum              *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	minItems             *int
+	maxItems             *int
+}
+
+// Compile compiles specified JSON Schema. It returns an error if the
+// schema has an invalid or unsupported keyword.
+func Compile(s map[string]interface{}) (*Schema, error) {
+	return compile(s, "")
+}
+
+func compile(s map[string]interface{}, path string) (*Schema, error) {
+	cs := &Schema{}
+
+	keys := []string{}
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := s[key]
+		kp := path + "/" + key
+
+		_, ok := annotations[key]
+		if ok {
+			continue
+		}
+
+		var err error
+		switch key {
+		case "type":
+			cs.types, err = compileTypes(v)
+		case "properties":
+			cs.properties, err = compileProperties(v, kp)
+		case "required":
+			cs.required, err = toStrings(v)
+		case "additionalProperties":
+			b, ok := v.(bool)
+			if ok {
+				cs.noAdditional = !b
+			} else {
+				cs.additionalProperties, err = compileValue(v, kp)
+			}
+		case "items":
+			cs.items, err = compileValue(v, kp)
+		case "enum":
+			list, ok := v.([]interface{})
+			if !ok || len(list) == 0 {
+				err = errors.New("must be a non-empty array")
+			}
+			cs.enum = list
+		case "const":
+			cs.enum = []interface{}{v}
+		case "minimum":
+			cs.minimum, err = toFloat(v)
+		case "maximum":
+			cs.maximum, err = toFloat(v)
+		case "exclusiveMinimum":
+			cs.exclusiveMinimum, err = toFloat(v)
+		case "exclusiveMaximum":
+			cs.exclusiveMaximum, err = toFloat(v)
+		case "minLength":
+			cs.minLength, err = toInt(v)
+		case "maxLength":
+			cs.maxLength, err = toInt(v)
+		case "minItems":
+			cs.minItems, err = toInt(v)
+		case "maxItems":
+			cs.maxItems, err = toInt(v)
+		case "pattern":
+			p, ok := v.(string)
+			if !ok {
+				err = errors.New("must be a string")
+				break
+			}
+			cs.pattern, err = regexp.Compile(p)
+		default:
+			err = errors.New("unsupported keyword")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", kp, err)
+		}
+	}
+
+	return cs, nil
+}
+
+func compileValue(v interface{}, path string) (*Schema, error) {
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("must be an object")
+	}
+
+	return compile(s, path)
+}
+
+func compileTypes(v interface{}) ([]string, error) {
+	list := []string{}
+
+	switch t := v.(type) {
+	case string:
+		list = append(list, t)
+	case []interface{}:
+		var err error
+		list, err = toStrings(t)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("must be a string or an array")
+	}
+
+	for _, t := range list {
+		_, ok := types[t]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+	}
+
+	return list, nil
+}
+
+func compileProperties(v interface{}, path string) (map[string]*Schema, error) {
+	props, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("must be an object")
+	}
+
+	list := map[string]*Schema{}
+	for name, p := range props {
+		s, err := compileValue(p, path+"/"+name)
+		if err != nil {
+			return nil, err
+		}
+		list[name] = s
+	}
+
+	return list, nil
+}
+
+// Validate validates specified value. The value must be decoded from
+// JSON. The error reports every field that does not match the schema.
+func (s *Schema) Validate(v interface{}) error {
+	errs := s.validate(v, "")
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (s *Schema) validate(v interface{}, path string) []string {
+	if path == "" {
+		path = "."
+	}
+
+	if len(s.types) > 0 && !matchType(v, s.types) {
+		return []string{fmt.Sprintf("%s: must be %s", path, strings.Join(s.types, " or "))}
+	}
+
+	errs := []string{}
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if len(s.enum) > 0 {
+		found := false
+		for _, e := range s.enum {
+			if equal(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", s.enum)
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			_, ok := val[name]
+			if !ok {
+				fail("missing required field %q", name)
+			}
+		}
+
+		names := []string{}
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fp := strings.TrimSuffix(path, ".") + "." + name
+
+			p, ok := s.properties[name]
+			if ok {
+				errs = append(errs, p.validate(val[name], fp)...)
+				continue
+			}
+
+			if s.noAdditional {
+				errs = append(errs, fmt.Sprintf("%s: unknown field", fp))
+			} else if s.additionalProperties != nil {
+				errs = append(errs, s.additionalProperties.validate(val[name], fp)...)
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(val) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(val) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i := range val {
+				errs = append(errs, s.items.validate(val[i], fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		n := len([]rune(val))
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			fail("must match %q", s.pattern.String())
+		}
+	default:
+		n, ok := number(v)
+		if !ok {
+			break
+		}
+		if s.minimum != nil && n < *s.minimum {
+			fail("must be greater than or equal to %v", *s.minimum)
+		}
+		if s.maximum != nil && n > *s.maximum {
+			fail("must be less than or equal to %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum {
+			fail("must be greater than %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum {
+			fail("must be less than %v", *s.exclusiveMaximum)
+		}
+	}
+
+	return errs
+}
+
+// matchType returns whether the value matches any of specified types.
+func matchType(v interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "object":
+			_, ok := v.(map[string]interface{})
+			if ok {
+				return true
+			}
+		case "array":
+			_, ok := v.([]interface{})
+			if ok {
+				return true
+			}
+		case "string":
+			_, ok := v.(string)
+			if ok {
+				return true
+			}
+		case "boolean":
+			_, ok := v.(bool)
+			if ok {
+				return true
+			}
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "number":
+			_, ok := number(v)
+			if ok {
+				return true
+			}
+		case "integer":
+			n, ok := number(v)
+			if ok && n == math.Trunc(n) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// equal returns whether two values are equal. Numbers are compared
+// by the values regardless of their types.
+func equal(a, b interface{}) bool {
+	na, ok := number(a)
+	if ok {
+		nb, ok := number(b)
+		return ok && na == nb
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// number returns the value of specified number.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+
+	return 0, false
+}
+
+func toFloat(v interface{}) (*float64, error) {
+	n, ok := number(v)
+	if !ok {
+		return nil, errors.New("must be a number")
+	}
+
+	return &n, nil
+}
+
+func toInt(v interface{}) (*int, error) {
+	n, ok := number(v)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, errors.New("must be a non-negative integer")
+	}
+
+	i := int(n)
+	return &i, nil
+}
+
+func toStrings(v interface{}) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("must be an array of strings")
+	}
+
+	strs := []string{}
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, errors.New("must be an array of strings")
+		}
+		strs = append(strs, s)
+	}
+
+	return strs, nil
+}
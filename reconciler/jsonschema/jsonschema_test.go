@@ -0,0 +1,108 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const testSchema = `{
+  "type": "object",
+  "required": ["spec"],
+  "properties": {
+    "spec": {
+      "type": "object",
+      "required": ["replicas"],
+      "additionalProperties": false,
+      "properties": {
+        "replicas": {"type": "integer", "minimum": 1, "maximum": 10},
+        "mode": {"enum": ["fast", "slow"]},
+        "name": {"type": "string", "pattern": "^[a-z]+$", "maxLength": 8}
+      }
+    },
+    "status": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    },
+    "tags": {
+      "type": "array",
+      "maxItems": 2,
+      "items": {"type": "string"}
+    }
+  }
+}`
+
+func TestCompile(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, err := Compile(decode(testSchema))
+	Expect(err).NotTo(HaveOccurred())
+
+	// Annotations
+	_, err = Compile(decode(`{"$schema": "http://json-schema.org/draft-07/schema#", "title": "test", "type": "object"}`))
+	Expect(err).NotTo(HaveOccurred())
+
+	// Unknown type
+	_, err = Compile(decode(`{"type": "map"}`))
+	Expect(err).To(HaveOccurred())
+
+	// Invalid pattern
+	_, err = Compile(decode(`{"properties": {"name": {"pattern": "["}}}`))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("/properties/name/pattern"))
+
+	// Invalid minimum
+	_, err = Compile(decode(`{"minimum": "1"}`))
+	Expect(err).To(HaveOccurred())
+
+	// Unsupported keyword
+	_, err = Compile(decode(`{"oneOf": [{"type": "string"}]}`))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("unsupported keyword"))
+}
+
+func TestValidate(t *testing.T) {
+	RegisterTestingT(t)
+
+	s, err := Compile(decode(testSchema))
+	Expect(err).NotTo(HaveOccurred())
+
+	// Valid
+	err = s.Validate(decode(`{"spec": {"replicas": 3, "mode": "fast", "name": "test"}, "status": {"phase": "Running"}, "tags": ["a"]}`))
+	Expect(err).NotTo(HaveOccurred())
+
+	// Integer decoded as int64
+	err = s.Validate(map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": int64(3)},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Missing required field
+	err = s.Validate(decode(`{}`))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring(`.: missing required field "spec"`))
+
+	// Invalid fields
+	err = s.Validate(decode(`{"spec": {"replicas": 1.5, "mode": "none", "name": "Test", "extra": true}, "status": {"phase": 1}, "tags": ["a", "b", "c"]}`))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring(".spec.replicas: must be integer"))
+	Expect(err.Error()).To(ContainSubstring(".spec.mode: must be one of"))
+	Expect(err.Error()).To(ContainSubstring(".spec.name: must match"))
+	Expect(err.Error()).To(ContainSubstring(".spec.extra: unknown field"))
+	Expect(err.Error()).To(ContainSubstring(".status.phase: must be string"))
+	Expect(err.Error()).To(ContainSubstring(".tags: must have at most 2 items"))
+
+	// Out of range
+	err = s.Validate(decode(`{"spec": {"replicas": 11}}`))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(Equal(".spec.replicas: must be less than or equal to 10"))
+}
+
+func decode(s string) map[string]interface{} {
+	v := map[string]interface{}{}
+	err := json.Unmarshal([]byte(s), &v)
+	Expect(err).NotTo(HaveOccurred())
+
+	return v
+}
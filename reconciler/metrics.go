@@ -0,0 +1,112 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+// Reasons of reconcile errors.
+const (
+	// ReasonTimeout means that the handler or the reconcile did not
+	// complete in time.
+	ReasonTimeout = "timeout"
+	// ReasonHandlerError means that the handler returned an error.
+	ReasonHandlerError = "handler-error"
+	// ReasonApplyConflict means that applying the new state conflicted
+	// with the current state of resources.
+	ReasonApplyConflict = "apply-conflict"
+	// ReasonValidation means that the new state returned by the handler
+	// is invalid.
+	ReasonValidation = "validation"
+	// ReasonAPIError means that a request to the API server failed.
+	ReasonAPIError = "api-error"
+	// ReasonQuotaExceeded means that applying the new state exceeded
+	// the resource quota of the namespace.
+	ReasonQuotaExceeded = "quota-exceeded"
+	// ReasonDeadlineExceeded means that the deadline of the object has
+	// passed.
+	ReasonDeadlineExceeded = "deadline-exceeded"
+)
+
+var reconcileErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "whitebox_reconcile_errors_total",
+		Help: "Total number of reconcile errors per controller and reason",
+	},
+	[]string{"controller", "reason"},
+)
+
+var reconcilesInFlight = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "whitebox_reconciles_in_flight",
+		Help: "Current number of reconciles in progress per controller",
+	},
+	[]string{"controller"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileErrors, reconcilesInFlight)
+}
+
+// trackInFlight counts the reconcile as in progress until the
+// returned function is called.
+func (r *Reconciler) trackInFlight() func() {
+	g := reconcilesInFlight.WithLabelValues(r.name)
+	g.Inc()
+
+	return g.Dec
+}
+
+// reconcileError represents an error of reconcile with its reason.
+type reconcileError struct {
+	reason string
+	err    error
+}
+
+func (e *reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.err
+}
+
+// newHandlerError returns a reconcile error of the handler.
+func newHandlerError(err error) error {
+	reason := ReasonHandlerError
+	if errors.Is(err, handler.ErrTimeout) {
+		reason = ReasonTimeout
+	}
+
+	return &reconcileError{reason: reason, err: err}
+}
+
+// newApplyError returns a reconcile error of applying the new state.
+func newApplyError(err error) error {
+	reason := ReasonAPIError
+	if isQuotaExceeded(err) {
+		reason = ReasonQuotaExceeded
+	} else if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+		reason = ReasonApplyConflict
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		reason = ReasonTimeout
+	}
+
+	return &reconcileError{reason: reason, err: err}
+}
+
+// errorReason returns the reason of specified reconcile error.
+func errorReason(err error) string {
+	var re *reconcileError
+	if errors.As(err, &re) {
+		return re.reason
+	}
+
+	return ReasonAPIError
+}
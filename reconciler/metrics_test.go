@@ -0,0 +1,73 @@
+package reconciler
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+func TestErrorReason(t *testing.T) {
+	RegisterTestingT(t)
+
+	gr := schema.GroupResource{Group: "example.com", Resource: "tests"}
+
+	timeout := newHandlerError(fmt.Errorf("%w after 30s", handler.ErrTimeout))
+	Expect(errorReason(timeout)).To(Equal(ReasonTimeout))
+
+	handlerErr := newHandlerError(errors.New("exit status 1"))
+	Expect(errorReason(handlerErr)).To(Equal(ReasonHandlerError))
+
+	conflict := newApplyError(apierrors.NewConflict(gr, "test", errors.New("conflict")))
+	Expect(errorReason(conflict)).To(Equal(ReasonApplyConflict))
+
+	exists := newApplyError(apierrors.NewAlreadyExists(gr, "test"))
+	Expect(errorReason(exists)).To(Equal(ReasonApplyConflict))
+
+	forbidden := newApplyError(apierrors.NewForbidden(gr, "test", errors.New("forbidden")))
+	Expect(errorReason(forbidden)).To(Equal(ReasonAPIError))
+
+	validation := &reconcileError{reason: ReasonValidation, err: errors.New("invalid")}
+	Expect(errorReason(validation)).To(Equal(ReasonValidation))
+
+	Expect(errorReason(errors.New("unknown"))).To(Equal(ReasonAPIError))
+}
+
+func TestReconcileErrorsMetric(t *testing.T) {
+	RegisterTestingT(t)
+
+	timeout := newHandlerError(fmt.Errorf("%w after 30s", handler.ErrTimeout))
+	conflict := newApplyError(apierrors.NewConflict(schema.GroupResource{}, "test", errors.New("conflict")))
+
+	reconcileErrors.WithLabelValues("metrics-test", errorReason(timeout)).Inc()
+	reconcileErrors.WithLabelValues("metrics-test", errorReason(conflict)).Inc()
+	reconcileErrors.WithLabelValues("metrics-test", errorReason(conflict)).Inc()
+
+	Expect(testutil.ToFloat64(reconcileErrors.WithLabelValues("metrics-test", ReasonTimeout))).To(Equal(1.0))
+	Expect(testutil.ToFloat64(reconcileErrors.WithLabelValues("metrics-test", ReasonApplyConflict))).To(Equal(2.0))
+	Expect(testutil.ToFloat64(reconcileErrors.WithLabelValues("metrics-test", ReasonHandlerError))).To(Equal(0.0))
+}
+
+func TestReconcilesInFlightMetric(t *testing.T) {
+	RegisterTestingT(t)
+
+	r := &Reconciler{name: "in-flight-test"}
+	g := reconcilesInFlight.WithLabelValues(r.name)
+
+	done1 := r.trackInFlight()
+	done2 := r.trackInFlight()
+	Expect(testutil.ToFloat64(g)).To(Equal(2.0))
+
+	done1()
+	Expect(testutil.ToFloat64(g)).To(Equal(1.0))
+
+	done2()
+	Expect(testutil.ToFloat64(g)).To(Equal(0.0))
+}
@@ -0,0 +1,84 @@
+package reconciler
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+const (
+	// The maximum duration of a call of the notify handler.
+	notifyTimeout = time.Minute
+	// The maximum number of the transitions waiting to be notified.
+	// Transitions are dropped while the queue is full.
+	notifyQueueSize = 128
+)
+
+// notifyTransition queues the notification of the transition if the
+// outcome of the reconcile of specified object differs from the
+// previous one. The previous outcome is a failure if the object has a
+// record of consecutive failures, so that only the first failure and
+// the recovery from it are notified. This must be called before the
+// failure is recorded or reset. The notify handler is run in the
+// background, and its errors do not affect the reconcile.
+func (r *Reconciler) notifyTransition(res *unstructured.Unstructured, reconcileErr error) {
+	if r.notify == nil {
+		return
+	}
+
+	nn := types.NamespacedName{Namespace: res.GetNamespace(), Name: res.GetName()}
+	failed := reconcileErr != nil
+
+	r.mu.Lock()
+	_, failing := r.failures[nn]
+	r.mu.Unlock()
+
+	if failing == failed {
+		return
+	}
+
+	t := &state.Transition{From: ResultSuccess, To: ResultError}
+	if failed {
+		t.Error = reconcileErr.Error()
+	} else {
+		t.From, t.To = ResultError, ResultSuccess
+	}
+
+	s := state.New(res.DeepCopy(), nil, nil)
+	s.Transition = t
+	s.Controller = r.controllerInfo()
+
+	r.notifyOnce.Do(func() {
+		r.notifications = make(chan *state.State, notifyQueueSize)
+		go r.runNotify()
+	})
+
+	select {
+	case r.notifications <- s:
+	default:
+		r.objectLog(res).Info("Dropped a reconcile transition since the notify queue is full", "from", t.From, "to", t.To)
+	}
+}
+
+// runNotify runs the notify handler for the queued transitions in
+// order.
+func (r *Reconciler) runNotify() {
+	for s := range r.notifications {
+		l := r.objectLog(s.Object)
+		l.Info("Notifying a reconcile transition", "from", s.Transition.From, "to", s.Transition.To)
+
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		s.Context = ctx
+
+		err := r.notify.HandleState(s)
+		if err != nil {
+			l.Error(err, "Notify handler error")
+		}
+
+		cancel()
+	}
+}
@@ -0,0 +1,129 @@
+package reconciler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithNotify(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	var (
+		handlerErr error
+		mu         sync.Mutex
+		notified   []*state.State
+	)
+	notifications := func() []*state.State {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*state.State{}, notified...)
+	}
+
+	rc.Reconciler.Notify = &config.HandlerConfig{
+		StateHandler: &testHandler{
+			Func: func(s *state.State) error {
+				mu.Lock()
+				defer mu.Unlock()
+				notified = append(notified, s)
+				return errors.New("notify failed")
+			},
+		},
+	}
+	r := newTestReconciler(rc, &testQuotaClient{object: object}, &testHandler{
+		Func: func(s *state.State) error {
+			return handlerErr
+		},
+	}, nil)
+
+	nn := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	req := reconcile.Request{NamespacedName: nn}
+
+	// Success without previous failure
+	_, err := r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Consistently(notifications, 100*time.Millisecond).Should(BeEmpty())
+
+	// Transition to error
+	handlerErr = errors.New("handler failed")
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Eventually(notifications).Should(HaveLen(1))
+	n := notifications()
+	Expect(n[0].Object.GetName()).To(Equal("test"))
+	Expect(n[0].Transition.From).To(Equal(ResultSuccess))
+	Expect(n[0].Transition.To).To(Equal(ResultError))
+	Expect(n[0].Transition.Error).To(ContainSubstring("handler failed"))
+	Expect(n[0].Context).NotTo(BeNil())
+
+	// Repeated error
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Consistently(notifications, 100*time.Millisecond).Should(HaveLen(1))
+
+	// Transition to success
+	handlerErr = nil
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(notifications).Should(HaveLen(2))
+	n = notifications()
+	Expect(n[1].Transition.From).To(Equal(ResultError))
+	Expect(n[1].Transition.To).To(Equal(ResultSuccess))
+	Expect(n[1].Transition.Error).To(BeEmpty())
+
+	// Repeated success
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Consistently(notifications, 100*time.Millisecond).Should(HaveLen(2))
+}
+
+func TestReconcileWithSlowNotify(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	object := newObject(rc.GroupVersionKind, "test")
+	release := make(chan struct{})
+	defer close(release)
+
+	rc.Reconciler.Notify = &config.HandlerConfig{
+		StateHandler: &testHandler{
+			Func: func(s *state.State) error {
+				<-release
+				return nil
+			},
+		},
+	}
+	r := newTestReconciler(rc, &testQuotaClient{object: object}, &testHandler{
+		Func: func(s *state.State) error {
+			return errors.New("handler failed")
+		},
+	}, nil)
+
+	nn := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+
+	// The reconcile does not wait for the notify handler
+	done := make(chan struct{})
+	go func() {
+		r.Reconcile(reconcile.Request{NamespacedName: nn})
+		close(done)
+	}()
+	Eventually(done, time.Second).Should(BeClosed())
+}
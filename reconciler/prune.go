@@ -0,0 +1,98 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Prune deletes the tracked dependents whose owner no longer exists,
+// such as the dependents left by a previous version of the controller.
+// The dependent is orphaned if the object of its tracking labels is
+// not found or has another UID. If dryRun is true, the orphaned
+// dependents are only logged. It returns the orphaned dependents.
+func (r *Reconciler) Prune(ctx context.Context, dryRun bool) ([]*unstructured.Unstructured, error) {
+	orphans := []*unstructured.Unstructured{}
+
+	// Selects the resources that have the tracking labels.
+	selector, err := labels.Parse(LabelOwnerUID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dep := range r.config.Dependents {
+		if !dep.TrackingLabels {
+			continue
+		}
+
+		gvk := dep.GroupVersionKind
+		gvk.Kind = gvk.Kind + "List"
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk)
+
+		err := r.List(ctx, list, client.MatchingLabelsSelector{Selector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tracked dependent resources: %v", err)
+		}
+
+		for i := range list.Items {
+			res := &list.Items[i]
+			if isDeleting(res) {
+				continue
+			}
+
+			owned, err := r.hasOwner(ctx, res)
+			if err != nil {
+				return nil, err
+			}
+			if owned {
+				continue
+			}
+
+			orphans = append(orphans, res)
+			if dryRun {
+				log.Info("Found orphaned tracked resource (dry-run)", "kind", res.GetKind(), "namespace", res.GetNamespace(), "name", res.GetName())
+				continue
+			}
+
+			log.Info("Pruning orphaned tracked resource", "kind", res.GetKind(), "namespace", res.GetNamespace(), "name", res.GetName())
+			err = r.delete(ctx, res)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to delete a resource '%s/%s': %v", res.GetNamespace(), res.GetName(), err)
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// hasOwner returns whether the owner in the tracking labels of
+// specified dependent exists. The dependent without the name of the
+// owner is treated as owned since its owner cannot be verified.
+func (r *Reconciler) hasOwner(ctx context.Context, dep *unstructured.Unstructured) (bool, error) {
+	l := dep.GetLabels()
+
+	name := l[LabelOwnerName]
+	if name == "" {
+		return true, nil
+	}
+
+	owner := &unstructured.Unstructured{}
+	owner.SetGroupVersionKind(r.config.GroupVersionKind)
+
+	nn := types.NamespacedName{Namespace: l[LabelOwnerNamespace], Name: name}
+	err := r.Get(ctx, nn, owner)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get the owner '%s/%s': %v", nn.Namespace, nn.Name, err)
+	}
+
+	return string(owner.GetUID()) == l[LabelOwnerUID], nil
+}
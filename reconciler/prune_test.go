@@ -0,0 +1,65 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPrune(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newTrackingResourceConfig()
+	owner := newObject(rc.GroupVersionKind, "owner")
+	gone := newObject(rc.GroupVersionKind, "gone")
+	recreated := newObject(rc.GroupVersionKind, "owner")
+
+	newTracked := func(name string, o *unstructured.Unstructured) *unstructured.Unstructured {
+		cm := newDynamicConfigMap(name, "hello")
+		cm.SetNamespace("other")
+		setTrackingLabels(o, cm)
+		return cm
+	}
+
+	owned := newTracked("owned", owner)
+	ownerDeleted := newTracked("owner-deleted", gone)
+	ownerRecreated := newTracked("owner-recreated", recreated)
+	untracked := newDynamicConfigMap("untracked", "hello")
+
+	newClient := func() *testTrackingClient {
+		return &testTrackingClient{
+			objects: []*unstructured.Unstructured{owner, owned, ownerDeleted, ownerRecreated, untracked},
+		}
+	}
+
+	// Dry-run
+	c := newClient()
+	r := &Reconciler{Client: c, config: rc}
+
+	orphans, err := r.Prune(context.TODO(), true)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(referenceNames(orphans)).To(ConsistOf("owner-deleted", "owner-recreated"))
+	Expect(c.deleted).To(BeEmpty())
+
+	// Orphaned dependents are deleted and owned ones are kept
+	c = newClient()
+	r.Client = c
+
+	orphans, err = r.Prune(context.TODO(), false)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(orphans).To(HaveLen(2))
+	Expect(referenceNames(c.deleted)).To(ConsistOf("owner-deleted", "owner-recreated"))
+
+	// Dependents without tracking labels are not pruned
+	rc.Dependents[0].TrackingLabels = false
+	c = newClient()
+	r.Client = c
+
+	orphans, err = r.Prune(context.TODO(), false)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(orphans).To(BeEmpty())
+	Expect(c.deleted).To(BeEmpty())
+}
@@ -0,0 +1,55 @@
+package reconciler
+
+import (
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// The initial delay of requeue after exceeding the quota.
+	quotaBackoffBase = 10 * time.Second
+	// The maximum delay of requeue after exceeding the quota.
+	quotaBackoffMax = 5 * time.Minute
+)
+
+// isQuotaExceeded returns whether the error is caused by exceeding
+// the resource quota of the namespace.
+func isQuotaExceeded(err error) bool {
+	return apierrors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
+
+// handleQuotaExceeded emits a warning event and requeues specified
+// object with exponential backoff while the quota is exceeded.
+func (r *Reconciler) handleQuotaExceeded(res *unstructured.Unstructured, err error) reconcile.Result {
+	nn := types.NamespacedName{Namespace: res.GetNamespace(), Name: res.GetName()}
+
+	r.mu.Lock()
+	r.quotas[nn]++
+	count := r.quotas[nn]
+	r.mu.Unlock()
+
+	delay := quotaBackoff(count)
+	log.Info("Requeueing a resource due to exceeded quota", "namespace", nn.Namespace, "name", nn.Name, "after", delay.String())
+	r.recorder.Event(res, "Warning", "QuotaExceeded", err.Error())
+
+	return reconcile.Result{RequeueAfter: delay}
+}
+
+// quotaBackoff returns the delay of requeue for specified number of
+// consecutive quota errors.
+func quotaBackoff(count int) time.Duration {
+	delay := quotaBackoffBase
+	for i := 1; i < count; i++ {
+		delay *= 2
+		if delay >= quotaBackoffMax {
+			return quotaBackoffMax
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,122 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithQuotaExceeded(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"}
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	object.SetNamespace("default")
+	object.SetName("test")
+
+	c := &testQuotaClient{object: object, quotaExceeded: true}
+	recorder := record.NewFakeRecorder(32)
+
+	h := &testHandler{
+		Func: func(s *state.State) error {
+			pod := &unstructured.Unstructured{}
+			pod.SetGroupVersionKind(podGVK)
+			pod.SetNamespace("default")
+			pod.SetName("test")
+			s.Dependents[state.ResourceKey(podGVK)] = []*unstructured.Unstructured{pod}
+			return nil
+		},
+	}
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: gvk,
+		Dependents: []config.DependentConfig{
+			{GroupVersionKind: podGVK},
+		},
+	}
+	r := newTestReconciler(rc, c, h, recorder)
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "test"},
+	}
+
+	// Requeued with backoff
+	result, err := r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(result.RequeueAfter).To(Equal(quotaBackoffBase))
+	Expect(recorder.Events).To(Receive(HavePrefix("Warning QuotaExceeded")))
+
+	result, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(result.RequeueAfter).To(Equal(2 * quotaBackoffBase))
+
+	// Quota becomes available
+	c.quotaExceeded = false
+	result, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(result.RequeueAfter).To(BeZero())
+	Expect(r.quotas).To(BeEmpty())
+
+	// Other errors are still returned
+	c.createErr = errors.New("unexpected error")
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+}
+
+func TestQuotaBackoff(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(quotaBackoff(1)).To(Equal(10 * time.Second))
+	Expect(quotaBackoff(2)).To(Equal(20 * time.Second))
+	Expect(quotaBackoff(3)).To(Equal(40 * time.Second))
+	Expect(quotaBackoff(10)).To(Equal(quotaBackoffMax))
+}
+
+// testQuotaClient is a client that fails to create resources due to
+// exceeded quota.
+type testQuotaClient struct {
+	client.Client
+	object        *unstructured.Unstructured
+	quotaExceeded bool
+	createErr     error
+}
+
+func (c *testQuotaClient) Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+	c.object.DeepCopyInto(obj.(*unstructured.Unstructured))
+	return nil
+}
+
+func (c *testQuotaClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	return nil
+}
+
+func (c *testQuotaClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if c.createErr != nil {
+		return c.createErr
+	}
+
+	if c.quotaExceeded {
+		gr := schema.GroupResource{Resource: "pods"}
+		return apierrors.NewForbidden(gr, "test", errors.New("exceeded quota: compute, requested: pods=1, used: pods=10, limited: pods=10"))
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package reconciler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+const (
+	// ConditionReady is the type of condition that represents the
+	// readiness of the dependent resources.
+	ConditionReady = "Ready"
+
+	reasonDependentsReady    = "DependentsReady"
+	reasonDependentsNotReady = "DependentsNotReady"
+)
+
+// setReadyCondition sets the Ready condition to the new state of the
+// object based on the readiness of the current dependent resources.
+// The condition is False until all dependents in the new state exist
+// and report ready.
+func (r *Reconciler) setReadyCondition(s, ns *state.State) {
+	if !r.config.DependentReadiness || ns.Object == nil {
+		return
+	}
+
+	status := "True"
+	reason := reasonDependentsReady
+	message := "All dependent resources are ready"
+
+	msg, err := r.checkDependentReadiness(s, ns)
+	if err != nil {
+		log.Error(err, "Failed to check readiness of dependent resources", "namespace", ns.Object.GetNamespace(), "name", ns.Object.GetName())
+		msg = err.Error()
+	}
+	if msg != "" {
+		status = "False"
+		reason = reasonDependentsNotReady
+		message = msg
+	}
+
+	err = setCondition(ns.Object, ConditionReady, status, reason, message)
+	if err != nil {
+		log.Error(err, "Failed to set ready condition", "namespace", ns.Object.GetNamespace(), "name", ns.Object.GetName())
+	}
+}
+
+// checkDependentReadiness returns a message for the first dependent
+// resource that is not ready, or an empty string if all are ready.
+func (r *Reconciler) checkDependentReadiness(s, ns *state.State) (string, error) {
+	for _, dep := range r.config.Dependents {
+		if dep.ReadinessPath == "" {
+			continue
+		}
+
+		key := state.ResourceKey(dep.GroupVersionKind)
+		for _, desired := range ns.Dependents[key] {
+			current := findObject(s.Dependents[key], desired)
+			if current == nil {
+				return fmt.Sprintf("%s %s is not created yet", dep.Kind, desired.GetName()), nil
+			}
+
+			ready, err := isReady(current, dep.ReadinessPath)
+			if err != nil {
+				return "", fmt.Errorf("invalid readiness of %s %s: %v", dep.Kind, desired.GetName(), err)
+			}
+			if !ready {
+				return fmt.Sprintf("%s %s is not ready", dep.Kind, desired.GetName()), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// findObject returns the object that has same namespace and name as
+// specified object.
+func findObject(objs []*unstructured.Unstructured, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	for _, o := range objs {
+		if o.GetNamespace() == obj.GetNamespace() && o.GetName() == obj.GetName() {
+			return o
+		}
+	}
+
+	return nil
+}
+
+// isReady returns whether the field of specified JSON Path is true
+// or "True" in specified object.
+func isReady(obj *unstructured.Unstructured, readinessPath string) (bool, error) {
+	jp := jsonpath.New("readiness")
+	jp.AllowMissingKeys(true)
+
+	err := jp.Parse(fmt.Sprintf("{%s}", readinessPath))
+	if err != nil {
+		return false, err
+	}
+
+	results, err := jp.FindResults(obj.Object)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for x := range results {
+		for _, v := range results[x] {
+			switch val := v.Interface().(type) {
+			case bool:
+				if !val {
+					return false, nil
+				}
+			case string:
+				if !strings.EqualFold(val, "true") {
+					return false, nil
+				}
+			default:
+				return false, nil
+			}
+			found = true
+		}
+	}
+
+	return found, nil
+}
+
+// setCondition sets the condition of specified type to the status
+// of specified object. The last transition time is updated only when
+// the status of the condition changes.
+func setCondition(obj *unstructured.Unstructured, condType, status, reason, message string) error {
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return err
+	}
+
+	cond := map[string]interface{}{
+		"type":               condType,
+		"status":             status,
+		"reason":             reason,
+		"message":            message,
+		"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+	}
+
+	found := false
+	for i := range conditions {
+		c, ok := conditions[i].(map[string]interface{})
+		if !ok || c["type"] != condType {
+			continue
+		}
+
+		if c["status"] == status && c["lastTransitionTime"] != nil {
+			cond["lastTransitionTime"] = c["lastTransitionTime"]
+		}
+		conditions[i] = cond
+		found = true
+	}
+
+	if !found {
+		conditions = append(conditions, cond)
+	}
+
+	return unstructured.SetNestedSlice(obj.Object, conditions, "status", "conditions")
+}
@@ -0,0 +1,125 @@
+package reconciler
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	. "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestSetReadyCondition(t *testing.T) {
+	RegisterTestingT(t)
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	key := state.ResourceKey(gvk)
+
+	r := &Reconciler{
+		config: &config.ResourceConfig{
+			DependentReadiness: true,
+			Dependents: []config.DependentConfig{
+				{
+					GroupVersionKind: gvk,
+					ReadinessPath:    `.status.conditions[?(@.type=="Available")].status`,
+				},
+			},
+		},
+	}
+
+	owner := &Unstructured{}
+	owner.SetNamespace("default")
+	owner.SetName("test")
+
+	dep := &Unstructured{}
+	dep.SetGroupVersionKind(gvk)
+	dep.SetNamespace("default")
+	dep.SetName("test")
+
+	// Dependent is not created yet
+	s := state.New(owner, map[string][]*Unstructured{key: {}}, nil)
+	ns := s.Copy()
+	ns.Dependents[key] = []*Unstructured{dep}
+	r.setReadyCondition(s, ns)
+	Expect(readyCondition(ns.Object)["status"]).To(Equal("False"))
+
+	// Dependent is not ready
+	notReady := dep.DeepCopy()
+	SetNestedSlice(notReady.Object, []interface{}{
+		map[string]interface{}{"type": "Available", "status": "False"},
+	}, "status", "conditions")
+
+	s = state.New(ns.Object, map[string][]*Unstructured{key: {notReady}}, nil)
+	ns = s.Copy()
+	r.setReadyCondition(s, ns)
+	cond := readyCondition(ns.Object)
+	Expect(cond["status"]).To(Equal("False"))
+	Expect(cond["reason"]).To(Equal(reasonDependentsNotReady))
+
+	// Dependent becomes ready
+	ready := dep.DeepCopy()
+	SetNestedSlice(ready.Object, []interface{}{
+		map[string]interface{}{"type": "Available", "status": "True"},
+	}, "status", "conditions")
+
+	s = state.New(ns.Object, map[string][]*Unstructured{key: {ready}}, nil)
+	ns = s.Copy()
+	r.setReadyCondition(s, ns)
+	cond = readyCondition(ns.Object)
+	Expect(cond["status"]).To(Equal("True"))
+	Expect(cond["reason"]).To(Equal(reasonDependentsReady))
+
+	// Condition is not changed while the dependent is ready
+	s = state.New(ns.Object, map[string][]*Unstructured{key: {ready}}, nil)
+	ns = s.Copy()
+	r.setReadyCondition(s, ns)
+	Expect(ns.Object).To(Equal(s.Object))
+
+	// Disabled
+	r.config.DependentReadiness = false
+	s = state.New(owner.DeepCopy(), map[string][]*Unstructured{key: {}}, nil)
+	ns = s.Copy()
+	r.setReadyCondition(s, ns)
+	Expect(readyCondition(ns.Object)).To(BeNil())
+}
+
+func TestIsReady(t *testing.T) {
+	RegisterTestingT(t)
+
+	obj := &Unstructured{Object: map[string]interface{}{}}
+
+	// Missing field
+	ready, err := isReady(obj, ".status.ready")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ready).To(BeFalse())
+
+	SetNestedField(obj.Object, true, "status", "ready")
+	ready, err = isReady(obj, ".status.ready")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ready).To(BeTrue())
+
+	SetNestedField(obj.Object, "True", "status", "ready")
+	ready, err = isReady(obj, ".status.ready")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ready).To(BeTrue())
+
+	SetNestedField(obj.Object, "False", "status", "ready")
+	ready, err = isReady(obj, ".status.ready")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ready).To(BeFalse())
+}
+
+func readyCondition(obj *Unstructured) map[string]interface{} {
+	conditions, _, _ := NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if ok && cond["type"] == ConditionReady {
+			return cond
+		}
+	}
+
+	return nil
+}
@@ -7,35 +7,89 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/third_party/forked/golang/template"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 
 	"github.com/summerwind/whitebox-controller/config"
 	"github.com/summerwind/whitebox-controller/handler"
 	"github.com/summerwind/whitebox-controller/handler/common"
+	"github.com/summerwind/whitebox-controller/reconciler/cel"
+	"github.com/summerwind/whitebox-controller/reconciler/jsonschema"
 	"github.com/summerwind/whitebox-controller/reconciler/state"
 )
 
+// maxReferenceWorkers is the maximum number of reference resources
+// resolved concurrently in a reconcile.
+const maxReferenceWorkers = 8
+
 var log = logf.Log.WithName("reconciler")
 
 // Reconciler represents a reconciler of controller.
 type Reconciler struct {
 	client.Client
+	name         string
 	config       *config.ResourceConfig
 	handler      handler.StateHandler
 	finalizer    handler.StateHandler
+	shadow       handler.StateHandler
+	notify       handler.StateHandler
 	recorder     record.EventRecorder
 	requeueAfter *time.Duration
+	timeout      time.Duration
+	timeoutPath  string
+	maxRetries   int
+	statusError  []string
+	refCache     *referenceCache
+	skipDeletion bool
+	keyField     []string
+	apiVersions  *apiVersionCache
+	outputSchema *jsonschema.Schema
+	serverDryRun bool
+	predicate    *cel.Program
+	resultSink   *resultSink
+	dynamicKinds map[schema.GroupVersionKind]struct{}
+
+	conflictRetries int
+	enforceDeadline bool
+	started         <-chan struct{}
+	stop            <-chan struct{}
+
+	allowedFields          [][]string
+	finalizerAllowedFields [][]string
+
+	mu       sync.Mutex
+	failures map[types.NamespacedName]*failure
+	triggers map[types.NamespacedName]string
+	quotas   map[types.NamespacedName]int
+
+	errorEvents     bool
+	errorEventTimes map[types.NamespacedName]time.Time
+
+	warningEventTimes map[warningEvent]time.Time
+
+	notifyOnce    sync.Once
+	notifications chan *state.State
+}
+
+// failure represents consecutive reconcile failures of an object.
+type failure struct {
+	count           int
+	resourceVersion string
 }
 
 // New returns a new reconciler.
@@ -46,11 +100,26 @@ func New(c *config.ResourceConfig, rec record.EventRecorder) (*Reconciler, error
 	}
 
 	r := &Reconciler{
-		config:   c,
-		handler:  h,
-		recorder: rec,
+		name:         fmt.Sprintf("%s-controller", strings.ToLower(c.Kind)),
+		config:       c,
+		handler:      h,
+		recorder:     rec,
+		maxRetries:   c.Reconciler.MaxRetries,
+		timeoutPath:  c.Reconciler.TimeoutFieldPath,
+		serverDryRun: c.Reconciler.ServerDryRun,
+		errorEvents:  c.Reconciler.ErrorEvents,
+		failures:     map[types.NamespacedName]*failure{},
+		triggers:     map[types.NamespacedName]string{},
+		quotas:       map[types.NamespacedName]int{},
+		dynamicKinds: newDynamicKinds(c.Reconciler),
+
+		conflictRetries: c.Reconciler.ConflictRetries,
+		enforceDeadline: c.Reconciler.EnforceDeadline,
+		started:         c.Reconciler.Started,
 	}
 
+	r.skipDeletion = c.Finalizer == nil && c.Reconciler.SkipsDeletionWithoutFinalizer()
+
 	if c.Reconciler.RequeueAfter != "" {
 		ra, err := time.ParseDuration(c.Reconciler.RequeueAfter)
 		if err != nil {
@@ -59,12 +128,91 @@ func New(c *config.ResourceConfig, rec record.EventRecorder) (*Reconciler, error
 		r.requeueAfter = &ra
 	}
 
+	if c.Reconciler.Timeout != "" {
+		timeout, err := time.ParseDuration(c.Reconciler.Timeout)
+		if err != nil {
+			return nil, errors.New("invalid timeout")
+		}
+		r.timeout = timeout
+	}
+
+	if c.Reconciler.ReferenceCacheTTL != "" {
+		ttl, err := time.ParseDuration(c.Reconciler.ReferenceCacheTTL)
+		if err != nil {
+			return nil, errors.New("invalid reference cache TTL")
+		}
+		if ttl > 0 {
+			r.refCache = newReferenceCache(ttl)
+		}
+	}
+
+	if c.KeyFieldPath != "" {
+		fields, err := config.ParseFieldPath(c.KeyFieldPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key field path: %v", err)
+		}
+		r.keyField = fields
+	}
+
+	if c.Reconciler.StatusErrorField != "" {
+		fields, err := config.ParseFieldPath(c.Reconciler.StatusErrorField)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status error field: %v", err)
+		}
+		r.statusError = fields
+	}
+
+	if c.Predicate != "" {
+		r.predicate, err = cel.Compile(c.Predicate, config.PredicateObjectVar)
+		if err != nil {
+			return nil, fmt.Errorf("invalid predicate: %v", err)
+		}
+	}
+
+	if c.Reconciler.ResultSink != nil {
+		r.resultSink, err = newResultSink(c.Reconciler.ResultSink)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result sink: %v", err)
+		}
+	}
+
+	if c.Reconciler.OutputSchema != nil {
+		r.outputSchema, err = jsonschema.Compile(c.Reconciler.OutputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output schema: %v", err)
+		}
+	}
+
+	r.allowedFields, err = parseAllowedFields(c.Reconciler.AllowedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Reconciler.Shadow != nil {
+		r.shadow, err = common.NewStateHandler(c.Reconciler.Shadow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow handler: %v", err)
+		}
+	}
+
+	if c.Reconciler.Notify != nil {
+		r.notify, err = common.NewStateHandler(c.Reconciler.Notify)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notify handler: %v", err)
+		}
+	}
+
 	if c.Finalizer != nil {
 		fh, err := common.NewStateHandler(c.Finalizer)
 		if err != nil {
 			return nil, err
 		}
 		r.finalizer = fh
+
+		r.finalizerAllowedFields, err = parseAllowedFields(c.Finalizer.AllowedFields)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return r, nil
@@ -76,112 +224,290 @@ func (r *Reconciler) InjectClient(c client.Client) error {
 	return nil
 }
 
+// InjectStopChannel implements inject.Stoppable interface. The stop
+// channel of the manager cancels the running reconciles.
+func (r *Reconciler) InjectStopChannel(stop <-chan struct{}) error {
+	r.stop = stop
+	return nil
+}
+
 // Reconcile reconciles specified object.
 func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	// Waits for the startup delay before the first reconcile. The nil
+	// stop channel never stops the wait.
+	if r.started != nil {
+		select {
+		case <-r.started:
+		case <-r.stop:
+			return reconcile.Result{}, nil
+		}
+	}
+
+	done := r.trackInFlight()
+	defer done()
+
 	var (
-		err       error
-		finalized bool
+		result reconcile.Result
+		err    error
 	)
 
+	start := time.Now()
 	if r.IsObserver() {
-		return r.Observe(req)
+		result, err = r.Observe(req)
+	} else {
+		result, err = r.reconcileRequest(req)
 	}
 
+	if r.resultSink != nil {
+		r.resultSink.send(r.newResultRecord(req, result, err, time.Since(start)))
+	}
+
+	return result, err
+}
+
+// reconcileRequest reconciles the object of specified request and
+// handles the failure of it.
+func (r *Reconciler) reconcileRequest(req reconcile.Request) (reconcile.Result, error) {
 	namespace := req.Namespace
 	name := req.Name
-	log.Info("Reconcile a resource", "namespace", namespace, "name", name)
+	trigger := r.popTrigger(req.NamespacedName)
+	log.Info("Reconcile a resource", "namespace", namespace, "name", name, "trigger", trigger)
 
 	instance := &unstructured.Unstructured{}
 	instance.SetGroupVersionKind(r.config.GroupVersionKind)
 
-	err = r.Get(context.TODO(), req.NamespacedName, instance)
+	ctx, cancel := r.newContext()
+	defer cancel()
+
+	err := r.Get(ctx, req.NamespacedName, instance)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			r.resetFailure(req.NamespacedName)
+			r.forgetWarningEvents(req.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		log.Error(err, "Failed to get a resource", "namespace", namespace, "name", name)
 		return reconcile.Result{}, err
 	}
 
-	dependents, err := r.getDependents(instance)
+	result, err := r.reconcile(ctx, instance, trigger)
 	if err != nil {
-		log.Error(err, "Failed to get dependent resources", "namespace", namespace, "name", name)
+		reason := errorReason(err)
+		reconcileErrors.WithLabelValues(r.name, reason).Inc()
+
+		// Exceeding the quota is not a failure of the object. It is
+		// retried until the quota becomes available.
+		if reason == ReasonQuotaExceeded {
+			return r.handleQuotaExceeded(instance, err), nil
+		}
+
+		if reason == ReasonDeadlineExceeded {
+			return r.handleDeadlineExceeded(instance, err), nil
+		}
+
+		if reason == ReasonHandlerError || reason == ReasonTimeout {
+			r.recordErrorEvent(instance, err)
+		}
+
+		r.notifyTransition(instance, err)
+
+		// Status error must be written first so that the failure is
+		// recorded with the latest resource version of the object.
+		r.setStatusError(instance, err)
+		return r.handleFailure(instance, err)
+	}
+
+	r.notifyTransition(instance, nil)
+	r.resetFailure(req.NamespacedName)
+	return r.requeueAtDeadline(instance, result), nil
+}
+
+// reconcile runs the handler with the state of specified object and
+// applies the new state.
+func (r *Reconciler) reconcile(ctx context.Context, instance *unstructured.Unstructured, trigger string) (reconcile.Result, error) {
+	var (
+		err       error
+		finalized bool
+	)
+
+	l := r.objectLog(instance)
+
+	// Tracked dependents are deleted after the finalizer of the handler
+	// has been completed.
+	if isDeleting(instance) && hasFinalizer(instance, r.getTrackingFinalizerName()) && !hasFinalizer(instance, r.getFinalizerName()) {
+		l.Info("Deleting tracked dependent resources")
+		return reconcile.Result{}, r.cleanupTrackedDependents(ctx, instance)
+	}
+
+	if isDeleting(instance) && r.finalizer == nil && r.skipDeletion {
+		l.Info("Skipping a resource being deleted")
+		return reconcile.Result{}, nil
+	}
+
+	// The deleting object is finalized regardless of its deadline.
+	if !isDeleting(instance) {
+		err := r.checkDeadline(instance)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		ok, err := r.matchPredicate(instance)
+		if err != nil {
+			l.Error(err, "Failed to evaluate the predicate")
+			return reconcile.Result{}, &reconcileError{reason: ReasonValidation, err: fmt.Errorf("failed to evaluate predicate: %v", err)}
+		}
+		if !ok {
+			l.Info("Skipping a resource that does not match the predicate")
+			return reconcile.Result{}, nil
+		}
+	}
+
+	dependents, err := r.getDependents(ctx, instance)
+	if err != nil {
+		l.Error(err, "Failed to get dependent resources")
 		return reconcile.Result{}, err
 	}
 
-	refs, err := r.getReferences(instance)
+	refs, err := r.getReferences(ctx, instance)
 	if err != nil {
-		log.Error(err, "Failed to get reference resources", "namespace", namespace, "name", name)
+		l.Error(err, "Failed to get reference resources")
+		return reconcile.Result{}, err
+	}
+
+	events := r.getRecentEvents(ctx, instance)
+
+	apiVersions, err := r.getAPIVersions()
+	if err != nil {
+		l.Error(err, "Failed to get API versions")
 		return reconcile.Result{}, err
 	}
 
 	s := state.New(instance, dependents, refs)
+	s.Trigger = trigger
+	// The deletion is passed as the delete trigger regardless of the
+	// event, so that the finalizer can tell it from other reconciles.
+	if isDeleting(instance) {
+		s.Trigger = TriggerDelete
+	}
+	s.RecentEvents = events
+	s.APIVersions = apiVersions
+	s.Controller = r.controllerInfo()
+	s.Context = ctx
+
+	s.Timeout, err = r.handlerTimeout(instance)
+	if err != nil {
+		l.Info("Ignored invalid timeout of the object", "error", err.Error())
+		r.recordWarningEvent(instance, "InvalidTimeout", fmt.Sprintf("Ignored invalid timeout: %v", err))
+	}
+
 	ns := s.Copy()
 
 	if isDeleting(instance) && r.finalizer != nil {
 		finalized = true
-		log.Info("Starting finalizer", "namespace", namespace, "name", name)
+		l.Info("Starting finalizer")
 		err = r.finalizer.HandleState(ns)
 	} else {
+		// The shadow handler runs alongside the handler only, not the
+		// finalizer.
+		wait := r.startShadow(l, s)
 		err = r.handler.HandleState(ns)
+		if wait != nil {
+			wait(ns, err)
+		}
 	}
 	if err != nil {
-		log.Error(err, "Handler error", "namespace", namespace, "name", name)
-		return reconcile.Result{}, err
+		l.Error(err, "Handler error")
+		return reconcile.Result{}, newHandlerError(err)
 	}
 
 	err = r.validateState(s, ns)
 	if err != nil {
-		log.Error(err, "The new state is invalid", "namespace", namespace, "name", name)
-		return reconcile.Result{}, err
+		l.Error(err, "The new state is invalid")
+		return reconcile.Result{}, &reconcileError{reason: ReasonValidation, err: err}
+	}
+
+	err = r.validateOutput(ns)
+	if err != nil {
+		l.Error(err, "The new object does not match the output schema")
+		r.recorder.Event(instance, "Warning", "InvalidOutput", fmt.Sprintf("Handler output does not match the output schema: %v", err))
+		return reconcile.Result{}, &reconcileError{reason: ReasonValidation, err: err}
+	}
+
+	allowed := r.allowedFields
+	if finalized {
+		allowed = r.finalizerAllowedFields
+	}
+	dropped := restrictObject(s.Object, ns.Object, allowed)
+	if len(dropped) > 0 {
+		msg := fmt.Sprintf("Dropped changes outside allowed fields: %s", strings.Join(dropped, ", "))
+		l.Info(msg)
+		r.recorder.Event(instance, "Warning", "ChangesDropped", msg)
 	}
 
+	tracked := r.trackedKeys()
+
 	r.setOwnerReference(ns)
+	r.unsetStatusError(ns.Object)
+	r.setReadyCondition(s, ns)
 
 	if finalized {
 		if !ns.Requeue && ns.RequeueAfter == 0 {
 			r.unsetFinalizer(ns.Object)
 		}
-	} else if r.finalizer != nil {
-		r.setFinalizer(ns.Object)
+	} else {
+		if r.finalizer != nil {
+			r.setFinalizer(ns.Object)
+		}
+		if len(tracked) > 0 {
+			addFinalizer(ns.Object, r.getTrackingFinalizerName())
+		}
 	}
 
-	created, updated, deleted := s.Diff(ns)
+	created, updated, deleted := s.Diff(ns, r.crossNamespaceKeys()...)
 
 	for _, res := range created {
 		log.Info("Creating resource", "kind", res.GetKind(), "namespace", res.GetNamespace(), "name", res.GetName())
 
-		err = r.Create(context.TODO(), res)
+		err = r.create(ctx, res)
 		if err != nil {
 			log.Error(err, "Failed to create a resource", "namespace", res.GetNamespace(), "name", res.GetName())
-			return reconcile.Result{}, err
+			return reconcile.Result{}, newApplyError(err)
 		}
 	}
 
 	for _, res := range updated {
 		log.Info("Updating resource", "kind", res.GetKind(), "namespace", res.GetNamespace(), "name", res.GetName())
 
-		err = r.Update(context.TODO(), res)
+		if res == ns.Object {
+			err = r.updateObject(ctx, s.Object, res)
+		} else {
+			err = r.update(ctx, res)
+		}
 		if err != nil {
 			log.Error(err, "Failed to update a resource", "namespace", res.GetNamespace(), "name", res.GetName())
-			return reconcile.Result{}, err
+			return reconcile.Result{}, newApplyError(err)
 		}
 	}
 
 	for _, res := range deleted {
 		log.Info("Deleting resource", "kind", res.GetKind(), "namespace", res.GetNamespace(), "name", res.GetName())
 
-		err = r.Delete(context.TODO(), res)
+		err = r.delete(ctx, res)
 		if err != nil {
 			log.Error(err, "Failed to delete a resource", "namespace", res.GetNamespace(), "name", res.GetName())
-			return reconcile.Result{}, err
+			return reconcile.Result{}, newApplyError(err)
 		}
 	}
 
+	err = r.applyObjects(ctx, ns)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
 	for _, ev := range ns.Events {
 		err := ev.Validate()
 		if err != nil {
-			log.Info("Ignored event due to the event is invalid", "namespace", namespace, "name", name, "error", err.Error())
+			l.Info("Ignored event due to the event is invalid", "error", err.Error())
 			continue
 		}
 		r.recorder.Event(instance, ev.Type, ev.Reason, ev.Message)
@@ -200,9 +526,125 @@ func (r *Reconciler) Reconcile(req reconcile.Request) (reconcile.Result, error)
 	return result, nil
 }
 
+// objectLog returns a logger with the identifier of specified object.
+// If the key field is configured and found in the object, its value is
+// used instead of the namespace and name.
+func (r *Reconciler) objectLog(obj *unstructured.Unstructured) logr.Logger {
+	if len(r.keyField) > 0 {
+		key, ok, err := unstructured.NestedFieldNoCopy(obj.Object, r.keyField...)
+		if err == nil && ok {
+			return log.WithValues("key", fmt.Sprint(key))
+		}
+	}
+
+	return log.WithValues("namespace", obj.GetNamespace(), "name", obj.GetName())
+}
+
+// newContext returns a context for a reconcile. The context has
+// a deadline if the timeout of reconciler is configured, and is
+// cancelled when the manager stops.
+func (r *Reconciler) newContext() (context.Context, context.CancelFunc) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	if r.timeout == 0 {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), r.timeout)
+	}
+
+	if r.stop != nil {
+		go func() {
+			select {
+			case <-r.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	return ctx, cancel
+}
+
+// handleFailure records a reconcile failure of specified object.
+// Once the object fails maxRetries times in a row, it emits a warning
+// event and stops requeueing the object until the object is changed.
+func (r *Reconciler) handleFailure(res *unstructured.Unstructured, err error) (reconcile.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nn := types.NamespacedName{Namespace: res.GetNamespace(), Name: res.GetName()}
+	f, ok := r.failures[nn]
+	if !ok || f.resourceVersion != res.GetResourceVersion() {
+		f = &failure{resourceVersion: res.GetResourceVersion()}
+		r.failures[nn] = f
+	}
+
+	f.count++
+	if r.maxRetries == 0 || f.count < r.maxRetries {
+		return reconcile.Result{}, err
+	}
+
+	if f.count == r.maxRetries {
+		log.Info("Giving up reconciling a resource", "namespace", nn.Namespace, "name", nn.Name, "retries", f.count)
+		msg := fmt.Sprintf("Reconcile failed %d times in a row: %v", f.count, err)
+		r.recorder.Event(res, "Warning", "ReconcileFailed", msg)
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// setStatusError writes the error message to the status error field
+// of specified object.
+func (r *Reconciler) setStatusError(res *unstructured.Unstructured, reconcileErr error) {
+	if len(r.statusError) == 0 {
+		return
+	}
+
+	msg := reconcileErr.Error()
+	current, ok, _ := unstructured.NestedString(res.Object, r.statusError...)
+	if ok && current == msg {
+		return
+	}
+
+	original := res.DeepCopy()
+	err := unstructured.SetNestedField(res.Object, msg, r.statusError...)
+	if err != nil {
+		log.Error(err, "Failed to set status error", "namespace", res.GetNamespace(), "name", res.GetName())
+		return
+	}
+
+	err = r.updateObject(context.TODO(), original, res)
+	if err != nil {
+		log.Error(err, "Failed to update status error", "namespace", res.GetNamespace(), "name", res.GetName())
+	}
+}
+
+// unsetStatusError removes the status error field from specified object.
+func (r *Reconciler) unsetStatusError(res *unstructured.Unstructured) {
+	if res == nil || len(r.statusError) == 0 {
+		return
+	}
+
+	unstructured.RemoveNestedField(res.Object, r.statusError...)
+}
+
+// resetFailure clears the reconcile failures of specified object.
+func (r *Reconciler) resetFailure(nn types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.failures, nn)
+	delete(r.quotas, nn)
+	delete(r.errorEventTimes, nn)
+}
+
 func (r *Reconciler) Observe(req reconcile.Request) (reconcile.Result, error) {
 	namespace := req.Namespace
 	name := req.Name
+	trigger := r.popTrigger(req.NamespacedName)
 
 	instance := &unstructured.Unstructured{}
 	instance.SetGroupVersionKind(r.config.GroupVersionKind)
@@ -213,12 +655,25 @@ func (r *Reconciler) Observe(req reconcile.Request) (reconcile.Result, error) {
 		return reconcile.Result{}, nil
 	}
 
+	if err == nil {
+		ok, err := r.matchPredicate(instance)
+		if err != nil {
+			log.Error(err, "Failed to evaluate the predicate", "namespace", namespace, "name", name)
+			return reconcile.Result{}, nil
+		}
+		if !ok {
+			return reconcile.Result{}, nil
+		}
+	}
+
 	// This allows determination of deleted resources
 	instance.SetNamespace(namespace)
 	instance.SetName(name)
 
 	s := &state.State{
-		Object: instance,
+		Object:     instance,
+		Trigger:    trigger,
+		Controller: r.controllerInfo(),
 	}
 
 	err = r.handler.HandleState(s)
@@ -230,26 +685,69 @@ func (r *Reconciler) Observe(req reconcile.Request) (reconcile.Result, error) {
 	return reconcile.Result{}, nil
 }
 
+// matchPredicate returns whether specified object matches the
+// predicate. All objects match if the predicate is not configured.
+func (r *Reconciler) matchPredicate(obj *unstructured.Unstructured) (bool, error) {
+	if r.predicate == nil {
+		return true, nil
+	}
+
+	return r.predicate.EvalBool(map[string]interface{}{config.PredicateObjectVar: obj.Object})
+}
+
+// controllerInfo returns the information of the controller that is
+// passed to the handler.
+func (r *Reconciler) controllerInfo() *state.Controller {
+	return &state.Controller{
+		Name:    r.name,
+		Group:   r.config.Group,
+		Version: r.config.Version,
+		Kind:    r.config.Kind,
+	}
+}
+
 func (r *Reconciler) IsObserver() bool {
 	return r.config.Reconciler.Observe
 }
 
+// ResultSink returns the runnable that sends the result records to the
+// result sink, or nil if the result sink is not configured. It must be
+// started for the records to be sent.
+func (r *Reconciler) ResultSink() manager.Runnable {
+	if r.resultSink == nil {
+		return nil
+	}
+
+	return r.resultSink
+}
+
 // getDependents returns a list of dependent resources with
 // an specified owner reference.
-func (r *Reconciler) getDependents(res *unstructured.Unstructured) (map[string][]*unstructured.Unstructured, error) {
+func (r *Reconciler) getDependents(ctx context.Context, res *unstructured.Unstructured) (map[string][]*unstructured.Unstructured, error) {
 	dependents := map[string][]*unstructured.Unstructured{}
-	ownerRef := metav1.NewControllerRef(res, res.GroupVersionKind())
 
 	for _, dep := range r.config.Dependents {
+		ownerRef := newOwnerReference(res, dep.IsControllerOwnerRef())
 		key := state.ResourceKey(dep.GroupVersionKind)
 		dependents[key] = []*unstructured.Unstructured{}
 
+		if dep.TrackingLabels {
+			deps, err := r.listTrackedDependents(ctx, res, dep.GroupVersionKind)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get a list for dependent resource: %v", err)
+			}
+			dependents[key] = deps
+			continue
+		}
+
 		gvk := dep.GroupVersionKind
 		gvk.Kind = gvk.Kind + "List"
 		dependentList := &unstructured.UnstructuredList{}
 		dependentList.SetGroupVersionKind(gvk)
 
-		err := r.List(context.TODO(), dependentList, client.InNamespace(res.GetNamespace()))
+		// Dependents of cluster-scoped resource are listed from all
+		// namespaces since the namespace of it is empty.
+		err := r.List(ctx, dependentList, client.InNamespace(res.GetNamespace()))
 		if err != nil {
 			return nil, fmt.Errorf("Failed to get a list for dependent resource: %v", err)
 		}
@@ -268,10 +766,29 @@ func (r *Reconciler) getDependents(res *unstructured.Unstructured) (map[string][
 	return dependents, nil
 }
 
+// crossNamespaceKeys returns the resource keys of the dependents that
+// can be in other namespaces than the object. Dependents of
+// cluster-scoped resource can be in any namespace or cluster-scoped.
+func (r *Reconciler) crossNamespaceKeys() []string {
+	if !r.config.ClusterScoped {
+		return r.trackedKeys()
+	}
+
+	keys := []string{}
+	for _, dep := range r.config.Dependents {
+		keys = append(keys, state.ResourceKey(dep.GroupVersionKind))
+	}
+
+	return keys
+}
+
 // getReferences returns a list of reference resources based on
-// spcified field path.
-func (r *Reconciler) getReferences(res *unstructured.Unstructured) (map[string][]*unstructured.Unstructured, error) {
+// spcified field path. The resources are resolved concurrently by up
+// to maxReferenceWorkers workers, and are returned in the order of
+// their names in the object.
+func (r *Reconciler) getReferences(ctx context.Context, res *unstructured.Unstructured) (map[string][]*unstructured.Unstructured, error) {
 	refs := map[string][]*unstructured.Unstructured{}
+	lookups := []*referenceLookup{}
 
 	for _, ref := range r.config.References {
 		if ref.NameFieldPath == "" {
@@ -286,82 +803,164 @@ func (r *Reconciler) getReferences(res *unstructured.Unstructured) (map[string][
 			return nil, fmt.Errorf("failed to get reference name list: %v", err)
 		}
 
-		if len(refNames) == 0 {
-			continue
+		namespace := res.GetNamespace()
+		if ref.Namespace != "" {
+			namespace = ref.Namespace
 		}
 
 		for i := range refNames {
-			refRes := &unstructured.Unstructured{}
-			refRes.SetGroupVersionKind(ref.GroupVersionKind)
+			lookups = append(lookups, &referenceLookup{
+				key:      key,
+				gvk:      ref.GroupVersionKind,
+				required: ref.Required,
+				nn: types.NamespacedName{
+					Namespace: namespace,
+					Name:      refNames[i],
+				},
+			})
+		}
+	}
 
-			nn := types.NamespacedName{
-				Namespace: res.GetNamespace(),
-				Name:      refNames[i],
-			}
-			err = r.Get(context.TODO(), nn, refRes)
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					continue
-				}
-				return nil, fmt.Errorf("failed to get a resource '%s/%s': %v", res.GetNamespace(), refNames[i], err)
-			}
+	r.resolveReferences(ctx, lookups)
 
-			refs[key] = append(refs[key], refRes)
+	errs := []error{}
+	for _, l := range lookups {
+		if l.err != nil {
+			if apierrors.IsNotFound(l.err) && !l.required {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("failed to get a resource '%s/%s': %v", l.nn.Namespace, l.nn.Name, l.err))
+			continue
 		}
+
+		refs[l.key] = append(refs[l.key], l.object)
+	}
+
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
 	}
 
 	return refs, nil
 }
 
-// setFinalizer adds it's finalizer name to resource's metadata.
-func (r *Reconciler) setFinalizer(res *unstructured.Unstructured) {
-	if res == nil {
-		return
+// referenceLookup represents a reference resource to resolve and the
+// result of it.
+type referenceLookup struct {
+	key      string
+	gvk      schema.GroupVersionKind
+	nn       types.NamespacedName
+	required bool
+
+	object *unstructured.Unstructured
+	err    error
+}
+
+// resolveReferences resolves specified reference resources concurrently
+// and stores the results to each lookup.
+func (r *Reconciler) resolveReferences(ctx context.Context, lookups []*referenceLookup) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxReferenceWorkers)
+
+	for _, l := range lookups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(l *referenceLookup) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			l.object, l.err = r.getReference(ctx, l.gvk, l.nn)
+		}(l)
 	}
 
-	finalizers := res.GetFinalizers()
-	name := r.getFinalizerName()
+	wg.Wait()
+}
 
-	exist := false
-	for i := range finalizers {
-		if finalizers[i] == name {
-			exist = true
+// getReference returns the reference resource of specified name. If
+// the reference cache is enabled, the cached resource is returned.
+func (r *Reconciler) getReference(ctx context.Context, gvk schema.GroupVersionKind, nn types.NamespacedName) (*unstructured.Unstructured, error) {
+	if r.refCache != nil {
+		cached := r.refCache.get(gvk, nn)
+		if cached != nil {
+			return cached, nil
 		}
 	}
 
-	if !exist {
-		finalizers = append(finalizers, name)
-		res.SetFinalizers(finalizers)
+	res := &unstructured.Unstructured{}
+	res.SetGroupVersionKind(gvk)
+
+	err := r.Get(ctx, nn, res)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.refCache != nil {
+		r.refCache.set(res)
 	}
+
+	return res, nil
+}
+
+// InvalidateReference removes specified resource from the reference
+// cache if it has been changed.
+func (r *Reconciler) InvalidateReference(res *unstructured.Unstructured) {
+	if r.refCache == nil {
+		return
+	}
+
+	r.refCache.invalidate(res)
+}
+
+// setFinalizer adds it's finalizer name to resource's metadata.
+func (r *Reconciler) setFinalizer(res *unstructured.Unstructured) {
+	addFinalizer(res, r.getFinalizerName())
 }
 
 // unsetFinalizer removes it's finalizer name from resource's metadata.
 func (r *Reconciler) unsetFinalizer(res *unstructured.Unstructured) {
-	if res == nil {
+	removeFinalizer(res, r.getFinalizerName())
+}
+
+// getFinalizerName returns controller's finalizer name.
+func (r *Reconciler) getFinalizerName() string {
+	return fmt.Sprintf("%s-controller.%s", strings.ToLower(r.config.Kind), r.config.Group)
+}
+
+// addFinalizer adds specified finalizer name to resource's metadata.
+func addFinalizer(res *unstructured.Unstructured, name string) {
+	if res == nil || hasFinalizer(res, name) {
 		return
 	}
 
-	finalizers := res.GetFinalizers()
-	name := r.getFinalizerName()
+	res.SetFinalizers(append(res.GetFinalizers(), name))
+}
+
+// removeFinalizer removes specified finalizer name from resource's
+// metadata.
+func removeFinalizer(res *unstructured.Unstructured, name string) {
+	if res == nil || !hasFinalizer(res, name) {
+		return
+	}
 
 	list := []string{}
-	exist := false
-	for i := range finalizers {
-		if finalizers[i] == name {
-			exist = true
-			continue
+	for _, f := range res.GetFinalizers() {
+		if f != name {
+			list = append(list, f)
 		}
-		list = append(list, finalizers[i])
 	}
 
-	if exist {
-		res.SetFinalizers(list)
-	}
+	res.SetFinalizers(list)
 }
 
-// getFinalizerName returns controller's finalizer name.
-func (r *Reconciler) getFinalizerName() string {
-	return fmt.Sprintf("%s-controller.%s", strings.ToLower(r.config.Kind), r.config.Group)
+// hasFinalizer returns whether the resource has specified finalizer.
+func hasFinalizer(res *unstructured.Unstructured, name string) bool {
+	for _, f := range res.GetFinalizers() {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
 }
 
 // validateState validates specified state.
@@ -399,21 +998,37 @@ func (r *Reconciler) validateState(s, ns *state.State) error {
 		}
 	}
 
-	return nil
+	return r.validateObjects(s, ns)
+}
+
+// validateOutput validates the object of specified state with the
+// output schema.
+func (r *Reconciler) validateOutput(ns *state.State) error {
+	if r.outputSchema == nil || ns.Object == nil {
+		return nil
+	}
+
+	return r.outputSchema.Validate(ns.Object.Object)
 }
 
-// setOwnerReference sets OwnerReference to dependent resources.
+// setOwnerReference sets OwnerReference to dependent resources. The
+// dependents tracked by labels have the labels of the owner instead.
 func (r *Reconciler) setOwnerReference(s *state.State) {
 	if s.Object == nil {
 		return
 	}
 
-	ownerRef := metav1.NewControllerRef(s.Object, s.Object.GroupVersionKind())
-
 	orphans := map[string]struct{}{}
+	plain := map[string]struct{}{}
+	tracked := map[string]struct{}{}
 	for _, dep := range r.config.Dependents {
+		key := state.ResourceKey(dep.GroupVersionKind)
 		if dep.Orphan {
-			orphans[state.ResourceKey(dep.GroupVersionKind)] = struct{}{}
+			orphans[key] = struct{}{}
+		} else if dep.TrackingLabels {
+			tracked[key] = struct{}{}
+		} else if !dep.IsControllerOwnerRef() {
+			plain[key] = struct{}{}
 		}
 	}
 
@@ -423,12 +1038,35 @@ func (r *Reconciler) setOwnerReference(s *state.State) {
 			continue
 		}
 
+		_, ok = tracked[key]
+		if ok {
+			for _, dep := range deps {
+				setTrackingLabels(s.Object, dep)
+			}
+			continue
+		}
+
+		_, ok = plain[key]
+		ownerRef := newOwnerReference(s.Object, !ok)
+
 		for _, dep := range deps {
 			dep.SetOwnerReferences([]metav1.OwnerReference{*ownerRef})
 		}
 	}
 }
 
+// newOwnerReference returns an owner reference of specified owner.
+// If controller is false, the reference is not a controller reference.
+func newOwnerReference(owner *unstructured.Unstructured, controller bool) *metav1.OwnerReference {
+	ref := metav1.NewControllerRef(owner, owner.GroupVersionKind())
+	if !controller {
+		isController := false
+		ref.Controller = &isController
+	}
+
+	return ref
+}
+
 // getReferenceNames returns a list of reference resource names based
 // on JSON Path and resource.
 func getReferenceNames(res *unstructured.Unstructured, namePath string) ([]string, error) {
@@ -445,7 +1083,9 @@ func getReferenceNames(res *unstructured.Unstructured, namePath string) ([]strin
 		return []string{}, err
 	}
 
-	nameMap := map[string]bool{}
+	// Names are returned in the order of appearance without duplicates.
+	names := []string{}
+	seen := map[string]bool{}
 	for x := range results {
 		for _, v := range results[x] {
 			val, ok := template.PrintableValue(v)
@@ -455,13 +1095,14 @@ func getReferenceNames(res *unstructured.Unstructured, namePath string) ([]strin
 
 			var buf bytes.Buffer
 			fmt.Fprint(&buf, val)
-			nameMap[buf.String()] = true
-		}
-	}
 
-	names := []string{}
-	for key, _ := range nameMap {
-		names = append(names, key)
+			name := buf.String()
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
 
 	return names, nil
@@ -2,6 +2,7 @@ package reconciler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
 	. "github.com/onsi/gomega"
 	. "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -26,6 +28,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+	"github.com/summerwind/whitebox-controller/reconciler/cel"
+	"github.com/summerwind/whitebox-controller/reconciler/jsonschema"
 	"github.com/summerwind/whitebox-controller/reconciler/state"
 )
 
@@ -186,6 +191,52 @@ func TestReconcile(t *testing.T) {
 	Expect(len(recorder.Events)).To(Equal(1))
 }
 
+func TestReconcileWithTrigger(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	recorder := record.NewFakeRecorder(32)
+	r, err := New(rc, recorder)
+	Expect(err).NotTo(HaveOccurred())
+
+	c := newClient()
+	r.InjectClient(c)
+
+	// Create target object
+	object := newObject(rc.GroupVersionKind, "test")
+	err = r.Create(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	defer r.Delete(context.TODO(), object)
+
+	// Enable test handler
+	h := &testHandler{}
+	r.handler = h
+
+	// Set reconcile handler
+	triggers := []string{}
+	h.Func = func(s *state.State) error {
+		triggers = append(triggers, s.Trigger)
+		return nil
+	}
+
+	// Run reconcile function
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+		},
+	}
+	r.SetTrigger(req.NamespacedName, TriggerCreate)
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+
+	// Reconcile without trigger
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+
+	Expect(triggers).To(Equal([]string{TriggerCreate, TriggerRequeue}))
+}
+
 func TestReconcileWithFinalizer(t *testing.T) {
 	RegisterTestingT(t)
 
@@ -412,6 +463,170 @@ func TestReconcileWithHandlerError(t *testing.T) {
 	Expect(err).To(HaveOccurred())
 }
 
+func TestReconcileWithHandlerPanic(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testHandler{
+		Func: func(s *state.State) error {
+			panic("handler panic")
+		},
+	}
+
+	rc := newResourceConfig()
+	rc.Reconciler.StateHandler = h
+
+	recorder := record.NewFakeRecorder(32)
+	r, err := New(rc, recorder)
+	Expect(err).NotTo(HaveOccurred())
+
+	c := newClient()
+	r.InjectClient(c)
+
+	// Create target object
+	object := newObject(rc.GroupVersionKind, "test")
+	err = r.Create(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	defer r.Delete(context.TODO(), object)
+
+	// Run reconcile function
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+		},
+	}
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Expect(errors.Is(err, handler.ErrPanic)).To(BeTrue())
+	Expect(errorReason(err)).To(Equal(ReasonHandlerError))
+}
+
+func TestReconcileWithMaxRetries(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Reconciler.MaxRetries = 2
+	recorder := record.NewFakeRecorder(32)
+	r, err := New(rc, recorder)
+	Expect(err).NotTo(HaveOccurred())
+
+	c := newClient()
+	r.InjectClient(c)
+
+	// Create target object
+	object := newObject(rc.GroupVersionKind, "test")
+	err = r.Create(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	defer r.Delete(context.TODO(), object)
+
+	// Enable test handler
+	h := &testHandler{}
+	r.handler = h
+
+	// Set reconcile handler
+	h.Func = func(s *state.State) error {
+		return errors.New("handler error")
+	}
+
+	// Run reconcile function
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+		},
+	}
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+	Expect(recorder.Events).To(HaveLen(0))
+
+	// Give up and record an event
+	result, err := r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(result.Requeue).To(BeFalse())
+	Expect(result.RequeueAfter).To(BeZero())
+	Expect(recorder.Events).To(HaveLen(1))
+	Expect(<-recorder.Events).To(HavePrefix("Warning ReconcileFailed"))
+
+	// No more events until the object is changed
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(recorder.Events).To(HaveLen(0))
+
+	// Retry after the object is changed
+	err = r.Get(context.TODO(), req.NamespacedName, object)
+	Expect(err).NotTo(HaveOccurred())
+	SetNestedField(object.Object, "updated", "spec", "message")
+	err = r.Update(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+}
+
+func TestReconcileWithStatusError(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Reconciler.StatusErrorField = ".status.lastError"
+	recorder := record.NewFakeRecorder(32)
+	r, err := New(rc, recorder)
+	Expect(err).NotTo(HaveOccurred())
+
+	c := newClient()
+	r.InjectClient(c)
+
+	// Create target object
+	object := newObject(rc.GroupVersionKind, "test")
+	err = r.Create(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	defer r.Delete(context.TODO(), object)
+
+	// Enable test handler
+	h := &testHandler{}
+	r.handler = h
+
+	// Set reconcile handler
+	h.Func = func(s *state.State) error {
+		return errors.New("handler error")
+	}
+
+	// Run reconcile function
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+		},
+	}
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+
+	// Test status error
+	o := &Unstructured{}
+	o.SetGroupVersionKind(object.GroupVersionKind())
+	err = c.Get(context.TODO(), req.NamespacedName, o)
+	Expect(err).NotTo(HaveOccurred())
+
+	msg, ok, err := NestedString(o.Object, "status", "lastError")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ok).To(BeTrue())
+	Expect(msg).To(ContainSubstring("handler error"))
+
+	// Succeed on the next reconcile
+	h.Func = nil
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+
+	// Test cleared status error
+	o = &Unstructured{}
+	o.SetGroupVersionKind(object.GroupVersionKind())
+	err = c.Get(context.TODO(), req.NamespacedName, o)
+	Expect(err).NotTo(HaveOccurred())
+
+	_, ok, err = NestedString(o.Object, "status", "lastError")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ok).To(BeFalse())
+}
+
 func TestReconcileWithInvalidState(t *testing.T) {
 	RegisterTestingT(t)
 
@@ -518,7 +733,7 @@ func TestGetDependents(t *testing.T) {
 	Expect(err).NotTo(HaveOccurred())
 	defer c.Delete(context.TODO(), p2)
 
-	deps, err := r.getDependents(object)
+	deps, err := r.getDependents(context.TODO(), object)
 	Expect(err).NotTo(HaveOccurred())
 	Expect(len(deps["pod.v1"])).To(Equal(1))
 }
@@ -555,7 +770,7 @@ func TestGetReferences(t *testing.T) {
 	for _, test := range tests {
 		rc.References[0].NameFieldPath = test.nameFieldPath
 
-		refs, err := r.getReferences(object)
+		refs, err := r.getReferences(context.TODO(), object)
 		if test.err {
 			Expect(err).To(HaveOccurred())
 		} else {
@@ -563,6 +778,18 @@ func TestGetReferences(t *testing.T) {
 			Expect(len(refs["configmap.v1"])).To(Equal(test.length))
 		}
 	}
+
+	// Missing required reference
+	rc.References[0].Required = true
+
+	rc.References[0].NameFieldPath = ".spec.configMapRefs[0]"
+	refs, err := r.getReferences(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(len(refs["configmap.v1"])).To(Equal(1))
+
+	rc.References[0].NameFieldPath = ".spec.configMapRefs[1]"
+	_, err = r.getReferences(context.TODO(), object)
+	Expect(err).To(HaveOccurred())
 }
 
 func TestSetFinalizer(t *testing.T) {
@@ -675,8 +902,37 @@ func TestSetOwnerReference(t *testing.T) {
 			Expect(len(ownerRefs)).To(Equal(1))
 			Expect(ownerRefs[0].Name).To(Equal(s.Object.GetName()))
 			Expect(ownerRefs[0].UID).To(Equal(s.Object.GetUID()))
+			Expect(*ownerRefs[0].Controller).To(BeTrue())
 		}
 	}
+
+	// Plain owner reference
+	controller := false
+	rc.Dependents[0].ControllerOwnerRef = &controller
+
+	s = newState(rc)
+	r.setOwnerReference(s)
+
+	deps := s.Dependents[state.ResourceKey(rc.Dependents[0].GroupVersionKind)]
+	Expect(deps).NotTo(BeEmpty())
+	for _, dep := range deps {
+		ownerRefs := dep.GetOwnerReferences()
+		Expect(len(ownerRefs)).To(Equal(1))
+		Expect(ownerRefs[0].UID).To(Equal(s.Object.GetUID()))
+		Expect(*ownerRefs[0].Controller).To(BeFalse())
+	}
+
+	// Orphan
+	rc.Dependents[0].ControllerOwnerRef = nil
+	rc.Dependents[0].Orphan = true
+
+	s = newState(rc)
+	r.setOwnerReference(s)
+
+	deps = s.Dependents[state.ResourceKey(rc.Dependents[0].GroupVersionKind)]
+	for _, dep := range deps {
+		Expect(dep.GetOwnerReferences()).To(BeEmpty())
+	}
 }
 
 func TestGetReferenceNames(t *testing.T) {
@@ -714,12 +970,189 @@ func TestIsDeleting(t *testing.T) {
 	rc := newResourceConfig()
 	object := newObject(rc.GroupVersionKind, "test")
 	deleting := newObject(rc.GroupVersionKind, "test")
-	SetNestedField(deleting.Object, string(time.Now().Unix()), "metadata", "deletionTimestamp")
+	SetNestedField(deleting.Object, time.Now().Format(time.RFC3339), "metadata", "deletionTimestamp")
 
 	Expect(isDeleting(object)).To(BeFalse())
 	Expect(isDeleting(deleting)).To(BeTrue())
 }
 
+func TestReconcileWithRequeueAfterOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	var output string
+
+	rc := newResourceConfig()
+	static := 30 * time.Second
+	r := &Reconciler{
+		Client: newClient(),
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				return json.Unmarshal([]byte(output), s)
+			},
+		},
+		recorder:     record.NewFakeRecorder(32),
+		requeueAfter: &static,
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	tests := []struct {
+		output       string
+		requeueAfter time.Duration
+	}{
+		{`{"requeueAfter":"5m"}`, 5 * time.Minute},
+		{`{"requeueAfter":"500ms"}`, time.Second},
+		{`{"requeueAfter":60}`, 60 * time.Second},
+		{`{"requeueAfter":"soon"}`, static},
+		{`{}`, static},
+	}
+
+	for _, test := range tests {
+		output = test.output
+		result, err := r.reconcile(context.TODO(), object, "update")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(test.requeueAfter))
+	}
+}
+
+func TestReconcileWithClusterScoped(t *testing.T) {
+	RegisterTestingT(t)
+
+	nsGVK := schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	cmGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Cluster"},
+		ClusterScoped:    true,
+		Dependents: []config.DependentConfig{
+			{GroupVersionKind: nsGVK},
+			{GroupVersionKind: cmGVK},
+		},
+		Reconciler: &config.ReconcilerConfig{},
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+	object.SetNamespace("")
+
+	c := &testTrackingClient{objects: []*Unstructured{object}}
+	r := newTestReconciler(rc, c, &testHandler{
+		Func: func(s *state.State) error {
+			ns := &Unstructured{}
+			ns.SetGroupVersionKind(nsGVK)
+			ns.SetName("test")
+			s.Dependents[state.ResourceKey(nsGVK)] = []*Unstructured{ns}
+
+			cm := &Unstructured{}
+			cm.SetGroupVersionKind(cmGVK)
+			cm.SetNamespace("test")
+			cm.SetName("test")
+			s.Dependents[state.ResourceKey(cmGVK)] = []*Unstructured{cm}
+			return nil
+		},
+	}, nil)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Both the cluster-scoped and namespaced dependents are created
+	Expect(c.created).To(HaveLen(2))
+	namespaces := []string{}
+	for _, dep := range c.created {
+		Expect(dep.GetOwnerReferences()).To(HaveLen(1))
+		Expect(dep.GetOwnerReferences()[0].UID).To(Equal(object.GetUID()))
+		namespaces = append(namespaces, dep.GetNamespace())
+	}
+	Expect(namespaces).To(ConsistOf("", "test"))
+}
+
+func TestReconcileWithOutputSchema(t *testing.T) {
+	RegisterTestingT(t)
+
+	var replicas interface{}
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	schema, err := jsonschema.Compile(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"replicas"},
+				"properties": map[string]interface{}{
+					"replicas": map[string]interface{}{"type": "integer"},
+				},
+			},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	c := &testTrackingClient{}
+	rec := record.NewFakeRecorder(32)
+	r := &Reconciler{
+		Client: c,
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				return SetNestedField(s.Object.Object, replicas, "spec", "replicas")
+			},
+		},
+		recorder:     rec,
+		outputSchema: schema,
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	// Conforming output
+	replicas = int64(3)
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.updated).To(HaveLen(1))
+
+	// Non-conforming output is not applied
+	replicas = "three"
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).To(HaveOccurred())
+	Expect(errorReason(err)).To(Equal(ReasonValidation))
+	Expect(err.Error()).To(ContainSubstring(".spec.replicas: must be integer"))
+	Expect(c.updated).To(HaveLen(1))
+	Expect(<-rec.Events).To(ContainSubstring("InvalidOutput"))
+}
+
+func TestReconcileWithKeyField(t *testing.T) {
+	RegisterTestingT(t)
+
+	tl := &testLogger{}
+	defer func(l logr.Logger) { log = l }(log)
+	log = tl
+
+	rc := newResourceConfig()
+	r := &Reconciler{
+		config:       rc,
+		handler:      &testHandler{},
+		skipDeletion: true,
+		keyField:     []string{"spec", "orderId"},
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+	SetNestedField(object.Object, time.Now().UTC().Format(time.RFC3339), "metadata", "deletionTimestamp")
+
+	// Key field is found
+	SetNestedField(object.Object, "order-1234", "spec", "orderId")
+	_, err := r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(tl.messages).To(Equal([]string{"Skipping a resource being deleted"}))
+	Expect(tl.values[0]).To(Equal([]interface{}{"key", "order-1234"}))
+
+	// Key field is not found
+	RemoveNestedField(object.Object, "spec", "orderId")
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(tl.values[1]).To(Equal([]interface{}{"namespace", object.GetNamespace(), "name", "test"}))
+}
+
 type testHandler struct {
 	Func func(*state.State) error
 }
@@ -731,6 +1164,42 @@ func (h *testHandler) HandleState(s *state.State) error {
 	return nil
 }
 
+// testLogger records the messages and the values of the logger.
+type testLogger struct {
+	logr.Logger
+	messages []string
+	values   [][]interface{}
+}
+
+func (l *testLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.messages = append(l.messages, msg)
+	l.values = append(l.values, keysAndValues)
+}
+
+func (l *testLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Info(msg, keysAndValues...)
+}
+
+func (l *testLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &testValuesLogger{parent: l, values: keysAndValues}
+}
+
+// testValuesLogger is a logger that records the messages with its
+// values to the parent.
+type testValuesLogger struct {
+	logr.Logger
+	parent *testLogger
+	values []interface{}
+}
+
+func (l *testValuesLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.parent.Info(msg, append(l.values, keysAndValues...)...)
+}
+
+func (l *testValuesLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.parent.Error(err, msg, append(l.values, keysAndValues...)...)
+}
+
 func newClient() client.Client {
 	cl, err := client.New(kconfig, client.Options{})
 	Expect(err).NotTo(HaveOccurred())
@@ -738,6 +1207,25 @@ func newClient() client.Client {
 	return cl
 }
 
+// newTestReconciler returns a reconciler built by New with specified
+// client and handler. The recorder defaults to a fake recorder.
+func newTestReconciler(rc *config.ResourceConfig, c client.Client, h handler.StateHandler, rec record.EventRecorder) *Reconciler {
+	if rc.Reconciler == nil {
+		rc.Reconciler = &config.ReconcilerConfig{}
+	}
+	rc.Reconciler.StateHandler = h
+
+	if rec == nil {
+		rec = record.NewFakeRecorder(32)
+	}
+
+	r, err := New(rc, rec)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(r.InjectClient(c)).To(Succeed())
+
+	return r
+}
+
 func newResourceConfig() *config.ResourceConfig {
 	return &config.ResourceConfig{
 		GroupVersionKind: schema.GroupVersionKind{
@@ -875,3 +1363,169 @@ func newCRD(kind string) *apiextensionsv1beta1.CustomResourceDefinition {
 		},
 	}
 }
+
+func TestReconcileWithControllerInfo(t *testing.T) {
+	RegisterTestingT(t)
+
+	var input *state.Controller
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	r := &Reconciler{
+		Client: &testTrackingClient{},
+		name:   "test-controller",
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				input = s.Controller
+				return nil
+			},
+		},
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	_, err := r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(input).To(Equal(&state.Controller{
+		Name:    "test-controller",
+		Group:   rc.Group,
+		Version: rc.Version,
+		Kind:    rc.Kind,
+	}))
+}
+
+func TestReconcileWithPredicate(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	predicate, err := cel.Compile("object.spec.replicas > 0", config.PredicateObjectVar)
+	Expect(err).NotTo(HaveOccurred())
+
+	called := false
+	r := &Reconciler{
+		Client: &testTrackingClient{},
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				called = true
+				return nil
+			},
+		},
+		recorder:  record.NewFakeRecorder(32),
+		predicate: predicate,
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	// Predicate is true
+	SetNestedField(object.Object, int64(1), "spec", "replicas")
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeTrue())
+
+	// Predicate is false
+	called = false
+	SetNestedField(object.Object, int64(0), "spec", "replicas")
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeFalse())
+
+	// Predicate fails
+	RemoveNestedField(object.Object, "spec", "replicas")
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).To(HaveOccurred())
+	Expect(errorReason(err)).To(Equal(ReasonValidation))
+	Expect(called).To(BeFalse())
+}
+
+func TestReconcileWithAdditionalResources(t *testing.T) {
+	RegisterTestingT(t)
+
+	var inputs []*state.State
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+	rc.Reconciler.RequeueAfter = ""
+	rc.Reconciler.StateHandler = &testHandler{
+		Func: func(s *state.State) error {
+			inputs = append(inputs, s)
+			return nil
+		},
+	}
+
+	other := schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Other"}
+	rc.AdditionalResources = []schema.GroupVersionKind{other}
+
+	for _, res := range rc.ReconciledResources() {
+		r, err := New(res, record.NewFakeRecorder(32))
+		Expect(err).NotTo(HaveOccurred())
+		r.InjectClient(&testTrackingClient{})
+
+		_, err = r.reconcile(context.TODO(), newObject(res.GroupVersionKind, "test"), "create")
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// Both kinds are passed to the same handler with the kind
+	Expect(inputs).To(HaveLen(2))
+	Expect(inputs[0].Object.GetKind()).To(Equal("Test"))
+	Expect(inputs[0].Controller.Kind).To(Equal("Test"))
+	Expect(inputs[1].Object.GetKind()).To(Equal("Other"))
+	Expect(inputs[1].Controller).To(Equal(&state.Controller{
+		Name:    "other-controller",
+		Group:   other.Group,
+		Version: other.Version,
+		Kind:    other.Kind,
+	}))
+}
+
+func TestReconcileWithStartupDelay(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	object := newObject(rc.GroupVersionKind, "test")
+	called := make(chan struct{}, 1)
+	started := make(chan struct{})
+
+	rc.Reconciler.Started = started
+	r := newTestReconciler(rc, &testTrackingClient{objects: []*Unstructured{object}}, &testHandler{
+		Func: func(s *state.State) error {
+			called <- struct{}{}
+			return nil
+		},
+	}, nil)
+
+	go r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}})
+
+	// Reconcile does not begin until the delay elapses
+	Consistently(called, 200*time.Millisecond).ShouldNot(Receive())
+
+	close(started)
+	Eventually(called).Should(Receive())
+
+	// Stop of the manager during the delay
+	stop := make(chan struct{})
+	r.started = make(chan struct{})
+	r.InjectStopChannel(stop)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}})
+		errs <- err
+	}()
+	Consistently(errs, 200*time.Millisecond).ShouldNot(Receive())
+
+	close(stop)
+	Eventually(errs).Should(Receive(BeNil()))
+	Expect(called).NotTo(Receive())
+}
@@ -0,0 +1,179 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+var secretGVK = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+
+func TestGetReferencesConcurrently(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"},
+		References: []config.ReferenceConfig{
+			{GroupVersionKind: configMapGVK, NameFieldPath: ".spec.configMapRefs[*]", Required: true},
+			{GroupVersionKind: secretGVK, NameFieldPath: ".spec.secretRefs[*]"},
+		},
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+	unstructured.SetNestedStringSlice(object.Object, []string{"c3", "c1", "c2", "c1"}, "spec", "configMapRefs")
+	unstructured.SetNestedStringSlice(object.Object, []string{"s1", "missing"}, "spec", "secretRefs")
+
+	// All references are resolved at the same time
+	c := &testReferenceClient{wait: 4}
+	r := &Reconciler{Client: c, config: rc}
+
+	refs, err := r.getReferences(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.maxInFlight).To(Equal(5))
+	Expect(referenceNames(refs["configmap.v1"])).To(Equal([]string{"c3", "c1", "c2"}))
+	Expect(referenceNames(refs["secret.v1"])).To(Equal([]string{"s1"}))
+
+	// Errors are aggregated
+	unstructured.SetNestedStringSlice(object.Object, []string{"missing", "c1", "missing-2"}, "spec", "configMapRefs")
+	c = &testReferenceClient{}
+	r.Client = c
+
+	_, err = r.getReferences(context.TODO(), object)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("'default/missing'"))
+	Expect(err.Error()).To(ContainSubstring("'default/missing-2'"))
+}
+
+func TestGetReferencesWithWorkerLimit(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"},
+		References: []config.ReferenceConfig{
+			{GroupVersionKind: configMapGVK, NameFieldPath: ".spec.configMapRefs[*]"},
+		},
+	}
+
+	names := []string{}
+	for i := 0; i < maxReferenceWorkers*3; i++ {
+		names = append(names, fmt.Sprintf("c%d", i))
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+	unstructured.SetNestedStringSlice(object.Object, names, "spec", "configMapRefs")
+
+	c := &testReferenceClient{delay: 10 * time.Millisecond}
+	r := &Reconciler{Client: c, config: rc}
+
+	refs, err := r.getReferences(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(referenceNames(refs["configmap.v1"])).To(Equal(names))
+	Expect(c.maxInFlight).To(BeNumerically("<=", maxReferenceWorkers))
+	Expect(c.maxInFlight).To(BeNumerically(">", 1))
+}
+
+func TestGetReferencesOfClusterScoped(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Cluster"},
+		ClusterScoped:    true,
+		References: []config.ReferenceConfig{
+			{GroupVersionKind: configMapGVK, NameFieldPath: ".spec.configMapRefs[*]", Namespace: "shared"},
+		},
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+	object.SetNamespace("")
+	unstructured.SetNestedStringSlice(object.Object, []string{"c1"}, "spec", "configMapRefs")
+
+	r := &Reconciler{Client: &testReferenceClient{}, config: rc}
+
+	// References are looked up in the specified namespace
+	refs, err := r.getReferences(context.TODO(), object)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(refs["configmap.v1"]).To(HaveLen(1))
+	Expect(refs["configmap.v1"][0].GetNamespace()).To(Equal("shared"))
+}
+
+// testReferenceClient returns the objects of any name except names
+// starting with 'missing', and records the maximum number of
+// concurrent requests. If wait is set, each request waits until the
+// number of concurrent requests exceeds wait.
+type testReferenceClient struct {
+	client.Client
+	wait  int
+	delay time.Duration
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *testReferenceClient) Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+
+	if c.wait > 0 {
+		timeout := time.After(5 * time.Second)
+		for {
+			c.mu.Lock()
+			done := c.maxInFlight > c.wait
+			c.mu.Unlock()
+			if done {
+				break
+			}
+
+			select {
+			case <-timeout:
+				return fmt.Errorf("timed out waiting for concurrent requests")
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+
+	time.Sleep(c.delay)
+
+	if strings.HasPrefix(key.Name, "missing") {
+		return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+	}
+
+	u := obj.(*unstructured.Unstructured)
+	u.SetNamespace(key.Namespace)
+	u.SetName(key.Name)
+
+	return nil
+}
+
+func referenceNames(objs []*unstructured.Unstructured) []string {
+	names := []string{}
+	for _, o := range objs {
+		names = append(names, o.GetName())
+	}
+
+	return names
+}
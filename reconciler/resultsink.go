@@ -0,0 +1,195 @@
+package reconciler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+const (
+	// resultSinkQueueSize is the number of records waiting to be sent.
+	// Records are dropped when the queue is full.
+	resultSinkQueueSize = 100
+	// defaultResultSinkTimeout is the timeout of sending a record.
+	defaultResultSinkTimeout = 10 * time.Second
+)
+
+// Outcomes of reconcile in the result record.
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+// ResultRecord represents the outcome of a reconcile that is sent to
+// the result sink.
+type ResultRecord struct {
+	Time         time.Time       `json:"time"`
+	Controller   string          `json:"controller"`
+	Object       ResultObjectRef `json:"object"`
+	Result       string          `json:"result"`
+	Requeue      bool            `json:"requeue,omitempty"`
+	RequeueAfter string          `json:"requeueAfter,omitempty"`
+	Duration     float64         `json:"duration"`
+	Reason       string          `json:"reason,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// ResultObjectRef represents the object of the reconcile.
+type ResultObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// newResultRecord returns the record of specified outcome of the
+// reconcile. The duration is in seconds.
+func (r *Reconciler) newResultRecord(req reconcile.Request, result reconcile.Result, err error, d time.Duration) *ResultRecord {
+	rec := &ResultRecord{
+		Time:       time.Now().UTC(),
+		Controller: r.name,
+		Object: ResultObjectRef{
+			APIVersion: r.config.GroupVersionKind.GroupVersion().String(),
+			Kind:       r.config.Kind,
+			Namespace:  req.Namespace,
+			Name:       req.Name,
+		},
+		Result:   ResultSuccess,
+		Requeue:  result.Requeue,
+		Duration: d.Seconds(),
+	}
+
+	if result.RequeueAfter > 0 {
+		rec.RequeueAfter = result.RequeueAfter.String()
+	}
+
+	if err != nil {
+		rec.Result = ResultError
+		rec.Reason = errorReason(err)
+		rec.Error = err.Error()
+	}
+
+	return rec
+}
+
+// resultSink sends the result records to the HTTP endpoint in the
+// background, so that reconciles are not blocked by the endpoint. The
+// records are sent while the sink is started as a Runnable of the
+// manager, and the queued records are sent when it is stopped.
+type resultSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	records chan *ResultRecord
+}
+
+// newResultSink returns a result sink. The records are queued until
+// the sink is started.
+func newResultSink(c *config.ResultSinkConfig) (*resultSink, error) {
+	if c.HTTP == nil {
+		return nil, errors.New("sink must be specified")
+	}
+
+	timeout := defaultResultSinkTimeout
+	if c.HTTP.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(c.HTTP.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %v", err)
+		}
+	}
+
+	s := &resultSink{
+		url:     c.HTTP.URL,
+		headers: c.HTTP.Headers,
+		client:  &http.Client{Timeout: timeout},
+		records: make(chan *ResultRecord, resultSinkQueueSize),
+	}
+
+	return s, nil
+}
+
+// send queues specified record. The record is dropped if the queue is
+// full.
+func (s *resultSink) send(rec *ResultRecord) {
+	select {
+	case s.records <- rec:
+	default:
+		log.Info("Dropped a result record since the queue is full", "namespace", rec.Object.Namespace, "name", rec.Object.Name)
+	}
+}
+
+// Start sends the queued records until stop is closed, and then sends
+// the records left in the queue before returning. It implements
+// manager.Runnable interface.
+func (s *resultSink) Start(stop <-chan struct{}) error {
+	for {
+		select {
+		case rec := <-s.records:
+			s.deliver(rec)
+		case <-stop:
+			s.flush()
+			return nil
+		}
+	}
+}
+
+// flush sends the records in the queue.
+func (s *resultSink) flush() {
+	for {
+		select {
+		case rec := <-s.records:
+			s.deliver(rec)
+		default:
+			return
+		}
+	}
+}
+
+// deliver sends specified record and logs the failure.
+func (s *resultSink) deliver(rec *ResultRecord) {
+	err := s.post(rec)
+	if err != nil {
+		log.Error(err, "Failed to send a result record", "namespace", rec.Object.Namespace, "name", rec.Object.Name)
+	}
+}
+
+// post sends specified record to the endpoint.
+func (s *resultSink) post(rec *ResultRecord) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	for key, val := range s.headers {
+		req.Header.Set(key, val)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("invalid status: %s", res.Status)
+	}
+
+	return nil
+}
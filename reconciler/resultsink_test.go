@@ -0,0 +1,128 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithResultSink(t *testing.T) {
+	RegisterTestingT(t)
+
+	records := make(chan *ResultRecord, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		rec := &ResultRecord{}
+		err := json.NewDecoder(r.Body).Decode(rec)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		records <- rec
+	}))
+	defer server.Close()
+
+	sink, err := newResultSink(&config.ResultSinkConfig{
+		HTTP: &config.HTTPSinkConfig{
+			URL:     server.URL,
+			Headers: map[string]string{"Authorization": "Bearer test"},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go sink.Start(stop)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+	rc.Reconciler.RequeueAfter = ""
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	var handlerErr error
+	r := newTestReconciler(rc, &testQuotaClient{object: object}, &testHandler{
+		Func: func(s *state.State) error {
+			s.RequeueAfter = state.Duration(30)
+			return handlerErr
+		},
+	}, nil)
+	r.resultSink = sink
+
+	nn := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	req := reconcile.Request{NamespacedName: nn}
+
+	// Success
+	_, err = r.Reconcile(req)
+	Expect(err).NotTo(HaveOccurred())
+
+	var rec *ResultRecord
+	Eventually(records, 5*time.Second).Should(Receive(&rec))
+	Expect(rec.Controller).To(Equal("test-controller"))
+	Expect(rec.Object).To(Equal(ResultObjectRef{
+		APIVersion: rc.GroupVersionKind.GroupVersion().String(),
+		Kind:       rc.Kind,
+		Namespace:  object.GetNamespace(),
+		Name:       object.GetName(),
+	}))
+	Expect(rec.Result).To(Equal(ResultSuccess))
+	Expect(rec.RequeueAfter).To(Equal("30s"))
+	Expect(rec.Duration).To(BeNumerically(">=", 0))
+	Expect(rec.Error).To(BeEmpty())
+
+	// Error
+	handlerErr = errors.New("handler failed")
+	_, err = r.Reconcile(req)
+	Expect(err).To(HaveOccurred())
+
+	Eventually(records, 5*time.Second).Should(Receive(&rec))
+	Expect(rec.Result).To(Equal(ResultError))
+	Expect(rec.Reason).To(Equal(ReasonHandlerError))
+	Expect(rec.Error).To(Equal("handler failed"))
+}
+
+func TestResultSinkFlushOnStop(t *testing.T) {
+	RegisterTestingT(t)
+
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &ResultRecord{}
+		json.NewDecoder(r.Body).Decode(rec)
+		received <- rec.Object.Name
+	}))
+	defer server.Close()
+
+	sink, err := newResultSink(&config.ResultSinkConfig{
+		HTTP: &config.HTTPSinkConfig{URL: server.URL},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// The records are queued until the sink is started
+	for _, name := range []string{"test1", "test2", "test3"} {
+		sink.send(&ResultRecord{Object: ResultObjectRef{Name: name}})
+	}
+	Consistently(received, 100*time.Millisecond).ShouldNot(Receive())
+
+	// All queued records are sent before the stopped sink returns
+	stop := make(chan struct{})
+	close(stop)
+	err = sink.Start(stop)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(received).To(HaveLen(3))
+}
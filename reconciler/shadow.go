@@ -0,0 +1,123 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+// Results of comparing the output of the shadow handler.
+const (
+	// ShadowMatch means that the shadow handler returned the same state
+	// as the handler.
+	ShadowMatch = "match"
+	// ShadowMismatch means that the shadow handler returned a state
+	// different from the handler.
+	ShadowMismatch = "mismatch"
+	// ShadowError means that the shadow handler returned an error.
+	ShadowError = "error"
+)
+
+var shadowResults = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "whitebox_shadow_results_total",
+		Help: "Total number of shadow handler runs per controller and result",
+	},
+	[]string{"controller", "result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(shadowResults)
+}
+
+// startShadow runs the shadow handler with a copy of specified state
+// in parallel with the handler. The returned function waits for the
+// shadow handler and compares its output with the output of the
+// handler. The output of the shadow handler is discarded. It returns
+// nil if the shadow handler is not configured.
+func (r *Reconciler) startShadow(l logr.Logger, s *state.State) func(ns *state.State, err error) {
+	if r.shadow == nil {
+		return nil
+	}
+
+	ss := s.Copy()
+	done := make(chan error, 1)
+	go func() {
+		done <- r.shadow.HandleState(ss)
+	}()
+
+	return func(ns *state.State, err error) {
+		shadowErr := <-done
+		r.compareShadow(l, ns, err, ss, shadowErr)
+	}
+}
+
+// compareShadow compares the output of the handler with the output of
+// the shadow handler and records the result. Nothing is compared if
+// the handler failed.
+func (r *Reconciler) compareShadow(l logr.Logger, ns *state.State, err error, ss *state.State, shadowErr error) {
+	if err != nil {
+		return
+	}
+
+	if shadowErr != nil {
+		l.Info("Shadow handler error", "error", shadowErr.Error())
+		shadowResults.WithLabelValues(r.name, ShadowError).Inc()
+		return
+	}
+
+	diff, err := diffStates(ns, ss)
+	if err != nil {
+		l.Info("Failed to compare the output of shadow handler", "error", err.Error())
+		shadowResults.WithLabelValues(r.name, ShadowError).Inc()
+		return
+	}
+
+	if len(diff) > 0 {
+		l.Info("Shadow handler output differs", "fields", strings.Join(diff, ", "))
+		shadowResults.WithLabelValues(r.name, ShadowMismatch).Inc()
+		return
+	}
+
+	shadowResults.WithLabelValues(r.name, ShadowMatch).Inc()
+}
+
+// diffStates returns the field paths of the handler output that differ
+// between specified states.
+func diffStates(a, b *state.State) ([]string, error) {
+	am, err := stateMap(a)
+	if err != nil {
+		return nil, err
+	}
+
+	bm, err := stateMap(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return changedPaths(am, bm, ""), nil
+}
+
+// stateMap returns specified state as the handler output in JSON.
+func stateMap(s *state.State) (map[string]interface{}, error) {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	// The status is compared as a part of the object.
+	delete(m, "status")
+
+	return m, nil
+}
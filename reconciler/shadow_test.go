@@ -0,0 +1,123 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithShadow(t *testing.T) {
+	RegisterTestingT(t)
+
+	tl := &testLogger{}
+	defer func(l logr.Logger) { log = l }(log)
+	log = tl
+
+	rc := newResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+
+	results := func(result string) float64 {
+		return testutil.ToFloat64(shadowResults.WithLabelValues("shadow-test", result))
+	}
+	match, mismatch, errs := results(ShadowMatch), results(ShadowMismatch), results(ShadowError)
+
+	setPhase := func(phase string) func(*state.State) error {
+		return func(s *state.State) error {
+			return unstructured.SetNestedField(s.Object.Object, phase, "status", "phase")
+		}
+	}
+
+	// Matching output
+	started := make(chan struct{})
+	shadowCalled := false
+	c := &testTrackingClient{}
+	r := &Reconciler{
+		Client: c,
+		name:   "shadow-test",
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				// The shadow handler runs in parallel with the handler.
+				select {
+				case <-started:
+				case <-time.After(5 * time.Second):
+					return errors.New("shadow handler is not started")
+				}
+				return setPhase("done")(s)
+			},
+		},
+		shadow: &testHandler{
+			Func: func(s *state.State) error {
+				shadowCalled = true
+				close(started)
+				return setPhase("done")(s)
+			},
+		},
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	_, err := r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(shadowCalled).To(BeTrue())
+	Expect(c.updated).To(HaveLen(1))
+	Expect(results(ShadowMatch)).To(Equal(match + 1))
+
+	// Different output is logged and discarded
+	c = &testTrackingClient{}
+	r.Client = c
+	r.handler = &testHandler{Func: setPhase("done")}
+	r.shadow = &testHandler{
+		Func: func(s *state.State) error {
+			s.Requeue = true
+			return setPhase("failed")(s)
+		},
+	}
+
+	result, err := r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(result.Requeue).To(BeFalse())
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].Object["status"]).To(Equal(map[string]interface{}{"phase": "done"}))
+	Expect(results(ShadowMismatch)).To(Equal(mismatch + 1))
+	Expect(tl.messages).To(ContainElement("Shadow handler output differs"))
+	for i, msg := range tl.messages {
+		if msg == "Shadow handler output differs" {
+			Expect(tl.values[i]).To(ContainElement(".object.status.phase, .requeue"))
+		}
+	}
+
+	// Shadow handler error does not fail the reconcile
+	c = &testTrackingClient{}
+	r.Client = c
+	r.shadow = &testHandler{
+		Func: func(s *state.State) error {
+			return errors.New("test error")
+		},
+	}
+
+	_, err = r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.updated).To(HaveLen(1))
+	Expect(results(ShadowError)).To(Equal(errs + 1))
+
+	// Nothing is compared if the handler fails
+	r.handler = &testHandler{
+		Func: func(s *state.State) error {
+			return errors.New("test error")
+		},
+	}
+
+	_, err = r.reconcile(context.TODO(), object.DeepCopy(), "update")
+	Expect(err).To(HaveOccurred())
+	Expect(results(ShadowError)).To(Equal(errs + 1))
+}
@@ -0,0 +1,9 @@
+package state
+
+// Controller represents the controller that runs the handler.
+type Controller struct {
+	Name    string `json:"name"`
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
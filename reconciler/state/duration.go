@@ -0,0 +1,47 @@
+package state
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("state")
+
+// Duration is a duration in seconds. It can be decoded from a number
+// of seconds or a duration string such as "5m". An invalid duration
+// string is ignored with a warning.
+type Duration int
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var sec int
+	err := json.Unmarshal(b, &sec)
+	if err == nil {
+		*d = Duration(sec)
+		return nil
+	}
+
+	var s string
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	*d = 0
+	if s == "" {
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		log.Info("Ignored an invalid duration", "duration", s, "error", err.Error())
+		return nil
+	}
+
+	// Round up to keep a positive duration shorter than one second.
+	*d = Duration(math.Ceil(v.Seconds()))
+	return nil
+}
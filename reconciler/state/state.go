@@ -1,10 +1,12 @@
 package state
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -16,8 +18,50 @@ type State struct {
 	Dependents   map[string][]*unstructured.Unstructured `json:"dependents,omitempty"`
 	References   map[string][]*unstructured.Unstructured `json:"references,omitempty"`
 	Events       []Event                                 `json:"events,omitempty"`
+	RecentEvents []*unstructured.Unstructured            `json:"recentEvents,omitempty"`
+	APIVersions  []string                                `json:"apiVersions,omitempty"`
 	Requeue      bool                                    `json:"requeue,omitempty"`
-	RequeueAfter int                                     `json:"requeueAfter,omitempty"`
+	RequeueAfter Duration                                `json:"requeueAfter,omitempty"`
+	Trigger      string                                  `json:"trigger,omitempty"`
+	Controller   *Controller                             `json:"controller,omitempty"`
+
+	// Transition is the change of the outcome of the reconciles that is
+	// passed to the notify handler.
+	Transition *Transition `json:"transition,omitempty"`
+
+	// Objects are the additional objects to apply that are returned by
+	// the handler besides the dependents.
+	Objects []*unstructured.Unstructured `json:"objects,omitempty"`
+
+	// Timeout overrides the timeout of the handler if it is greater
+	// than 0. It is not passed to the handler.
+	Timeout time.Duration `json:"-"`
+
+	// Context is the context of the reconcile. Handlers should abort
+	// the work when it is cancelled. It is not passed to the handler.
+	Context context.Context `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler. The status of the object is
+// also included as the top-level 'status' key so that handlers can
+// read the current status without the object. It is ignored in the
+// output of handlers.
+func (s State) MarshalJSON() ([]byte, error) {
+	// state has the fields of State without this method.
+	type state State
+
+	v := struct {
+		state
+		Status interface{} `json:"status,omitempty"`
+	}{
+		state: state(s),
+	}
+
+	if s.Object != nil {
+		v.Status = s.Object.Object["status"]
+	}
+
+	return json.Marshal(&v)
 }
 
 // NewState returns a new state with specified object.
@@ -36,6 +80,31 @@ func (s *State) Copy() *State {
 		Object:     s.Object.DeepCopy(),
 		Dependents: map[string][]*unstructured.Unstructured{},
 		References: map[string][]*unstructured.Unstructured{},
+		Trigger:    s.Trigger,
+		Timeout:    s.Timeout,
+		Context:    s.Context,
+	}
+
+	if s.Controller != nil {
+		c := *s.Controller
+		ns.Controller = &c
+	}
+
+	if s.Transition != nil {
+		t := *s.Transition
+		ns.Transition = &t
+	}
+
+	if len(s.APIVersions) > 0 {
+		ns.APIVersions = make([]string, len(s.APIVersions))
+		copy(ns.APIVersions, s.APIVersions)
+	}
+
+	if len(s.RecentEvents) > 0 {
+		ns.RecentEvents = make([]*unstructured.Unstructured, len(s.RecentEvents))
+		for i := range s.RecentEvents {
+			ns.RecentEvents[i] = s.RecentEvents[i].DeepCopy()
+		}
 	}
 
 	if len(s.Dependents) > 0 {
@@ -56,6 +125,13 @@ func (s *State) Copy() *State {
 		}
 	}
 
+	if len(s.Objects) > 0 {
+		ns.Objects = make([]*unstructured.Unstructured, len(s.Objects))
+		for i := range s.Objects {
+			ns.Objects[i] = s.Objects[i].DeepCopy()
+		}
+	}
+
 	if len(s.Events) > 0 {
 		ns.Events = make([]Event, len(s.Events))
 		for i := range s.Events {
@@ -67,7 +143,9 @@ func (s *State) Copy() *State {
 }
 
 // Diff compares two states and returns lists of modified objects.
-func (s *State) Diff(ns *State) ([]*unstructured.Unstructured, []*unstructured.Unstructured, []*unstructured.Unstructured) {
+// Dependents must be in the namespace of the object unless their
+// resource key is listed in crossNamespace.
+func (s *State) Diff(ns *State, crossNamespace ...string) ([]*unstructured.Unstructured, []*unstructured.Unstructured, []*unstructured.Unstructured) {
 	created := []*unstructured.Unstructured{}
 	updated := []*unstructured.Unstructured{}
 	deleted := []*unstructured.Unstructured{}
@@ -91,6 +169,16 @@ func (s *State) Diff(ns *State) ([]*unstructured.Unstructured, []*unstructured.U
 		}
 	}
 
+	anyNamespace := map[string]struct{}{}
+	for _, key := range crossNamespace {
+		anyNamespace[key] = struct{}{}
+	}
+
+	inNamespace := func(key string, dep *unstructured.Unstructured) bool {
+		_, ok := anyNamespace[key]
+		return ok || s.Object.GetNamespace() == dep.GetNamespace()
+	}
+
 	checked := map[string]struct{}{}
 
 	// Search for updated or deleted dependent resources.
@@ -112,7 +200,7 @@ func (s *State) Diff(ns *State) ([]*unstructured.Unstructured, []*unstructured.U
 				if dep.GetName() != newDep.GetName() {
 					continue
 				}
-				if s.Object.GetNamespace() != newDep.GetNamespace() {
+				if !inNamespace(key, newDep) {
 					continue
 				}
 
@@ -149,7 +237,7 @@ func (s *State) Diff(ns *State) ([]*unstructured.Unstructured, []*unstructured.U
 				continue
 			}
 
-			if s.Object.GetNamespace() != newDep.GetNamespace() {
+			if !inNamespace(key, newDep) {
 				continue
 			}
 			if key != ResourceKey(newDep.GroupVersionKind()) {
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -37,10 +38,22 @@ func TestCopy(t *testing.T) {
 				newObject("D", "d2"),
 			},
 		},
+		Objects: []*Unstructured{
+			newObject("E", "e1"),
+		},
+		Trigger: "create",
+		Controller: &Controller{
+			Name:    "test-controller",
+			Group:   "example.com",
+			Version: "v1alpha1",
+			Kind:    "Resource",
+		},
 	}
 
 	ns := s.Copy()
 	Expect(reflect.DeepEqual(*s, *ns)).To(BeTrue())
+	Expect(ns.Controller).NotTo(BeIdenticalTo(s.Controller))
+	Expect(ns.Objects[0]).NotTo(BeIdenticalTo(s.Objects[0]))
 }
 
 func TestDiff(t *testing.T) {
@@ -90,6 +103,83 @@ func TestDiff(t *testing.T) {
 	Expect([]string{deleted[0].GetName(), deleted[1].GetName()}).To(ConsistOf("a2", "b2"))
 }
 
+func TestDiffWithCrossNamespace(t *testing.T) {
+	RegisterTestingT(t)
+
+	other := newObject("A", "a1")
+	other.SetNamespace("other")
+
+	s := &State{
+		Object: newObject("Resource", "test"),
+		Dependents: map[string][]*Unstructured{
+			"a.v1alpha1.example.com": []*Unstructured{other},
+			"b.v1alpha1.example.com": []*Unstructured{},
+		},
+	}
+
+	ns := s.Copy()
+	SetNestedField(ns.Dependents["a.v1alpha1.example.com"][0].Object, "bye", "spec", "message")
+
+	a2 := newObject("A", "a2")
+	a2.SetNamespace("other")
+	b1 := newObject("B", "b1")
+	b1.SetNamespace("other")
+	ns.Dependents["a.v1alpha1.example.com"] = append(ns.Dependents["a.v1alpha1.example.com"], a2)
+	ns.Dependents["b.v1alpha1.example.com"] = append(ns.Dependents["b.v1alpha1.example.com"], b1)
+
+	// Dependents in other namespaces are ignored by default
+	created, updated, deleted := s.Diff(ns)
+	Expect(created).To(BeEmpty())
+	Expect(updated).To(BeEmpty())
+	Expect(deleted).To(HaveLen(1))
+
+	// Only the dependents of cross-namespace keys are applied
+	created, updated, deleted = s.Diff(ns, "a.v1alpha1.example.com")
+	Expect(created).To(HaveLen(1))
+	Expect(created[0].GetName()).To(Equal("a2"))
+	Expect(updated).To(HaveLen(1))
+	Expect(updated[0].GetName()).To(Equal("a1"))
+	Expect(deleted).To(BeEmpty())
+}
+
+func TestMarshalStatus(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := New(newObject("Resource", "test"), nil, nil)
+	s.Object.Object["status"] = map[string]interface{}{
+		"phase":    "Ready",
+		"replicas": int64(3),
+	}
+
+	buf, err := json.Marshal(s)
+	Expect(err).NotTo(HaveOccurred())
+
+	m := map[string]interface{}{}
+	err = json.Unmarshal(buf, &m)
+	Expect(err).NotTo(HaveOccurred())
+
+	// The status shortcut matches the status of the object
+	Expect(m).To(HaveKey("status"))
+	Expect(m["status"]).To(Equal(m["object"].(map[string]interface{})["status"]))
+	Expect(m["status"]).To(Equal(map[string]interface{}{"phase": "Ready", "replicas": float64(3)}))
+
+	// The other fields are marshaled as is
+	Expect(m["object"].(map[string]interface{})["metadata"]).To(HaveKeyWithValue("name", "test"))
+	Expect(m).NotTo(HaveKey("timeout"))
+
+	// The status shortcut is ignored in the output
+	ns := &State{}
+	err = json.Unmarshal([]byte(`{"object": {"kind": "Resource"}, "status": {"phase": "Failed"}}`), ns)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(ns.Object.Object).NotTo(HaveKey("status"))
+
+	// Object without status
+	s = New(newObject("Resource", "test"), nil, nil)
+	buf, err = json.Marshal(s)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(buf)).NotTo(ContainSubstring(`"status"`))
+}
+
 func TestPack(t *testing.T) {
 	RegisterTestingT(t)
 
@@ -204,3 +294,29 @@ func newObject(kind, name string) *unstructured.Unstructured {
 
 	return object
 }
+
+func TestUnmarshalRequeueAfter(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		input    string
+		expected Duration
+	}{
+		{`{"requeueAfter":120}`, 120},
+		{`{"requeueAfter":"5m"}`, 300},
+		{`{"requeueAfter":"1500ms"}`, 2},
+		{`{"requeueAfter":""}`, 0},
+		{`{"requeueAfter":"invalid"}`, 0},
+	}
+
+	for _, test := range tests {
+		s := &State{}
+		err := json.Unmarshal([]byte(test.input), s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.RequeueAfter).To(Equal(test.expected))
+	}
+
+	// Invalid type
+	err := json.Unmarshal([]byte(`{"requeueAfter":true}`), &State{})
+	Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,10 @@
+package state
+
+// Transition represents a change of the outcome of the reconciles of
+// an object, such as from 'success' to 'error'.
+type Transition struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Error is the error of the reconcile that failed.
+	Error string `json:"error,omitempty"`
+}
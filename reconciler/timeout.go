@@ -0,0 +1,54 @@
+package reconciler
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// handlerTimeout returns the timeout of the handler read from the
+// configured field of specified object. It returns 0 if the field is
+// not configured or not found, so that the timeout of the handler is
+// used.
+func (r *Reconciler) handlerTimeout(obj *unstructured.Unstructured) (time.Duration, error) {
+	if r.timeoutPath == "" {
+		return 0, nil
+	}
+
+	jp := jsonpath.New("timeout")
+	jp.AllowMissingKeys(true)
+
+	err := jp.Parse(fmt.Sprintf("{%s}", r.timeoutPath))
+	if err != nil {
+		return 0, err
+	}
+
+	results, err := jp.FindResults(obj.Object)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(results) == 0 || len(results[0]) == 0 {
+		return 0, nil
+	}
+
+	val, ok := results[0][0].Interface().(string)
+	if !ok {
+		return 0, fmt.Errorf("timeout must be a duration string, got %v", results[0][0].Interface())
+	}
+
+	timeout, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %v", err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be greater than 0, got %s", val)
+	}
+	if r.timeout > 0 && timeout > r.timeout {
+		return 0, fmt.Errorf("timeout (%s) must be less than or equal to reconciler timeout (%s)", val, r.timeout)
+	}
+
+	return timeout, nil
+}
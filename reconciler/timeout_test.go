@@ -0,0 +1,121 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	. "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler/exec"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+func TestReconcileWithTimeoutFieldPath(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	var timeout time.Duration
+	recorder := record.NewFakeRecorder(32)
+	r := &Reconciler{
+		Client: &testTrackingClient{},
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				timeout = s.Timeout
+				return nil
+			},
+		},
+		recorder:    recorder,
+		timeout:     time.Minute,
+		timeoutPath: ".spec.timeout",
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	// Timeout of the object
+	SetNestedField(object.Object, "30s", "spec", "timeout")
+	_, err := r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(timeout).To(Equal(30 * time.Second))
+
+	// Missing field
+	RemoveNestedField(object.Object, "spec", "timeout")
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(timeout).To(BeZero())
+	Expect(recorder.Events).NotTo(Receive())
+
+	// Invalid values fall back to the timeout of the handler
+	invalid := []interface{}{"invalid", "-1s", "2m", int64(30)}
+	for _, v := range invalid {
+		SetNestedField(object.Object, v, "spec", "timeout")
+		_, err = r.reconcile(context.TODO(), object, "update")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(timeout).To(BeZero())
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning InvalidTimeout")))
+	}
+
+	// Repeated invalid value does not emit the event again
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(recorder.Events).NotTo(Receive())
+}
+
+func TestReconcileWithTimeoutFieldPathHonored(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newResourceConfig()
+	rc.Dependents = nil
+	rc.References = nil
+
+	h, err := exec.New(&config.ExecHandlerConfig{
+		Command: "sleep 1 && cat",
+		Shell:   "/bin/sh",
+		Timeout: "10s",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	r := &Reconciler{
+		Client:      &testTrackingClient{},
+		config:      rc,
+		handler:     h,
+		recorder:    record.NewFakeRecorder(32),
+		timeoutPath: ".spec.timeout",
+	}
+
+	object := newObject(rc.GroupVersionKind, "test")
+
+	// The handler times out with the timeout of the object
+	SetNestedField(object.Object, "100ms", "spec", "timeout")
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).To(HaveOccurred())
+	Expect(errorReason(err)).To(Equal(ReasonTimeout))
+}
+
+func TestNewContextWithStop(t *testing.T) {
+	RegisterTestingT(t)
+
+	stop := make(chan struct{})
+	r := &Reconciler{timeout: time.Minute}
+	Expect(r.InjectStopChannel(stop)).To(Succeed())
+
+	ctx, cancel := r.newContext()
+	defer cancel()
+
+	_, ok := ctx.Deadline()
+	Expect(ok).To(BeTrue())
+	Expect(ctx.Err()).NotTo(HaveOccurred())
+
+	// Stopping the manager cancels the running reconciles
+	close(stop)
+	Eventually(ctx.Done()).Should(BeClosed())
+	Expect(ctx.Err()).To(Equal(context.Canceled))
+}
@@ -0,0 +1,111 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+const (
+	// LabelOwnerUID is the label of tracked dependents that has the
+	// UID of the owner.
+	LabelOwnerUID = "whitebox.summerwind.dev/owner-uid"
+	// LabelOwnerNamespace is the label of tracked dependents that has
+	// the namespace of the owner.
+	LabelOwnerNamespace = "whitebox.summerwind.dev/owner-namespace"
+	// LabelOwnerName is the label of tracked dependents that has the
+	// name of the owner.
+	LabelOwnerName = "whitebox.summerwind.dev/owner-name"
+)
+
+// trackedKeys returns the resource keys of the dependents that are
+// tracked by labels.
+func (r *Reconciler) trackedKeys() []string {
+	keys := []string{}
+	for _, dep := range r.config.Dependents {
+		if dep.TrackingLabels {
+			keys = append(keys, state.ResourceKey(dep.GroupVersionKind))
+		}
+	}
+
+	return keys
+}
+
+// listTrackedDependents returns the dependents of specified owner that
+// have the tracking labels in all namespaces.
+func (r *Reconciler) listTrackedDependents(ctx context.Context, owner *unstructured.Unstructured, gvk schema.GroupVersionKind) ([]*unstructured.Unstructured, error) {
+	gvk.Kind = gvk.Kind + "List"
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk)
+
+	err := r.List(ctx, list, client.MatchingLabels{LabelOwnerUID: string(owner.GetUID())})
+	if err != nil {
+		return nil, err
+	}
+
+	deps := []*unstructured.Unstructured{}
+	for i := range list.Items {
+		deps = append(deps, &list.Items[i])
+	}
+
+	return deps, nil
+}
+
+// setTrackingLabels sets the labels of specified owner to the dependent.
+func setTrackingLabels(owner, dep *unstructured.Unstructured) {
+	labels := dep.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	labels[LabelOwnerUID] = string(owner.GetUID())
+	labels[LabelOwnerNamespace] = owner.GetNamespace()
+	labels[LabelOwnerName] = owner.GetName()
+	dep.SetLabels(labels)
+}
+
+// getTrackingFinalizerName returns the name of the finalizer that
+// deletes the tracked dependents.
+func (r *Reconciler) getTrackingFinalizerName() string {
+	return fmt.Sprintf("%s/dependents", r.getFinalizerName())
+}
+
+// cleanupTrackedDependents deletes the tracked dependents of specified
+// owner being deleted, and then removes the tracking finalizer so that
+// the owner is deleted.
+func (r *Reconciler) cleanupTrackedDependents(ctx context.Context, owner *unstructured.Unstructured) error {
+	for _, dep := range r.config.Dependents {
+		if !dep.TrackingLabels {
+			continue
+		}
+
+		deps, err := r.listTrackedDependents(ctx, owner, dep.GroupVersionKind)
+		if err != nil {
+			return fmt.Errorf("failed to get tracked dependent resources: %v", err)
+		}
+
+		for _, res := range deps {
+			log.Info("Deleting tracked resource", "kind", res.GetKind(), "namespace", res.GetNamespace(), "name", res.GetName())
+
+			err = r.delete(ctx, res)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return newApplyError(err)
+			}
+		}
+	}
+
+	removeFinalizer(owner, r.getTrackingFinalizerName())
+
+	err := r.update(ctx, owner)
+	if err != nil {
+		return newApplyError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/reconciler/state"
+)
+
+var configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+func TestReconcileWithTrackingLabels(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newTrackingResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+	c := &testTrackingClient{}
+
+	r := &Reconciler{
+		Client: c,
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				cm := &unstructured.Unstructured{}
+				cm.SetGroupVersionKind(configMapGVK)
+				cm.SetNamespace("other")
+				cm.SetName("test")
+
+				key := state.ResourceKey(configMapGVK)
+				s.Dependents[key] = []*unstructured.Unstructured{cm}
+				return nil
+			},
+		},
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	_, err := r.reconcile(context.TODO(), object, "create")
+	Expect(err).NotTo(HaveOccurred())
+
+	// The dependent is created in other namespace with the labels
+	Expect(c.created).To(HaveLen(1))
+	cm := c.created[0]
+	Expect(cm.GetNamespace()).To(Equal("other"))
+	Expect(cm.GetOwnerReferences()).To(BeEmpty())
+	Expect(cm.GetLabels()).To(Equal(map[string]string{
+		LabelOwnerUID:       string(object.GetUID()),
+		LabelOwnerNamespace: "default",
+		LabelOwnerName:      "test",
+	}))
+
+	// The owner has the tracking finalizer
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].GetFinalizers()).To(ConsistOf("test-controller.example.com/dependents"))
+
+	// The created dependent is passed to the handler
+	var deps []*unstructured.Unstructured
+	r.handler = &testHandler{
+		Func: func(s *state.State) error {
+			deps = s.Dependents[state.ResourceKey(configMapGVK)]
+			return nil
+		},
+	}
+
+	c.objects = c.created
+	_, err = r.reconcile(context.TODO(), c.updated[0], "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(deps).To(HaveLen(1))
+	Expect(deps[0].GetNamespace()).To(Equal("other"))
+}
+
+func TestReconcileWithTrackingLabelsOnDeletion(t *testing.T) {
+	RegisterTestingT(t)
+
+	rc := newTrackingResourceConfig()
+	object := newObject(rc.GroupVersionKind, "test")
+	object.SetFinalizers([]string{"test-controller.example.com/dependents"})
+	unstructured.SetNestedField(object.Object, time.Now().Format(time.RFC3339), "metadata", "deletionTimestamp")
+
+	tracked := &unstructured.Unstructured{}
+	tracked.SetGroupVersionKind(configMapGVK)
+	tracked.SetNamespace("other")
+	tracked.SetName("test")
+	setTrackingLabels(object, tracked)
+
+	untracked := tracked.DeepCopy()
+	untracked.SetName("untracked")
+	untracked.SetLabels(nil)
+
+	c := &testTrackingClient{objects: []*unstructured.Unstructured{tracked, untracked}}
+
+	called := false
+	r := &Reconciler{
+		Client: c,
+		config: rc,
+		handler: &testHandler{
+			Func: func(s *state.State) error {
+				called = true
+				return nil
+			},
+		},
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	_, err := r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(called).To(BeFalse())
+
+	// Only the tracked dependent is deleted
+	Expect(c.deleted).To(HaveLen(1))
+	Expect(c.deleted[0].GetName()).To(Equal("test"))
+
+	// The tracking finalizer is removed
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].GetFinalizers()).To(BeEmpty())
+
+	// Waits for the finalizer of the handler
+	c = &testTrackingClient{objects: []*unstructured.Unstructured{tracked}}
+	r.Client = c
+	r.finalizer = &testHandler{}
+	object.SetFinalizers([]string{"test-controller.example.com", "test-controller.example.com/dependents"})
+
+	_, err = r.reconcile(context.TODO(), object, "update")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(c.deleted).To(BeEmpty())
+	Expect(c.updated).To(HaveLen(1))
+	Expect(c.updated[0].GetFinalizers()).To(ConsistOf("test-controller.example.com/dependents"))
+}
+
+func newTrackingResourceConfig() *config.ResourceConfig {
+	return &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{
+			Group:   "example.com",
+			Version: "v1alpha1",
+			Kind:    "Test",
+		},
+		Dependents: []config.DependentConfig{
+			{GroupVersionKind: configMapGVK, TrackingLabels: true},
+		},
+		Reconciler: &config.ReconcilerConfig{},
+	}
+}
+
+// testTrackingClient gets and lists the objects by the label selector
+// and records the changes.
+type testTrackingClient struct {
+	client.Client
+	objects []*unstructured.Unstructured
+	created []*unstructured.Unstructured
+	updated []*unstructured.Unstructured
+	deleted []*unstructured.Unstructured
+}
+
+func (c *testTrackingClient) Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+	for _, o := range c.objects {
+		if o.GetNamespace() == key.Namespace && o.GetName() == key.Name {
+			o.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		}
+	}
+
+	return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func (c *testTrackingClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	lo := (&client.ListOptions{}).ApplyOptions(opts)
+
+	ul := list.(*unstructured.UnstructuredList)
+	for _, obj := range c.objects {
+		if lo.LabelSelector != nil && !lo.LabelSelector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		ul.Items = append(ul.Items, *obj.DeepCopy())
+	}
+
+	return nil
+}
+
+func (c *testTrackingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.created = append(c.created, obj.(*unstructured.Unstructured).DeepCopy())
+	return nil
+}
+
+func (c *testTrackingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.updated = append(c.updated, obj.(*unstructured.Unstructured).DeepCopy())
+	return nil
+}
+
+func (c *testTrackingClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.deleted = append(c.deleted, obj.(*unstructured.Unstructured).DeepCopy())
+	return nil
+}
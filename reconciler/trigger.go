@@ -0,0 +1,48 @@
+package reconciler
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Triggers of reconcile passed to the handler.
+const (
+	// TriggerCreate means that the resource was created.
+	TriggerCreate = "create"
+	// TriggerUpdate means that the resource was updated.
+	TriggerUpdate = "update"
+	// TriggerDelete means that the resource was deleted.
+	TriggerDelete = "delete"
+	// TriggerSync means that the resource was resynced periodically.
+	TriggerSync = "sync"
+	// TriggerDependent means that a dependent resource was changed.
+	TriggerDependent = "dependent"
+	// TriggerWatch means that a watched resource was changed.
+	TriggerWatch = "watch"
+	// TriggerRequeue means that the resource was requeued.
+	TriggerRequeue = "requeue"
+)
+
+// SetTrigger records the trigger of the next reconcile of specified
+// resource. If multiple triggers are recorded before the reconcile,
+// only the last one is used.
+func (r *Reconciler) SetTrigger(nn types.NamespacedName, trigger string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.triggers[nn] = trigger
+}
+
+// popTrigger returns the recorded trigger of specified resource and
+// removes it. If no trigger is recorded, it returns TriggerRequeue.
+func (r *Reconciler) popTrigger(nn types.NamespacedName) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trigger, ok := r.triggers[nn]
+	if !ok {
+		return TriggerRequeue
+	}
+	delete(r.triggers, nn)
+
+	return trigger
+}
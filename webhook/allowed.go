@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// isAllowedPath returns whether the JSON Pointer of a patch is within
+// any of the allowed fields.
+func isAllowedPath(pointer string, allowed [][]string) bool {
+	segments := []string{}
+	if pointer != "" {
+		for _, s := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			s = strings.Replace(s, "~1", "/", -1)
+			s = strings.Replace(s, "~0", "~", -1)
+			segments = append(segments, s)
+		}
+	}
+
+	for _, f := range allowed {
+		if len(segments) < len(f) {
+			continue
+		}
+
+		matched := true
+		for i := range f {
+			if segments[i] != f[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterJSONPatch removes the operations that modify the fields
+// outside the allowed fields from the JSON Patch. It returns the
+// filtered patch and the paths of removed operations.
+func filterJSONPatch(patch []byte, allowed [][]string) ([]byte, []string, error) {
+	ops := []map[string]json.RawMessage{}
+	err := json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered := []map[string]json.RawMessage{}
+	dropped := []string{}
+	for _, op := range ops {
+		var name, path, from string
+		json.Unmarshal(op["op"], &name)
+		json.Unmarshal(op["path"], &path)
+
+		ok := name == "test" || isAllowedPath(path, allowed)
+		if ok && name == "move" {
+			json.Unmarshal(op["from"], &from)
+			ok = isAllowedPath(from, allowed)
+		}
+
+		if !ok {
+			dropped = append(dropped, path)
+			continue
+		}
+		filtered = append(filtered, op)
+	}
+
+	buf, err := json.Marshal(filtered)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return buf, dropped, nil
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestIsAllowedPath(t *testing.T) {
+	RegisterTestingT(t)
+
+	allowed := [][]string{
+		{"metadata", "labels"},
+		{"metadata", "annotations", "example.com/foo"},
+	}
+
+	Expect(isAllowedPath("/metadata/labels", allowed)).To(BeTrue())
+	Expect(isAllowedPath("/metadata/labels/foo", allowed)).To(BeTrue())
+	Expect(isAllowedPath("/metadata/annotations/example.com~1foo", allowed)).To(BeTrue())
+	Expect(isAllowedPath("/metadata/annotations/example.com~1bar", allowed)).To(BeFalse())
+	Expect(isAllowedPath("/metadata", allowed)).To(BeFalse())
+	Expect(isAllowedPath("/spec/replicas", allowed)).To(BeFalse())
+	Expect(isAllowedPath("", allowed)).To(BeFalse())
+}
+
+func TestMutationHookWithAllowedFields(t *testing.T) {
+	RegisterTestingT(t)
+
+	patch := `[{"op":"add","path":"/metadata/labels/foo","value":"bar"},{"op":"replace","path":"/spec/replicas","value":3},{"op":"move","from":"/spec/image","path":"/metadata/labels/image"}]`
+
+	hook, err := newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testPatchHandler{patch: patch},
+		AllowedFields:           []string{".metadata.labels"},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendMutationReview(hook)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(res["patchType"]).To(Equal("JSONPatch"))
+	Expect(res["patch"]).To(MatchJSON(`[{"op":"add","path":"/metadata/labels/foo","value":"bar"}]`))
+	Expect(res["warnings"]).To(ConsistOf(
+		"Dropped changes outside allowed fields: /spec/replicas, /metadata/labels/image",
+	))
+
+	// All changes are out of scope
+	hook, err = newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testPatchHandler{patch: patch},
+		AllowedFields:           []string{".metadata.annotations"},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res = sendMutationReview(hook)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(res["patch"]).To(MatchJSON(`[]`))
+}
@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// certWatcher serves the certificate of the webhook server and reloads
+// it when the certificate files are changed, so that the rotated
+// certificate is used without restarting the server. The certificate
+// is also reloaded periodically if the interval is specified.
+type certWatcher struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertWatcher(certFile, keyFile string, interval time.Duration) (*certWatcher, error) {
+	w := &certWatcher{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+
+	err := w.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// GetCertificate returns the current certificate. It is used as
+// GetCertificate of tls.Config.
+func (w *certWatcher) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.cert, nil
+}
+
+// load loads the certificate files. The current certificate is kept
+// if the files are invalid, such as while they are being written.
+func (w *certWatcher) load() error {
+	cert, err := tls.LoadX509KeyPair(w.certFile, w.keyFile)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.cert = &cert
+	w.mu.Unlock()
+
+	return nil
+}
+
+func (w *certWatcher) reload(reason string) {
+	err := w.load()
+	if err != nil {
+		log.Error(err, "Failed to reload certificate", "reason", reason)
+		return
+	}
+
+	log.Info("Reloaded certificate", "reason", reason)
+}
+
+// Start watches the directories of the certificate files until stop is
+// closed. Directories are watched instead of the files since mounted
+// secrets are updated by replacing a symbolic link.
+func (w *certWatcher) Start(stop <-chan struct{}) error {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Error(err, "Failed to watch certificate files")
+	} else {
+		defer fw.Close()
+
+		for _, dir := range uniqueDirs(w.certFile, w.keyFile) {
+			err = fw.Add(dir)
+			if err != nil {
+				log.Error(err, "Failed to watch certificate directory", "path", dir)
+			}
+		}
+
+		events = fw.Events
+		errs = fw.Errors
+	}
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		t := time.NewTicker(w.interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case ev := <-events:
+			if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove) != 0 {
+				w.reload("changed")
+			}
+		case err := <-errs:
+			log.Error(err, "Certificate watch error")
+		case <-tick:
+			w.reload("interval")
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// uniqueDirs returns the unique directories of specified files.
+func uniqueDirs(files ...string) []string {
+	dirs := []string{}
+	seen := map[string]struct{}{}
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		_, ok := seen[dir]
+		if ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
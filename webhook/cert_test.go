@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCertWatcher(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-cert")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	first := writeTestCert(certFile, keyFile, "first")
+
+	w, err := newCertWatcher(certFile, keyFile, 0)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(currentCert(w)).To(Equal(first))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.Start(stop)
+
+	// Wait for the watcher to be started
+	time.Sleep(100 * time.Millisecond)
+
+	// Rotated by the file change
+	second := writeTestCert(certFile, keyFile, "second")
+	Eventually(func() []byte { return currentCert(w) }, 5*time.Second).Should(Equal(second))
+
+	// Invalid files keep the current certificate
+	err = ioutil.WriteFile(certFile, []byte("invalid"), 0644)
+	Expect(err).NotTo(HaveOccurred())
+	Consistently(func() []byte { return currentCert(w) }, 200*time.Millisecond).Should(Equal(second))
+}
+
+func TestCertWatcherWithInterval(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-cert")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeTestCert(certFile, keyFile, "first")
+
+	w, err := newCertWatcher(certFile, keyFile, 10*time.Millisecond)
+	Expect(err).NotTo(HaveOccurred())
+
+	second := writeTestCert(certFile, keyFile, "second")
+
+	// Reloaded by the interval without the file watch
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.Start(stop)
+
+	Eventually(func() []byte { return currentCert(w) }, 5*time.Second).Should(Equal(second))
+}
+
+func TestCertWatcherWithServer(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-cert")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	first := writeTestCert(certFile, keyFile, "first")
+
+	w, err := newCertWatcher(certFile, keyFile, 10*time.Millisecond)
+	Expect(err).NotTo(HaveOccurred())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.Start(stop)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: w.GetCertificate})
+	Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	peerCert := func() []byte {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return nil
+		}
+		defer conn.Close()
+
+		return conn.ConnectionState().PeerCertificates[0].Raw
+	}
+
+	Expect(peerCert()).To(Equal(first))
+
+	// The new certificate is served without restarting the listener
+	second := writeTestCert(certFile, keyFile, "second")
+	Eventually(peerCert, 5*time.Second).Should(Equal(second))
+}
+
+// writeTestCert writes a self-signed certificate and its key to the
+// files, and returns the DER bytes of the certificate.
+func writeTestCert(certFile, keyFile, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	return der
+}
+
+func currentCert(w *certWatcher) []byte {
+	cert, _ := w.GetCertificate(nil)
+	return cert.Certificate[0]
+}
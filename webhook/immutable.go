@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// changedImmutableField returns the index of the first immutable field
+// whose value is changed by the update request. It returns -1 if no
+// immutable field is changed or the request is not an update of the
+// object. Adding or removing the field is also a change.
+func changedImmutableField(req admission.Request, immutable [][]string) (int, error) {
+	if req.Operation != admissionv1beta1.Update || req.SubResource != "" {
+		return -1, nil
+	}
+
+	if len(req.Object.Raw) == 0 || len(req.OldObject.Raw) == 0 {
+		return -1, nil
+	}
+
+	obj := map[string]interface{}{}
+	err := json.Unmarshal(req.Object.Raw, &obj)
+	if err != nil {
+		return -1, err
+	}
+
+	old := map[string]interface{}{}
+	err = json.Unmarshal(req.OldObject.Raw, &old)
+	if err != nil {
+		return -1, err
+	}
+
+	for i, f := range immutable {
+		val, found, _ := unstructured.NestedFieldNoCopy(obj, f...)
+		oldVal, oldFound, _ := unstructured.NestedFieldNoCopy(old, f...)
+		if found != oldFound || !reflect.DeepEqual(val, oldVal) {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
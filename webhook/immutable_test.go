@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+const testImmutableObject = `{
+  "apiVersion": "example.com/v1alpha1",
+  "kind": "Test",
+  "metadata": {"namespace": "default", "name": "test"},
+  "spec": {"storageClass": "standard", "size": 1}
+}`
+
+func TestValidationHookWithImmutableFields(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testRecordHandler{}
+	hook, err := newValidationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: h,
+		ImmutableFields:         []string{".spec.storageClass"},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Change of the immutable field
+	res := sendUpdateReview(hook, `{
+  "apiVersion": "example.com/v1alpha1",
+  "kind": "Test",
+  "metadata": {"namespace": "default", "name": "test"},
+  "spec": {"storageClass": "fast", "size": 1}
+}`, testImmutableObject)
+	Expect(res["allowed"]).To(BeFalse())
+	Expect(res["status"]).To(HaveKeyWithValue("reason", "field .spec.storageClass is immutable"))
+	Expect(h.requests).To(BeEmpty())
+
+	// Removal of the immutable field
+	res = sendUpdateReview(hook, `{
+  "apiVersion": "example.com/v1alpha1",
+  "kind": "Test",
+  "metadata": {"namespace": "default", "name": "test"},
+  "spec": {"size": 1}
+}`, testImmutableObject)
+	Expect(res["allowed"]).To(BeFalse())
+	Expect(h.requests).To(BeEmpty())
+
+	// Change of other fields
+	res = sendUpdateReview(hook, `{
+  "apiVersion": "example.com/v1alpha1",
+  "kind": "Test",
+  "metadata": {"namespace": "default", "name": "test", "labels": {"app": "test"}},
+  "spec": {"storageClass": "standard", "size": 2}
+}`, testImmutableObject)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(h.requests).To(HaveLen(1))
+
+	// Invalid immutable field
+	_, err = newValidationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: h,
+		ImmutableFields:         []string{"spec"},
+	})
+	Expect(err).To(HaveOccurred())
+}
+
+func sendUpdateReview(h http.Handler, obj, oldObj string) map[string]interface{} {
+	review := map[string]interface{}{
+		"apiVersion": "admission.k8s.io/v1beta1",
+		"kind":       "AdmissionReview",
+		"request": map[string]interface{}{
+			"uid":       "test",
+			"operation": "UPDATE",
+			"kind":      map[string]interface{}{"group": "example.com", "version": "v1alpha1", "kind": "Test"},
+			"object":    json.RawMessage(obj),
+			"oldObject": json.RawMessage(oldObj),
+		},
+	}
+	body, err := json.Marshal(review)
+	Expect(err).NotTo(HaveOccurred())
+
+	req := httptest.NewRequest("POST", "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+	Expect(rec.Code).To(Equal(http.StatusOK))
+
+	res := struct {
+		Response map[string]interface{} `json:"response"`
+	}{}
+	err = json.Unmarshal(rec.Body.Bytes(), &res)
+	Expect(err).NotTo(HaveOccurred())
+
+	return res.Response
+}
@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+// patchOperation represents an add operation of JSON Patch.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// applyInjection translates the injection of the response into JSON
+// Patch operations on the Pod of the request and appends them to the
+// JSON Patch of the response. The patches of the response are converted
+// into the JSON Patch so that they are applied together.
+func applyInjection(req admission.Request, res *handler.AdmissionResponse) error {
+	if req.Kind.Group != "" || req.Kind.Kind != "Pod" {
+		return fmt.Errorf("inject is supported only for pods, got %s", req.Kind.Kind)
+	}
+
+	if len(req.Object.Raw) == 0 {
+		return errors.New("inject requires the object of the request")
+	}
+
+	pod := &corev1.Pod{}
+	err := json.Unmarshal(req.Object.Raw, pod)
+	if err != nil {
+		return fmt.Errorf("failed to decode pod: %v", err)
+	}
+
+	ops, err := injectionPatch(pod, res.Inject)
+	if err != nil {
+		return err
+	}
+
+	patch := []interface{}{}
+	if len(res.JSONPatch) > 0 {
+		err := validateJSONPatch(res.JSONPatch)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(res.JSONPatch, &patch)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, p := range res.Patches {
+			patch = append(patch, p)
+		}
+	}
+
+	for _, op := range ops {
+		patch = append(patch, op)
+	}
+
+	buf, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	res.JSONPatch = buf
+	res.Patches = nil
+	res.Inject = nil
+
+	return nil
+}
+
+// injectionPatch returns JSON Patch operations that inject specified
+// containers, volumes and environment variables into the pod.
+func injectionPatch(pod *corev1.Pod, inj *handler.PodInjection) ([]patchOperation, error) {
+	err := validateInjection(pod, inj)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []patchOperation{}
+
+	// Environment variables are added only to the existing containers
+	// since the injected containers define their own variables.
+	if len(inj.Env) > 0 {
+		for i, c := range pod.Spec.Containers {
+			defined := map[string]struct{}{}
+			for _, e := range c.Env {
+				defined[e.Name] = struct{}{}
+			}
+
+			env := []interface{}{}
+			for _, e := range inj.Env {
+				_, ok := defined[e.Name]
+				if !ok {
+					env = append(env, e)
+				}
+			}
+
+			path := fmt.Sprintf("/spec/containers/%d/env", i)
+			ops = append(ops, appendOperations(path, len(c.Env) == 0, env)...)
+		}
+	}
+
+	containers := make([]interface{}, len(inj.Containers))
+	for i := range inj.Containers {
+		containers[i] = inj.Containers[i]
+	}
+	ops = append(ops, appendOperations("/spec/containers", len(pod.Spec.Containers) == 0, containers)...)
+
+	volumes := make([]interface{}, len(inj.Volumes))
+	for i := range inj.Volumes {
+		volumes[i] = inj.Volumes[i]
+	}
+	ops = append(ops, appendOperations("/spec/volumes", len(pod.Spec.Volumes) == 0, volumes)...)
+
+	return ops, nil
+}
+
+// appendOperations returns JSON Patch operations that append values
+// to the list at specified path. If the list is empty, it may be
+// missing, so the whole list is added instead.
+func appendOperations(path string, empty bool, values []interface{}) []patchOperation {
+	if len(values) == 0 {
+		return nil
+	}
+
+	if empty {
+		return []patchOperation{{Op: "add", Path: path, Value: values}}
+	}
+
+	ops := []patchOperation{}
+	for _, v := range values {
+		ops = append(ops, patchOperation{Op: "add", Path: path + "/-", Value: v})
+	}
+
+	return ops
+}
+
+// validateInjection validates that the injected objects have names that
+// are unique within the pod.
+func validateInjection(pod *corev1.Pod, inj *handler.PodInjection) error {
+	containers := map[string]struct{}{}
+	for _, c := range pod.Spec.InitContainers {
+		containers[c.Name] = struct{}{}
+	}
+	for _, c := range pod.Spec.Containers {
+		containers[c.Name] = struct{}{}
+	}
+
+	for i, c := range inj.Containers {
+		if c.Name == "" {
+			return fmt.Errorf("containers[%d]: name must be specified", i)
+		}
+		if c.Image == "" {
+			return fmt.Errorf("containers[%d]: image must be specified", i)
+		}
+
+		_, ok := containers[c.Name]
+		if ok {
+			return fmt.Errorf("containers[%d]: duplicate container name: %s", i, c.Name)
+		}
+		containers[c.Name] = struct{}{}
+	}
+
+	volumes := map[string]struct{}{}
+	for _, v := range pod.Spec.Volumes {
+		volumes[v.Name] = struct{}{}
+	}
+
+	for i, v := range inj.Volumes {
+		if v.Name == "" {
+			return fmt.Errorf("volumes[%d]: name must be specified", i)
+		}
+
+		_, ok := volumes[v.Name]
+		if ok {
+			return fmt.Errorf("volumes[%d]: duplicate volume name: %s", i, v.Name)
+		}
+		volumes[v.Name] = struct{}{}
+	}
+
+	env := map[string]struct{}{}
+	for i, e := range inj.Env {
+		if e.Name == "" {
+			return fmt.Errorf("env[%d]: name must be specified", i)
+		}
+
+		_, ok := env[e.Name]
+		if ok {
+			return fmt.Errorf("env[%d]: duplicate variable name: %s", i, e.Name)
+		}
+		env[e.Name] = struct{}{}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+const testPod = `{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {"name": "test", "namespace": "default"},
+  "spec": {
+    "containers": [
+      {"name": "app", "image": "nginx", "env": [{"name": "MODE", "value": "app"}]}
+    ]
+  }
+}`
+
+func TestMutationHookWithInject(t *testing.T) {
+	RegisterTestingT(t)
+
+	inject := &handler.PodInjection{
+		Containers: []corev1.Container{
+			{Name: "proxy", Image: "envoy"},
+		},
+		Volumes: []corev1.Volume{
+			{Name: "proxy-config", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+		Env: []corev1.EnvVar{
+			{Name: "MODE", Value: "proxy"},
+			{Name: "PROXY_PORT", Value: "15001"},
+		},
+	}
+
+	hook, err := newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testInjectHandler{
+			inject: inject,
+			patch:  `[{"op":"add","path":"/metadata/labels","value":{"injected":"true"}}]`,
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendPodMutationReview(hook, testPod)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(res["patchType"]).To(Equal("JSONPatch"))
+
+	patch, err := jsonpatch.DecodePatch(res["patch"].([]byte))
+	Expect(err).NotTo(HaveOccurred())
+
+	buf, err := patch.Apply([]byte(testPod))
+	Expect(err).NotTo(HaveOccurred())
+
+	pod := &corev1.Pod{}
+	err = json.Unmarshal(buf, pod)
+	Expect(err).NotTo(HaveOccurred())
+
+	// The container is injected
+	Expect(pod.Spec.Containers).To(HaveLen(2))
+	Expect(pod.Spec.Containers[1].Name).To(Equal("proxy"))
+	Expect(pod.Spec.Containers[1].Image).To(Equal("envoy"))
+
+	// The volume is injected into the missing list
+	Expect(pod.Spec.Volumes).To(HaveLen(1))
+	Expect(pod.Spec.Volumes[0].Name).To(Equal("proxy-config"))
+
+	// The variables are added to the existing containers without
+	// overriding the defined ones
+	Expect(pod.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+		{Name: "MODE", Value: "app"},
+		{Name: "PROXY_PORT", Value: "15001"},
+	}))
+	Expect(pod.Spec.Containers[1].Env).To(BeEmpty())
+
+	// The patch of the handler is also applied
+	Expect(pod.Labels).To(Equal(map[string]string{"injected": "true"}))
+}
+
+func TestMutationHookWithInvalidInject(t *testing.T) {
+	RegisterTestingT(t)
+
+	invalid := []*handler.PodInjection{
+		{Containers: []corev1.Container{{Image: "envoy"}}},
+		{Containers: []corev1.Container{{Name: "proxy"}}},
+		{Containers: []corev1.Container{{Name: "app", Image: "envoy"}}},
+		{Volumes: []corev1.Volume{{}}},
+		{Volumes: []corev1.Volume{{Name: "config"}, {Name: "config"}}},
+		{Env: []corev1.EnvVar{{Value: "proxy"}}},
+	}
+
+	for _, inject := range invalid {
+		hook, err := newMutationHook(&config.HandlerConfig{
+			AdmissionRequestHandler: &testInjectHandler{inject: inject},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		res := sendPodMutationReview(hook, testPod)
+		Expect(res["allowed"]).To(BeFalse())
+		Expect(res).NotTo(HaveKey("patch"))
+	}
+
+	// Not a pod
+	hook, err := newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testInjectHandler{
+			inject: &handler.PodInjection{Containers: []corev1.Container{{Name: "proxy", Image: "envoy"}}},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendMutationReview(hook)
+	Expect(res["allowed"]).To(BeFalse())
+}
+
+func sendPodMutationReview(h http.Handler, pod string) map[string]interface{} {
+	review := map[string]interface{}{
+		"apiVersion": "admission.k8s.io/v1beta1",
+		"kind":       "AdmissionReview",
+		"request": map[string]interface{}{
+			"uid":       "test",
+			"operation": "CREATE",
+			"kind":      map[string]interface{}{"group": "", "version": "v1", "kind": "Pod"},
+			"object":    json.RawMessage(pod),
+		},
+	}
+	body, err := json.Marshal(review)
+	Expect(err).NotTo(HaveOccurred())
+
+	req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+	Expect(rec.Code).To(Equal(http.StatusOK))
+
+	res := struct {
+		Response map[string]interface{} `json:"response"`
+	}{}
+	err = json.Unmarshal(rec.Body.Bytes(), &res)
+	Expect(err).NotTo(HaveOccurred())
+
+	// Decode the base64 encoded patch
+	if p, ok := res.Response["patch"].(string); ok {
+		var patch []byte
+		err := json.Unmarshal([]byte(`"`+p+`"`), &patch)
+		Expect(err).NotTo(HaveOccurred())
+		res.Response["patch"] = patch
+	}
+
+	return res.Response
+}
+
+type testInjectHandler struct {
+	inject *handler.PodInjection
+	patch  string
+}
+
+func (h *testInjectHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	res, err := h.HandleAdmissionRequestWithWarnings(req)
+	return res.Response, err
+}
+
+func (h *testInjectHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (handler.AdmissionResponse, error) {
+	res := handler.AdmissionResponse{
+		Response: admission.Allowed(""),
+		Inject:   h.inject,
+	}
+	if h.patch != "" {
+		res.JSONPatch = json.RawMessage(h.patch)
+	}
+
+	return res, nil
+}
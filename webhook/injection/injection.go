@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/go-logr/logr"
@@ -29,6 +30,15 @@ type Webhook struct {
 	Handler    HandlerFunc
 	KeyHandler jwt.Keyfunc
 	log        logr.Logger
+
+	replay *replayGuard
+}
+
+// SetReplayWindow enables the replay protection. Tokens must have the
+// issued time within the window, not in the future beyond a small clock
+// skew, and an ID that has not been used.
+func (wh *Webhook) SetReplayWindow(window time.Duration) {
+	wh.replay = newReplayGuard(window)
 }
 
 func (wh *Webhook) InjectClient(c client.Client) error {
@@ -65,6 +75,14 @@ func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wh.replay != nil {
+		err = wh.replay.check(claims)
+		if err != nil {
+			wh.error(w, err.Error(), 400)
+			return
+		}
+	}
+
 	namespace := claims["namespace"].(string)
 	if namespace == "" {
 		wh.error(w, "Invalid namespace", 400)
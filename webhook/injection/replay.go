@@ -0,0 +1,72 @@
+package injection
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// The maximum clock skew allowed for the issued time of the token.
+const maxClockSkew = 30 * time.Second
+
+// replayGuard rejects the tokens issued before the window or in the
+// future, and the tokens whose ID has been seen within the window.
+type replayGuard struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newReplayGuard(window time.Duration) *replayGuard {
+	return &replayGuard{
+		window: window,
+		now:    time.Now,
+		seen:   map[string]time.Time{},
+	}
+}
+
+// check validates the issued time and the ID of the token claims, and
+// records the ID until the token expires.
+func (g *replayGuard) check(claims jwt.MapClaims) error {
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return errors.New("Missing issued time")
+	}
+
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return errors.New("Missing token ID")
+	}
+
+	now := g.now()
+	issued := time.Unix(int64(iat), 0)
+	if issued.After(now.Add(maxClockSkew)) {
+		return errors.New("Token issued in the future")
+	}
+
+	expires := issued.Add(g.window)
+	if !now.Before(expires) {
+		return errors.New("Expired token")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for id, t := range g.seen {
+		if !now.Before(t) {
+			delete(g.seen, id)
+		}
+	}
+
+	_, ok = g.seen[jti]
+	if ok {
+		return errors.New("Replayed token")
+	}
+	g.seen[jti] = expires
+
+	return nil
+}
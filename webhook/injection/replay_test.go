@@ -0,0 +1,89 @@
+package injection
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	. "github.com/onsi/gomega"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var testKey = []byte("test-key")
+
+func TestServeHTTPWithReplayWindow(t *testing.T) {
+	RegisterTestingT(t)
+
+	now := time.Now()
+
+	wh := &Webhook{
+		Handler: HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+			return Response{}, nil
+		}),
+		KeyHandler: func(token *jwt.Token) (interface{}, error) {
+			return testKey, nil
+		},
+	}
+	wh.InjectLogger(logf.Log)
+	wh.SetReplayWindow(time.Minute)
+	wh.replay.now = func() time.Time { return now }
+
+	// Valid token
+	token := newTestToken(now, "id1")
+	Expect(serve(wh, token).Code).To(Equal(http.StatusOK))
+
+	// Replayed token
+	res := serve(wh, token)
+	Expect(res.Code).To(Equal(http.StatusBadRequest))
+	Expect(res.Body.String()).To(ContainSubstring("Replayed token"))
+
+	// Expired token
+	res = serve(wh, newTestToken(now.Add(-2*time.Minute), "id2"))
+	Expect(res.Code).To(Equal(http.StatusBadRequest))
+	Expect(res.Body.String()).To(ContainSubstring("Expired token"))
+
+	// Token without ID
+	res = serve(wh, newTestToken(now, ""))
+	Expect(res.Code).To(Equal(http.StatusBadRequest))
+	Expect(res.Body.String()).To(ContainSubstring("Missing token ID"))
+
+	// Token issued in the future
+	Expect(wh.replay.check(jwt.MapClaims{"iat": float64(now.Add(time.Hour).Unix()), "jti": "id3"})).To(MatchError("Token issued in the future"))
+
+	// Token issued within the clock skew
+	Expect(wh.replay.check(jwt.MapClaims{"iat": float64(now.Add(maxClockSkew).Unix()), "jti": "id4"})).To(Succeed())
+
+	// The ID is forgotten after the window
+	now = now.Add(2 * time.Minute)
+	Expect(wh.replay.check(jwt.MapClaims{"iat": float64(now.Unix()), "jti": "id1"})).To(Succeed())
+	Expect(wh.replay.seen).To(HaveLen(1))
+	Expect(wh.replay.seen).To(HaveKey("id1"))
+}
+
+func newTestToken(iat time.Time, id string) string {
+	claims := jwt.MapClaims{
+		"name":      "test",
+		"namespace": "default",
+		"iat":       iat.Unix(),
+	}
+	if id != "" {
+		claims["jti"] = id
+	}
+
+	t, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	return t
+}
+
+func serve(wh *Webhook, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/inject?token="+token, strings.NewReader("{}"))
+	res := httptest.NewRecorder()
+	wh.ServeHTTP(res, req)
+
+	return res
+}
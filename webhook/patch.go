@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// validateJSONPatch validates that the patch is a list of RFC6902
+// JSON Patch operations.
+func validateJSONPatch(patch []byte) error {
+	ops := []map[string]json.RawMessage{}
+	err := json.Unmarshal(patch, &ops)
+	if err != nil {
+		return fmt.Errorf("patch must be a list of operations: %v", err)
+	}
+
+	for i, op := range ops {
+		var name string
+		err := json.Unmarshal(op["op"], &name)
+		if err != nil {
+			return fmt.Errorf("patch[%d]: op must be a string", i)
+		}
+
+		var path string
+		err = json.Unmarshal(op["path"], &path)
+		if err != nil {
+			return fmt.Errorf("patch[%d]: path must be a string", i)
+		}
+
+		switch name {
+		case "add", "replace", "test":
+			_, ok := op["value"]
+			if !ok {
+				return fmt.Errorf("patch[%d]: value must be specified for %s", i, name)
+			}
+		case "move", "copy":
+			var from string
+			err = json.Unmarshal(op["from"], &from)
+			if err != nil {
+				return fmt.Errorf("patch[%d]: from must be a string for %s", i, name)
+			}
+		case "remove":
+		default:
+			return fmt.Errorf("patch[%d]: invalid op: %q", i, name)
+		}
+	}
+
+	return nil
+}
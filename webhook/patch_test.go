@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+func TestValidateJSONPatch(t *testing.T) {
+	RegisterTestingT(t)
+
+	valid := []string{
+		`[]`,
+		`[{"op":"add","path":"/metadata/labels/foo","value":"bar"}]`,
+		`[{"op":"remove","path":"/spec/foo"}]`,
+		`[{"op":"replace","path":"/spec/foo","value":null}]`,
+		`[{"op":"move","from":"/spec/foo","path":"/spec/bar"}]`,
+		`[{"op":"copy","from":"/spec/foo","path":"/spec/bar"}]`,
+		`[{"op":"test","path":"/spec/foo","value":1}]`,
+	}
+	for _, p := range valid {
+		Expect(validateJSONPatch([]byte(p))).To(Succeed(), p)
+	}
+
+	invalid := []string{
+		`{"op":"remove","path":"/spec/foo"}`,
+		`[{"path":"/spec/foo"}]`,
+		`[{"op":"unknown","path":"/spec/foo"}]`,
+		`[{"op":"remove"}]`,
+		`[{"op":"add","path":"/spec/foo"}]`,
+		`[{"op":"move","path":"/spec/bar"}]`,
+	}
+	for _, p := range invalid {
+		Expect(validateJSONPatch([]byte(p))).NotTo(Succeed(), p)
+	}
+}
+
+func TestMutationHookWithJSONPatch(t *testing.T) {
+	RegisterTestingT(t)
+
+	patch := `[{"op":"add","path":"/metadata/labels","value":{"foo":"bar"}}]`
+
+	hook, err := newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testPatchHandler{patch: patch},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendMutationReview(hook)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(res["patchType"]).To(Equal("JSONPatch"))
+	Expect(res["patch"]).To(Equal([]byte(patch)))
+
+	// Invalid patch
+	hook, err = newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testPatchHandler{patch: `[{"op":"add"}]`},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res = sendMutationReview(hook)
+	Expect(res["allowed"]).To(BeFalse())
+	Expect(res).NotTo(HaveKey("patch"))
+}
+
+func sendMutationReview(h http.Handler) map[string]interface{} {
+	body := []byte(`{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"test","operation":"CREATE"}}`)
+
+	req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+	Expect(rec.Code).To(Equal(http.StatusOK))
+
+	review := struct {
+		Response map[string]interface{} `json:"response"`
+	}{}
+	err := json.Unmarshal(rec.Body.Bytes(), &review)
+	Expect(err).NotTo(HaveOccurred())
+
+	// Decode the base64 encoded patch for comparison
+	if p, ok := review.Response["patch"].(string); ok {
+		var patch []byte
+		err := json.Unmarshal([]byte(`"`+p+`"`), &patch)
+		Expect(err).NotTo(HaveOccurred())
+		review.Response["patch"] = patch
+	}
+
+	return review.Response
+}
+
+type testPatchHandler struct {
+	patch string
+}
+
+func (h *testPatchHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	res, err := h.HandleAdmissionRequestWithWarnings(req)
+	return res.Response, err
+}
+
+func (h *testPatchHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (handler.AdmissionResponse, error) {
+	return handler.AdmissionResponse{
+		Response:  admission.Allowed(""),
+		JSONPatch: json.RawMessage(h.patch),
+	}, nil
+}
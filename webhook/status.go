@@ -0,0 +1,32 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// normalizeStatus validates the status of the admission response
+// returned by the handler. The status code of a denied response is
+// set to 403 if the handler does not specify it.
+func normalizeStatus(res *admission.Response) error {
+	if res.Result == nil {
+		if res.Allowed {
+			return nil
+		}
+		res.Result = &metav1.Status{}
+	}
+
+	code := res.Result.Code
+	if code != 0 && (code < 100 || code > 599) {
+		return fmt.Errorf("invalid status code: %d", code)
+	}
+
+	if code == 0 && !res.Allowed {
+		res.Result.Code = http.StatusForbidden
+	}
+
+	return nil
+}
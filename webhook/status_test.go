@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestValidationHookWithStatus(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testStatusHandler{
+		status: &metav1.Status{
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: "spec.replicas must be positive",
+		},
+	}
+	hook, err := newValidationHook(&config.HandlerConfig{AdmissionRequestHandler: h})
+	Expect(err).NotTo(HaveOccurred())
+
+	review := sendAdmissionReview(hook)
+	Expect(review.Response.Allowed).To(BeFalse())
+	Expect(review.Response.Status.Code).To(Equal(int32(http.StatusUnprocessableEntity)))
+	Expect(review.Response.Status.Reason).To(Equal(metav1.StatusReasonInvalid))
+	Expect(review.Response.Status.Message).To(Equal("spec.replicas must be positive"))
+
+	// Default status code
+	h.status = &metav1.Status{Message: "denied"}
+	review = sendAdmissionReview(hook)
+	Expect(review.Response.Allowed).To(BeFalse())
+	Expect(review.Response.Status.Code).To(Equal(int32(http.StatusForbidden)))
+
+	// No status
+	h.status = nil
+	review = sendAdmissionReview(hook)
+	Expect(review.Response.Allowed).To(BeFalse())
+	Expect(review.Response.Status.Code).To(Equal(int32(http.StatusForbidden)))
+
+	// Invalid status code
+	h.status = &metav1.Status{Code: 1000, Message: "denied"}
+	review = sendAdmissionReview(hook)
+	Expect(review.Response.Allowed).To(BeFalse())
+	Expect(string(review.Response.Status.Reason)).To(ContainSubstring("invalid status code"))
+}
+
+// testStatusHandler denies the admission requests with the status.
+type testStatusHandler struct {
+	status *metav1.Status
+}
+
+func (h *testStatusHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	res := admission.Response{}
+	res.Allowed = false
+	if h.status != nil {
+		s := *h.status
+		res.Result = &s
+	}
+
+	return res, nil
+}
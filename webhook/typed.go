@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+var (
+	typedScheme = runtime.NewScheme()
+	typedCodecs = serializer.NewCodecFactory(typedScheme)
+)
+
+func init() {
+	corev1.AddToScheme(typedScheme)
+	appsv1.AddToScheme(typedScheme)
+
+	// The schemes of the API types do not have the defaulting functions
+	// of the kinds, so that the defaults of config.TypedKinds that the
+	// API server applies are registered here.
+	typedScheme.AddTypeDefaultingFunc(&corev1.Pod{}, func(obj interface{}) {
+		setPodSpecDefaults(&obj.(*corev1.Pod).Spec)
+	})
+	typedScheme.AddTypeDefaultingFunc(&appsv1.Deployment{}, func(obj interface{}) {
+		setDeploymentDefaults(obj.(*appsv1.Deployment))
+	})
+}
+
+// typedRequest returns a copy of specified admission request whose
+// objects of config.TypedKinds are decoded into the typed objects and
+// defaulted by the scheme. The objects of the other kinds, such as
+// custom resources, are kept as is.
+func typedRequest(req admission.Request) (admission.Request, error) {
+	var err error
+
+	req.Object.Raw, err = typedObject(req.Object.Raw)
+	if err != nil {
+		return req, err
+	}
+
+	req.OldObject.Raw, err = typedObject(req.OldObject.Raw)
+	if err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+// typedObject decodes raw into the typed object, applies the defaults
+// and encodes it back into JSON.
+func typedObject(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+
+	obj, gvk, err := typedCodecs.UniversalDeserializer().Decode(raw, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return raw, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !config.IsTypedKind(*gvk) {
+		return raw, nil
+	}
+
+	typedScheme.Default(obj)
+	obj.GetObjectKind().SetGroupVersionKind(*gvk)
+
+	return json.Marshal(obj)
+}
+
+// setPodSpecDefaults applies the defaults of the spec of Pod that the
+// API server applies.
+func setPodSpecDefaults(spec *corev1.PodSpec) {
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if spec.RestartPolicy == "" {
+		spec.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	if spec.TerminationGracePeriodSeconds == nil {
+		period := int64(corev1.DefaultTerminationGracePeriodSeconds)
+		spec.TerminationGracePeriodSeconds = &period
+	}
+	if spec.SchedulerName == "" {
+		spec.SchedulerName = corev1.DefaultSchedulerName
+	}
+	if spec.EnableServiceLinks == nil {
+		enabled := corev1.DefaultEnableServiceLinks
+		spec.EnableServiceLinks = &enabled
+	}
+
+	for i := range spec.InitContainers {
+		setContainerDefaults(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		setContainerDefaults(&spec.Containers[i])
+	}
+}
+
+// setDeploymentDefaults applies the defaults of Deployment that the API
+// server applies, including the defaults of its pod template.
+func setDeploymentDefaults(d *appsv1.Deployment) {
+	spec := &d.Spec
+
+	if spec.Replicas == nil {
+		replicas := int32(1)
+		spec.Replicas = &replicas
+	}
+	if spec.Strategy.Type == "" {
+		spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+	}
+	if spec.Strategy.Type == appsv1.RollingUpdateDeploymentStrategyType {
+		if spec.Strategy.RollingUpdate == nil {
+			spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{}
+		}
+		if spec.Strategy.RollingUpdate.MaxUnavailable == nil {
+			maxUnavailable := intstr.FromString("25%")
+			spec.Strategy.RollingUpdate.MaxUnavailable = &maxUnavailable
+		}
+		if spec.Strategy.RollingUpdate.MaxSurge == nil {
+			maxSurge := intstr.FromString("25%")
+			spec.Strategy.RollingUpdate.MaxSurge = &maxSurge
+		}
+	}
+	if spec.RevisionHistoryLimit == nil {
+		limit := int32(10)
+		spec.RevisionHistoryLimit = &limit
+	}
+	if spec.ProgressDeadlineSeconds == nil {
+		deadline := int32(600)
+		spec.ProgressDeadlineSeconds = &deadline
+	}
+
+	setPodSpecDefaults(&spec.Template.Spec)
+}
+
+// setContainerDefaults applies the defaults of the container of Pod.
+func setContainerDefaults(c *corev1.Container) {
+	if c.ImagePullPolicy == "" {
+		if imageTag(c.Image) == "latest" {
+			c.ImagePullPolicy = corev1.PullAlways
+		} else {
+			c.ImagePullPolicy = corev1.PullIfNotPresent
+		}
+	}
+	if c.TerminationMessagePath == "" {
+		c.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	}
+	if c.TerminationMessagePolicy == "" {
+		c.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	}
+
+	for i := range c.Ports {
+		if c.Ports[i].Protocol == "" {
+			c.Ports[i].Protocol = corev1.ProtocolTCP
+		}
+	}
+}
+
+// imageTag returns the tag of specified image. It returns 'latest' if
+// the image has neither tag nor digest, and an empty string if the
+// image has only digest.
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return "latest"
+	}
+
+	return name[i+1:]
+}
@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+const testTypedPod = `{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {"namespace": "default", "name": "test"},
+  "spec": {
+    "containers": [
+      {"name": "app", "image": "nginx", "ports": [{"containerPort": 80}]},
+      {"name": "sidecar", "image": "envoy:v1.12.0", "imagePullPolicy": "Never"}
+    ]
+  }
+}`
+
+const testTypedDeployment = `{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {"namespace": "default", "name": "test"},
+  "spec": {
+    "selector": {"matchLabels": {"app": "test"}},
+    "template": {
+      "metadata": {"labels": {"app": "test"}},
+      "spec": {"containers": [{"name": "app", "image": "nginx:1.17"}]}
+    }
+  }
+}`
+
+const testTypedService = `{
+  "apiVersion": "v1",
+  "kind": "Service",
+  "metadata": {"namespace": "default", "name": "test"},
+  "spec": {"ports": [{"port": 80}]}
+}`
+
+func TestValidationHookWithTyped(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testRecordHandler{}
+	hook, err := newValidationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: h,
+		Typed:                   true,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendUpdateReview(hook, testTypedPod, testTypedPod)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(h.requests).To(HaveLen(1))
+
+	// The defaults are applied before the handler sees the pod
+	for _, raw := range [][]byte{h.requests[0].Object.Raw, h.requests[0].OldObject.Raw} {
+		pod := &corev1.Pod{}
+		err = json.Unmarshal(raw, pod)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(pod.Kind).To(Equal("Pod"))
+		Expect(pod.APIVersion).To(Equal("v1"))
+		Expect(pod.Name).To(Equal("test"))
+		Expect(pod.Spec.RestartPolicy).To(Equal(corev1.RestartPolicyAlways))
+		Expect(pod.Spec.DNSPolicy).To(Equal(corev1.DNSClusterFirst))
+		Expect(pod.Spec.SchedulerName).To(Equal(corev1.DefaultSchedulerName))
+		Expect(*pod.Spec.TerminationGracePeriodSeconds).To(Equal(int64(30)))
+
+		Expect(pod.Spec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullAlways))
+		Expect(pod.Spec.Containers[0].TerminationMessagePath).To(Equal("/dev/termination-log"))
+		Expect(pod.Spec.Containers[0].Ports[0].Protocol).To(Equal(corev1.ProtocolTCP))
+
+		// Specified values are kept
+		Expect(pod.Spec.Containers[1].ImagePullPolicy).To(Equal(corev1.PullNever))
+	}
+}
+
+func TestMutationHookWithTyped(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testRecordHandler{}
+	hook, err := newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: h,
+		Typed:                   true,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendUpdateReview(hook, testTypedPod, testTypedPod)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(h.requests).To(HaveLen(1))
+
+	pod := &corev1.Pod{}
+	err = json.Unmarshal(h.requests[0].Object.Raw, pod)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(pod.Spec.RestartPolicy).To(Equal(corev1.RestartPolicyAlways))
+
+	// Objects of the kinds not in the scheme are passed as is
+	res = sendUpdateReview(hook, testImmutableObject, testImmutableObject)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(h.requests).To(HaveLen(2))
+	Expect(string(h.requests[1].Object.Raw)).To(MatchJSON(testImmutableObject))
+}
+
+func TestMutationHookWithTypedDeployment(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testRecordHandler{}
+	hook, err := newMutationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: h,
+		Typed:                   true,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendUpdateReview(hook, testTypedDeployment, testTypedDeployment)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(h.requests).To(HaveLen(1))
+
+	d := &appsv1.Deployment{}
+	err = json.Unmarshal(h.requests[0].Object.Raw, d)
+	Expect(err).NotTo(HaveOccurred())
+
+	Expect(d.Kind).To(Equal("Deployment"))
+	Expect(d.APIVersion).To(Equal("apps/v1"))
+	Expect(*d.Spec.Replicas).To(Equal(int32(1)))
+	Expect(d.Spec.Strategy.Type).To(Equal(appsv1.RollingUpdateDeploymentStrategyType))
+	Expect(d.Spec.Strategy.RollingUpdate.MaxUnavailable.String()).To(Equal("25%"))
+	Expect(d.Spec.Strategy.RollingUpdate.MaxSurge.String()).To(Equal("25%"))
+	Expect(*d.Spec.RevisionHistoryLimit).To(Equal(int32(10)))
+	Expect(*d.Spec.ProgressDeadlineSeconds).To(Equal(int32(600)))
+
+	// The defaults of the pod template are applied
+	Expect(d.Spec.Template.Spec.RestartPolicy).To(Equal(corev1.RestartPolicyAlways))
+	Expect(d.Spec.Template.Spec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+
+	// Objects of the kinds that are not typed are passed as is
+	res = sendUpdateReview(hook, testTypedService, testTypedService)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(h.requests).To(HaveLen(2))
+	Expect(string(h.requests[1].Object.Raw)).To(MatchJSON(testTypedService))
+}
+
+func TestValidationHookWithoutTyped(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testRecordHandler{}
+	hook, err := newValidationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: h,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	res := sendUpdateReview(hook, testTypedPod, testTypedPod)
+	Expect(res["allowed"]).To(BeTrue())
+	Expect(h.requests).To(HaveLen(1))
+	Expect(string(h.requests[0].Object.Raw)).To(MatchJSON(testTypedPod))
+}
+
+func TestImageTag(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(imageTag("nginx")).To(Equal("latest"))
+	Expect(imageTag("nginx:latest")).To(Equal("latest"))
+	Expect(imageTag("nginx:1.17")).To(Equal("1.17"))
+	Expect(imageTag("localhost:5000/nginx")).To(Equal("latest"))
+	Expect(imageTag("localhost:5000/nginx:1.17")).To(Equal("1.17"))
+	Expect(imageTag("nginx@sha256:abcdef")).To(Equal(""))
+}
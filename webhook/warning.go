@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+// warningsKey is the context key for admission warnings.
+type warningsKey struct{}
+
+// handleAdmissionRequest runs the handler and stores the warnings
+// returned by the handler to the context.
+func handleAdmissionRequest(ctx context.Context, h handler.AdmissionRequestHandler, req admission.Request) (handler.AdmissionResponse, error) {
+	wh, ok := h.(handler.AdmissionWarningHandler)
+	if !ok {
+		res, err := h.HandleAdmissionRequest(req)
+		return handler.AdmissionResponse{Response: res}, err
+	}
+
+	res, err := wh.HandleAdmissionRequestWithWarnings(req)
+	if err != nil {
+		return res, err
+	}
+
+	warnings, ok := ctx.Value(warningsKey{}).(*[]string)
+	if ok {
+		*warnings = append(*warnings, res.Warnings...)
+	}
+
+	return res, nil
+}
+
+// addWarning adds a warning to the admission response.
+func addWarning(ctx context.Context, warning string) {
+	warnings, ok := ctx.Value(warningsKey{}).(*[]string)
+	if ok {
+		*warnings = append(*warnings, warning)
+	}
+}
+
+// withWarnings wraps an admission webhook and adds the warnings
+// returned by the handler to the admission response. This is required
+// because the AdmissionResponse in use does not have warnings field.
+func withWarnings(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		warnings := []string{}
+		ctx := context.WithValue(r.Context(), warningsKey{}, &warnings)
+
+		bw := &bufferedResponseWriter{header: w.Header()}
+		h.ServeHTTP(bw, r.WithContext(ctx))
+
+		body := bw.body.Bytes()
+		if len(warnings) > 0 {
+			b, err := addWarnings(body, warnings)
+			if err != nil {
+				log.Error(err, "Failed to add warnings to the response")
+			} else {
+				body = b
+			}
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if bw.code != 0 {
+			w.WriteHeader(bw.code)
+		}
+		w.Write(body)
+	})
+}
+
+// addWarnings adds warnings to the encoded AdmissionReview.
+func addWarnings(body []byte, warnings []string) ([]byte, error) {
+	review := map[string]interface{}{}
+	err := json.Unmarshal(body, &review)
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := review["response"].(map[string]interface{})
+	if !ok {
+		return body, nil
+	}
+	res["warnings"] = warnings
+
+	return json.Marshal(review)
+}
+
+// bufferedResponseWriter is a http.ResponseWriter that buffers the
+// response body.
+type bufferedResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	code   int
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
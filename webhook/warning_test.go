@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+	"github.com/summerwind/whitebox-controller/handler"
+)
+
+func TestValidationHookWithWarnings(t *testing.T) {
+	RegisterTestingT(t)
+
+	hook, err := newValidationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testWarningHandler{
+			warnings: []string{"spec.foo is deprecated"},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	review := sendAdmissionReview(hook)
+	Expect(review.Response.Allowed).To(BeTrue())
+	Expect(review.Response.Warnings).To(Equal([]string{"spec.foo is deprecated"}))
+
+	// No warnings
+	hook, err = newValidationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: &testWarningHandler{},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	review = sendAdmissionReview(hook)
+	Expect(review.Response.Allowed).To(BeTrue())
+	Expect(review.Response.Warnings).To(BeEmpty())
+}
+
+type testAdmissionReview struct {
+	Response struct {
+		UID      string         `json:"uid"`
+		Allowed  bool           `json:"allowed"`
+		Status   *metav1.Status `json:"status"`
+		Warnings []string       `json:"warnings"`
+	} `json:"response"`
+}
+
+func sendAdmissionReview(h http.Handler) testAdmissionReview {
+	body := []byte(`{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"test","operation":"CREATE"}}`)
+
+	req := httptest.NewRequest("POST", "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+	Expect(rec.Code).To(Equal(http.StatusOK))
+
+	review := testAdmissionReview{}
+	err := json.Unmarshal(rec.Body.Bytes(), &review)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(review.Response.UID).To(Equal("test"))
+
+	return review
+}
+
+type testWarningHandler struct {
+	warnings []string
+}
+
+func (h *testWarningHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	res, err := h.HandleAdmissionRequestWithWarnings(req)
+	return res.Response, err
+}
+
+func (h *testWarningHandler) HandleAdmissionRequestWithWarnings(req admission.Request) (handler.AdmissionResponse, error) {
+	return handler.AdmissionResponse{
+		Response: admission.Allowed(""),
+		Warnings: h.warnings,
+	}, nil
+}
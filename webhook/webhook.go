@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -52,17 +53,37 @@ func NewServer(c *config.ServerConfig, mgr manager.Manager) (*Server, error) {
 		handler: wrap(mux),
 	}
 
+	if c.Default != nil {
+		err := s.addDefault(c.Default)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return s, mgr.Add(s)
 }
 
 func (s *Server) Start(stop <-chan struct{}) error {
-	cert, err := tls.LoadX509KeyPair(s.config.TLS.CertFile, s.config.TLS.KeyFile)
+	var (
+		interval time.Duration
+		err      error
+	)
+
+	if s.config.CertReloadInterval != "" {
+		interval, err = time.ParseDuration(s.config.CertReloadInterval)
+		if err != nil {
+			return fmt.Errorf("invalid cert reload interval: %v", err)
+		}
+	}
+
+	cw, err := newCertWatcher(s.config.TLS.CertFile, s.config.TLS.KeyFile, interval)
 	if err != nil {
 		return err
 	}
+	go cw.Start(stop)
 
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
+		GetCertificate: cw.GetCertificate,
 	}
 
 	port := s.config.Port
@@ -76,8 +97,9 @@ func (s *Server) Start(stop <-chan struct{}) error {
 		return err
 	}
 
-	server := &http.Server{
-		Handler: s.handler,
+	server, err := s.newHTTPServer()
+	if err != nil {
+		return err
 	}
 
 	shutdown := make(chan struct{})
@@ -105,6 +127,65 @@ func (s *Server) Start(stop <-chan struct{}) error {
 	return nil
 }
 
+// newHTTPServer returns a HTTP server with configured timeouts.
+func (s *Server) newHTTPServer() (*http.Server, error) {
+	var err error
+
+	server := &http.Server{
+		Handler: s.handler,
+	}
+
+	if s.config.ReadTimeout != "" {
+		server.ReadTimeout, err = time.ParseDuration(s.config.ReadTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid read timeout: %v", err)
+		}
+	}
+
+	if s.config.WriteTimeout != "" {
+		server.WriteTimeout, err = time.ParseDuration(s.config.WriteTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid write timeout: %v", err)
+		}
+	}
+
+	if s.config.IdleTimeout != "" {
+		server.IdleTimeout, err = time.ParseDuration(s.config.IdleTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid idle timeout: %v", err)
+		}
+	}
+
+	return server, nil
+}
+
+// AddResource adds the webhooks of specified resource that are served
+// by the server.
+func (s *Server) AddResource(c *config.ResourceConfig) error {
+	if c.Validator != nil && s.config.Serves(config.HookValidate) {
+		err := s.AddValidator(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Mutator != nil && s.config.Serves(config.HookMutate) {
+		err := s.AddMutator(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Injector != nil && s.config.Serves(config.HookInject) {
+		err := s.AddInjector(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *Server) AddValidator(c *config.ResourceConfig) error {
 	hook, err := newValidationHook(c.Validator)
 	if err != nil {
@@ -144,6 +225,45 @@ func (s *Server) AddInjector(c *config.ResourceConfig) error {
 	return nil
 }
 
+// addDefault adds the handlers for the requests that do not match
+// any resource. The requests whose path ends with '/validate' or
+// '/mutate' are passed to the default validator or mutator.
+func (s *Server) addDefault(c *config.DefaultWebhookConfig) error {
+	var (
+		validator http.Handler
+		mutator   http.Handler
+		err       error
+	)
+
+	if c.Validator != nil {
+		validator, err = newValidationHook(c.Validator)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Mutator != nil {
+		mutator, err = newMutationHook(c.Mutator)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("Adding default hook", "path", "/")
+	s.mux.Handle("/", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch {
+		case validator != nil && strings.HasSuffix(req.URL.Path, "/validate"):
+			validator.ServeHTTP(resp, req)
+		case mutator != nil && strings.HasSuffix(req.URL.Path, "/mutate"):
+			mutator.ServeHTTP(resp, req)
+		default:
+			http.NotFound(resp, req)
+		}
+	}))
+
+	return nil
+}
+
 func (s *Server) InjectClient(c client.Client) error {
 	s.Client = c
 	return nil
@@ -177,19 +297,50 @@ func newValidationHook(hc *config.HandlerConfig) (http.Handler, error) {
 		return nil, err
 	}
 
+	immutable := [][]string{}
+	for _, f := range hc.ImmutableFields {
+		fields, err := config.ParseFieldPath(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid immutable field: %v", err)
+		}
+		immutable = append(immutable, fields)
+	}
+
 	validator := func(ctx context.Context, req admission.Request) admission.Response {
-		res, err := h.HandleAdmissionRequest(req)
+		// Changes of the immutable fields are denied without running
+		// the handler.
+		i, err := changedImmutableField(req, immutable)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("invalid object: %v", err))
+		}
+		if i >= 0 {
+			return admission.ValidationResponse(false, fmt.Sprintf("field %s is immutable", hc.ImmutableFields[i]))
+		}
+
+		if hc.Typed {
+			req, err = typedRequest(req)
+			if err != nil {
+				return admission.Errored(http.StatusBadRequest, fmt.Errorf("invalid object: %v", err))
+			}
+		}
+
+		res, err := handleAdmissionRequest(ctx, h, req)
+		if err != nil {
+			return admission.ValidationResponse(false, fmt.Sprintf("handler error: %v", err))
+		}
+
+		err = normalizeStatus(&res.Response)
 		if err != nil {
 			return admission.ValidationResponse(false, fmt.Sprintf("handler error: %v", err))
 		}
 
-		return res
+		return res.Response
 	}
 
 	hook := &admission.Webhook{Handler: admission.HandlerFunc(validator)}
 	hook.InjectLogger(log)
 
-	return hook, nil
+	return withWarnings(hook), nil
 }
 
 func newMutationHook(hc *config.HandlerConfig) (http.Handler, error) {
@@ -198,19 +349,82 @@ func newMutationHook(hc *config.HandlerConfig) (http.Handler, error) {
 		return nil, err
 	}
 
+	allowed := [][]string{}
+	for _, f := range hc.AllowedFields {
+		fields, err := config.ParseFieldPath(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed field: %v", err)
+		}
+		allowed = append(allowed, fields)
+	}
+
 	mutator := func(ctx context.Context, req admission.Request) admission.Response {
-		res, err := h.HandleAdmissionRequest(req)
+		if hc.Typed {
+			typed, err := typedRequest(req)
+			if err != nil {
+				return admission.Errored(http.StatusBadRequest, fmt.Errorf("invalid object: %v", err))
+			}
+			req = typed
+		}
+
+		res, err := handleAdmissionRequest(ctx, h, req)
 		if err != nil {
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("handler error: %v", err))
 		}
 
-		return res
+		if res.Inject != nil {
+			err := applyInjection(req, &res)
+			if err != nil {
+				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("invalid inject: %v", err))
+			}
+		}
+
+		dropped := []string{}
+
+		if len(res.JSONPatch) > 0 {
+			err := validateJSONPatch(res.JSONPatch)
+			if err != nil {
+				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("invalid patch: %v", err))
+			}
+
+			patch := []byte(res.JSONPatch)
+			if len(allowed) > 0 {
+				patch, dropped, err = filterJSONPatch(patch, allowed)
+				if err != nil {
+					return admission.Errored(http.StatusInternalServerError, fmt.Errorf("invalid patch: %v", err))
+				}
+			}
+
+			// The patch is passed through to the API server as is.
+			patchType := admissionv1beta1.PatchTypeJSONPatch
+			res.Patches = nil
+			res.Patch = patch
+			res.PatchType = &patchType
+		} else if len(allowed) > 0 {
+			patches := res.Patches[:0]
+			for _, p := range res.Patches {
+				if !isAllowedPath(p.Path, allowed) {
+					dropped = append(dropped, p.Path)
+					continue
+				}
+				patches = append(patches, p)
+			}
+			res.Patches = patches
+		}
+
+		if len(dropped) > 0 {
+			msg := fmt.Sprintf("Dropped changes outside allowed fields: %s", strings.Join(dropped, ", "))
+			log.Info(msg, "namespace", req.Namespace, "name", req.Name)
+			addWarning(ctx, msg)
+		}
+
+		return res.Response
 	}
 
 	hook := &admission.Webhook{Handler: admission.HandlerFunc(mutator)}
 	hook.InjectLogger(log)
 
-	return hook, nil
+	return withWarnings(hook), nil
 }
 
 func newInjectionHook(ic *config.InjectorConfig, client client.Client) (http.Handler, error) {
@@ -264,6 +478,14 @@ func newInjectionHook(ic *config.InjectorConfig, client client.Client) (http.Han
 	hook.InjectClient(client)
 	hook.InjectLogger(log)
 
+	if ic.ReplayWindow != "" {
+		window, err := time.ParseDuration(ic.ReplayWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replay window: %v", err)
+		}
+		hook.SetReplayWindow(window)
+	}
+
 	return hook, nil
 }
 
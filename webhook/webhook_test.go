@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/summerwind/whitebox-controller/config"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := &Server{
+		config: &config.ServerConfig{
+			Port:         443,
+			ReadTimeout:  "10s",
+			WriteTimeout: "20s",
+			IdleTimeout:  "30s",
+		},
+		handler: http.NewServeMux(),
+	}
+
+	server, err := s.newHTTPServer()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(server.ReadTimeout).To(Equal(10 * time.Second))
+	Expect(server.WriteTimeout).To(Equal(20 * time.Second))
+	Expect(server.IdleTimeout).To(Equal(30 * time.Second))
+
+	// Default timeouts
+	s.config = &config.ServerConfig{Port: 443}
+	server, err = s.newHTTPServer()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(server.ReadTimeout).To(BeZero())
+	Expect(server.WriteTimeout).To(BeZero())
+	Expect(server.IdleTimeout).To(BeZero())
+
+	// Invalid timeout
+	s.config = &config.ServerConfig{Port: 443, ReadTimeout: "invalid"}
+	_, err = s.newHTTPServer()
+	Expect(err).To(HaveOccurred())
+}
+
+func TestValidationHookWithScale(t *testing.T) {
+	RegisterTestingT(t)
+
+	h := &testRecordHandler{}
+	hook, err := newValidationHook(&config.HandlerConfig{
+		AdmissionRequestHandler: h,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	body := []byte(`{
+  "apiVersion": "admission.k8s.io/v1beta1",
+  "kind": "AdmissionReview",
+  "request": {
+    "uid": "test",
+    "kind": {"group": "autoscaling", "version": "v1", "kind": "Scale"},
+    "resource": {"group": "example.com", "version": "v1alpha1", "resource": "tests"},
+    "subResource": "scale",
+    "namespace": "default",
+    "name": "test",
+    "operation": "UPDATE",
+    "object": {
+      "apiVersion": "autoscaling/v1",
+      "kind": "Scale",
+      "metadata": {"namespace": "default", "name": "test"},
+      "spec": {"replicas": 3}
+    }
+  }
+}`)
+
+	req := httptest.NewRequest("POST", "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	hook.ServeHTTP(rec, req)
+	Expect(rec.Code).To(Equal(http.StatusOK))
+	Expect(h.requests).To(HaveLen(1))
+
+	r := h.requests[0]
+	Expect(r.SubResource).To(Equal("scale"))
+	Expect(r.Kind.Kind).To(Equal("Scale"))
+
+	scale := map[string]interface{}{}
+	err = json.Unmarshal(r.Object.Raw, &scale)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(scale["kind"]).To(Equal("Scale"))
+	Expect(scale["spec"]).To(Equal(map[string]interface{}{"replicas": 3.0}))
+}
+
+// testRecordHandler records the admission requests and allows them.
+type testRecordHandler struct {
+	requests []admission.Request
+}
+
+func (h *testRecordHandler) HandleAdmissionRequest(req admission.Request) (admission.Response, error) {
+	h.requests = append(h.requests, req)
+	return admission.Allowed(""), nil
+}
+
+func TestServerWithDefault(t *testing.T) {
+	RegisterTestingT(t)
+
+	resource := &testRecordHandler{}
+	validator := &testRecordHandler{}
+
+	mux := http.NewServeMux()
+	s := &Server{
+		config:  &config.ServerConfig{Port: 443},
+		mux:     mux,
+		handler: wrap(mux),
+	}
+
+	err := s.AddValidator(&config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"},
+		Validator: &config.HandlerConfig{
+			AdmissionRequestHandler: resource,
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = s.addDefault(&config.DefaultWebhookConfig{
+		Validator: &config.HandlerConfig{
+			AdmissionRequestHandler: validator,
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Matched resource
+	Expect(postAdmissionReview(s.handler, "/example.com/v1alpha1/test/validate")).To(Equal(http.StatusOK))
+	Expect(resource.requests).To(HaveLen(1))
+	Expect(validator.requests).To(HaveLen(0))
+
+	// Unmatched resource
+	Expect(postAdmissionReview(s.handler, "/example.com/v1/other/validate")).To(Equal(http.StatusOK))
+	Expect(resource.requests).To(HaveLen(1))
+	Expect(validator.requests).To(HaveLen(1))
+
+	// Default mutator is not configured
+	Expect(postAdmissionReview(s.handler, "/example.com/v1/other/mutate")).To(Equal(http.StatusNotFound))
+	Expect(validator.requests).To(HaveLen(1))
+}
+
+func postAdmissionReview(h http.Handler, path string) int {
+	body := []byte(`{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"test","operation":"CREATE"}}`)
+
+	req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestServersOnDifferentPorts(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "whitebox-webhook")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	tlsConfig := &config.TLSConfig{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	writeTestCert(tlsConfig.CertFile, tlsConfig.KeyFile, "test")
+
+	rc := &config.ResourceConfig{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Test"},
+		Validator:        &config.HandlerConfig{AdmissionRequestHandler: &testRecordHandler{}},
+		Mutator:          &config.HandlerConfig{AdmissionRequestHandler: &testRecordHandler{}},
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	newServer := func(hook string) string {
+		port := freePort()
+
+		mux := http.NewServeMux()
+		s := &Server{
+			config: &config.ServerConfig{
+				Host:  "127.0.0.1",
+				Port:  port,
+				TLS:   tlsConfig,
+				Hooks: []string{hook},
+			},
+			mux:     mux,
+			handler: wrap(mux),
+		}
+
+		err := s.AddResource(rc)
+		Expect(err).NotTo(HaveOccurred())
+
+		go s.Start(stop)
+
+		return fmt.Sprintf("https://127.0.0.1:%d", port)
+	}
+
+	validator := newServer(config.HookValidate)
+	mutator := newServer(config.HookMutate)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	post := func(u string) int {
+		body := []byte(`{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"test","operation":"CREATE"}}`)
+		res, err := client.Post(u, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return 0
+		}
+		defer res.Body.Close()
+
+		return res.StatusCode
+	}
+
+	Eventually(func() int { return post(validator + "/example.com/v1alpha1/test/validate") }, 5*time.Second).Should(Equal(http.StatusOK))
+	Eventually(func() int { return post(mutator + "/example.com/v1alpha1/test/mutate") }, 5*time.Second).Should(Equal(http.StatusOK))
+
+	// Hooks are served only by the configured server
+	Expect(post(validator + "/example.com/v1alpha1/test/mutate")).To(Equal(http.StatusNotFound))
+	Expect(post(mutator + "/example.com/v1alpha1/test/validate")).To(Equal(http.StatusNotFound))
+}
+
+// freePort returns a TCP port that is available on the loopback address.
+func freePort() int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}